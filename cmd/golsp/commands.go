@@ -0,0 +1,300 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// A command is a feature of the server that runs from the command line,
+// without an editor.
+type command struct {
+	usage string // the arguments of the command
+	help  string
+	run   func(ctx context.Context, v *source.View, args []string) error
+}
+
+var commands = map[string]command{
+	"check": {
+		usage: "file.go... | packages",
+		help:  "print the errors of the packages of the files, or of the packages, such as ./..., and their progress",
+		run:   check,
+	},
+	"definition": {
+		usage: "file.go:line:column | file.go:#offset",
+		help:  "print the position of the declaration of the identifier at the position",
+		run:   definition,
+	},
+	"format": {
+		usage: "file.go",
+		help:  "print the file formatted",
+		run:   format,
+	},
+	"symbols": {
+		usage: "file.go",
+		help:  "print the declarations of the file",
+		run:   symbols,
+	},
+}
+
+// runCommand runs the command named by the first of args, with the others
+// as its arguments, and exits.
+func runCommand(args []string) {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "golsp: unknown command %q\n", args[0])
+		flag.Usage()
+		os.Exit(2)
+	}
+	if len(args) == 1 {
+		fmt.Fprintf(os.Stderr, "usage: golsp %s %s\n", args[0], cmd.usage)
+		os.Exit(2)
+	}
+	v := source.NewView()
+	if dir, err := os.Getwd(); err == nil {
+		v.Config.Dir = dir
+	}
+	if err := cmd.run(context.Background(), v, args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "golsp %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// commandsUsage returns the usage of the commands, for the usage of the
+// golsp command.
+func commandsUsage() string {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(&b, "  golsp %s %s\n    \t%s\n", name, cmd.usage, cmd.help)
+	}
+	return b.String()
+}
+
+// getFile returns the file of the view with the given name.
+func getFile(v *source.View, filename string) (*source.File, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return nil, err
+	}
+	return v.GetFile(source.ToURI(abs)), nil
+}
+
+// getPosition returns the file and the position of a position argument, as
+// file.go:line:column, whose column is in bytes, or file.go:#offset.
+func getPosition(ctx context.Context, v *source.View, arg string) (*source.File, token.Pos, error) {
+	malformed := fmt.Errorf("malformed position %q", arg)
+	filename, pos := arg, ""
+	if i := strings.Index(arg, ":"); i >= 0 {
+		filename, pos = arg[:i], arg[i+1:]
+	}
+	f, err := getFile(v, filename)
+	if err != nil {
+		return nil, token.NoPos, err
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, token.NoPos, err
+	}
+	var offset int
+	if strings.HasPrefix(pos, "#") {
+		if offset, err = strconv.Atoi(pos[1:]); err != nil {
+			return nil, token.NoPos, malformed
+		}
+	} else {
+		parts := strings.Split(pos, ":")
+		if len(parts) != 2 {
+			return nil, token.NoPos, malformed
+		}
+		line, err := strconv.Atoi(parts[0])
+		if err != nil || line < 1 || line > tok.LineCount() {
+			return nil, token.NoPos, malformed
+		}
+		col, err := strconv.Atoi(parts[1])
+		if err != nil || col < 1 {
+			return nil, token.NoPos, malformed
+		}
+		offset = tok.Offset(tok.LineStart(line)) + col - 1
+	}
+	if offset < 0 || offset > tok.Size() {
+		return nil, token.NoPos, fmt.Errorf("position %q is out of the file", arg)
+	}
+	return f, tok.Pos(offset), nil
+}
+
+// check prints the diagnostics of the packages of the files of args or, if
+// none of args is a Go file, of the packages that match them as patterns.
+// It fails if they have errors.
+func check(ctx context.Context, v *source.View, args []string) error {
+	diagnostics := make(map[string][]source.Diagnostic)
+	isFile := false
+	for _, arg := range args {
+		isFile = isFile || strings.HasSuffix(arg, ".go")
+	}
+	if isFile {
+		for _, filename := range args {
+			f, err := getFile(v, filename)
+			if err != nil {
+				return err
+			}
+			diags, err := source.Diagnostics(ctx, v, f, v.Options().Diagnostics)
+			if err != nil {
+				return err
+			}
+			for filename, d := range diags {
+				if _, ok := diagnostics[filename]; !ok { // or another of the files is in the same package
+					diagnostics[filename] = d
+				}
+			}
+		}
+	} else {
+		var err error
+		if diagnostics, err = source.WorkspaceDiagnostics(ctx, v, args, v.Options().Diagnostics, printProgress); err != nil {
+			return err
+		}
+	}
+	return printDiagnostics(v, diagnostics)
+}
+
+// printProgress prints the progress of the diagnosis of packages to the
+// standard error, in tenths of each of its stages.
+func printProgress(stage string, done, total int) {
+	if done*10/total > (done-1)*10/total {
+		fmt.Fprintf(os.Stderr, "%s %d/%d packages\n", stage, done, total)
+	}
+}
+
+// printDiagnostics prints the diagnostics, by filename, in the order of
+// their positions, and fails if some are errors.
+func printDiagnostics(v *source.View, diagnostics map[string][]source.Diagnostic) error {
+	type report struct {
+		pos      token.Position
+		severity string
+		message  string
+	}
+	var reports []report
+	failed := false
+	for _, diags := range diagnostics {
+		for _, d := range diags {
+			r := report{pos: v.Config.Fset.Position(d.Range.Start), severity: "warning", message: d.Message}
+			if d.Severity == source.SeverityError {
+				r.severity = "error"
+				failed = true
+			}
+			reports = append(reports, r)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		p, q := reports[i].pos, reports[j].pos
+		if p.Filename != q.Filename {
+			return p.Filename < q.Filename
+		}
+		return p.Offset < q.Offset
+	})
+	for _, r := range reports {
+		fmt.Printf("%s: %s: %s\n", r.pos, r.severity, r.message)
+	}
+	if failed {
+		return fmt.Errorf("found errors")
+	}
+	return nil
+}
+
+func definition(ctx context.Context, v *source.View, args []string) error {
+	f, pos, err := getPosition(ctx, v, args[0])
+	if err != nil {
+		return err
+	}
+	rng, err := source.Definition(ctx, f, pos)
+	if err != nil {
+		return err
+	}
+	fmt.Println(v.Config.Fset.Position(rng.Start))
+	return nil
+}
+
+func format(ctx context.Context, v *source.View, args []string) error {
+	f, err := getFile(v, args[0])
+	if err != nil {
+		return err
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return err
+	}
+	content, err := f.Read()
+	if err != nil {
+		return err
+	}
+	edits, err := source.Format(ctx, f, source.Range{Start: tok.Pos(0), End: tok.Pos(tok.Size())})
+	if err != nil {
+		return err
+	}
+	// Apply the edits from the last one, so that the offsets of the others
+	// stay valid.
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Range.Start > edits[j].Range.Start })
+	text := string(content)
+	for _, e := range edits {
+		text = text[:tok.Offset(e.Range.Start)] + e.NewText + text[tok.Offset(e.Range.End):]
+	}
+	fmt.Print(text)
+	return nil
+}
+
+// symbolKinds names the kinds of symbols.
+var symbolKinds = map[source.SymbolKind]string{
+	source.PackageSymbol:   "package",
+	source.StructSymbol:    "struct",
+	source.TypeSymbol:      "type",
+	source.InterfaceSymbol: "interface",
+	source.VariableSymbol:  "variable",
+	source.ConstantSymbol:  "constant",
+	source.FunctionSymbol:  "function",
+	source.MethodSymbol:    "method",
+	source.FieldSymbol:     "field",
+	source.NumberSymbol:    "number",
+	source.StringSymbol:    "string",
+	source.BooleanSymbol:   "boolean",
+}
+
+func symbols(ctx context.Context, v *source.View, args []string) error {
+	f, err := getFile(v, args[0])
+	if err != nil {
+		return err
+	}
+	symbols, err := source.DocumentSymbols(ctx, f)
+	if err != nil {
+		return err
+	}
+	var print func(symbols []source.Symbol, indent string)
+	print = func(symbols []source.Symbol, indent string) {
+		for _, s := range symbols {
+			pos := v.Config.Fset.Position(s.SelectionSpan.Start)
+			fmt.Printf("%s%s %s %d:%d\n", indent, s.Name, symbolKinds[s.Kind], pos.Line, pos.Column)
+			print(s.Children, indent+"\t")
+		}
+	}
+	print(symbols, "")
+	return nil
+}
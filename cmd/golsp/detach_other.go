@@ -0,0 +1,13 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux,!darwin,!freebsd,!openbsd,!netbsd
+
+package main
+
+import "os/exec"
+
+// detach does nothing on the systems where the process of cmd cannot be made
+// independent of that of the editor.
+func detach(cmd *exec.Cmd) {}
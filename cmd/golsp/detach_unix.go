@@ -0,0 +1,18 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux darwin freebsd openbsd netbsd
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach makes the process of cmd the leader of a session of its own, so
+// that it is not ended with the process group of the editor.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
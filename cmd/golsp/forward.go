@@ -0,0 +1,152 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// daemonStartTimeout is how long the forwarder waits for a daemon that is
+// started to listen.
+const daemonStartTimeout = 5 * time.Second
+
+// defaultDaemonAddress returns the address of the daemon of the user, that
+// the -remote=auto flag selects.
+func defaultDaemonAddress() (string, error) {
+	dir, err := daemonDir()
+	if err != nil {
+		return "", err
+	}
+	return "unix:" + filepath.Join(dir, "golsp-daemon.sock"), nil
+}
+
+// daemonDir returns the directory of the socket of the default daemon and of
+// the lock files of the forwarders, which only the user can access, so that
+// other users can neither connect to the daemon nor replace it:
+// $XDG_RUNTIME_DIR, or the golsp directory of the cache of the user.
+func daemonDir() (string, error) {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(cache, "golsp")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() || info.Mode().Perm()&0077 != 0 || !ownedByUser(info) {
+		return "", fmt.Errorf("%s is not a directory that only the user can access", dir)
+	}
+	return dir, nil
+}
+
+// forward forwards the messages of the client on the standard input and
+// output to the daemon listening on remote, and back, until either closes
+// the connection. If no daemon listens on remote, it starts one in the
+// background, which serves the clients of all the editors that forward to
+// it, so that they share its cache.
+func forward(remote string) error {
+	if remote == "auto" {
+		var err error
+		if remote, err = defaultDaemonAddress(); err != nil {
+			return err
+		}
+	}
+	if network, _ := parseAddress(remote); network == "ws" {
+		return fmt.Errorf("cannot forward to the WebSocket address %s", remote)
+	}
+	conn, err := dialDaemon(remote)
+	if err != nil {
+		return err
+	}
+	clientGone := make(chan struct{})
+	go func() {
+		io.Copy(conn, os.Stdin)
+		// The client is gone, so end its session in the daemon.
+		close(clientGone)
+		conn.Close()
+	}()
+	_, err = io.Copy(os.Stdout, conn)
+	select {
+	case <-clientGone:
+		return nil // the error is that of the closed connection
+	default:
+		return err
+	}
+}
+
+// dialDaemon connects to the daemon listening on remote, starting it if
+// needed. Of the forwarders that find no daemon at the same time, only one
+// starts it, and the others wait for it to listen, so that they do not start
+// several daemons. It does not connect to a unix socket of another user.
+func dialDaemon(remote string) (net.Conn, error) {
+	network, addr := parseAddress(remote)
+	dir, err := daemonDir()
+	if err != nil {
+		return nil, err
+	}
+	h := fnv.New32a()
+	io.WriteString(h, remote)
+	lock := filepath.Join(dir, fmt.Sprintf("golsp-daemon-%x.lock", h.Sum32()))
+	deadline := time.Now().Add(daemonStartTimeout)
+	started := false
+	for {
+		if network == "unix" {
+			if info, err := os.Lstat(addr); err == nil && !ownedByUser(info) {
+				return nil, fmt.Errorf("the socket %s belongs to another user", addr)
+			}
+		}
+		conn, err := net.Dial(network, addr)
+		if err == nil {
+			return conn, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		if !started {
+			if f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL, 0600); err == nil {
+				f.Close()
+				defer os.Remove(lock)
+				if err := startDaemon(remote); err != nil {
+					return nil, fmt.Errorf("starting the daemon: %v", err)
+				}
+				started = true
+			} else if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > daemonStartTimeout {
+				os.Remove(lock) // left by a forwarder that did not finish
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// startDaemon starts a daemon listening on remote, which outlives the
+// forwarder.
+func startDaemon(remote string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "-listen", remote)
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the daemon if it exits while the forwarder runs.
+	go cmd.Wait()
+	return nil
+}
@@ -6,9 +6,19 @@
 // The Language Server Protocol allows any text editor
 // to be extended with IDE-like features;
 // see https://langserver.org/ for details.
+//
+// Some of its features also run from the command line, so that they can be
+// scripted; run golsp -help for the list of commands.
+//
+// Besides the messages of the protocol, the server answers requests for the
+// features of Go editors that the protocol has no message for, such as
+// golsp/listKnownPackages and golsp/addImport, which list the packages that a
+// file may import and add an import to it. Their parameters and results are
+// documented in golang.org/x/tools/internal/lsp/protocol/golsp.go.
 package main // import "golang.org/x/tools/cmd/golsp"
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"flag"
@@ -19,16 +29,26 @@ import (
 	"runtime"
 	"runtime/pprof"
 	"runtime/trace"
+	"strings"
 	"time"
 
 	"golang.org/x/tools/internal/jsonrpc2"
 	"golang.org/x/tools/internal/lsp"
+	"golang.org/x/tools/internal/lsp/debug"
+	"golang.org/x/tools/internal/lsp/telemetry"
 )
 
 var (
 	cpuprofile = flag.String("cpuprofile", "", "write CPU profile to this file")
 	memprofile = flag.String("memprofile", "", "write memory profile to this file")
 	traceFlag  = flag.String("trace", "", "write trace log to this file")
+	spans      = flag.String("spans", "", "write the trace spans of the server to this file, as lines of JSON")
+	rpcTrace   = flag.Bool("rpc.trace", false, "write the messages of the clients and the server as they are on the wire, pretty-printed, to the log, for bug reports")
+	debugAddr  = flag.String("debug", "", "serve debug information on this address, such as localhost:6060")
+	listen     = flag.String("listen", "", "serve the clients that connect to this address, tcp:host:port, unix:path or ws:host:port for WebSocket clients, instead of the standard input and output")
+	framing    = flag.String("framing", "header", "the framing of the messages of the clients that do not use WebSocket: header, for Content-Length headers, or line, for lines of JSON")
+	origins    = flag.String("origins", "", "the comma separated origins of the pages of browsers allowed to connect to a ws address, besides the address itself")
+	remote     = flag.String("remote", "", "forward the client to the daemon listening on this address, or on a default address of the user if it is auto, starting it if needed")
 
 	// Flags for compatitibility with VSCode.
 	logfile = flag.String("logfile", "", "filename to log to")
@@ -38,12 +58,14 @@ var (
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "usage: golsp [flags]\n")
+		fmt.Fprintf(os.Stderr, "       golsp command [arguments]\n")
+		fmt.Fprintf(os.Stderr, "\nThe commands are:\n%s", commandsUsage())
+		fmt.Fprintf(os.Stderr, "\nThe flags are:\n")
 		flag.PrintDefaults()
 	}
 	flag.Parse()
 	if flag.NArg() > 0 {
-		flag.Usage()
-		os.Exit(2)
+		runCommand(flag.Args())
 	}
 
 	if *cpuprofile != "" {
@@ -73,6 +95,21 @@ func main() {
 		}()
 	}
 
+	if *spans != "" {
+		f, err := os.Create(*spans)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		telemetry.RegisterExporter(telemetry.NewJSONExporter(f))
+	}
+
+	if *debugAddr != "" {
+		go func() {
+			log.Printf("debug server: %v", debug.Serve(*debugAddr))
+		}()
+	}
+
 	if *memprofile != "" {
 		f, err := os.Create(*memprofile)
 		if err != nil {
@@ -88,7 +125,8 @@ func main() {
 		}()
 	}
 
-	out := os.Stderr
+	var err error
+	var out io.Writer = os.Stderr
 	if *logfile != "" {
 		f, err := os.Create(*logfile)
 		if err != nil {
@@ -98,50 +136,133 @@ func main() {
 		log.SetOutput(io.MultiWriter(os.Stderr, f))
 		out = f
 	}
-	if err := lsp.RunServer(
-		context.Background(),
-		jsonrpc2.NewHeaderStream(os.Stdin, os.Stdout),
-		func(direction jsonrpc2.Direction, id *jsonrpc2.ID, elapsed time.Duration, method string, payload *json.RawMessage, err *jsonrpc2.Error) {
-
-			if err != nil {
-				fmt.Fprintf(out, "[Error - %v] %s %s%s %v", time.Now().Format("3:04:05 PM"), direction, method, id, err)
-				return
-			}
-			fmt.Fprintf(out, "[Trace - %v] ", time.Now().Format("3:04:05 PM"))
-			switch direction {
-			case jsonrpc2.Send:
-				fmt.Fprint(out, "Received ")
-			case jsonrpc2.Receive:
-				fmt.Fprint(out, "Sending ")
-			}
-			switch {
-			case id == nil:
-				fmt.Fprint(out, "notification ")
-			case elapsed >= 0:
-				fmt.Fprint(out, "response ")
-			default:
-				fmt.Fprint(out, "request ")
-			}
-			fmt.Fprintf(out, "'%s", method)
-			switch {
-			case id == nil:
-				// do nothing
-			case id.Name != "":
-				fmt.Fprintf(out, " - (%s)", id.Name)
-			default:
-				fmt.Fprintf(out, " - (%d)", id.Number)
-			}
-			fmt.Fprint(out, "'")
-			if elapsed >= 0 {
-				fmt.Fprintf(out, " in %vms", elapsed.Nanoseconds()/1000)
-			}
-			params := string(*payload)
-			if params == "null" {
-				params = "{}"
+	ctx := context.Background()
+	opts := []interface{}{rpcLogger(out)}
+	if *rpcTrace {
+		opts = append(opts, rpcTracer(out))
+	}
+	framer := jsonrpc2.NewHeaderStream
+	switch *framing {
+	case "header":
+	case "line":
+		framer = jsonrpc2.NewLineStream
+	default:
+		log.Fatalf("invalid -framing %q, expected header or line", *framing)
+	}
+	if *remote != "" {
+		err = forward(*remote)
+	} else if *listen != "" {
+		network, addr := parseAddress(*listen)
+		if network == "ws" {
+			var allowed []string
+			if *origins != "" {
+				allowed = strings.Split(*origins, ",")
 			}
-			fmt.Fprintf(out, ".\r\nParams: %s\r\n\r\n\r\n", params)
-		},
-	); err != nil {
+			err = lsp.RunServerOnWebSocket(ctx, addr, allowed, opts...)
+		} else {
+			err = lsp.RunServerOnAddress(ctx, network, addr, framer, opts...)
+		}
+	} else {
+		err = lsp.RunServer(ctx, framer(os.Stdin, os.Stdout), opts...)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// parseAddress splits the address of the -listen flag into its network and
+// the address in that network. An address without a network is a TCP one.
+func parseAddress(listen string) (network, addr string) {
+	if i := strings.Index(listen, ":"); i >= 0 {
+		switch listen[:i] {
+		case "tcp", "unix", "ws":
+			return listen[:i], listen[i+1:]
+		}
+	}
+	return "tcp", listen
+}
+
+// rpcLogger returns a logger of the messages of the connections to out, in
+// the format of the traces of VSCode, from the point of view of the client.
+// Each message is written at once, so that the messages of concurrent
+// connections do not interleave.
+func rpcLogger(out io.Writer) jsonrpc2.Logger {
+	return func(direction jsonrpc2.Direction, id *jsonrpc2.ID, elapsed time.Duration, method string, payload *json.RawMessage, err *jsonrpc2.Error) {
+		if err != nil {
+			fmt.Fprintf(out, "[Error - %v] %s %s%s %v", time.Now().Format("3:04:05 PM"), direction, method, id, err)
+			return
+		}
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "[Trace - %v] ", time.Now().Format("3:04:05 PM"))
+		switch direction {
+		case jsonrpc2.Send:
+			fmt.Fprint(&b, "Received ")
+		case jsonrpc2.Receive:
+			fmt.Fprint(&b, "Sending ")
+		}
+		switch {
+		case id == nil:
+			fmt.Fprint(&b, "notification ")
+		case elapsed >= 0:
+			fmt.Fprint(&b, "response ")
+		default:
+			fmt.Fprint(&b, "request ")
+		}
+		fmt.Fprintf(&b, "'%s", method)
+		switch {
+		case id == nil:
+			// do nothing
+		case id.Name != "":
+			fmt.Fprintf(&b, " - (%s)", id.Name)
+		default:
+			fmt.Fprintf(&b, " - (%d)", id.Number)
+		}
+		fmt.Fprint(&b, "'")
+		if elapsed >= 0 {
+			fmt.Fprintf(&b, " in %vms", elapsed.Nanoseconds()/int64(time.Millisecond))
+		}
+		params := "null"
+		if payload != nil {
+			params = string(*payload)
+		}
+		if params == "null" {
+			params = "{}"
+		}
+		fmt.Fprintf(&b, ".\r\nParams: %s\r\n\r\n\r\n", params)
+		out.Write(b.Bytes())
+	}
+}
+
+// rpcTracer returns a tracer of the messages of the connections to out, from
+// the point of view of the server, with their size and the latency of the
+// responses, and the messages indented.
+// Each message is written at once, so that the messages of concurrent
+// connections do not interleave.
+func rpcTracer(out io.Writer) jsonrpc2.Tracer {
+	return func(m *jsonrpc2.WireMessage) {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "[Wire - %v] ", time.Now().Format("3:04:05.000 PM"))
+		switch m.Direction {
+		case jsonrpc2.Send:
+			fmt.Fprint(&b, "--> sent ")
+		case jsonrpc2.Receive:
+			fmt.Fprint(&b, "<-- received ")
+		}
+		switch {
+		case m.ID == nil && m.Method == "":
+			fmt.Fprint(&b, "invalid message")
+		case m.ID == nil:
+			fmt.Fprintf(&b, "notification '%s'", m.Method)
+		case m.Elapsed >= 0:
+			fmt.Fprintf(&b, "response '%s - %v' in %v", m.Method, m.ID, m.Elapsed)
+		default:
+			fmt.Fprintf(&b, "request '%s - %v'", m.Method, m.ID)
+		}
+		fmt.Fprintf(&b, ", %d bytes:\n", len(m.Data))
+		if json.Indent(&b, m.Data, "", "  ") != nil {
+			b.Write(m.Data) // not JSON, so as it is
+		}
+		fmt.Fprint(&b, "\n\n")
+		out.Write(b.Bytes())
+	}
+}
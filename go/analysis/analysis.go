@@ -189,4 +189,24 @@ type Diagnostic struct {
 	Pos      token.Pos
 	Category string // optional
 	Message  string
+
+	// SuggestedFixes are the optional changes to the code that address
+	// the diagnostic, of which the user may choose one to apply.
+	SuggestedFixes []SuggestedFix
+}
+
+// A SuggestedFix is a change to the code that addresses a diagnostic,
+// such as the removal of a useless statement.
+type SuggestedFix struct {
+	// Message describes the fix to a user deciding whether to apply it.
+	Message   string
+	TextEdits []TextEdit
+}
+
+// A TextEdit replaces the code between Pos and End with NewText.
+// For an insertion, End is Pos.
+type TextEdit struct {
+	Pos     token.Pos
+	End     token.Pos
+	NewText []byte
 }
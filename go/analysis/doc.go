@@ -199,13 +199,16 @@ diagnostic by formatting a string.
 Diagnostic is defined as:
 
 	type Diagnostic struct {
-		Pos      token.Pos
-		Category string // optional
-		Message  string
+		Pos            token.Pos
+		Category       string // optional
+		Message        string
+		SuggestedFixes []SuggestedFix // optional
 	}
 
 The optional Category field is a short identifier that classifies the
 kind of message when an analysis produces several kinds of diagnostic.
+The optional SuggestedFixes are edits of the source that address the
+diagnostic, which tools such as editors may offer to apply.
 
 Most Analyzers inspect typed Go syntax trees, but a few, such as asmdecl
 and buildtag, inspect the raw text of Go source files or even non-Go
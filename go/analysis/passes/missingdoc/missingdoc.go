@@ -0,0 +1,135 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package missingdoc defines an analyzer that checks for exported
+// declarations without doc comments.
+package missingdoc
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for exported declarations without doc comments
+
+The missingdoc checker reports the exported functions, types, variables
+and constants of a package, and the exported methods of its exported
+types, that have no doc comment, as golint does. A variable or constant
+declared in a group may have the doc comment of the group instead.
+The declarations of test files are not checked.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "missingdoc",
+	Doc:  Doc,
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		if strings.HasSuffix(pass.Fset.Position(f.Pos()).Filename, "_test.go") {
+			continue
+		}
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Doc != nil || !decl.Name.IsExported() {
+					continue
+				}
+				kind, name := "function", decl.Name.Name
+				if decl.Recv != nil {
+					recv := receiverName(decl.Recv)
+					if !ast.IsExported(recv) {
+						continue
+					}
+					kind, name = "method", recv+"."+name
+				}
+				report(pass, decl.Pos(), decl.Name, kind, name)
+			case *ast.GenDecl:
+				if decl.Doc != nil && !decl.Lparen.IsValid() {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					checkSpec(pass, decl, spec)
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// checkSpec reports spec, a specification of decl, if it declares an
+// exported name without a doc comment.
+func checkSpec(pass *analysis.Pass, decl *ast.GenDecl, spec ast.Spec) {
+	// The doc comment of an ungrouped declaration precedes its keyword.
+	pos := decl.Pos()
+	if decl.Lparen.IsValid() {
+		pos = spec.Pos()
+	}
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		if spec.Doc == nil && spec.Name.IsExported() {
+			report(pass, pos, spec.Name, "type", spec.Name.Name)
+		}
+	case *ast.ValueSpec:
+		if spec.Doc != nil || decl.Doc != nil {
+			return
+		}
+		kind := "variable"
+		if decl.Tok == token.CONST {
+			kind = "constant"
+		}
+		for _, name := range spec.Names {
+			if name.IsExported() {
+				report(pass, pos, name, kind, name.Name)
+				return
+			}
+		}
+	}
+}
+
+// report reports the declaration of name, which lacks a doc comment, with
+// the fix that inserts a stub of one at pos, the start of the declaration.
+func report(pass *analysis.Pass, pos token.Pos, id *ast.Ident, kind, name string) {
+	// The stub is indented as the declaration is, which is on a line of its
+	// own as gofmt formats it.
+	indent := strings.Repeat("\t", pass.Fset.Position(pos).Column-1)
+	pass.Report(analysis.Diagnostic{
+		Pos:     id.Pos(),
+		Message: fmt.Sprintf("exported %s %s should have a doc comment", kind, name),
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message: "Add documentation comment",
+			TextEdits: []analysis.TextEdit{{
+				Pos:     pos,
+				End:     pos,
+				NewText: []byte(fmt.Sprintf("// %s ...\n%s", id.Name, indent)),
+			}},
+		}},
+	})
+}
+
+// receiverName returns the name of the base type of the receiver recv.
+func receiverName(recv *ast.FieldList) string {
+	if len(recv.List) == 0 {
+		return ""
+	}
+	typ := recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+		case *ast.ParenExpr:
+			typ = t.X
+		case *ast.IndexExpr:
+			typ = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Package a is a test for the missingdoc checker.
+package a
+
+func Exported() {} // want "exported function Exported should have a doc comment"
+
+// Documented is documented.
+func Documented() {}
+
+func unexported() {}
+
+type T struct{} // want "exported type T should have a doc comment"
+
+func (T) M() {} // want "exported method T.M should have a doc comment"
+
+func (*T) N() {} // want "exported method T.N should have a doc comment"
+
+type t struct{}
+
+func (t) M() {}
+
+// U is documented.
+type U int
+
+type (
+	// V is documented.
+	V int
+
+	W int // want "exported type W should have a doc comment"
+)
+
+var X = 1 // want "exported variable X should have a doc comment"
+
+const (
+	y, Y = 1, 2 // want "exported constant Y should have a doc comment"
+	z    = 3
+)
+
+// The group is documented.
+const (
+	A = 1
+	B = 2
+)
@@ -0,0 +1,103 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package simplifycompositelit defines an analyzer that checks for
+// composite literals that gofmt -s simplifies.
+package simplifycompositelit
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const Doc = `check for redundant types in composite literals
+
+The simplifycompositelit checker reports the types of the elements of
+array, slice and map composite literals, and of their keys, that the
+type of the literal implies, and which gofmt -s removes:
+
+	[]T{T{}, T{}}
+
+is simplified to:
+
+	[]T{{}, {}}
+
+and, if the elements are pointers:
+
+	[]*T{&T{}, &T{}}
+
+is simplified to:
+
+	[]*T{{}, {}}`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "simplifycompositelit",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodeFilter := []ast.Node{
+		(*ast.CompositeLit)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		lit := n.(*ast.CompositeLit)
+		var keyType, eltType types.Type
+		switch t := pass.TypesInfo.TypeOf(lit).Underlying().(type) {
+		case *types.Array:
+			eltType = t.Elem()
+		case *types.Slice:
+			eltType = t.Elem()
+		case *types.Map:
+			keyType, eltType = t.Key(), t.Elem()
+		default:
+			return
+		}
+		for _, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if keyType != nil {
+					check(pass, kv.Key, keyType)
+				}
+				elt = kv.Value
+			}
+			check(pass, elt, eltType)
+		}
+	})
+	return nil, nil
+}
+
+// check reports x, an element or key of a composite literal whose type is
+// typ, if it is a composite literal, or the address of one, whose type typ
+// implies.
+func check(pass *analysis.Pass, x ast.Expr, typ types.Type) {
+	start := x.Pos()
+	if addr, ok := x.(*ast.UnaryExpr); ok && addr.Op == token.AND {
+		// &T{} may be elided if typ is *T.
+		ptr, ok := typ.(*types.Pointer)
+		if !ok {
+			return
+		}
+		x, typ = addr.X, ptr.Elem()
+	}
+	lit, ok := x.(*ast.CompositeLit)
+	if !ok || lit.Type == nil || !types.Identical(pass.TypesInfo.TypeOf(lit), typ) {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:     start,
+		Message: "redundant type in composite literal",
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   "Remove redundant type",
+			TextEdits: []analysis.TextEdit{{Pos: start, End: lit.Type.End()}},
+		}},
+	})
+}
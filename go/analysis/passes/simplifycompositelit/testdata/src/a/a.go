@@ -0,0 +1,50 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the simplifycompositelit checker.
+
+package a
+
+type T struct {
+	x, y int
+}
+
+type P *T
+
+var _ = []T{
+	T{1, 2}, // want "redundant type in composite literal"
+	{3, 4},
+}
+
+var _ = [...]*T{
+	&T{1, 2}, // want "redundant type in composite literal"
+	{3, 4},
+}
+
+var _ = map[T]*T{
+	T{1, 2}: &T{3, 4}, // want "redundant type in composite literal" "redundant type in composite literal"
+}
+
+var _ = [][]int{
+	[]int{1}, // want "redundant type in composite literal"
+	{2},
+}
+
+var _ = [][]T{
+	{T{1, 2}}, // want "redundant type in composite literal"
+}
+
+type U T
+
+var _ = []T{
+	T(U{1, 2}),
+}
+
+var _ = []interface{}{
+	T{1, 2},
+}
+
+var _ = []P{
+	&T{1, 2},
+}
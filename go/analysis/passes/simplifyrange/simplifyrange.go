@@ -0,0 +1,78 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package simplifyrange defines an analyzer that checks for range
+// statements that gofmt -s simplifies.
+package simplifyrange
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const Doc = `check for redundant blank identifiers in range statements
+
+The simplifyrange checker reports the blank identifiers of range
+statements that gofmt -s removes:
+
+	for x, _ = range v {...}
+
+is simplified to:
+
+	for x = range v {...}
+
+and:
+
+	for _ = range v {...}
+
+is simplified to:
+
+	for range v {...}`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "simplifyrange",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodeFilter := []ast.Node{
+		(*ast.RangeStmt)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		stmt := n.(*ast.RangeStmt)
+		var edit analysis.TextEdit
+		switch {
+		case isBlank(stmt.Key) && (stmt.Value == nil || isBlank(stmt.Value)):
+			// Remove the blank identifiers and the assignment, up to the
+			// range keyword before the ranged expression.
+			edit = analysis.TextEdit{Pos: stmt.Key.Pos(), End: stmt.X.Pos(), NewText: []byte("range ")}
+		case isBlank(stmt.Value):
+			edit = analysis.TextEdit{Pos: stmt.Key.End(), End: stmt.Value.End()}
+		default:
+			return
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     edit.Pos,
+			Message: "redundant blank identifier in range statement",
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   "Remove blank identifier",
+				TextEdits: []analysis.TextEdit{edit},
+			}},
+		})
+	})
+	return nil, nil
+}
+
+// isBlank reports whether x is the blank identifier.
+func isBlank(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && id.Name == "_"
+}
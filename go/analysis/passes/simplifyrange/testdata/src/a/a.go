@@ -0,0 +1,29 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the simplifyrange checker.
+
+package a
+
+func _(m map[string]int) {
+	var k string
+	for k, _ = range m { // want "redundant blank identifier in range statement"
+		println(k)
+	}
+	for k, _ := range m { // want "redundant blank identifier in range statement"
+		println(k)
+	}
+	for _ = range m { // want "redundant blank identifier in range statement"
+	}
+	for _, _ = range m { // want "redundant blank identifier in range statement"
+	}
+	for _, v := range m {
+		println(v)
+	}
+	for k := range m {
+		println(k)
+	}
+	for range m {
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package simplifyslice defines an analyzer that checks for slice
+// expressions that gofmt -s simplifies.
+package simplifyslice
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const Doc = `check for redundant high indices in slice expressions
+
+The simplifyslice checker reports the slice expressions whose high index
+is the length of the sliced variable, which is the default, and which
+gofmt -s removes:
+
+	s[a:len(s)]
+
+is simplified to:
+
+	s[a:]`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "simplifyslice",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodeFilter := []ast.Node{
+		(*ast.SliceExpr)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		expr := n.(*ast.SliceExpr)
+		if expr.Slice3 || expr.High == nil {
+			return
+		}
+		// The sliced expression must be a variable, as evaluating it
+		// twice may differ.
+		s, ok := analysisutil.Unparen(expr.X).(*ast.Ident)
+		if !ok {
+			return
+		}
+		call, ok := analysisutil.Unparen(expr.High).(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 || call.Ellipsis.IsValid() {
+			return
+		}
+		fn, ok := analysisutil.Unparen(call.Fun).(*ast.Ident)
+		if !ok {
+			return
+		}
+		if _, ok := pass.TypesInfo.Uses[fn].(*types.Builtin); !ok || fn.Name != "len" {
+			return
+		}
+		arg, ok := analysisutil.Unparen(call.Args[0]).(*ast.Ident)
+		obj := pass.TypesInfo.Uses[s]
+		if !ok || obj == nil || pass.TypesInfo.Uses[arg] != obj {
+			return
+		}
+		high := analysisutil.Format(pass.Fset, expr.High)
+		pass.Report(analysis.Diagnostic{
+			Pos:     expr.High.Pos(),
+			Message: fmt.Sprintf("redundant %s in slice expression", high),
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   fmt.Sprintf("Remove %s", high),
+				TextEdits: []analysis.TextEdit{{Pos: expr.High.Pos(), End: expr.High.End()}},
+			}},
+		})
+	})
+	return nil, nil
+}
@@ -0,0 +1,24 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the simplifyslice checker.
+
+package a
+
+func f() []int { return nil }
+
+func _(s, t []int, str string, a int) {
+	_ = s[a:len(s)]     // want `redundant len\(s\) in slice expression`
+	_ = s[:len(s)]      // want `redundant len\(s\) in slice expression`
+	_ = str[1:len(str)] // want `redundant len\(str\) in slice expression`
+	_ = s[a:len(t)]
+	_ = s[a:len(s):len(s)]
+	_ = f()[a:len(f())]
+	_ = s[a:]
+}
+
+func _(s []int) {
+	len := func([]int) int { return 0 }
+	_ = s[:len(s)]
+}
@@ -0,0 +1,10 @@
+// The unusedparams command applies the golang.org/x/tools/go/analysis/passes/unusedparams
+// analysis to the specified packages of Go source code.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/passes/unusedparams"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() { singlechecker.Main(unusedparams.Analyzer) }
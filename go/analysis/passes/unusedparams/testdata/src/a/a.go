@@ -0,0 +1,55 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the unusedparams checker.
+
+package a
+
+func add(x, y int) int { // want "parameter y is unused"
+	return x + x
+}
+
+func blank(_ int, s string) string {
+	return s
+}
+
+func unnamed(int, string) {
+	println()
+}
+
+func stub(x int) int {
+	return 0
+}
+
+func todo(x int) {
+	panic("TODO")
+}
+
+func handle(pattern string, handler func(w []byte, r string)) {
+	handler(nil, pattern)
+}
+
+func handler(w []byte, r string) {
+	println(w)
+}
+
+func shadowed(x int) int { // want "parameter x is unused"
+	for x := 0; x < 1; x++ {
+		return x
+	}
+	return 0
+}
+
+type T struct{}
+
+func (T) method(x int) {
+	println()
+}
+
+func _() {
+	handle("/", handler)
+	add(1, 2)
+	var f func(int) int = stub
+	f(0)
+}
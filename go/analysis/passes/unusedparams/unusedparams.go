@@ -0,0 +1,139 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package unusedparams defines an analyzer that checks for parameters of
+// functions that are never used.
+package unusedparams
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const Doc = `check for unused parameters of functions
+
+The unusedparams checker reports the parameters of functions that the
+bodies of the functions never use, such as x in:
+
+	func f(x, y int) int { return y }
+
+An unused parameter may be a mistake, or a leftover of a change to the
+function. Renaming it to _ documents that it is unused on purpose.
+
+The checker does not report the parameters of methods, which may be
+needed to implement an interface, nor those of functions that are used
+as values, such as callbacks, whose signatures their uses dictate, nor
+those of test functions. Neither does it report those of stubs, whose
+bodies are empty, or only return constants or panic.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "unusedparams",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	// Find the functions that are used other than by calling them.
+	called := make(map[*ast.Ident]bool)
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		if id, ok := analysisutil.Unparen(n.(*ast.CallExpr).Fun).(*ast.Ident); ok {
+			called[id] = true
+		}
+	})
+	values := make(map[types.Object]bool)
+	for id, obj := range pass.TypesInfo.Uses {
+		if _, ok := obj.(*types.Func); ok && !called[id] {
+			values[obj] = true
+		}
+	}
+
+	inspect.Preorder([]ast.Node{(*ast.FuncDecl)(nil)}, func(n ast.Node) {
+		decl := n.(*ast.FuncDecl)
+		if decl.Recv != nil || decl.Body == nil || isStub(decl.Body) {
+			return
+		}
+		if values[pass.TypesInfo.Defs[decl.Name]] || isTest(pass, decl) {
+			return
+		}
+		used := make(map[types.Object]bool)
+		ast.Inspect(decl.Body, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				used[pass.TypesInfo.Uses[id]] = true
+			}
+			return true
+		})
+		for _, field := range decl.Type.Params.List {
+			for _, name := range field.Names {
+				obj := pass.TypesInfo.Defs[name]
+				if name.Name == "_" || obj == nil || used[obj] {
+					continue
+				}
+				pass.Report(analysis.Diagnostic{
+					Pos:     name.Pos(),
+					Message: fmt.Sprintf("parameter %s is unused", name.Name),
+					SuggestedFixes: []analysis.SuggestedFix{{
+						Message:   fmt.Sprintf("Rename %s to _", name.Name),
+						TextEdits: []analysis.TextEdit{{Pos: name.Pos(), End: name.End(), NewText: []byte("_")}},
+					}},
+				})
+			}
+		}
+	})
+	return nil, nil
+}
+
+// isStub reports whether body is empty, or only returns constants or
+// panics.
+func isStub(body *ast.BlockStmt) bool {
+	switch len(body.List) {
+	case 0:
+		return true
+	case 1:
+		switch stmt := body.List[0].(type) {
+		case *ast.ReturnStmt:
+			for _, result := range stmt.Results {
+				switch result := analysisutil.Unparen(result).(type) {
+				case *ast.BasicLit:
+				case *ast.Ident:
+					if result.Name != "nil" && result.Name != "true" && result.Name != "false" {
+						return false
+					}
+				default:
+					return false
+				}
+			}
+			return true
+		case *ast.ExprStmt:
+			if call, ok := stmt.X.(*ast.CallExpr); ok {
+				id, ok := analysisutil.Unparen(call.Fun).(*ast.Ident)
+				return ok && id.Name == "panic"
+			}
+		}
+	}
+	return false
+}
+
+// isTest reports whether decl declares a function that go test calls,
+// whose signature is therefore given.
+func isTest(pass *analysis.Pass, decl *ast.FuncDecl) bool {
+	if !strings.HasSuffix(pass.Fset.Position(decl.Pos()).Filename, "_test.go") {
+		return false
+	}
+	for _, prefix := range []string{"Test", "Benchmark", "Example", "Fuzz"} {
+		if strings.HasPrefix(decl.Name.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}
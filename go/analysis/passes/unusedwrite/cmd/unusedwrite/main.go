@@ -0,0 +1,10 @@
+// The unusedwrite command applies the golang.org/x/tools/go/analysis/passes/unusedwrite
+// analysis to the specified packages of Go source code.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/passes/unusedwrite"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() { singlechecker.Main(unusedwrite.Analyzer) }
@@ -0,0 +1,113 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the unusedwrite checker.
+
+package a
+
+func f() (int, error) { return 0, nil }
+
+func sequence() int {
+	x := 1
+	x = 2 // want "the value assigned to x is never read"
+	x = 3
+	return x
+}
+
+func results() (int, error) {
+	n, err := f()
+	n, err = f() // want "the value assigned to n is never read" "the value assigned to err is never read"
+	n, err = f()
+	return n, err
+}
+
+func param(x, y int) int {
+	z := x + y
+	x++    // want "the value assigned to x is never read"
+	y += 2 // want "the value assigned to y is never read"
+	return z
+}
+
+func loop(xs []int) int {
+	sum := 0
+	last := 0
+	_ = last
+	for _, x := range xs {
+		sum += x
+		last = x // want "the value assigned to last is never read"
+	}
+	return sum
+}
+
+func branches(c bool) int {
+	x := 0
+	if c {
+		x = 1
+	} else {
+		x = 2
+	}
+	y := 0
+	for i := 0; i < 3; i++ {
+		if i == 2 {
+			return y
+		}
+		y = i
+	}
+	return x
+}
+
+func found(xs []int) bool {
+	ok := false
+	for _, x := range xs {
+		if x == 0 {
+			ok = true
+			break
+		}
+	}
+	return ok
+}
+
+func named() (x int) {
+	x = 1
+	return
+}
+
+func captured() int {
+	x := 1
+	func() { x = 2 }()
+	x = 3
+	f := func() int { return x }
+	return f()
+}
+
+type T struct{ f int }
+
+func (t *T) set() { t.f = 1 }
+
+func addressed() int {
+	x := 1
+	p := &x
+	x = 2
+	t := T{}
+	t.set()
+	t = T{f: 2}
+	t.set()
+	return *p
+}
+
+func fields() T {
+	var t T
+	t = T{}
+	t.f = 1
+	return t
+}
+
+func channels(ch chan int) {
+	var v int
+	v = 1
+	select {
+	case v = <-ch:
+	case ch <- v:
+	}
+}
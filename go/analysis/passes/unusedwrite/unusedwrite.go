@@ -0,0 +1,348 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package unusedwrite defines an analyzer that checks for values assigned
+// to local variables that are never read.
+package unusedwrite
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/internal/analysisutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/cfg"
+)
+
+const Doc = `check for unused writes to local variables
+
+The unusedwrite checker reports the assignments of values to local
+variables that no path from them reads, such as the first one in:
+
+	x = f()
+	x = g()
+	return x
+
+Such an assignment is useless, and often a mistake, such as an error
+that is not checked:
+
+	n, err = w.Write(b)
+	n, err = w.Write(b)
+	if err != nil {
+
+The checker tracks the variables of each function whose address it does
+not take and that no function literal uses, and does not report their
+declarations, nor assignments to named results.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "unusedwrite",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, ctrlflow.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cfgs := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+
+	nodeFilter := []ast.Node{
+		(*ast.FuncDecl)(nil),
+		(*ast.FuncLit)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		var (
+			typ  *ast.FuncType
+			body *ast.BlockStmt
+			g    *cfg.CFG
+		)
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Body == nil {
+				return
+			}
+			typ, body, g = n.Type, n.Body, cfgs.FuncDecl(n)
+		case *ast.FuncLit:
+			typ, body, g = n.Type, n.Body, cfgs.FuncLit(n)
+		}
+		c := &checker{pass: pass, vars: localVars(pass.TypesInfo, typ, body)}
+		if len(c.vars) > 0 && g != nil {
+			c.check(g)
+		}
+	})
+	return nil, nil
+}
+
+// localVars returns the variables of the function with type typ and body
+// body whose writes the checker tracks: its parameters and the variables
+// that its body declares, except those of the function literals in it,
+// that no function literal uses and whose address is not taken.
+func localVars(info *types.Info, typ *ast.FuncType, body *ast.BlockStmt) map[*types.Var]bool {
+	vars := make(map[*types.Var]bool)
+	declare := func(id *ast.Ident) {
+		if v, ok := info.Defs[id].(*types.Var); ok && !v.IsField() {
+			vars[v] = true
+		}
+	}
+	if typ.Params != nil {
+		for _, field := range typ.Params.List {
+			for _, name := range field.Names {
+				declare(name)
+			}
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.Ident:
+			declare(n)
+		}
+		return true
+	})
+
+	// The variable at the root of an expression, such as x in x.f[i],
+	// whose address the expression takes.
+	root := func(e ast.Expr) *types.Var {
+		for {
+			switch x := analysisutil.Unparen(e).(type) {
+			case *ast.SelectorExpr:
+				e = x.X
+			case *ast.IndexExpr:
+				e = x.X
+			case *ast.Ident:
+				v, _ := info.Uses[x].(*types.Var)
+				return v
+			default:
+				return nil
+			}
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			ast.Inspect(n.Body, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					if v, ok := info.Uses[id].(*types.Var); ok {
+						delete(vars, v)
+					}
+				}
+				return true
+			})
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				delete(vars, root(n.X))
+			}
+		case *ast.SliceExpr:
+			if _, ok := info.TypeOf(n.X).Underlying().(*types.Array); ok {
+				delete(vars, root(n.X))
+			}
+		case *ast.SelectorExpr:
+			// A call of a method with a pointer receiver takes the
+			// address of its addressable receiver.
+			if sel := info.Selections[n]; sel != nil && sel.Kind() == types.MethodVal {
+				recv := sel.Obj().Type().(*types.Signature).Recv()
+				if _, ok := recv.Type().(*types.Pointer); ok {
+					delete(vars, root(n.X))
+				}
+			}
+		case *ast.CommClause:
+			// The control-flow graph omits the operands of the
+			// communications of select statements.
+			ast.Inspect(n.Comm, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					if v, ok := info.Uses[id].(*types.Var); ok {
+						delete(vars, v)
+					}
+				}
+				return true
+			})
+		}
+		return true
+	})
+	return vars
+}
+
+// A checker checks the writes to the variables of a function.
+type checker struct {
+	pass *analysis.Pass
+	vars map[*types.Var]bool
+}
+
+// A liveSet is a set of variables whose current values some path reads.
+type liveSet map[*types.Var]bool
+
+// check reports the writes to the variables of the function whose control
+// flow graph is g after which they are not live.
+func (c *checker) check(g *cfg.CFG) {
+	// Compute the variables that are live at the start of each block,
+	// until they no longer change.
+	liveIn := make([]liveSet, len(g.Blocks))
+	for changed := true; changed; {
+		changed = false
+		for i := len(g.Blocks) - 1; i >= 0; i-- {
+			b := g.Blocks[i]
+			live := c.liveOut(b, liveIn)
+			for j := len(b.Nodes) - 1; j >= 0; j-- {
+				c.transfer(b.Nodes[j], live)
+			}
+			if len(live) != len(liveIn[i]) {
+				liveIn[i] = live
+				changed = true
+			}
+		}
+	}
+	for _, b := range g.Blocks {
+		if !b.Live {
+			continue
+		}
+		live := c.liveOut(b, liveIn)
+		for j := len(b.Nodes) - 1; j >= 0; j-- {
+			c.report(b.Nodes[j], live)
+			c.transfer(b.Nodes[j], live)
+		}
+	}
+}
+
+// liveOut returns the variables that are live at the end of b, those that
+// are live at the start of its successors.
+func (c *checker) liveOut(b *cfg.Block, liveIn []liveSet) liveSet {
+	live := make(liveSet)
+	for _, succ := range b.Succs {
+		for v := range liveIn[succ.Index] {
+			live[v] = true
+		}
+	}
+	return live
+}
+
+// transfer updates live, the variables that are live after n, a node of a
+// control-flow graph, to those that are live before it.
+func (c *checker) transfer(n ast.Node, live liveSet) {
+	writes, reads := c.effects(n)
+	for _, id := range writes {
+		delete(live, c.pass.TypesInfo.ObjectOf(id).(*types.Var))
+	}
+	for _, v := range reads {
+		live[v] = true
+	}
+}
+
+// effects returns the identifiers of the variables that n writes, and the
+// variables whose values it reads.
+func (c *checker) effects(n ast.Node) (writes []*ast.Ident, reads []*types.Var) {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		for _, rhs := range n.Rhs {
+			reads = c.reads(rhs, reads)
+		}
+		for _, lhs := range n.Lhs {
+			if id, v := c.variable(lhs); v != nil {
+				writes = append(writes, id)
+				if n.Tok != token.ASSIGN && n.Tok != token.DEFINE {
+					reads = append(reads, v)
+				}
+				continue
+			}
+			reads = c.reads(lhs, reads)
+		}
+	case *ast.IncDecStmt:
+		if id, v := c.variable(n.X); v != nil {
+			return []*ast.Ident{id}, []*types.Var{v}
+		}
+		reads = c.reads(n.X, reads)
+	case *ast.ValueSpec:
+		for _, value := range n.Values {
+			reads = c.reads(value, reads)
+		}
+		for _, name := range n.Names {
+			if _, v := c.variable(name); v != nil {
+				writes = append(writes, name)
+			}
+		}
+	default:
+		reads = c.reads(n, reads)
+	}
+	return writes, reads
+}
+
+// variable returns e and its variable, if it is a tracked variable.
+func (c *checker) variable(e ast.Expr) (*ast.Ident, *types.Var) {
+	id, ok := analysisutil.Unparen(e).(*ast.Ident)
+	if !ok {
+		return nil, nil
+	}
+	v, _ := c.pass.TypesInfo.ObjectOf(id).(*types.Var)
+	if !c.vars[v] {
+		return nil, nil
+	}
+	return id, v
+}
+
+// reads appends the variables that n reads to vars.
+func (c *checker) reads(n ast.Node, vars []*types.Var) []*types.Var {
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.Ident:
+			if v, ok := c.pass.TypesInfo.Uses[n].(*types.Var); ok && c.vars[v] {
+				vars = append(vars, v)
+			}
+		}
+		return true
+	})
+	return vars
+}
+
+// report reports the assignments of n, a node of a control-flow graph, to
+// the variables that are not live after it, with the fixes that remove
+// them.
+func (c *checker) report(n ast.Node, live liveSet) {
+	// The statement may be removed if it only assigns the variable, and
+	// _ may replace the variable if it is assigned with =.
+	var removable, blankable bool
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		if n.Tok == token.DEFINE {
+			return
+		}
+		removable = len(n.Lhs) == 1
+		for _, e := range n.Rhs {
+			removable = removable && !analysisutil.HasSideEffects(c.pass.TypesInfo, e)
+		}
+		blankable = n.Tok == token.ASSIGN
+	case *ast.IncDecStmt:
+		removable = true
+	default:
+		return
+	}
+	writes, _ := c.effects(n)
+	for _, id := range writes {
+		if live[c.pass.TypesInfo.ObjectOf(id).(*types.Var)] {
+			continue
+		}
+		diag := analysis.Diagnostic{
+			Pos:     id.Pos(),
+			Message: fmt.Sprintf("the value assigned to %s is never read", id.Name),
+		}
+		switch {
+		case removable:
+			diag.SuggestedFixes = []analysis.SuggestedFix{{
+				Message:   "Remove assignment",
+				TextEdits: []analysis.TextEdit{{Pos: n.Pos(), End: n.End()}},
+			}}
+		case blankable:
+			diag.SuggestedFixes = []analysis.SuggestedFix{{
+				Message:   fmt.Sprintf("Replace %s with _", id.Name),
+				TextEdits: []analysis.TextEdit{{Pos: id.Pos(), End: id.End(), NewText: []byte("_")}},
+			}}
+		}
+		c.pass.Report(diag)
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+// This file implements the on-disk cache of the metadata of the go list
+// driver, which short-lived tools may enable to skip the go command when
+// they load the same packages again.
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheVersion changes with the format of the entries of the cache, and
+// with the metadata that the driver reports.
+const cacheVersion = "3"
+
+// cacheMaxAge is how long an entry of the cache is kept after it was last
+// used.
+const cacheMaxAge = 5 * 24 * time.Hour
+
+// cacheTouchInterval is how often the modification time of an entry that is
+// used is updated, which tells how recently it was used.
+const cacheTouchInterval = time.Hour
+
+// cacheKeyVars are the variables of the environment, besides those of the go
+// command and of cgo, that the output of go list depends on: the go command
+// is found in PATH, and the default directories of GOPATH, of the cache of
+// the go command, and of its configuration are in that of the user.
+var cacheKeyVars = []string{
+	"PATH", "HOME", "USERPROFILE", "LocalAppData", "AppData", "home",
+	"XDG_CACHE_HOME", "XDG_CONFIG_HOME",
+	"CC", "CXX", "PKG_CONFIG",
+}
+
+// A cacheEntry is the response of the go list driver to a query, and the
+// stamps of the files and directories that it depends on.
+type cacheEntry struct {
+	// Stamps maps the paths of the files and directories to their stamps,
+	// which change when they do.
+	Stamps map[string]string
+
+	// Outputs are the files of the cache of the go command that the
+	// response names, such as export data. Their names change with their
+	// contents, so only their existence is checked.
+	Outputs []string
+
+	Response *DriverResponse
+}
+
+// metadataCacheDir returns the directory of the cache of the metadata, from
+// the GOPACKAGESCACHE variable of the environment of cfg, or "" if the cache
+// is disabled: the cache is opt-in, and GOPACKAGESCACHE=off disables it.
+func metadataCacheDir(cfg *Config) string {
+	dir := cfgEnv(cfg, "GOPACKAGESCACHE")
+	if dir == "off" {
+		return ""
+	}
+	return dir
+}
+
+// cfgEnv returns the value of the variable with the given name in the
+// environment of cfg, or "" if it is not set.
+func cfgEnv(cfg *Config, name string) string {
+	prefix := name + "="
+	val := ""
+	for _, env := range cfg.Env {
+		if v := strings.TrimPrefix(env, prefix); v != env {
+			val = v
+		}
+	}
+	return val
+}
+
+// cacheable reports whether the response of the go list driver to the
+// patterns may be cached. The patterns with "..." match the packages of
+// all the directories that the go command walks, which the stamps of an
+// entry do not cover, so a new package would go unnoticed.
+func cacheable(patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.Contains(pattern, "...") {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheKey returns the name of the entry of the cache of the response of
+// go list with the given arguments, run in the environment and directory
+// of cfg. Only the variables of the environment that go list depends on are
+// part of the key, so that the entries are shared by the shells and editors
+// of the user.
+func cacheKey(cfg *Config, args []string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "version %s\n", cacheVersion)
+	fmt.Fprintf(h, "dir %s\n", cfg.Dir)
+	for _, env := range cfg.Env {
+		if cacheKeyEnv(env) {
+			fmt.Fprintf(h, "env %q\n", env)
+		}
+	}
+	for _, arg := range args {
+		fmt.Fprintf(h, "arg %q\n", arg)
+	}
+	return fmt.Sprintf("%x.json", h.Sum(nil))
+}
+
+// cacheKeyEnv reports whether the variable of env, of the form NAME=value,
+// is part of the key of an entry of the cache.
+func cacheKeyEnv(env string) bool {
+	name := env
+	if i := strings.IndexByte(env, '='); i >= 0 {
+		name = env[:i]
+	}
+	switch {
+	case strings.HasPrefix(name, "GOPACKAGES"):
+		return false
+	case strings.HasPrefix(name, "GO"), strings.HasPrefix(name, "CGO_"):
+		return true
+	}
+	for _, v := range cacheKeyVars {
+		if name == v {
+			return true
+		}
+	}
+	return false
+}
+
+// readCache returns the response of the entry of the cache with the given
+// key, or nil if there is no entry, or the files that it depends on have
+// changed since it was written.
+func readCache(dir, key string) *DriverResponse {
+	filename := filepath.Join(dir, key)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Response == nil {
+		return nil
+	}
+	for path, s := range entry.Stamps {
+		if stamp(path) != s {
+			return nil
+		}
+	}
+	for _, path := range entry.Outputs {
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+	}
+	// Mark the entry as used, so that it is not evicted.
+	if fi, err := os.Stat(filename); err == nil && time.Since(fi.ModTime()) > cacheTouchInterval {
+		now := time.Now()
+		os.Chtimes(filename, now, now)
+	}
+	return entry.Response
+}
+
+// writeCache writes the response of the go list driver run in the directory
+// of cfg to the entry of the cache with the given key, unless one of its
+// packages has an error, which may be fixed by a package that the go
+// command did not find, and so that the stamps would not notice.
+// It writes the entry atomically, so that concurrent tools do not read
+// incomplete entries, and fails silently, as it only costs a later call of
+// the go command. It evicts the entries that were not used for cacheMaxAge.
+func writeCache(cfg *Config, dir, key string, response *DriverResponse) {
+	for _, pkg := range response.Packages {
+		if len(pkg.Errors) > 0 {
+			return
+		}
+	}
+	entry := cacheEntry{
+		Stamps:   make(map[string]string),
+		Response: response,
+	}
+	paths, outputs := cacheDependencies(cfg, response)
+	for _, path := range paths {
+		entry.Stamps[path] = stamp(path)
+	}
+	entry.Outputs = outputs
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(dir, key+".tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	evictCache(dir)
+}
+
+// evictCache removes the entries of the cache in dir that were not used for
+// cacheMaxAge, and the temporary files of the writes that did not finish.
+func evictCache(dir string) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		name := f.Name()
+		entry := strings.HasSuffix(name, ".json") || strings.Contains(name, ".json.tmp")
+		if entry && f.Mode().IsRegular() && time.Since(f.ModTime()) > cacheMaxAge {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+}
+
+// cacheDependencies returns the files and directories that a response of
+// the go list driver run in the directory of cfg depends on: the
+// directories of the source files of its packages, whose stamps change
+// when files are added, removed, or modified; the directories in which the
+// go command would find another package for the import paths of the
+// packages and their imports, whose stamps change when such a package is
+// created; the go.mod and go.sum files of cfg.Dir and its parent
+// directories, which select the modules of the packages; and the VERSION
+// file of GOROOT, which changes with the release of Go.
+// The packages of the standard library, and their imports, are not
+// expected to change otherwise, so the directories of GOROOT are not
+// stamped, as hashing them would cost more than the go command.
+// It also returns outputs, the files that the go command generates in its
+// cache, such as those of cgo and the export data.
+func cacheDependencies(cfg *Config, response *DriverResponse) (paths, outputs []string) {
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	goroot := cfgEnv(cfg, "GOROOT")
+	if goroot == "" {
+		goroot = build.Default.GOROOT
+	}
+	gopath := filepath.SplitList(cfgEnv(cfg, "GOPATH"))
+	if len(gopath) == 0 {
+		gopath = filepath.SplitList(build.Default.GOPATH)
+	}
+	add(filepath.Join(goroot, "VERSION"))
+	for _, pkg := range response.Packages {
+		dirs := make(map[string]bool)
+		for _, files := range [][]string{pkg.GoFiles, pkg.OtherFiles} {
+			for _, file := range files {
+				dirs[filepath.Dir(file)] = true
+				if !inDir(goroot, file) {
+					add(filepath.Dir(file))
+				}
+			}
+		}
+		for _, file := range pkg.CompiledGoFiles {
+			if !dirs[filepath.Dir(file)] {
+				outputs = append(outputs, file)
+			}
+		}
+		if pkg.ExportFile != "" {
+			outputs = append(outputs, pkg.ExportFile)
+		}
+		if len(pkg.GoFiles) == 0 || inDir(goroot, pkg.GoFiles[0]) {
+			continue
+		}
+		// The go command looks for an import path in the vendor
+		// directories of the directory of the importing package and its
+		// parents, then in GOROOT and in each directory of GOPATH.
+		dir := filepath.Dir(pkg.GoFiles[0])
+		for _, path := range append([]string{pkg.PkgPath}, importPaths(pkg)...) {
+			path = filepath.FromSlash(path)
+			for d := dir; ; {
+				add(filepath.Join(d, "vendor", path))
+				parent := filepath.Dir(d)
+				if parent == d {
+					break
+				}
+				d = parent
+			}
+			add(filepath.Join(goroot, "src", path))
+			for _, p := range gopath {
+				add(filepath.Join(p, "src", path))
+			}
+		}
+	}
+	for dir := cfg.Dir; dir != ""; {
+		add(filepath.Join(dir, "go.mod"))
+		add(filepath.Join(dir, "go.sum"))
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	sort.Strings(paths)
+	sort.Strings(outputs)
+	return paths, outputs
+}
+
+// importPaths returns the import paths of the imports of pkg, except those
+// of the standard library, whose first elements have no dot.
+func importPaths(pkg *Package) []string {
+	var paths []string
+	for path := range pkg.Imports {
+		elem := path
+		if i := strings.IndexByte(path, '/'); i >= 0 {
+			elem = path[:i]
+		}
+		if strings.Contains(elem, ".") {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// inDir reports whether the file named filename is in the directory dir or
+// its subdirectories.
+func inDir(dir, filename string) bool {
+	rel, err := filepath.Rel(dir, filename)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// stamp returns a summary of the file or directory with the given path,
+// which changes when it does: the hash of the content of a file, and the
+// hash of the names, modes, and contents of the files of a directory. It
+// returns "-" for a missing file.
+func stamp(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "-"
+	}
+	h := sha256.New()
+	if !fi.IsDir() {
+		if err := hashFile(h, path); err != nil {
+			return "-"
+		}
+		return fmt.Sprintf("%x", h.Sum(nil))
+	}
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return "-"
+	}
+	for _, f := range files {
+		fmt.Fprintf(h, "%q %v\n", f.Name(), f.Mode())
+		if f.Mode().IsRegular() {
+			if err := hashFile(h, filepath.Join(path, f.Name())); err != nil {
+				return "-"
+			}
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// hashFile writes the content of the file named filename to the hash h.
+func hashFile(h io.Writer, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
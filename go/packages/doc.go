@@ -19,11 +19,18 @@ but all patterns with the prefix "query=", where query is a
 non-empty string of letters from [a-z], are reserved and may be
 interpreted as query operators.
 
-Only two query operators are currently supported, "file" and "pattern".
+Three query operators are currently supported, "file", "name", and "pattern".
 
 The query "file=path/to/file.go" matches the package or packages enclosing
-the Go source file path/to/file.go.  For example "file=~/go/src/fmt/print.go"
+the Go source file path/to/file.go, which is relative to Config.Dir if it
+is not absolute.  For example "file=~/go/src/fmt/print.go"
 might returns the packages "fmt" and "fmt [fmt.test]".
+The go list driver lists each directory of the files once.
+
+The query "name=identifier" matches the packages named identifier, among
+those of GOROOT, GOPATH, the main module, and the module cache. For
+example "name=errors" might return the packages "errors" and
+"github.com/pkg/errors".
 
 The query "pattern=string" causes "string" to be passed directly to
 the underlying build tool. In most cases this is unnecessary,
@@ -70,8 +77,13 @@ causes Load to run in LoadFiles mode, collecting minimal information.
 See the documentation for type Config for details.
 
 As noted earlier, the Config.Mode controls the amount of detail
-reported about the loaded packages, with each mode returning all the data of the
-previous mode with some extra added. See the documentation for type LoadMode
+reported about the loaded packages. It is a set of Need flags, such as
+NeedName, NeedImports, or NeedTypes, each of which adds some fields of the
+packages, so that a tool requests only what it uses and does not pay for
+the rest: listing the names of packages is much cheaper than type-checking
+them. The LoadFiles, LoadImports, LoadTypes, LoadSyntax, and LoadAllSyntax
+modes combine them, with each mode returning all the data of the previous
+mode with some extra added. See the documentation for type LoadMode
 for details.
 
 Most tools should pass their command-line arguments (after any flags)
@@ -79,6 +91,34 @@ uninterpreted to the loader, so that the loader can interpret them
 according to the conventions of the underlying build system.
 See the Example function for typical usage.
 
+Load gets the metadata of the packages from the go list command, unless
+an external driver supplies it for another build system, such as Bazel.
+The driver is the program named by the GOPACKAGESDRIVER environment
+variable, or else the gopackagesdriver program found on the PATH;
+GOPACKAGESDRIVER=off disables the drivers.
+Load runs it with the patterns as its arguments, after "--", and writes a
+DriverRequest as JSON to its standard input, with the options of the
+Config. The driver writes a DriverResponse as JSON to its standard
+output: the packages that it found, as stubs that only have the IDs of
+their imports, which Load connects and then type-checks as requested.
+If the driver does not handle the patterns, it sets the NotHandled field
+of its response, and Load falls back to the go list command.
+
+The go command takes a while to list packages, which short-lived tools
+that load the same packages at each run, such as goimports, pay every
+time. If the GOPACKAGESCACHE environment variable names a directory,
+Load caches the metadata of the go list command in it, by the directory,
+build flags, and variables of the environment of the go command of the
+Config, and reuses it as long as the contents of the directories of the
+packages and their go.mod files are unchanged, and no directory of GOPATH
+or vendor directory has a new package for their import paths. The
+packages of GOROOT are assumed to change only with the release of Go.
+The cache does not hold the metadata of packages with errors, of a Config
+with an overlay, or of patterns with "...", which match the packages of
+directories that may be created at any time, and it forgets the metadata
+that was not used for five days. It is off by default, or if
+GOPACKAGESCACHE=off, and the directory may be removed at any time.
+
 */
 package packages // import "golang.org/x/tools/go/packages"
 
@@ -186,10 +226,14 @@ based on parser.ParseFile. This features enables editor-integrated tools
 that analyze the contents of modified but unsaved buffers: rather than
 read from the file system, a tool can read from an archive of modified
 buffers provided by the editor.
-This approach has its limits. Because package metadata is obtained by
-fork/execing an external query command for each build system, we can
-fake only the file contents seen by the parser, type-checker, and
-application, but not by the metadata query, so, for example:
+The Overlay field of the Config builds on it: the parser reads the
+contents of the overlay in place of the files, and the drivers pass the
+overlay to their query command, such as the -overlay flag of the go
+command, so that the metadata describes the imports and the files that
+the overlay adds.
+This approach has its limits. With a go command older than go1.16,
+which has no -overlay flag, only the parser sees the overlay, so, for
+example:
 - additional imports in the fake file will not be described by the
   metadata, so the type checker will fail to load imports that create
   new dependencies.
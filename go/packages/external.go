@@ -12,14 +12,66 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"go/types"
 	"os/exec"
 	"strings"
 )
 
-// findExternalTool returns the file path of a tool that supplies
-// the build system package structure, or "" if not found."
-// If GOPACKAGESDRIVER is set in the environment findExternalTool returns its
+// DriverRequest is the request of Load to an external driver, which it
+// writes as JSON to the standard input of the driver. The patterns of the
+// packages to load are the arguments of the driver that follow "--".
+type DriverRequest struct {
+	// Mode is the Mode of the Config of Load, with the flags that the
+	// requested ones depend on.
+	Mode LoadMode `json:"mode"`
+
+	// Env is the environment of the driver, and of the build system.
+	Env []string `json:"env"`
+
+	// BuildFlags are the flags of the build system of the Config.
+	BuildFlags []string `json:"build_flags"`
+
+	// Tests is set if the test packages are requested as well.
+	Tests bool `json:"tests"`
+
+	// Overlay maps the absolute paths of files to their contents, which
+	// replace those of the files of the file system, or add files to it.
+	Overlay map[string][]byte `json:"overlay"`
+}
+
+// DriverResponse contains the results for a driver query, which an external
+// driver writes as JSON to its standard output.
+type DriverResponse struct {
+	// NotHandled is set if the driver declines the request, such as for
+	// the packages of a workspace that its build system does not build,
+	// so that Load uses the go list command instead.
+	NotHandled bool `json:",omitempty"`
+
+	// Sizes, if not nil, is the types.Sizes to use when type checking.
+	Sizes *types.StdSizes
+
+	// Roots is the set of package IDs that make up the root packages.
+	// We have to encode this separately because when we encode a single package
+	// we cannot know if it is one of the roots as that requires knowledge of the
+	// graph it is part of.
+	Roots []string `json:",omitempty"`
+
+	// Packages is the full set of packages in the graph.
+	// The packages are not connected into a graph.
+	// The Imports if populated will be stubs that only have their ID set.
+	// Imports will be connected and then type and syntax information added in a
+	// later pass (see refine).
+	Packages []*Package
+}
+
+// findExternalDriver returns a driver that runs a tool that supplies
+// the build system package structure, or nil if not found.
+// If GOPACKAGESDRIVER is set in the environment findExternalDriver uses its
 // value, otherwise it searches for a binary named gopackagesdriver on the PATH.
+// The tool reads a DriverRequest from its standard input, and writes a
+// DriverResponse to its standard output.
+// Its arguments are also the flags of the former protocol of the drivers,
+// which predates DriverRequest, followed by "--" and the patterns.
 func findExternalDriver(cfg *Config) driver {
 	const toolPrefix = "GOPACKAGESDRIVER="
 	tool := ""
@@ -38,13 +90,24 @@ func findExternalDriver(cfg *Config) driver {
 			return nil
 		}
 	}
-	return func(cfg *Config, words ...string) (*driverResponse, error) {
+	return func(cfg *Config, words ...string) (*DriverResponse, error) {
+		req, err := json.Marshal(DriverRequest{
+			Mode:       cfg.Mode,
+			Env:        cfg.Env,
+			BuildFlags: cfg.BuildFlags,
+			Tests:      cfg.Tests,
+			Overlay:    cfg.Overlay,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode message to driver tool: %v", err)
+		}
+
 		buf := new(bytes.Buffer)
 		fullargs := []string{
 			"list",
 			fmt.Sprintf("-test=%t", cfg.Tests),
 			fmt.Sprintf("-export=%t", usesExportData(cfg)),
-			fmt.Sprintf("-deps=%t", cfg.Mode >= LoadImports),
+			fmt.Sprintf("-deps=%t", cfg.Mode&NeedImports != 0),
 		}
 		for _, f := range cfg.BuildFlags {
 			fullargs = append(fullargs, fmt.Sprintf("-buildflag=%v", f))
@@ -54,12 +117,13 @@ func findExternalDriver(cfg *Config) driver {
 		cmd := exec.CommandContext(cfg.Context, tool, fullargs...)
 		cmd.Env = cfg.Env
 		cmd.Dir = cfg.Dir
+		cmd.Stdin = bytes.NewReader(req)
 		cmd.Stdout = buf
 		cmd.Stderr = new(bytes.Buffer)
 		if err := cmd.Run(); err != nil {
 			return nil, fmt.Errorf("%v: %v: %s", tool, err, cmd.Stderr)
 		}
-		var response driverResponse
+		var response DriverResponse
 		if err := json.Unmarshal(buf.Bytes(), &response); err != nil {
 			return nil, err
 		}
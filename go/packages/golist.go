@@ -36,11 +36,11 @@ type goTooOldError struct {
 // goListDriver uses the go list command to interpret the patterns and produce
 // the build system package structure.
 // See driver for more details.
-func goListDriver(cfg *Config, patterns ...string) (*driverResponse, error) {
+func goListDriver(cfg *Config, patterns ...string) (*DriverResponse, error) {
 	var sizes types.Sizes
 	var sizeserr error
 	var sizeswg sync.WaitGroup
-	if cfg.Mode >= LoadTypes {
+	if cfg.Mode&NeedTypes != 0 {
 		sizeswg.Add(1)
 		go func() {
 			sizes, sizeserr = getSizes(cfg)
@@ -86,7 +86,7 @@ extractQueries:
 
 	// TODO(matloob): Remove the definition of listfunc and just use golistPackages once go1.12 is released.
 	var listfunc driver
-	listfunc = func(cfg *Config, words ...string) (*driverResponse, error) {
+	listfunc = func(cfg *Config, words ...string) (*DriverResponse, error) {
 		response, err := golistDriverCurrent(cfg, words...)
 		if _, ok := err.(goTooOldError); ok {
 			listfunc = golistDriverFallback
@@ -96,7 +96,7 @@ extractQueries:
 		return response, err
 	}
 
-	var response *driverResponse
+	var response *DriverResponse
 	var err error
 
 	// see if we have any patterns to pass through to go list.
@@ -106,7 +106,7 @@ extractQueries:
 			return nil, err
 		}
 	} else {
-		response = &driverResponse{}
+		response = &DriverResponse{}
 	}
 
 	sizeswg.Wait()
@@ -146,13 +146,28 @@ extractQueries:
 	return response, nil
 }
 
+// runContainsQueries returns the IDs of the packages that contain the files
+// of the file= queries, which are relative to cfg.Dir if not absolute.
+// It runs the driver once for each directory of the files.
 func runContainsQueries(cfg *Config, driver driver, addPkg func(*Package), queries []string) ([]string, error) {
-	var results []string
+	var dirs []string
+	files := make(map[string][]string) // base names of the files of each directory
 	for _, query := range queries {
-		// TODO(matloob): Do only one query per directory.
-		fdir := filepath.Dir(query)
-		cfg.Dir = fdir
-		dirResponse, err := driver(cfg, ".")
+		if !filepath.IsAbs(query) {
+			query = filepath.Join(cfg.Dir, query)
+		}
+		dir := filepath.Dir(query)
+		if files[dir] == nil {
+			dirs = append(dirs, dir)
+		}
+		files[dir] = append(files[dir], filepath.Base(query))
+	}
+
+	var results []string
+	for _, dir := range dirs {
+		dirCfg := *cfg
+		dirCfg.Dir = dir
+		dirResponse, err := driver(&dirCfg, ".")
 		if err != nil {
 			return nil, err
 		}
@@ -170,17 +185,26 @@ func runContainsQueries(cfg *Config, driver driver, addPkg func(*Package), queri
 			if !isRoot[pkg.ID] {
 				continue
 			}
-			for _, pkgFile := range pkg.GoFiles {
-				if filepath.Base(query) == filepath.Base(pkgFile) {
-					results = append(results, pkg.ID)
-					break
-				}
+			if containsFile(pkg, files[dir]) {
+				results = append(results, pkg.ID)
 			}
 		}
 	}
 	return results, nil
 }
 
+// containsFile reports whether pkg has a Go file with one of the base names.
+func containsFile(pkg *Package, bases []string) bool {
+	for _, pkgFile := range pkg.GoFiles {
+		for _, base := range bases {
+			if filepath.Base(pkgFile) == base {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // modCacheRegexp splits a path in a module cache into module, module version, and package.
 var modCacheRegexp = regexp.MustCompile(`(.*)@([^/\\]*)(.*)`)
 
@@ -252,9 +276,17 @@ func runNamedQueries(cfg *Config, driver driver, addPkg func(*Package), queries
 	}
 
 	var results []string
-	addResponse := func(r *driverResponse) {
+	addResponse := func(r *DriverResponse) {
+		isRoot := make(map[string]bool, len(r.Roots))
+		for _, root := range r.Roots {
+			isRoot[root] = true
+		}
 		for _, pkg := range r.Packages {
 			addPkg(pkg)
+			// The dependencies of the matches are not matches themselves.
+			if !isRoot[pkg.ID] {
+				continue
+			}
 			for _, name := range queries {
 				if pkg.Name == name {
 					results = append(results, pkg.ID)
@@ -473,6 +505,7 @@ type jsonPackage struct {
 	XTestImports    []string
 	ForTest         string // q in a "p [q.test]" package, else ""
 	DepOnly         bool
+	Module          *Module
 
 	Error *jsonPackageError
 }
@@ -490,7 +523,7 @@ func otherFiles(p *jsonPackage) [][]string {
 // golistDriverCurrent uses the "go list" command to expand the
 // pattern words and return metadata for the specified packages.
 // dir may be "" and env may be nil, as per os/exec.Command.
-func golistDriverCurrent(cfg *Config, words ...string) (*driverResponse, error) {
+func golistDriverCurrent(cfg *Config, words ...string) (*DriverResponse, error) {
 	// go list uses the following identifiers in ImportPath and Imports:
 	//
 	// 	"p"			-- importable package or main (command)
@@ -502,15 +535,45 @@ func golistDriverCurrent(cfg *Config, words ...string) (*driverResponse, error)
 	// are q itself, plus any helpers used by the external test q_test,
 	// typically including "testing" and all its dependencies.
 
+	// Use the metadata of the cache, if it is enabled and the files of the
+	// response have not changed. An overlay changes the files, so the
+	// responses for overlays are not cached, nor are those for the
+	// patterns that walk directories.
+	cacheDir := metadataCacheDir(cfg)
+	var key string
+	if cacheDir != "" && len(cfg.Overlay) == 0 && cacheable(words) {
+		key = cacheKey(cfg, golistargs(cfg, words, ""))
+		if response := readCache(cacheDir, key); response != nil {
+			return response, nil
+		}
+	}
+
+	// The go command sees the contents of the overlay through the
+	// temporary files of its -overlay flag.
+	var overlay string
+	if len(cfg.Overlay) > 0 {
+		file, cleanup, err := writeOverlay(cfg.Overlay)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		overlay = file
+	}
+
 	// Run "go list" for complete
 	// information on the specified packages.
-	buf, err := invokeGo(cfg, golistargs(cfg, words)...)
+	buf, err := invokeGo(cfg, golistargs(cfg, words, overlay)...)
+	if _, ok := err.(goTooOldError); ok && overlay != "" {
+		// The go command predates the -overlay flag of go1.16, so only
+		// the parser sees the overlay.
+		buf, err = invokeGo(cfg, golistargs(cfg, words, "")...)
+	}
 	if err != nil {
 		return nil, err
 	}
 	seen := make(map[string]*jsonPackage)
 	// Decode the JSON and convert it to Package form.
-	var response driverResponse
+	var response DriverResponse
 	for dec := json.NewDecoder(buf); dec.More(); {
 		p := new(jsonPackage)
 		if err := dec.Decode(p); err != nil {
@@ -524,8 +587,9 @@ func golistDriverCurrent(cfg *Config, words ...string) (*driverResponse, error)
 			// back a package without any way to refer to it.
 			if p.Error != nil {
 				return nil, Error{
-					Pos: p.Error.Pos,
-					Msg: p.Error.Err,
+					Pos:  listErrorPos(cfg.Dir, p.Error.Pos),
+					Msg:  p.Error.Err,
+					Kind: ListError,
 				}
 			}
 			return nil, fmt.Errorf("package missing import path: %+v", p)
@@ -575,6 +639,11 @@ func golistDriverCurrent(cfg *Config, words ...string) (*driverResponse, error)
 			log.Fatalf("internal error: go list returned non-absolute Package.Dir: %s", p.Dir)
 		}
 
+		pkg.ForTest = p.ForTest
+		if cfg.Mode&NeedModule != 0 {
+			pkg.Module = p.Module
+		}
+
 		if p.Export != "" && !filepath.IsAbs(p.Export) {
 			pkg.ExportFile = filepath.Join(p.Dir, p.Export)
 		} else {
@@ -614,14 +683,18 @@ func golistDriverCurrent(cfg *Config, words ...string) (*driverResponse, error)
 
 		if p.Error != nil {
 			pkg.Errors = append(pkg.Errors, Error{
-				Pos: p.Error.Pos,
-				Msg: p.Error.Err,
+				Pos:  listErrorPos(cfg.Dir, p.Error.Pos),
+				Msg:  p.Error.Err,
+				Kind: ListError,
 			})
 		}
 
 		response.Packages = append(response.Packages, pkg)
 	}
 
+	if key != "" {
+		writeCache(cfg, cacheDir, key, &response)
+	}
 	return &response, nil
 }
 
@@ -638,12 +711,28 @@ func absJoin(dir string, fileses ...[]string) (res []string) {
 	return res
 }
 
-func golistargs(cfg *Config, words []string) []string {
+// listErrorPos returns the position of an error of the go command, whose
+// file is relative to the directory dir of the command, with an absolute
+// file, so that the clients need not know the directory.
+func listErrorPos(dir, pos string) string {
+	if pos == "" || pos == "-" || filepath.IsAbs(pos) {
+		return pos
+	}
+	return filepath.Join(dir, pos)
+}
+
+// golistargs returns the arguments of the go list command for words, with
+// the -overlay flag of the overlay file, if it is not empty.
+func golistargs(cfg *Config, words []string, overlay string) []string {
 	fullargs := []string{
-		"list", "-e", "-json", "-compiled",
+		"list", "-e", "-json",
+		fmt.Sprintf("-compiled=%t", cfg.Mode&NeedCompiledGoFiles != 0),
 		fmt.Sprintf("-test=%t", cfg.Tests),
 		fmt.Sprintf("-export=%t", usesExportData(cfg)),
-		fmt.Sprintf("-deps=%t", cfg.Mode >= LoadImports),
+		fmt.Sprintf("-deps=%t", cfg.Mode&NeedImports != 0),
+	}
+	if overlay != "" {
+		fullargs = append(fullargs, "-overlay="+overlay)
 	}
 	fullargs = append(fullargs, cfg.BuildFlags...)
 	fullargs = append(fullargs, "--")
@@ -27,7 +27,7 @@ import (
 // This support will be removed once Go 1.12 is released
 // in Q1 2019.
 
-func golistDriverFallback(cfg *Config, words ...string) (*driverResponse, error) {
+func golistDriverFallback(cfg *Config, words ...string) (*DriverResponse, error) {
 	// Turn absolute paths into GOROOT and GOPATH-relative paths to provide to go list.
 	// This will have surprising behavior if GOROOT or GOPATH contain multiple packages with the same
 	// path and a user provides an absolute path to a directory that's shadowed by an earlier
@@ -44,7 +44,7 @@ func golistDriverFallback(cfg *Config, words ...string) (*driverResponse, error)
 		pkg, xtestPkg *Package
 	}
 
-	var response driverResponse
+	var response DriverResponse
 	allPkgs := make(map[string]bool)
 	addPackage := func(p *jsonPackage, isRoot bool) {
 		id := p.ImportPath
@@ -102,7 +102,7 @@ func golistDriverFallback(cfg *Config, words ...string) (*driverResponse, error)
 		}
 		processCgo := func() bool {
 			// Suppress any cgo errors. Any relevant errors will show up in typechecking.
-			// TODO(matloob): Skip running cgo if Mode < LoadTypes.
+			// TODO(matloob): Skip running cgo if Mode&NeedTypes == 0.
 			outdir, err := getOutdir()
 			if err != nil {
 				cgoErrors = append(cgoErrors, err)
@@ -134,8 +134,9 @@ func golistDriverFallback(cfg *Config, words ...string) (*driverResponse, error)
 		}
 		if p.Error != nil {
 			pkg.Errors = append(pkg.Errors, Error{
-				Pos: p.Error.Pos,
-				Msg: p.Error.Err,
+				Pos:  listErrorPos(cfg.Dir, p.Error.Pos),
+				Msg:  p.Error.Err,
+				Kind: ListError,
 			})
 		}
 		response.Packages = append(response.Packages, pkg)
@@ -150,6 +151,7 @@ func golistDriverFallback(cfg *Config, words ...string) (*driverResponse, error)
 					CompiledGoFiles: append(compiledGoFiles, absJoin(p.Dir, p.TestGoFiles)...),
 					OtherFiles:      absJoin(p.Dir, otherFiles(p)...),
 					PkgPath:         pkgpath,
+					ForTest:         pkgpath,
 					Imports:         importMap(append(p.Imports, p.TestImports...)),
 					// TODO(matloob): set errors on the Package to cgoErrors
 				}
@@ -168,6 +170,7 @@ func golistDriverFallback(cfg *Config, words ...string) (*driverResponse, error)
 						GoFiles:         absJoin(p.Dir, p.XTestGoFiles),
 						CompiledGoFiles: absJoin(p.Dir, p.XTestGoFiles),
 						PkgPath:         pkgpath + "_test",
+						ForTest:         pkgpath,
 						Imports:         importMap(p.XTestImports),
 					}
 					// Add to list of packages we need to rewrite imports for to refer to test variants.
@@ -222,7 +225,7 @@ func golistDriverFallback(cfg *Config, words ...string) (*driverResponse, error)
 	for _, pkg := range original {
 		addPackage(pkg, true)
 	}
-	if cfg.Mode < LoadImports || len(deps) == 0 {
+	if cfg.Mode&NeedImports == 0 || len(deps) == 0 {
 		return &response, nil
 	}
 
@@ -248,7 +251,7 @@ func golistDriverFallback(cfg *Config, words ...string) (*driverResponse, error)
 	return &response, nil
 }
 
-func createTestVariants(response *driverResponse, pkgUnderTest, xtestPkg *Package) {
+func createTestVariants(response *DriverResponse, pkgUnderTest, xtestPkg *Package) {
 	allPkgs := make(map[string]*Package)
 	for _, pkg := range response.Packages {
 		allPkgs[pkg.ID] = pkg
@@ -275,6 +278,7 @@ func createTestVariants(response *driverResponse, pkgUnderTest, xtestPkg *Packag
 		// but that's okay. It's only necessary for the Imports map to have a separate identity.
 		testVariant := *p
 		testVariant.ID = fmt.Sprintf("%s [%s.test]", p.ID, pkgUnderTest.ID)
+		testVariant.ForTest = pkgUnderTest.PkgPath
 		testVariant.Imports = make(map[string]*Package)
 		for imp, pkg := range p.Imports {
 			testVariant.Imports[imp] = pkg
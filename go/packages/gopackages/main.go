@@ -132,6 +132,7 @@ func main() {
 	default:
 		log.Fatalf("invalid mode: %s", *mode)
 	}
+	cfg.Mode |= packages.NeedModule
 
 	lpkgs, err := packages.Load(cfg, flag.Args()...)
 	if err != nil {
@@ -181,8 +182,9 @@ func print(lpkg *packages.Package) {
 	}
 	// title
 	var kind string
-	// TODO(matloob): If IsTest is added back print "test command" or
-	// "test package" for packages with IsTest == true.
+	if lpkg.ForTest != "" {
+		kind += "test "
+	}
 	if lpkg.Name == "main" {
 		kind += "command"
 	} else {
@@ -190,6 +192,9 @@ func print(lpkg *packages.Package) {
 	}
 	fmt.Printf("Go %s %q:\n", kind, lpkg.ID) // unique ID
 	fmt.Printf("\tpackage %s\n", lpkg.Name)
+	if lpkg.Module != nil {
+		fmt.Printf("\tmodule %s\n", lpkg.Module)
+	}
 
 	// characterize type info
 	if lpkg.Types == nil {
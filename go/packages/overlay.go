@@ -0,0 +1,47 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// writeOverlay writes the contents of overlay to temporary files, and the
+// file that replaces the files of overlay with them, in the JSON format of
+// the -overlay flag of the go command, whose name it returns.
+// The cleanup function removes all of them.
+func writeOverlay(overlay map[string][]byte) (file string, cleanup func(), err error) {
+	dir, err := ioutil.TempDir("", "gopackages-overlay")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	replace := make(map[string]string)
+	i := 0
+	for filename, contents := range overlay {
+		tmp := filepath.Join(dir, strconv.Itoa(i)+"-"+filepath.Base(filename))
+		i++
+		if err := ioutil.WriteFile(tmp, contents, 0600); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		replace[filename] = tmp
+	}
+	data, err := json.Marshal(struct{ Replace map[string]string }{replace})
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	file = filepath.Join(dir, "overlay.json")
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return file, cleanup, nil
+}
@@ -19,42 +19,88 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sync"
 
 	"golang.org/x/tools/go/gcexportdata"
 )
 
 // A LoadMode specifies the amount of detail to return when loading.
-// Higher-numbered modes cause Load to return more information,
-// but may be slower. Load may return more information than requested.
+// It is a set of the Need flags, which the caller combines to request only
+// the information it uses: the less it requests, the faster Load is.
+// Load may return more information than requested.
 type LoadMode int
 
+const (
+	// NeedName adds Name and PkgPath.
+	NeedName LoadMode = 1 << iota
+
+	// NeedFiles adds GoFiles and OtherFiles.
+	NeedFiles
+
+	// NeedCompiledGoFiles adds CompiledGoFiles.
+	// The build system may need to run preprocessors such as cgo for it.
+	NeedCompiledGoFiles
+
+	// NeedImports adds Imports, and the dependencies of the packages
+	// to the import graph, with the same metadata as the packages
+	// matching the patterns.
+	NeedImports
+
+	// NeedDeps adds the type and syntax information requested by the
+	// other flags to all the packages of the import graph, rather than
+	// to the packages matching the patterns only.
+	NeedDeps
+
+	// NeedTypes adds Types, Fset, and IllTyped.
+	// It implies NeedName, NeedCompiledGoFiles, and NeedImports,
+	// because the packages are type-checked from their dependencies.
+	// The packages matching the patterns are type-checked from source
+	// when it is combined with NeedSyntax or NeedTypesInfo, and their
+	// dependencies only if NeedDeps is set too. The other packages use
+	// type information provided by the build system when possible,
+	// which may fill in the ExportFile field.
+	NeedTypes
+
+	// NeedSyntax adds Syntax. It implies NeedTypes.
+	NeedSyntax
+
+	// NeedTypesInfo adds TypesInfo. It implies NeedSyntax.
+	NeedTypesInfo
+
+	// NeedModule adds Module.
+	NeedModule
+)
+
+// The Load modes are the combinations of the Need flags of the former
+// levels of detail of Load, each of which adds information to the previous
+// one.
 const (
 	// LoadFiles finds the packages and computes their source file lists.
-	// Package fields: ID, Name, Errors, GoFiles, and OtherFiles.
-	LoadFiles LoadMode = iota
+	// Package fields: ID, Name, Errors, GoFiles, CompiledGoFiles, and OtherFiles.
+	LoadFiles = NeedName | NeedFiles | NeedCompiledGoFiles
 
 	// LoadImports adds import information for each package
 	// and its dependencies.
 	// Package fields added: Imports.
-	LoadImports
+	LoadImports = LoadFiles | NeedImports
 
 	// LoadTypes adds type information for package-level
 	// declarations in the packages matching the patterns.
 	// Package fields added: Types, Fset, and IllTyped.
 	// This mode uses type information provided by the build system when
 	// possible, and may fill in the ExportFile field.
-	LoadTypes
+	LoadTypes = LoadImports | NeedTypes
 
 	// LoadSyntax adds typed syntax trees for the packages matching the patterns.
 	// Package fields added: Syntax, and TypesInfo, for direct pattern matches only.
-	LoadSyntax
+	LoadSyntax = LoadTypes | NeedSyntax | NeedTypesInfo
 
 	// LoadAllSyntax adds typed syntax trees for the packages matching the patterns
 	// and all dependencies.
 	// Package fields added: Types, Fset, Illtyped, Syntax, and TypesInfo,
 	// for all packages in the import graph.
-	LoadAllSyntax
+	LoadAllSyntax = LoadSyntax | NeedDeps
 )
 
 // A Config specifies details about how packages should be loaded.
@@ -62,6 +108,7 @@ const (
 // Calls to Load do not modify this struct.
 type Config struct {
 	// Mode controls the level of information returned for each package.
+	// The zero Mode is LoadFiles.
 	Mode LoadMode
 
 	// Context specifies the context for the load operation.
@@ -122,37 +169,20 @@ type Config struct {
 	Tests bool
 
 	// Overlay provides a mapping of absolute file paths to file contents.
-	// If the file  with the given path already exists, the parser will use the
-	// alternative file contents provided by the map.
-	//
-	// The Package.Imports map may not include packages that are imported only
-	// by the alternative file contents provided by Overlay. This may cause
-	// type-checking to fail.
+	// The parser and the drivers use the alternative file contents provided
+	// by the map in place of the file with the given path, whether it
+	// exists or not, so the overlay may add imports and files to packages.
+	// The go list driver passes the overlay to the go command through the
+	// temporary files of its -overlay flag, which requires go1.16 or later;
+	// with an older go command, only the parser sees the overlay, and the
+	// Package.Imports map may not include packages that are imported only
+	// by the alternative file contents.
 	Overlay map[string][]byte
 }
 
 // driver is the type for functions that query the build system for the
 // packages named by the patterns.
-type driver func(cfg *Config, patterns ...string) (*driverResponse, error)
-
-// driverResponse contains the results for a driver query.
-type driverResponse struct {
-	// Sizes, if not nil, is the types.Sizes to use when type checking.
-	Sizes *types.StdSizes
-
-	// Roots is the set of package IDs that make up the root packages.
-	// We have to encode this separately because when we encode a single package
-	// we cannot know if it is one of the roots as that requires knowledge of the
-	// graph it is part of.
-	Roots []string `json:",omitempty"`
-
-	// Packages is the full set of packages in the graph.
-	// The packages are not connected into a graph.
-	// The Imports if populated will be stubs that only have their ID set.
-	// Imports will be connected and then type and syntax information added in a
-	// later pass (see refine).
-	Packages []*Package
-}
+type driver func(cfg *Config, patterns ...string) (*DriverResponse, error)
 
 // Load loads and returns the Go packages named by the given patterns.
 //
@@ -179,13 +209,16 @@ func Load(cfg *Config, patterns ...string) ([]*Package, error) {
 }
 
 // defaultDriver is a driver that looks for an external driver binary, and if
-// it does not find it falls back to the built in go list driver.
-func defaultDriver(cfg *Config, patterns ...string) (*driverResponse, error) {
-	driver := findExternalDriver(cfg)
-	if driver == nil {
-		driver = goListDriver
+// it does not find it, or the external driver does not handle the patterns,
+// falls back to the built in go list driver.
+func defaultDriver(cfg *Config, patterns ...string) (*DriverResponse, error) {
+	if driver := findExternalDriver(cfg); driver != nil {
+		response, err := driver(cfg, patterns...)
+		if err != nil || !response.NotHandled {
+			return response, err
+		}
 	}
-	return driver(cfg, patterns...)
+	return goListDriver(cfg, patterns...)
 }
 
 // A Package describes a loaded Go package.
@@ -204,6 +237,10 @@ type Package struct {
 	// PkgPath is the package path as used by the go/types package.
 	PkgPath string
 
+	// ForTest is the package path of the package under test, for test
+	// variants such as "p [p.test]" and "p_test [p.test]"; otherwise empty.
+	ForTest string
+
 	// Errors contains any errors encountered querying the metadata
 	// of the package, or while parsing or type-checking its files.
 	Errors []Error
@@ -229,9 +266,9 @@ type Package struct {
 	Imports map[string]*Package
 
 	// Types provides type information for the package.
-	// Modes LoadTypes and above set this field for packages matching the
-	// patterns; type information for dependencies may be missing or incomplete.
-	// Mode LoadAllSyntax sets this field for all packages, including dependencies.
+	// NeedTypes sets this field for packages matching the patterns;
+	// type information for dependencies may be missing or incomplete,
+	// unless NeedDeps sets it for all packages, including dependencies.
 	Types *types.Package
 
 	// Fset provides position information for Types, TypesInfo, and Syntax.
@@ -244,18 +281,61 @@ type Package struct {
 
 	// Syntax is the package's syntax trees, for the files listed in CompiledGoFiles.
 	//
-	// Mode LoadSyntax sets this field for packages matching the patterns.
-	// Mode LoadAllSyntax sets this field for all packages, including dependencies.
+	// NeedSyntax sets this field for packages matching the patterns,
+	// and with NeedDeps for all packages, including dependencies.
 	Syntax []*ast.File
 
 	// TypesInfo provides type information about the package's syntax trees.
 	// It is set only when Syntax is set.
 	TypesInfo *types.Info
+
+	// Module is the module of the package, if the build system uses
+	// modules and NeedModule is set. It tells the packages of the main
+	// module, which the user works on, from those of its dependencies,
+	// which the go command reads from the module cache or from the
+	// directories that replace them.
+	Module *Module
+}
+
+// A Module describes a module, as reported by the go list -m command.
+type Module struct {
+	Path      string       // module path
+	Version   string       // module version
+	Replace   *Module      // replaced by this module
+	Main      bool         // is this the main module?
+	Indirect  bool         // is this module only an indirect dependency of main module?
+	Dir       string       // directory holding files for this module, if any
+	GoMod     string       // path to go.mod file for this module, if any
+	GoVersion string       // go version used in module
+	Error     *ModuleError // error loading module
+}
+
+// A ModuleError describes an error loading information about a module.
+type ModuleError struct {
+	Err string // the error itself
+}
+
+// String returns the module path and version of m, and those of its
+// replacement, in the format of go list -m.
+func (m *Module) String() string {
+	s := m.Path
+	if m.Version != "" {
+		s += " " + m.Version
+	}
+	if r := m.Replace; r != nil {
+		s += " => " + r.Path
+		if r.Version != "" {
+			s += " " + r.Version
+		}
+	}
+	return s
 }
 
 // An Error describes a problem with a package's metadata, syntax, or types.
+// Each package has its own errors, so that a tool can report them with the
+// package, or at their positions in its files.
 type Error struct {
-	Pos  string // "file:line:col" or "file:line" or "" or "-"
+	Pos  string // "file:line:col" or "file:line" or "" or "-", with an absolute file
 	Msg  string
 	Kind ErrorKind
 }
@@ -267,9 +347,9 @@ type ErrorKind int
 
 const (
 	UnknownError ErrorKind = iota
-	ListError
-	ParseError
-	TypeError
+	ListError              // from the driver, such as a missing import
+	ParseError             // from the parser
+	TypeError              // from the type checker
 )
 
 func (err Error) Error() string {
@@ -289,12 +369,14 @@ type flatPackage struct {
 	ID              string
 	Name            string            `json:",omitempty"`
 	PkgPath         string            `json:",omitempty"`
+	ForTest         string            `json:",omitempty"`
 	Errors          []Error           `json:",omitempty"`
 	GoFiles         []string          `json:",omitempty"`
 	CompiledGoFiles []string          `json:",omitempty"`
 	OtherFiles      []string          `json:",omitempty"`
 	ExportFile      string            `json:",omitempty"`
 	Imports         map[string]string `json:",omitempty"`
+	Module          *Module           `json:",omitempty"`
 }
 
 // MarshalJSON returns the Package in its JSON form.
@@ -311,11 +393,13 @@ func (p *Package) MarshalJSON() ([]byte, error) {
 		ID:              p.ID,
 		Name:            p.Name,
 		PkgPath:         p.PkgPath,
+		ForTest:         p.ForTest,
 		Errors:          p.Errors,
 		GoFiles:         p.GoFiles,
 		CompiledGoFiles: p.CompiledGoFiles,
 		OtherFiles:      p.OtherFiles,
 		ExportFile:      p.ExportFile,
+		Module:          p.Module,
 	}
 	if len(p.Imports) > 0 {
 		flat.Imports = make(map[string]string, len(p.Imports))
@@ -337,11 +421,13 @@ func (p *Package) UnmarshalJSON(b []byte) error {
 		ID:              flat.ID,
 		Name:            flat.Name,
 		PkgPath:         flat.PkgPath,
+		ForTest:         flat.ForTest,
 		Errors:          flat.Errors,
 		GoFiles:         flat.GoFiles,
 		CompiledGoFiles: flat.CompiledGoFiles,
 		OtherFiles:      flat.OtherFiles,
 		ExportFile:      flat.ExportFile,
+		Module:          flat.Module,
 	}
 	if len(flat.Imports) > 0 {
 		p.Imports = make(map[string]*Package, len(flat.Imports))
@@ -360,7 +446,7 @@ type loaderPackage struct {
 	importErrors map[string]error // maps each bad import to its error
 	loadOnce     sync.Once
 	color        uint8 // for cycle detection
-	needsrc      bool  // load from source (Mode >= LoadTypes)
+	needsrc      bool  // load from source (NeedSyntax, of a root or with NeedDeps)
 	needtypes    bool  // type information is either requested or depended on
 	initial      bool  // package was matched by a pattern
 }
@@ -390,12 +476,27 @@ func newLoader(cfg *Config) *loader {
 		}
 	}
 
-	if ld.Mode >= LoadTypes {
+	// Add the information that the requested information depends on.
+	// The zero Mode is LoadFiles, as it was before the Need flags.
+	if ld.Mode == 0 {
+		ld.Mode = LoadFiles
+	}
+	if ld.Mode&NeedTypesInfo != 0 {
+		ld.Mode |= NeedSyntax
+	}
+	if ld.Mode&NeedSyntax != 0 {
+		ld.Mode |= NeedTypes
+	}
+	if ld.Mode&NeedTypes != 0 {
+		ld.Mode |= NeedName | NeedCompiledGoFiles | NeedImports
+	}
+
+	if ld.Mode&NeedTypes != 0 {
 		if ld.Fset == nil {
 			ld.Fset = token.NewFileSet()
 		}
 
-		// ParseFile is required even in LoadTypes mode
+		// ParseFile is required even without NeedSyntax
 		// because we load source if export data is missing.
 		if ld.ParseFile == nil {
 			ld.ParseFile = func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
@@ -428,10 +529,10 @@ func (ld *loader) refine(roots []string, list ...*Package) ([]*Package, error) {
 		}
 		lpkg := &loaderPackage{
 			Package: pkg,
-			needtypes: ld.Mode >= LoadAllSyntax ||
-				ld.Mode >= LoadTypes && rootIndex >= 0,
-			needsrc: ld.Mode >= LoadAllSyntax ||
-				ld.Mode >= LoadSyntax && rootIndex >= 0 ||
+			needtypes: ld.Mode&NeedTypes != 0 &&
+				(ld.Mode&NeedDeps != 0 || rootIndex >= 0),
+			needsrc: ld.Mode&NeedSyntax != 0 &&
+				(ld.Mode&NeedDeps != 0 || rootIndex >= 0) ||
 				pkg.ExportFile == "" && pkg.PkgPath != "unsafe",
 		}
 		ld.pkgs[lpkg.ID] = lpkg
@@ -509,7 +610,7 @@ func (ld *loader) refine(roots []string, list ...*Package) ([]*Package, error) {
 		return lpkg.needsrc
 	}
 
-	if ld.Mode < LoadImports {
+	if ld.Mode&NeedImports == 0 {
 		//we do this to drop the stub import packages that we are not even going to try to resolve
 		for _, lpkg := range initial {
 			lpkg.Imports = nil
@@ -530,7 +631,7 @@ func (ld *loader) refine(roots []string, list ...*Package) ([]*Package, error) {
 	}
 	// Load type data if needed, starting at
 	// the initial packages (roots of the import DAG).
-	if ld.Mode >= LoadTypes {
+	if ld.Mode&NeedTypes != 0 {
 		var wg sync.WaitGroup
 		for _, lpkg := range initial {
 			wg.Add(1)
@@ -551,8 +652,9 @@ func (ld *loader) refine(roots []string, list ...*Package) ([]*Package, error) {
 
 // loadRecursive loads the specified package and its dependencies,
 // recursively, in parallel, in topological order.
+// At most GOMAXPROCS files are parsed, or packages type-checked, at once.
 // It is atomic and idempotent.
-// Precondition: ld.Mode >= LoadTypes.
+// Precondition: ld.Mode&NeedTypes != 0.
 func (ld *loader) loadRecursive(lpkg *loaderPackage) {
 	lpkg.loadOnce.Do(func() {
 		// Load the direct dependencies, in parallel.
@@ -574,7 +676,7 @@ func (ld *loader) loadRecursive(lpkg *loaderPackage) {
 // loadPackage loads the specified package.
 // It must be called only once per Package,
 // after immediate dependencies are loaded.
-// Precondition: ld.Mode >= LoadTypes.
+// Precondition: ld.Mode&NeedTypes != 0.
 func (ld *loader) loadPackage(lpkg *loaderPackage) {
 	if lpkg.PkgPath == "unsafe" {
 		// Fill in the blanks to avoid surprises.
@@ -702,12 +804,16 @@ func (ld *loader) loadPackage(lpkg *loaderPackage) {
 		// Type-check bodies of functions only in non-initial packages.
 		// Example: for import graph A->B->C and initial packages {A,C},
 		// we can ignore function bodies in B.
-		IgnoreFuncBodies: ld.Mode < LoadAllSyntax && !lpkg.initial,
+		IgnoreFuncBodies: ld.Mode&NeedDeps == 0 && !lpkg.initial,
 
 		Error: appendError,
 		Sizes: ld.sizes,
 	}
-	types.NewChecker(tc, ld.Fset, lpkg.Types, lpkg.TypesInfo).Files(lpkg.Syntax)
+	func() {
+		cpuLimit <- true              // wait
+		defer func() { <-cpuLimit }() // signal, even if the checker panics
+		types.NewChecker(tc, ld.Fset, lpkg.Types, lpkg.TypesInfo).Files(lpkg.Syntax)
+	}()
 
 	lpkg.importErrors = nil // no longer needed
 
@@ -755,6 +861,13 @@ func (f importerFunc) Import(path string) (*types.Package, error) { return f(pat
 // the number of parallel I/O calls per process.
 var ioLimit = make(chan bool, 20)
 
+// And another to limit the parsing and type checking that run in parallel
+// to the number of processors that run Go code at once: more would not run
+// sooner, but would hold more syntax trees in memory.
+// The loader never acquires it while it holds it, so that it cannot
+// deadlock.
+var cpuLimit = make(chan bool, runtime.GOMAXPROCS(0))
+
 // parseFiles reads and parses the Go source files and returns the ASTs
 // of the ones that could be at least partially parsed, along with a
 // list of I/O and parse errors encountered.
@@ -782,12 +895,14 @@ func (ld *loader) parseFiles(filenames []string) ([]*ast.File, []error) {
 			if src == nil {
 				src, err = ioutil.ReadFile(filename)
 			}
+			<-ioLimit // signal
 			if err != nil {
 				parsed[i], errors[i] = nil, err
 			} else {
+				cpuLimit <- true // wait
 				parsed[i], errors[i] = ld.ParseFile(ld.Fset, filename, src)
+				<-cpuLimit // signal
 			}
-			<-ioLimit // signal
 			wg.Done()
 		}(i, file)
 	}
@@ -819,6 +934,10 @@ func (ld *loader) parseFiles(filenames []string) ([]*ast.File, []error) {
 // the same file.
 //
 func sameFile(x, y string) bool {
+	if x == y {
+		// The file of an overlay need not exist.
+		return true
+	}
 	if filepath.Base(x) == filepath.Base(y) { // (optimisation)
 		if xi, err := os.Stat(x); err == nil {
 			if yi, err := os.Stat(y); err == nil {
@@ -931,6 +1050,8 @@ func (ld *loader) loadFromExportData(lpkg *loaderPackage) (*types.Package, error
 	return tpkg, nil
 }
 
+// usesExportData reports whether the packages of cfg are type-checked from
+// the export data of their dependencies, rather than from their syntax.
 func usesExportData(cfg *Config) bool {
-	return LoadTypes <= cfg.Mode && cfg.Mode < LoadAllSyntax
+	return cfg.Mode&NeedTypes != 0 && (cfg.Mode&NeedDeps == 0 || cfg.Mode&NeedSyntax == 0)
 }
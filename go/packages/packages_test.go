@@ -9,17 +9,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"go/ast"
+	"go/build"
 	constantpkg "go/constant"
 	"go/parser"
 	"go/token"
 	"go/types"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/go/packages/packagestest"
@@ -29,6 +33,15 @@ import (
 // for versions of go list before Go 1.10.4.
 var usesOldGolist = false
 
+func TestMain(m *testing.M) {
+	// The test binary is also the external driver of TestExternalDriver.
+	if mode := os.Getenv("GOPACKAGES_TEST_DRIVER"); mode != "" {
+		runTestDriver(mode)
+		return
+	}
+	os.Exit(m.Run())
+}
+
 // TODO(adonovan): more test cases to write:
 //
 // - When the tests fail, make them print a 'cd & load' command
@@ -277,6 +290,38 @@ func testLoadImportsTestVariants(t *testing.T, exporter packagestest.Exporter) {
 	}
 }
 
+func TestForTest(t *testing.T) { packagestest.TestAll(t, testForTest) }
+func testForTest(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go":      `package a`,
+			"a/a_test.go": `package a`,
+			"a/x_test.go": `package a_test; import _ "golang.org/fake/a"`,
+		}}})
+	defer exported.Cleanup()
+
+	exported.Config.Mode = packages.LoadFiles
+	exported.Config.Tests = true
+	initial, err := packages.Load(exported.Config, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, pkg := range initial {
+		got[pkg.ID] = pkg.ForTest
+	}
+	want := map[string]string{
+		"golang.org/fake/a":                               "",
+		"golang.org/fake/a [golang.org/fake/a.test]":      "golang.org/fake/a",
+		"golang.org/fake/a_test [golang.org/fake/a.test]": "golang.org/fake/a",
+		"golang.org/fake/a.test":                          "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got the ForTest of the packages %v, want %v", got, want)
+	}
+}
+
 func TestLoadAbsolutePath(t *testing.T) {
 	exported := packagestest.Export(t, packagestest.GOPATH, []packagestest.Module{{
 		Name: "golang.org/gopatha",
@@ -557,6 +602,67 @@ func testLoadTypes(t *testing.T, exporter packagestest.Exporter) {
 	}
 }
 
+func TestLoadNeedFlags(t *testing.T) { packagestest.TestAll(t, testLoadNeedFlags) }
+func testLoadNeedFlags(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": `package a; import "golang.org/fake/b"; const A = "a" + b.B`,
+			"b/b.go": `package b; const B = "b"`,
+		}}})
+	defer exported.Cleanup()
+
+	load := func(mode packages.LoadMode) *packages.Package {
+		exported.Config.Mode = mode
+		initial, err := packages.Load(exported.Config, "golang.org/fake/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return initial[0]
+	}
+
+	// NeedName only lists the package.
+	a := load(packages.NeedName)
+	if a.Name != "a" || a.PkgPath != "golang.org/fake/a" {
+		t.Errorf("NeedName: got package %s %s, want a golang.org/fake/a", a.Name, a.PkgPath)
+	}
+	if a.Imports != nil || a.Types != nil {
+		t.Errorf("NeedName: got imports or types, want none")
+	}
+
+	// NeedImports adds the dependencies.
+	a = load(packages.NeedName | packages.NeedImports)
+	if b := a.Imports["golang.org/fake/b"]; b == nil || b.Name != "b" {
+		t.Errorf("NeedImports: got imports %v, want golang.org/fake/b", a.Imports)
+	}
+
+	// NeedTypesInfo implies the syntax and the types of the package,
+	// but not of its dependencies without NeedDeps.
+	a = load(packages.NeedTypesInfo)
+	if a.Types == nil || a.Syntax == nil || a.TypesInfo == nil {
+		t.Errorf("NeedTypesInfo: got types %v, syntax %v, info %v, want all", a.Types, a.Syntax, a.TypesInfo)
+	}
+	if b := a.Imports["golang.org/fake/b"]; b == nil || b.Syntax != nil && !usesOldGolist {
+		t.Errorf("NeedTypesInfo: got syntax of golang.org/fake/b, want none")
+	}
+	a = load(packages.NeedTypesInfo | packages.NeedDeps)
+	if b := a.Imports["golang.org/fake/b"]; b == nil || b.Syntax == nil {
+		t.Errorf("NeedTypesInfo|NeedDeps: got no syntax of golang.org/fake/b")
+	}
+
+	// NeedModule adds the module, in module mode.
+	a = load(packages.NeedName | packages.NeedModule)
+	if exporter.Name() == "Modules" && !usesOldGolist {
+		if a.Module == nil || a.Module.Path != "golang.org/fake" || !a.Module.Main {
+			t.Errorf("NeedModule: got module %v, want the main module golang.org/fake", a.Module)
+		} else if dir := filepath.Dir(filepath.Dir(a.GoFiles[0])); a.Module.Dir != dir || a.Module.GoMod != filepath.Join(dir, "go.mod") {
+			t.Errorf("NeedModule: got module directory %s and go.mod %s, want %s", a.Module.Dir, a.Module.GoMod, dir)
+		}
+	} else if a.Module != nil {
+		t.Errorf("NeedModule: got module %v in GOPATH mode", a.Module)
+	}
+}
+
 func TestLoadSyntaxOK(t *testing.T) { packagestest.TestAll(t, testLoadSyntaxOK) }
 func testLoadSyntaxOK(t *testing.T, exporter packagestest.Exporter) {
 	exported := packagestest.Export(t, exporter, []packagestest.Module{{
@@ -803,6 +909,77 @@ func testParseFileModifyAST(t *testing.T, exporter packagestest.Exporter) {
 	}
 }
 
+// initialGOMAXPROCS is the value of GOMAXPROCS when the loader set its
+// limits, before the -cpu flag of the tests changes it.
+var initialGOMAXPROCS = runtime.GOMAXPROCS(0)
+
+func TestParseFileParallel(t *testing.T) { packagestest.TestAll(t, testParseFileParallel) }
+func testParseFileParallel(t *testing.T, exporter packagestest.Exporter) {
+	files := make(map[string]interface{})
+	for _, pkg := range []string{"a", "b", "c", "d"} {
+		for i := 0; i < 4; i++ {
+			files[fmt.Sprintf("%s/%s%d.go", pkg, pkg, i)] = fmt.Sprintf("package %s; const C%d = %d", pkg, i, i)
+		}
+	}
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name:  "golang.org/fake",
+		Files: files,
+	}})
+	defer exported.Cleanup()
+
+	// The loader parses the files in parallel, but no more of them at
+	// once than GOMAXPROCS. Each call of ParseFile waits for another to
+	// start, unless there can be only one, so that the calls overlap if
+	// the loader makes them in parallel.
+	parallel := initialGOMAXPROCS > 1
+	var mu sync.Mutex
+	var parsing, maxParsing int
+	overlapped, timedOut := make(chan struct{}), make(chan struct{})
+	defer time.AfterFunc(time.Minute, func() { close(timedOut) }).Stop()
+	exported.Config.Mode = packages.LoadAllSyntax
+	exported.Config.ParseFile = func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+		mu.Lock()
+		parsing++
+		if parsing > maxParsing {
+			maxParsing = parsing
+			if maxParsing == 2 {
+				close(overlapped)
+			}
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			parsing--
+			mu.Unlock()
+		}()
+		if parallel {
+			select {
+			case <-overlapped:
+			case <-timedOut:
+			}
+		}
+		return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+	}
+	initial, err := packages.Load(exported.Config, "golang.org/fake/...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial) != 4 {
+		t.Fatalf("got %d packages, want 4", len(initial))
+	}
+	for _, pkg := range initial {
+		if len(pkg.Syntax) != 4 || pkg.Types == nil || pkg.Types.Scope().Lookup("C3") == nil {
+			t.Errorf("%s: got %d files and types %v, want 4 files and C3", pkg, len(pkg.Syntax), pkg.Types)
+		}
+	}
+	if maxParsing > initialGOMAXPROCS {
+		t.Errorf("parsed %d files at once, want at most GOMAXPROCS (%d)", maxParsing, initialGOMAXPROCS)
+	}
+	if parallel && maxParsing < 2 {
+		t.Errorf("parsed the files one at a time, want them parsed in parallel")
+	}
+}
+
 func TestOverlay(t *testing.T) { packagestest.TestAll(t, testOverlay) }
 func testOverlay(t *testing.T, exporter packagestest.Exporter) {
 	exported := packagestest.Export(t, exporter, []packagestest.Module{{
@@ -814,6 +991,7 @@ func testOverlay(t *testing.T, exporter packagestest.Exporter) {
 			"d/d.go": `package d; const D = "d"`,
 		}}})
 	defer exported.Cleanup()
+	cdir := filepath.Dir(exported.File("golang.org/fake", "c/c.go"))
 
 	for i, test := range []struct {
 		overlay  map[string][]byte
@@ -825,7 +1003,14 @@ func testOverlay(t *testing.T, exporter packagestest.Exporter) {
 		{map[string][]byte{exported.File("golang.org/fake", "c/c.go"): []byte(`package c; const C = "C"`)}, `"abC"`, nil},
 		{map[string][]byte{exported.File("golang.org/fake", "b/b.go"): []byte(`package b; import "golang.org/fake/c"; const B = "B" + c.C`)}, `"aBc"`, nil},
 		{map[string][]byte{exported.File("golang.org/fake", "b/b.go"): []byte(`package b; import "d"; const B = "B" + d.D`)}, `unknown`,
-			[]string{`could not import d (no metadata for d)`}},
+			[]string{`could not import d (invalid package name: "")`}},
+		// The overlay adds an import to b.
+		{map[string][]byte{exported.File("golang.org/fake", "b/b.go"): []byte(`package b; import "golang.org/fake/d"; const B = "B" + d.D`)}, `"aBd"`, nil},
+		// The overlay adds a file to c.
+		{map[string][]byte{
+			filepath.Join(cdir, "c.go"):  []byte(`package c; const C = "c" + C2`),
+			filepath.Join(cdir, "c2.go"): []byte(`package c; const C2 = "2"`),
+		}, `"abc2"`, nil},
 	} {
 		exported.Config.Overlay = test.overlay
 		exported.Config.Mode = packages.LoadAllSyntax
@@ -850,7 +1035,14 @@ func testOverlay(t *testing.T, exporter packagestest.Exporter) {
 		// Check errors.
 		var errors []packages.Error
 		packages.Visit(initial, nil, func(pkg *packages.Package) {
-			errors = append(errors, pkg.Errors...)
+			for _, err := range pkg.Errors {
+				// The message of the go command about the unknown
+				// package d varies with its version and mode.
+				if pkg.PkgPath == "d" && err.Kind == packages.ListError {
+					continue
+				}
+				errors = append(errors, err)
+			}
 		})
 		if errs := errorMessages(errors); !reflect.DeepEqual(errs, test.wantErrs) {
 			t.Errorf("%d. got errors %s, want %s", i, errs, test.wantErrs)
@@ -943,6 +1135,41 @@ import (
 	}
 }
 
+func TestListErrors(t *testing.T) { packagestest.TestAll(t, testListErrors) }
+func testListErrors(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": "package a\n\nimport \"golang.org/fake/nonexistent\"\n",
+		}}})
+	defer exported.Cleanup()
+
+	exported.Config.Mode = packages.LoadImports
+	initial, err := packages.Load(exported.Config, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The error of the missing import is a ListError of one of the
+	// packages, at the import in a.go.
+	want := exported.File("golang.org/fake", "a/a.go") + ":3:8"
+	var found bool
+	packages.Visit(initial, nil, func(pkg *packages.Package) {
+		for _, err := range pkg.Errors {
+			if err.Pos == want && err.Kind == packages.ListError {
+				found = true
+			}
+		}
+	})
+	if !found {
+		var errs []packages.Error
+		packages.Visit(initial, nil, func(pkg *packages.Package) {
+			errs = append(errs, pkg.Errors...)
+		})
+		t.Errorf("got errors %v, want a list error at %s", errs, want)
+	}
+}
+
 func TestAbsoluteFilenames(t *testing.T) { packagestest.TestAll(t, testAbsoluteFilenames) }
 func testAbsoluteFilenames(t *testing.T, exporter packagestest.Exporter) {
 	exported := packagestest.Export(t, exporter, []packagestest.Module{{
@@ -1024,9 +1251,10 @@ func testContains(t *testing.T, exporter packagestest.Exporter) {
 	exported := packagestest.Export(t, exporter, []packagestest.Module{{
 		Name: "golang.org/fake",
 		Files: map[string]interface{}{
-			"a/a.go": `package a; import "golang.org/fake/b"`,
-			"b/b.go": `package b; import "golang.org/fake/c"`,
-			"c/c.go": `package c`,
+			"a/a.go":  `package a; import "golang.org/fake/b"`,
+			"b/b.go":  `package b; import "golang.org/fake/c"`,
+			"c/c.go":  `package c`,
+			"c/c2.go": `package c`,
 		}}})
 	defer exported.Cleanup()
 	bFile := exported.File("golang.org/fake", "b/b.go")
@@ -1045,6 +1273,20 @@ func testContains(t *testing.T, exporter packagestest.Exporter) {
 	if graph != wantGraph {
 		t.Errorf("wrong import graph: got <<%s>>, want <<%s>>", graph, wantGraph)
 	}
+
+	// The files of a directory, even relative to Config.Dir, match
+	// their package once.
+	c2File, err := filepath.Rel(exported.Config.Dir, exported.File("golang.org/fake", "c/c2.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial, err = packages.Load(exported.Config, "file="+exported.File("golang.org/fake", "c/c.go"), "file="+c2File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial) != 1 || initial[0].ID != "golang.org/fake/c" {
+		t.Errorf("got packages %v, want golang.org/fake/c", initial)
+	}
 }
 
 // This test ensures that the effective GOARCH variable in the
@@ -1119,12 +1361,15 @@ func testName(t *testing.T, exporter packagestest.Exporter) {
 		Name: "golang.org/fake",
 		Files: map[string]interface{}{
 			"a/needle/needle.go":       `package needle; import "golang.org/fake/c"`,
-			"b/needle/needle.go":       `package needle;`,
+			"b/needle/needle.go":       `package needle; import "golang.org/fake/d"`,
 			"c/c.go":                   `package c;`,
+			"d/d.go":                   `package needle;`,
 			"irrelevant/irrelevant.go": `package irrelevant;`,
 		}}})
 	defer exported.Cleanup()
 
+	// The package d is named needle too, but it is only a dependency of a
+	// match, so it is not a match itself.
 	exported.Config.Mode = packages.LoadImports
 	initial, err := packages.Load(exported.Config, "name=needle")
 	if err != nil {
@@ -1135,7 +1380,9 @@ func testName(t *testing.T, exporter packagestest.Exporter) {
 * golang.org/fake/a/needle
 * golang.org/fake/b/needle
   golang.org/fake/c
+  golang.org/fake/d
   golang.org/fake/a/needle -> golang.org/fake/c
+  golang.org/fake/b/needle -> golang.org/fake/d
 `[1:]
 	if graph != wantGraph {
 		t.Errorf("wrong import graph: got <<%s>>, want <<%s>>", graph, wantGraph)
@@ -1530,6 +1777,256 @@ func testBasicXTest(t *testing.T, exporter packagestest.Exporter) {
 	}
 }
 
+func TestExternalDriver(t *testing.T) {
+	exported := packagestest.Export(t, packagestest.GOPATH, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": `package a`,
+		}}})
+	defer exported.Cleanup()
+
+	exported.Config.Mode = packages.LoadFiles
+	exported.Config.Overlay = map[string][]byte{"/fake/x.go": []byte(`package x`)}
+	env := append(exported.Config.Env, "GOPACKAGESDRIVER="+os.Args[0])
+
+	// The driver handles the patterns: the package that it returns has
+	// the files of the overlay of its request.
+	exported.Config.Env = append(env, "GOPACKAGES_TEST_DRIVER=handle")
+	initial, err := packages.Load(exported.Config, "example.com/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial) != 1 || initial[0].ID != "example.com/x" || !reflect.DeepEqual(initial[0].GoFiles, []string{"/fake/x.go"}) {
+		t.Errorf("got packages %v, want example.com/x with the file /fake/x.go", initial)
+	}
+
+	// The driver declines: go list loads the package.
+	exported.Config.Env = append(env, "GOPACKAGES_TEST_DRIVER=decline")
+	initial, err = packages.Load(exported.Config, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial) != 1 || initial[0].ID != "golang.org/fake/a" || initial[0].Name != "a" {
+		t.Errorf("got packages %v, want golang.org/fake/a", initial)
+	}
+}
+
+func TestMetadataCache(t *testing.T) { packagestest.TestAll(t, testMetadataCache) }
+func testMetadataCache(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": `package a; import "golang.org/fake/b"`,
+			"b/b.go": `package b`,
+			"c/c.go": `package c; import "fmt"; var _ = fmt.Sprint`,
+		}}})
+	defer exported.Cleanup()
+
+	cacheDir := filepath.Join(exported.Temp(), "cache")
+	exported.Config.Mode = packages.LoadImports
+	exported.Config.Env = append(exported.Config.Env, "GOPACKAGESCACHE="+cacheDir)
+	load := func() *packages.Package {
+		initial, err := packages.Load(exported.Config, "golang.org/fake/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return initial[0].Imports["golang.org/fake/b"]
+	}
+
+	// The first load writes the entry of the cache.
+	if b := load(); b == nil || b.Name != "b" {
+		t.Fatalf("got package %v, want golang.org/fake/b", b)
+	}
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("got the cache entries %v, want one", entries)
+	}
+
+	// The second load reads it: mark its package b to tell.
+	data, err := ioutil.ReadFile(entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte(`"Name":"b"`), []byte(`"Name":"cached"`), 1)
+	if err := ioutil.WriteFile(entries[0], data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if b := load(); b == nil || b.Name != "cached" {
+		t.Errorf("got package %v, want the package of the cache", b)
+	}
+
+	// The variables of the environment that the go command ignores do not
+	// change the key of the entry, and the others do.
+	env := exported.Config.Env
+	exported.Config.Env = append(env, "EDITOR=ed")
+	if b := load(); b == nil || b.Name != "cached" {
+		t.Errorf("got package %v with another editor, want the package of the cache", b)
+	}
+	exported.Config.Env = append(env, "GOFLAGS=-tags=cachetest")
+	if b := load(); b == nil || b.Name != "b" {
+		t.Errorf("got package %v with other build tags, want golang.org/fake/b", b)
+	}
+	exported.Config.Env = env
+
+	// Adding a file to b invalidates the entry.
+	b2 := filepath.Join(filepath.Dir(exported.File("golang.org/fake", "b/b.go")), "b2.go")
+	if err := ioutil.WriteFile(b2, []byte(`package b`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if b := load(); b == nil || b.Name != "b" {
+		t.Errorf("got package %v, want golang.org/fake/b", b)
+	} else if len(b.GoFiles) != 2 {
+		t.Errorf("got files %v, want b.go and b2.go", b.GoFiles)
+	}
+
+	// The entry is invalidated by a change of the content of a file, even
+	// if its size and modification time are unchanged.
+	markCache := func() {
+		entries, err := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			data, err := ioutil.ReadFile(entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data = bytes.Replace(data, []byte(`"Name":"b"`), []byte(`"Name":"cached"`), -1)
+			if err := ioutil.WriteFile(entry, data, 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	markCache()
+	fi, err := os.Stat(b2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b2, []byte(`package c`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(b2, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if b := load(); b == nil || b.Name == "cached" {
+		t.Errorf("got package %v of the cache, after a file of b changed", b)
+	}
+	if err := ioutil.WriteFile(b2, []byte(`package b`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// The entry is invalidated by a package that a vendor directory of a
+	// parent directory of a provides for the import path of b.
+	if exporter == packagestest.GOPATH {
+		load()
+		markCache()
+		vendored := filepath.Join(filepath.Dir(filepath.Dir(exported.File("golang.org/fake", "a/a.go"))), "vendor", "golang.org", "fake", "b")
+		if err := os.MkdirAll(vendored, 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(vendored, "b.go"), []byte(`package vb`), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if b := load(); b == nil || b.Name != "vb" {
+			t.Errorf("got package %v, want the vendored package of golang.org/fake/b", b)
+		}
+	}
+
+	// The directories of the packages of GOROOT are not stamped.
+	if _, err := packages.Load(exported.Config, "golang.org/fake/c"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entryC string
+	for _, entry := range entries {
+		data, err := ioutil.ReadFile(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(data, []byte(`"golang.org/fake/c"`)) {
+			continue
+		}
+		entryC = entry
+		var stamps struct{ Stamps map[string]string }
+		if err := json.Unmarshal(data, &stamps); err != nil {
+			t.Fatal(err)
+		}
+		if fmtDir := filepath.Join(build.Default.GOROOT, "src", "fmt"); stamps.Stamps[fmtDir] != "" {
+			t.Errorf("the entry of golang.org/fake/c has the stamp of %s", fmtDir)
+		}
+	}
+	if entryC == "" {
+		t.Fatalf("no entry of golang.org/fake/c in %v", entries)
+	}
+
+	// Writing an entry evicts those that were not used for days.
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	if err := os.Chtimes(entryC, old, old); err != nil {
+		t.Fatal(err)
+	}
+	exported.Config.Env = append(env, "GOFLAGS=-tags=evict")
+	load()
+	exported.Config.Env = env
+	if _, err := os.Stat(entryC); !os.IsNotExist(err) {
+		t.Errorf("the entry of golang.org/fake/c, unused for 30 days, was not evicted: %v", err)
+	}
+
+	// The patterns that walk directories are not cached.
+	before, _ := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	if _, err := packages.Load(exported.Config, "golang.org/fake/..."); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	if len(after) != len(before) {
+		t.Errorf("got %d cache entries after loading golang.org/fake/..., want %d", len(after), len(before))
+	}
+}
+
+// runTestDriver is the external driver of TestExternalDriver, which declines
+// its requests in the decline mode, and otherwise returns a package for each
+// pattern, with the files of the overlay.
+func runTestDriver(mode string) {
+	var req packages.DriverRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var resp packages.DriverResponse
+	if mode == "decline" {
+		resp.NotHandled = true
+	} else {
+		var files []string
+		for file := range req.Overlay {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		args := os.Args[1:]
+		for i, arg := range args {
+			if arg == "--" {
+				args = args[i+1:]
+				break
+			}
+		}
+		for _, pattern := range args {
+			resp.Roots = append(resp.Roots, pattern)
+			resp.Packages = append(resp.Packages, &packages.Package{
+				ID:              pattern,
+				Name:            filepath.Base(pattern),
+				PkgPath:         pattern,
+				GoFiles:         files,
+				CompiledGoFiles: files,
+			})
+		}
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(&resp); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
 func errorMessages(errors []packages.Error) []string {
 	var msgs []string
 	for _, err := range errors {
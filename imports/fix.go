@@ -780,6 +780,61 @@ func findImportGoPath(ctx context.Context, pkgName string, symbols map[string]bo
 	return "", false, nil
 }
 
+// CandidateImports returns the import paths of the packages that the file
+// named filename may import to refer to a package as pkgName, as Process
+// finds them: the packages of the standard library named pkgName first,
+// then the packages of GOROOT and GOPATH whose directories suggest the name,
+// closest to the file first. The names of the latter are not checked.
+func CandidateImports(pkgName, filename string) ([]string, error) {
+	pkgDir, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	pkgDir = filepath.Dir(pkgDir)
+
+	var paths []string
+	seen := make(map[string]bool)
+	for importPath, name := range stdImportPackage {
+		if name == pkgName {
+			paths = append(paths, importPath)
+			seen[importPath] = true
+		}
+	}
+	sort.Strings(paths)
+
+	scanOnce.Do(func() { dirScan = scanGoDirs() })
+	var candidates []pkgDistance
+	for _, pkg := range dirScan {
+		if !seen[pkg.importPathShort] && pkgIsCandidate(filename, pkgName, pkg) {
+			candidates = append(candidates, pkgDistance{
+				pkg:      pkg,
+				distance: distance(pkgDir, pkg.dir),
+			})
+		}
+	}
+	sort.Sort(byDistanceOrImportPathShortLength(candidates))
+	for _, c := range candidates {
+		paths = append(paths, c.pkg.importPathShort)
+	}
+	return paths, nil
+}
+
+// ImportPaths returns the import paths of the packages of GOROOT and
+// GOPATH, sorted, as Process finds them.
+func ImportPaths() []string {
+	scanOnce.Do(func() { dirScan = scanGoDirs() })
+	seen := make(map[string]bool)
+	var paths []string
+	for _, pkg := range dirScan {
+		if !seen[pkg.importPathShort] {
+			seen[pkg.importPathShort] = true
+			paths = append(paths, pkg.importPathShort)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
 // pkgIsCandidate reports whether pkg is a candidate for satisfying the
 // finding which package pkgIdent in the file named by filename is trying
 // to refer to.
@@ -21,13 +21,15 @@ import (
 type Conn struct {
 	handle     Handler
 	cancel     Canceler
+	sequence   *sequencer
 	log        Logger
+	trace      Tracer
 	stream     Stream
 	done       chan struct{}
 	err        error
 	seq        int64      // must only be accessed using atomic operations
 	pendingMu  sync.Mutex // protects the pending map
-	pending    map[ID]chan *Response
+	pending    map[ID]outgoing
 	handlingMu sync.Mutex // protects the handling map
 	handling   map[ID]handling
 }
@@ -36,13 +38,16 @@ type Conn struct {
 // If the request returns false from IsNotify then the Handler must eventually
 // call Reply on the Conn with the supplied request.
 // Handlers are called synchronously, they should pass the work off to a go
-// routine if they are going to take a long time.
+// routine if they are going to take a long time, unless a Sequencer is
+// passed to NewConn, which calls them concurrently.
 type Handler func(context.Context, *Conn, *Request)
 
 // Canceler is an option you can pass to NewConn which is invoked for
 // cancelled outgoing requests.
 // The request will have the ID filled in, which can be used to propagate the
 // cancel to the other process if needed.
+// The default Canceler sends the $/cancelRequest notification with the ID,
+// which a Conn at the other end handles by cancelling the call.
 // It is okay to use the connection to send notifications, but the context will
 // be in the cancelled state, so you must do it with the background context
 // instead.
@@ -63,9 +68,10 @@ func NewConn(ctx context.Context, s Stream, options ...interface{}) *Conn {
 	conn := &Conn{
 		stream:   s,
 		done:     make(chan struct{}),
-		pending:  make(map[ID]chan *Response),
+		pending:  make(map[ID]outgoing),
 		handling: make(map[ID]handling),
 	}
+	var middlewares []Middleware
 	for _, opt := range options {
 		switch opt := opt.(type) {
 		case Handler:
@@ -78,11 +84,23 @@ func NewConn(ctx context.Context, s Stream, options ...interface{}) *Conn {
 				panic("Duplicate Canceler function in options list")
 			}
 			conn.cancel = opt
+		case Sequencer:
+			if conn.sequence != nil {
+				panic("Duplicate Sequencer function in options list")
+			}
+			conn.sequence = newSequencer(opt)
+		case Middleware:
+			middlewares = append(middlewares, opt)
 		case Logger:
 			if conn.log != nil {
 				panic("Duplicate Logger function in options list")
 			}
 			conn.log = opt
+		case Tracer:
+			if conn.trace != nil {
+				panic("Duplicate Tracer function in options list")
+			}
+			conn.trace = opt
 		default:
 			panic(fmt.Errorf("Unknown option type %T in options list", opt))
 		}
@@ -95,14 +113,24 @@ func NewConn(ctx context.Context, s Stream, options ...interface{}) *Conn {
 			}
 		}
 	}
+	// the first middleware is the outermost
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		conn.handle = middlewares[i](conn.handle)
+	}
 	if conn.cancel == nil {
-		// the default canceller does nothing
-		conn.cancel = func(context.Context, *Conn, *Request) {}
+		// the default canceller notifies the other end
+		conn.cancel = func(ctx context.Context, c *Conn, r *Request) {
+			c.Notify(context.Background(), cancelMethod, &cancelParams{ID: *r.ID})
+		}
 	}
 	if conn.log == nil {
 		// the default logger does nothing
 		conn.log = func(Direction, *ID, time.Duration, string, *json.RawMessage, *Error) {}
 	}
+	if conn.trace == nil {
+		// the default tracer does nothing
+		conn.trace = func(*WireMessage) {}
+	}
 	go func() {
 		conn.err = conn.run(ctx)
 		close(conn.done)
@@ -123,9 +151,11 @@ func (c *Conn) Wait(ctx context.Context) error {
 
 // Cancel cancels a pending Call on the server side.
 // The call is identified by its id.
-// JSON RPC 2 does not specify a cancel message, so cancellation support is not
-// directly wired in. This method allows a higher level protocol to choose how
-// to propagate the cancel.
+// JSON RPC 2 does not specify a cancel message, so the Conn calls this
+// method for the $/cancelRequest notification, the convention of the
+// Language Server Protocol. It allows a higher level protocol to choose
+// another way to propagate the cancel.
+// Cancelling a call that is not in progress does nothing.
 func (c *Conn) Cancel(id ID) {
 	c.handlingMu.Lock()
 	handling, found := c.handling[id]
@@ -152,6 +182,7 @@ func (c *Conn) Notify(ctx context.Context, method string, params interface{}) er
 		return fmt.Errorf("marshalling notify request: %v", err)
 	}
 	c.log(Send, nil, -1, request.Method, request.Params, nil)
+	c.trace(&WireMessage{Direction: Send, Method: method, Elapsed: -1, Data: data})
 	return c.stream.Write(ctx, data)
 }
 
@@ -177,9 +208,12 @@ func (c *Conn) Call(ctx context.Context, method string, params, result interface
 	}
 	// we have to add ourselves to the pending map before we send, otherwise we
 	// are racing the response
-	rchan := make(chan *Response)
+	// the channel is buffered so that a response that arrives after the call
+	// was cancelled does not block the read loop
+	rchan := make(chan *Response, 1)
+	before := time.Now()
 	c.pendingMu.Lock()
-	c.pending[id] = rchan
+	c.pending[id] = outgoing{response: rchan, method: method, start: before}
 	c.pendingMu.Unlock()
 	defer func() {
 		// clean up the pending response handler on the way out
@@ -188,8 +222,8 @@ func (c *Conn) Call(ctx context.Context, method string, params, result interface
 		c.pendingMu.Unlock()
 	}()
 	// now we are ready to send
-	before := time.Now()
 	c.log(Send, request.ID, -1, request.Method, request.Params, nil)
+	c.trace(&WireMessage{Direction: Send, ID: request.ID, Method: method, Elapsed: -1, Data: data})
 	if err := c.stream.Write(ctx, data); err != nil {
 		// sending failed, we will never get a response, so don't leave it pending
 		return err
@@ -234,6 +268,8 @@ func (c *Conn) Reply(ctx context.Context, req *Request, result interface{}, err
 	if !found {
 		return fmt.Errorf("not a call in progress: %v", req.ID)
 	}
+	// release the context of the call once the reply is sent
+	defer handling.cancel()
 
 	elapsed := time.Since(handling.start)
 	var raw *json.RawMessage
@@ -256,6 +292,7 @@ func (c *Conn) Reply(ctx context.Context, req *Request, result interface{}, err
 		return err
 	}
 	c.log(Send, response.ID, elapsed, req.Method, response.Result, response.Error)
+	c.trace(&WireMessage{Direction: Send, ID: response.ID, Method: req.Method, Elapsed: elapsed, Data: data})
 	if err = c.stream.Write(ctx, data); err != nil {
 		// TODO(iancottrell): if a stream write fails, we really need to shut down
 		// the whole stream
@@ -264,6 +301,13 @@ func (c *Conn) Reply(ctx context.Context, req *Request, result interface{}, err
 	return nil
 }
 
+// outgoing is a call of a Conn awaiting its response.
+type outgoing struct {
+	response chan *Response
+	method   string
+	start    time.Time
+}
+
 type handling struct {
 	request *Request
 	cancel  context.CancelFunc
@@ -297,6 +341,7 @@ func (c *Conn) run(ctx context.Context) error {
 		if err := json.Unmarshal(data, msg); err != nil {
 			// a badly formed message arrived, log it and continue
 			// we trust the stream to have isolated the error to just this message
+			c.trace(&WireMessage{Direction: Receive, Elapsed: -1, Data: data})
 			c.log(Receive, nil, -1, "", nil, NewErrorf(0, "unmarshal failed: %v", err))
 			continue
 		}
@@ -309,12 +354,22 @@ func (c *Conn) run(ctx context.Context) error {
 				Params: msg.Params,
 				ID:     msg.ID,
 			}
-			if request.IsNotify() {
+			c.trace(&WireMessage{Direction: Receive, ID: request.ID, Method: request.Method, Elapsed: -1, Data: data})
+			if request.IsNotify() && request.Method == cancelMethod {
+				c.log(Receive, request.ID, -1, request.Method, request.Params, nil)
+				// cancel the call in progress, the handler need not know
+				var params cancelParams
+				if request.Params == nil || json.Unmarshal(*request.Params, &params) != nil {
+					c.log(Receive, nil, -1, request.Method, nil, NewErrorf(CodeInvalidParams, "invalid cancel parameters"))
+					continue
+				}
+				c.Cancel(params.ID)
+			} else if request.IsNotify() {
 				c.log(Receive, request.ID, -1, request.Method, request.Params, nil)
-				// we have a Notify, forward to the handler in a go routine
-				c.handle(ctx, c, request)
+				// we have a Notify, forward to the handler
+				c.dispatch(ctx, request)
 			} else {
-				// we have a Call, forward to the handler in another go routine
+				// we have a Call, forward to the handler
 				reqCtx, cancelReq := context.WithCancel(ctx)
 				c.handlingMu.Lock()
 				c.handling[*request.ID] = handling{
@@ -324,30 +379,48 @@ func (c *Conn) run(ctx context.Context) error {
 				}
 				c.handlingMu.Unlock()
 				c.log(Receive, request.ID, -1, request.Method, request.Params, nil)
-				c.handle(reqCtx, c, request)
+				c.dispatch(reqCtx, request)
 			}
 		case msg.ID != nil:
 			// we have a response, get the pending entry from the map
 			c.pendingMu.Lock()
-			rchan := c.pending[*msg.ID]
-			if rchan != nil {
+			call, found := c.pending[*msg.ID]
+			if found {
 				delete(c.pending, *msg.ID)
 			}
 			c.pendingMu.Unlock()
+			if !found {
+				c.trace(&WireMessage{Direction: Receive, ID: msg.ID, Elapsed: -1, Data: data})
+				// the call was cancelled, or never made
+				c.log(Receive, msg.ID, -1, "", msg.Result, NewErrorf(0, "response to an unknown call, ignoring"))
+				continue
+			}
 			// and send the reply to the channel
 			response := &Response{
 				Result: msg.Result,
 				Error:  msg.Error,
 				ID:     msg.ID,
 			}
-			rchan <- response
-			close(rchan)
+			c.trace(&WireMessage{Direction: Receive, ID: msg.ID, Method: call.method, Elapsed: time.Since(call.start), Data: data})
+			call.response <- response
+			close(call.response)
 		default:
+			c.trace(&WireMessage{Direction: Receive, Elapsed: -1, Data: data})
 			c.log(Receive, nil, -1, "", nil, NewErrorf(0, "message not a call, notify or response, ignoring"))
 		}
 	}
 }
 
+// dispatch passes a request to the handler, at once in the read loop, or in
+// the order of the Sequencer if there is one.
+func (c *Conn) dispatch(ctx context.Context, r *Request) {
+	if c.sequence == nil {
+		c.handle(ctx, c, r)
+		return
+	}
+	c.sequence.dispatch(r, func() { c.handle(ctx, c, r) })
+}
+
 func marshalToRaw(obj interface{}) (*json.RawMessage, error) {
 	data, err := json.Marshal(obj)
 	if err != nil {
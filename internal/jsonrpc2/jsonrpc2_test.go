@@ -5,14 +5,22 @@
 package jsonrpc2_test
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"path"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/tools/internal/jsonrpc2"
 )
@@ -59,8 +67,20 @@ func (test *callTest) verifyResults(t *testing.T, results interface{}) {
 }
 
 func TestPlainCall(t *testing.T) {
+	testCall(t, jsonrpc2.NewStream)
+}
+
+func TestHeaderCall(t *testing.T) {
+	testCall(t, jsonrpc2.NewHeaderStream)
+}
+
+func TestLineCall(t *testing.T) {
+	testCall(t, jsonrpc2.NewLineStream)
+}
+
+func testCall(t *testing.T, framer jsonrpc2.Framer) {
 	ctx := context.Background()
-	a, b := prepare(ctx, t, false)
+	a, b := prepare(ctx, t, framer)
 	for _, test := range callTests {
 		results := test.newResults()
 		if err := a.Call(ctx, test.method, test.params, results); err != nil {
@@ -74,34 +94,307 @@ func TestPlainCall(t *testing.T) {
 	}
 }
 
-func TestHeaderCall(t *testing.T) {
+func TestCancel(t *testing.T) {
 	ctx := context.Background()
-	a, b := prepare(ctx, t, true)
-	for _, test := range callTests {
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	wait := func(ctx context.Context, c *jsonrpc2.Conn, r *jsonrpc2.Request) {
+		if r.Method != "wait" {
+			handle(ctx, c, r)
+			return
+		}
+		// the call blocks until cancelled, so it must not block the read loop
+		go func() {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			c.Reply(context.Background(), r, nil, ctx.Err())
+		}()
+	}
+	aReader, bWriter := io.Pipe()
+	bReader, aWriter := io.Pipe()
+	defer func() {
+		for _, c := range []io.Closer{aReader, aWriter, bReader, bWriter} {
+			c.Close()
+		}
+	}()
+	a := jsonrpc2.NewConn(ctx, jsonrpc2.NewStream(aReader, aWriter))
+	jsonrpc2.NewConn(ctx, jsonrpc2.NewStream(bReader, bWriter), jsonrpc2.Handler(wait))
+
+	callCtx, cancel := context.WithCancel(ctx)
+	errc := make(chan error)
+	go func() { errc <- a.Call(callCtx, "wait", nil, nil) }()
+	<-started
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("Call returned %v, expected %v", err, context.Canceled)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the cancel was not propagated to the handler")
+	}
+	// the late response to the cancelled call must not stop the connection
+	var result string
+	if err := a.Call(ctx, "one_string", "fish", &result); err != nil {
+		t.Fatalf("Call failed after a cancel: %v", err)
+	}
+	if result != "got:fish" {
+		t.Errorf("Results are incorrect, got %q expect %q", result, "got:fish")
+	}
+}
+
+func TestSequencer(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var changes []string
+	handler := func(ctx context.Context, c *jsonrpc2.Conn, r *jsonrpc2.Request) {
+		var queue string
+		json.Unmarshal(*r.Params, &queue)
+		switch r.Method {
+		case "slow":
+			<-release
+			c.Reply(ctx, r, queue, nil)
+		case "change":
+			mu.Lock()
+			changes = append(changes, queue)
+			mu.Unlock()
+		case "read":
+			mu.Lock()
+			c.Reply(ctx, r, strings.Join(changes, ","), nil)
+			mu.Unlock()
+		}
+	}
+	// the queue of a message is its parameter, and only changes change it
+	order := func(r *jsonrpc2.Request) (string, bool) {
+		var queue string
+		json.Unmarshal(*r.Params, &queue)
+		return queue, r.Method == "change"
+	}
+	aReader, bWriter := io.Pipe()
+	bReader, aWriter := io.Pipe()
+	defer func() {
+		for _, c := range []io.Closer{aReader, aWriter, bReader, bWriter} {
+			c.Close()
+		}
+	}()
+	a := jsonrpc2.NewConn(ctx, jsonrpc2.NewStream(aReader, aWriter))
+	jsonrpc2.NewConn(ctx, jsonrpc2.NewStream(bReader, bWriter), jsonrpc2.Handler(handler), jsonrpc2.Sequencer(order))
+
+	errc := make(chan error)
+	go func() { errc <- a.Call(ctx, "slow", "a", nil) }()
+	// the slow call blocks neither the changes of its queue nor the calls
+	// that follow them, which see them
+	for _, queue := range []string{"a", "b", "a"} {
+		if err := a.Notify(ctx, "change", queue); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got string
+	if err := a.Call(ctx, "read", "a", &got); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "a,") || strings.Count(got, "a") != 2 {
+		t.Errorf("read saw the changes %q, expected both changes of a", got)
+	}
+	close(release)
+	if err := <-errc; err != nil {
+		t.Errorf("slow call failed: %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) jsonrpc2.Middleware {
+		return func(handler jsonrpc2.Handler) jsonrpc2.Handler {
+			return func(ctx context.Context, c *jsonrpc2.Conn, r *jsonrpc2.Request) {
+				mu.Lock()
+				calls = append(calls, name+":"+r.Method)
+				mu.Unlock()
+				handler(ctx, c, r)
+			}
+		}
+	}
+	panicky := func(ctx context.Context, c *jsonrpc2.Conn, r *jsonrpc2.Request) {
+		if r.Method == "panic" {
+			panic("oops")
+		}
+		handle(ctx, c, r)
+	}
+	aReader, bWriter := io.Pipe()
+	bReader, aWriter := io.Pipe()
+	defer func() {
+		for _, c := range []io.Closer{aReader, aWriter, bReader, bWriter} {
+			c.Close()
+		}
+	}()
+	a := jsonrpc2.NewConn(ctx, jsonrpc2.NewStream(aReader, aWriter))
+	jsonrpc2.NewConn(ctx, jsonrpc2.NewStream(bReader, bWriter), jsonrpc2.Handler(panicky),
+		jsonrpc2.Middleware(jsonrpc2.Recover), record("outer"), jsonrpc2.Limit(1), record("inner"))
+
+	err := a.Call(ctx, "panic", nil, nil)
+	if err, ok := err.(*jsonrpc2.Error); !ok || err.Code != jsonrpc2.CodeInternalError || !strings.Contains(err.Message, "oops") {
+		t.Errorf("Call of a panicking handler returned %v, expected an internal error", err)
+	}
+	var result string
+	if err := a.Call(ctx, "one_string", "fish", &result); err != nil || result != "got:fish" {
+		t.Errorf("Call after a panic returned %q, %v, expected %q", result, err, "got:fish")
+	}
+	want := []string{"outer:panic", "inner:panic", "outer:one_string", "inner:one_string"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("middlewares were called as %v, expected %v", calls, want)
+	}
+}
+
+func TestWebSocketCall(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, conn, err := jsonrpc2.AcceptWebSocket(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		jsonrpc2.NewConn(ctx, stream, jsonrpc2.Handler(handle)).Wait(ctx)
+	}))
+	defer server.Close()
+	stream, conn, err := jsonrpc2.DialWebSocket(ctx, "ws"+strings.TrimPrefix(server.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	a := jsonrpc2.NewConn(ctx, stream)
+	// the long string needs the longest length of a frame
+	tests := append(callTests[:len(callTests):len(callTests)], callTest{"one_string", strings.Repeat("fish", 1<<15), "got:" + strings.Repeat("fish", 1<<15)})
+	for _, test := range tests {
 		results := test.newResults()
 		if err := a.Call(ctx, test.method, test.params, results); err != nil {
 			t.Fatalf("%v:Call failed: %v", test.method, err)
 		}
 		test.verifyResults(t, results)
-		if err := b.Call(ctx, test.method, test.params, results); err != nil {
-			t.Fatalf("%v:Call failed: %v", test.method, err)
+	}
+
+	// the pages of other sites cannot connect
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{
+		"Connection":            "Upgrade",
+		"Upgrade":               "websocket",
+		"Sec-WebSocket-Version": "13",
+		"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+		"Origin":                "http://example.com",
+	} {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("handshake from another origin got %s, expected %d", resp.Status, http.StatusForbidden)
+	}
+}
+
+func TestWebSocketInvalidFrames(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, conn, err := jsonrpc2.AcceptWebSocket(w, r, nil)
+		if err != nil {
+			return
 		}
-		test.verifyResults(t, results)
+		defer conn.Close()
+		jsonrpc2.NewConn(ctx, stream, jsonrpc2.Handler(handle)).Wait(ctx)
+	}))
+	defer server.Close()
+	for _, test := range []struct {
+		name  string
+		frame []byte
+	}{
+		// the frames of a client must be masked
+		{"unmasked", append([]byte{0x81, 2}, "{}"...)},
+		// the header claims a terabyte, which is never allocated
+		{"too long", []byte{0x81, 0x80 | 127, 0, 0, 1, 0, 0, 0, 0, 0, 1, 2, 3, 4}},
+	} {
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", server.Listener.Addr())
+		in := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(in, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("%s: handshake got %s", test.name, resp.Status)
+		}
+		if _, err := conn.Write(test.frame); err != nil {
+			t.Fatal(err)
+		}
+		// the server closes the connection
+		conn.SetReadDeadline(time.Now().Add(time.Minute))
+		if n, err := io.Copy(ioutil.Discard, in); err != nil || n != 0 {
+			t.Errorf("%s: the server replied with %d bytes, %v, expected it to close the connection", test.name, n, err)
+		}
+		conn.Close()
 	}
 }
 
-func prepare(ctx context.Context, t *testing.T, withHeaders bool) (*testHandler, *testHandler) {
+func TestTracer(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var traced []jsonrpc2.WireMessage
+	tracer := func(m *jsonrpc2.WireMessage) {
+		mu.Lock()
+		traced = append(traced, *m)
+		mu.Unlock()
+	}
+	aReader, bWriter := io.Pipe()
+	bReader, aWriter := io.Pipe()
+	defer func() {
+		for _, c := range []io.Closer{aReader, aWriter, bReader, bWriter} {
+			c.Close()
+		}
+	}()
+	a := jsonrpc2.NewConn(ctx, jsonrpc2.NewStream(aReader, aWriter), jsonrpc2.Tracer(tracer))
+	jsonrpc2.NewConn(ctx, jsonrpc2.NewStream(bReader, bWriter), jsonrpc2.Handler(handle))
+	var result string
+	if err := a.Call(ctx, "one_string", "fish", &result); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(traced) != 2 {
+		t.Fatalf("traced %d messages, expected the call and its response", len(traced))
+	}
+	for i, want := range []struct {
+		direction jsonrpc2.Direction
+		response  bool
+		data      string
+	}{
+		{jsonrpc2.Send, false, `"params":"fish"`},
+		{jsonrpc2.Receive, true, `"result":"got:fish"`},
+	} {
+		m := traced[i]
+		if m.Direction != want.direction || m.ID == nil || m.Method != "one_string" || (m.Elapsed >= 0) != want.response || !strings.Contains(string(m.Data), want.data) {
+			t.Errorf("traced %v %v %s in %v: %s, expected the %v of a one_string message with %s", m.Direction, m.ID, m.Method, m.Elapsed, m.Data, want.direction, want.data)
+		}
+	}
+}
+
+func prepare(ctx context.Context, t *testing.T, framer jsonrpc2.Framer) (*testHandler, *testHandler) {
 	a := &testHandler{t: t}
 	b := &testHandler{t: t}
 	a.reader, b.writer = io.Pipe()
 	b.reader, a.writer = io.Pipe()
 	for _, h := range []*testHandler{a, b} {
 		h := h
-		if withHeaders {
-			h.stream = jsonrpc2.NewHeaderStream(h.reader, h.writer)
-		} else {
-			h.stream = jsonrpc2.NewStream(h.reader, h.writer)
-		}
+		h.stream = framer(h.reader, h.writer)
 		args := []interface{}{jsonrpc2.Handler(handle)}
 		if *logRPC {
 			args = append(args, jsonrpc2.Log)
@@ -21,6 +21,29 @@ import (
 // response
 type Logger = func(direction Direction, id *ID, elapsed time.Duration, method string, payload *json.RawMessage, err *Error)
 
+// Tracer is an option you can pass to NewConn which is invoked for every
+// message that a Conn is about to write to its stream, or has read from it,
+// as it is on the wire, including those that it could not decode.
+// It is called synchronously, and must be safe for concurrent use.
+type Tracer func(*WireMessage)
+
+// WireMessage is a message that a Conn wrote to or read from its stream.
+type WireMessage struct {
+	// Direction indicates if the message was sent or received.
+	Direction Direction
+	// ID is the id of the call or response, if not set it is a notification.
+	ID *ID
+	// Method is the method of the call or notification, or of the call that a
+	// response replies to, if known.
+	Method string
+	// Elapsed is the time between a call being seen and the response, and is
+	// negative for anything that is not a response.
+	Elapsed time.Duration
+	// Data is the message as it is on the wire, without the framing of the
+	// stream. It must not be modified.
+	Data []byte
+}
+
 // Direction is used to indicate to a logger whether the logged message was being
 // sent or received.
 type Direction bool
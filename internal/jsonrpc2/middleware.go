@@ -0,0 +1,63 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"context"
+	"fmt"
+)
+
+// Middleware is an option you can pass to NewConn to wrap its Handler, so
+// that logging, metrics, recovery from panics or limits can be layered onto
+// the connection.
+// The middlewares wrap the handler in the order of the options list, the
+// first one being the outermost.
+type Middleware func(Handler) Handler
+
+// Recover is a Middleware that recovers from the panics of the handler, and
+// replies to a call whose handler panicked with a CodeInternalError error.
+func Recover(handler Handler) Handler {
+	return func(ctx context.Context, c *Conn, r *Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			err := NewErrorf(CodeInternalError, "panic handling %s: %v", r.Method, p)
+			c.log(Receive, r.ID, -1, r.Method, nil, err)
+			if !r.IsNotify() {
+				// the handler may have replied before it panicked
+				c.Reply(ctx, r, nil, err)
+			}
+		}()
+		handler(ctx, c, r)
+	}
+}
+
+// Limit returns a Middleware that handles at most n calls at once: the
+// others wait for one of them to return. Notifications are not limited.
+func Limit(n int) Middleware {
+	if n <= 0 {
+		panic(fmt.Errorf("Invalid limit %d of concurrent calls", n))
+	}
+	slots := make(chan struct{}, n)
+	return func(handler Handler) Handler {
+		return func(ctx context.Context, c *Conn, r *Request) {
+			if r.IsNotify() {
+				handler(ctx, c, r)
+				return
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				// the call was cancelled while it waited
+				c.Reply(context.Background(), r, nil, ctx.Err())
+				return
+			}
+			defer func() { <-slots }()
+			handler(ctx, c, r)
+		}
+	}
+}
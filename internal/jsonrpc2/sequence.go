@@ -0,0 +1,93 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import "sync"
+
+// Sequencer is an option you can pass to NewConn to handle incoming requests
+// concurrently rather than one at a time, while preserving the order that
+// matters to the protocol.
+// It returns the queue of a request, such as the document it refers to, and
+// whether the request changes the state of that queue.
+// A request that changes its queue is handled after the earlier requests of
+// the queue that change it, and the later requests of the queue wait for it.
+// A request that does not change its queue waits for the same requests, but
+// nothing waits for it, so a slow one blocks nothing.
+// The empty queue is the state of the whole connection: a request of the
+// empty queue waits for the earlier requests that change any queue, and a
+// request that changes it also waits for every earlier request, and every
+// later request waits for it.
+type Sequencer func(*Request) (queue string, changes bool)
+
+// sequencer dispatches the requests to the handler of a Conn in the order
+// of its Sequencer.
+type sequencer struct {
+	order Sequencer
+
+	mu       sync.Mutex
+	barrier  chan struct{}            // closed when the last change of the empty queue is handled
+	changes  map[string]chan struct{} // closed when the last change of each queue is handled
+	inFlight map[chan struct{}]bool   // the requests that are not handled yet
+}
+
+func newSequencer(order Sequencer) *sequencer {
+	return &sequencer{
+		order:    order,
+		changes:  make(map[string]chan struct{}),
+		inFlight: make(map[chan struct{}]bool),
+	}
+}
+
+// dispatch calls handle for r in a goroutine, once the requests it must
+// follow are handled.
+// It must be called in the order that the requests arrive.
+func (s *sequencer) dispatch(r *Request, handle func()) {
+	queue, changes := s.order(r)
+	done := make(chan struct{})
+	var wait []chan struct{}
+	s.mu.Lock()
+	if s.barrier != nil {
+		wait = append(wait, s.barrier)
+	}
+	switch {
+	case queue == "" && changes:
+		for c := range s.inFlight {
+			wait = append(wait, c)
+		}
+		// the later requests wait for this one, which waits for the others
+		s.barrier = done
+		s.changes = make(map[string]chan struct{})
+	case queue == "":
+		for _, c := range s.changes {
+			wait = append(wait, c)
+		}
+	default:
+		if c, ok := s.changes[queue]; ok {
+			wait = append(wait, c)
+		}
+		if changes {
+			s.changes[queue] = done
+		}
+	}
+	s.inFlight[done] = true
+	s.mu.Unlock()
+
+	go func() {
+		for _, c := range wait {
+			<-c
+		}
+		handle()
+		s.mu.Lock()
+		delete(s.inFlight, done)
+		if s.barrier == done {
+			s.barrier = nil
+		}
+		if s.changes[queue] == done {
+			delete(s.changes, queue)
+		}
+		s.mu.Unlock()
+		close(done)
+	}()
+}
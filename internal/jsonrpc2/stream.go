@@ -6,6 +6,7 @@ package jsonrpc2
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -28,6 +29,11 @@ type Stream interface {
 	Write(context.Context, []byte) error
 }
 
+// Framer returns a Stream that reads the messages from in and writes them to
+// out, framed in a format of its own, such as NewHeaderStream or
+// NewLineStream.
+type Framer func(in io.Reader, out io.Writer) Stream
+
 // NewStream returns a Stream built on top of an io.Reader and io.Writer
 // The messages are sent with no wrapping, and rely on json decode consistency
 // to determine message boundaries.
@@ -144,3 +150,58 @@ func (s *headerStream) Write(ctx context.Context, data []byte) error {
 	s.outMu.Unlock()
 	return err
 }
+
+// NewLineStream returns a Stream built on top of an io.Reader and io.Writer
+// The messages are sent as lines of JSON, the format of newline-delimited
+// JSON. Blank lines are ignored.
+func NewLineStream(in io.Reader, out io.Writer) Stream {
+	return &lineStream{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+type lineStream struct {
+	in    *bufio.Reader
+	outMu sync.Mutex
+	out   io.Writer
+}
+
+func (s *lineStream) Read(ctx context.Context) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	for {
+		line, err := s.in.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *lineStream) Write(ctx context.Context, data []byte) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	// a message must fit on a line, which compact JSON does
+	var line bytes.Buffer
+	if bytes.IndexByte(data, '\n') >= 0 {
+		if err := json.Compact(&line, data); err != nil {
+			return err
+		}
+	} else {
+		line.Write(data)
+	}
+	line.WriteByte('\n')
+	s.outMu.Lock()
+	_, err := s.out.Write(line.Bytes())
+	s.outMu.Unlock()
+	return err
+}
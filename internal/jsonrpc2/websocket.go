@@ -0,0 +1,325 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// this file contains a minimal implementation of the WebSocket protocol
+// see https://tools.ietf.org/html/rfc6455 for details
+// each message is sent as a text message of a single frame
+
+// The opcodes of the frames of the WebSocket protocol that a stream handles
+// specially, the others carry the fragments of a message.
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+// wsMaxMessage is the length of the largest message that a WebSocket stream
+// accepts.
+const wsMaxMessage = 4 << 20
+
+// wsReadChunk is the length of the chunks that the payload of a frame is read
+// in, so that the memory of a frame grows only as its data arrives.
+const wsReadChunk = 64 << 10
+
+// wsGUID is the suffix of the key of the opening handshake whose hash the
+// server accepts the connection with.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// AcceptWebSocket completes the opening handshake of the WebSocket client that
+// sent r, and returns a Stream of the messages of the connection, and the
+// connection itself, which the caller must close.
+// The requests of browsers are accepted if their origin is the host of the
+// server, or one of origins, such as "http://localhost:8080", so that the
+// pages of other sites cannot connect.
+// If the handshake fails, AcceptWebSocket replies to r with the error.
+func AcceptWebSocket(w http.ResponseWriter, r *http.Request, origins []string) (Stream, net.Conn, error) {
+	if !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "not a websocket handshake", http.StatusBadRequest)
+		return nil, nil, fmt.Errorf("not a websocket handshake")
+	}
+	if version := r.Header.Get("Sec-WebSocket-Version"); version != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, nil, fmt.Errorf("unsupported websocket version %q", version)
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, nil, fmt.Errorf("missing Sec-WebSocket-Key header")
+	}
+	if origin := r.Header.Get("Origin"); origin != "" && !allowedOrigin(origin, r.Host, origins) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return nil, nil, fmt.Errorf("origin %q not allowed", origin)
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, nil, fmt.Errorf("cannot hijack the connection of %T", w)
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return &webSocketStream{in: rw.Reader, out: conn}, conn, nil
+}
+
+// DialWebSocket connects to the WebSocket server at the ws or wss URL, and
+// returns a Stream of the messages of the connection, and the connection
+// itself, which the caller must close.
+func DialWebSocket(ctx context.Context, rawurl string) (Stream, net.Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, nil, err
+	}
+	host := u.Host
+	switch {
+	case u.Scheme != "ws" && u.Scheme != "wss":
+		return nil, nil, fmt.Errorf("invalid websocket URL %q", rawurl)
+	case u.Port() != "":
+	case u.Scheme == "ws":
+		host = net.JoinHostPort(u.Hostname(), "80")
+	default:
+		host = net.JoinHostPort(u.Hostname(), "443")
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme == "wss" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = tlsConn
+	}
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	if _, err := fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", u.RequestURI(), u.Host, key); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	in := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(in, nil)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, nil, fmt.Errorf("websocket handshake failed: %s", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, nil, fmt.Errorf("websocket handshake failed: invalid Sec-WebSocket-Accept header")
+	}
+	return &webSocketStream{in: in, out: conn, client: true}, conn, nil
+}
+
+// webSocketStream is a Stream of the text messages of a WebSocket connection.
+type webSocketStream struct {
+	in     *bufio.Reader
+	client bool // the frames of a client are masked
+	outMu  sync.Mutex
+	out    io.Writer
+}
+
+func (s *webSocketStream) Read(ctx context.Context) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	var message []byte
+	for {
+		fin, opcode, payload, err := s.readFrame(wsMaxMessage - len(message))
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsPing:
+			if err := s.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			// echo the status code of the close, the end of the connection
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			s.writeFrame(wsClose, payload)
+			return nil, io.EOF
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+func (s *webSocketStream) Write(ctx context.Context, data []byte) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	return s.writeFrame(wsText, data)
+}
+
+// readFrame reads the next frame of the connection, whose payload is at most
+// limit bytes long, and unmasks its payload.
+func (s *webSocketStream) readFrame(limit int) (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(s.in, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = header[0]&0x80 != 0, header[0]&0x0f
+	masked, length := header[1]&0x80 != 0, uint64(header[1]&0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(s.in, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(s.in, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > uint64(limit) {
+		return false, 0, nil, fmt.Errorf("websocket message too long")
+	}
+	// the frames of a client must be masked, see section 5.1 of RFC 6455
+	if !s.client && !masked {
+		return false, 0, nil, fmt.Errorf("unmasked websocket frame from the client")
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(s.in, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	// the length is that of the header, so the payload is read in chunks
+	// rather than allocated at once
+	for n := int(length); len(payload) < n; {
+		chunk := n - len(payload)
+		if chunk > wsReadChunk {
+			chunk = wsReadChunk
+		}
+		start := len(payload)
+		payload = append(payload, make([]byte, chunk)...)
+		if _, err := io.ReadFull(s.in, payload[start:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a frame of a single fragment, masked if s is a client.
+func (s *webSocketStream) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+	var maskBit byte
+	if s.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		frame = append(append(frame, maskBit|127), ext[:]...)
+	}
+	if s.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	s.outMu.Lock()
+	_, err := s.out.Write(frame)
+	s.outMu.Unlock()
+	return err
+}
+
+// acceptKey returns the value of the Sec-WebSocket-Accept header of the reply
+// to an opening handshake with the given key.
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// allowedOrigin reports whether a browser page of origin may connect to host.
+func allowedOrigin(origin, host string, origins []string) bool {
+	for _, allowed := range origins {
+		if strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, host)
+}
+
+// headerHas reports whether the comma separated tokens of the header name
+// contain token, ignoring case.
+func headerHas(header http.Header, name, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
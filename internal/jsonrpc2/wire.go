@@ -30,6 +30,11 @@ const (
 	CodeInternalError = -32603
 )
 
+// cancelMethod is the method of the notification that cancels a call, by its
+// ID. It is not part of the JSON RPC 2 spec, but a convention of the Language
+// Server Protocol.
+const cancelMethod = "$/cancelRequest"
+
 // Request is sent to a server to represent a Call or Notify operaton.
 type Request struct {
 	// VersionTag is always encoded as the string "2.0"
@@ -69,6 +74,12 @@ type Error struct {
 	Data *json.RawMessage `json:"data"`
 }
 
+// cancelParams are the parameters of the cancelMethod notification.
+type cancelParams struct {
+	// ID is the identifier of the call to cancel.
+	ID ID `json:"id"`
+}
+
 // VersionTag is a special 0 sized struct that encodes as the jsonrpc version
 // tag.
 // It will fail during decode if it is not the correct version tag in the
@@ -0,0 +1,159 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages/packagestest"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// The benchmarks measure the latency of the server on corpora of generated
+// packages of several sizes, so that its regressions are caught:
+//
+//	go test -run=^$ -bench=. golang.org/x/tools/internal/lsp
+
+// benchSizes are the numbers of packages of the corpora of the benchmarks.
+var benchSizes = []int{10, 100}
+
+// benchModule is the module of the corpora of the benchmarks.
+const benchModule = "golang.org/x/tools/internal/lsp/bench"
+
+// benchCorpus exports a corpus of n packages, each of which imports the
+// previous one, and a main package that imports the last one.
+func benchCorpus(b *testing.B, n int) *packagestest.Exported {
+	files := make(map[string]interface{})
+	for i := 0; i < n; i++ {
+		var src strings.Builder
+		fmt.Fprintf(&src, "package p%d\n\n", i)
+		if i > 0 {
+			fmt.Fprintf(&src, "import %q\n\n", fmt.Sprintf("%s/p%d", benchModule, i-1))
+			fmt.Fprintf(&src, "// T is a type of the package.\ntype T struct {\n\tA, B int\n\tPrev p%d.T\n}\n\n", i-1)
+			fmt.Fprintf(&src, "// Sum returns the sum of the fields of t and of its predecessors.\nfunc (t T) Sum() int { return t.A + t.B + t.Prev.Sum() }\n\n")
+		} else {
+			fmt.Fprintf(&src, "// T is a type of the package.\ntype T struct {\n\tA, B int\n}\n\n")
+			fmt.Fprintf(&src, "// Sum returns the sum of the fields of t.\nfunc (t T) Sum() int { return t.A + t.B }\n\n")
+		}
+		fmt.Fprintf(&src, "// F returns a T of n.\nfunc F(n int) T { return T{A: n, B: 2 * n} }\n")
+		files[fmt.Sprintf("p%d/p%d.go", i, i)] = src.String()
+	}
+	files["main/main.go"] = fmt.Sprintf("package main\n\nimport %q\n\nfunc main() {\n\tt := p%d.F(1)\n\tprintln(t.Sum())\n}\n", fmt.Sprintf("%s/p%d", benchModule, n-1), n-1)
+	return packagestest.Export(b, packagestest.GOPATH, []packagestest.Module{{Name: benchModule, Files: files}})
+}
+
+// benchServer returns a server whose view loads the packages of exported.
+func benchServer(exported *packagestest.Exported) *server {
+	s := &server{session: source.NewSession()}
+	s.view = s.session.NewView()
+	cfg := *exported.Config
+	cfg.Fset = s.view.Config.Fset
+	cfg.Mode = s.view.Config.Mode
+	cfg.Tests = s.view.Config.Tests
+	s.view.Config = &cfg
+	return s
+}
+
+// benchMain returns the URI of the file of the main package of exported and
+// its content.
+func benchMain(b *testing.B, exported *packagestest.Exported) (source.URI, string) {
+	filename := exported.File(benchModule, "main/main.go")
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return source.ToURI(filename), string(content)
+}
+
+// benchEdit changes the content of the file of uri, alternately, so that its
+// package is type-checked again.
+func benchEdit(v *source.View, uri source.URI, content string, i int) {
+	if i%2 == 0 {
+		content += "// edited\n"
+	}
+	v.GetFile(uri).SetContent([]byte(content))
+}
+
+// BenchmarkColdLoad measures the loading and type-checking of all the
+// packages of a workspace by a new server.
+func BenchmarkColdLoad(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("packages=%d", n), func(b *testing.B) {
+			exported := benchCorpus(b, n)
+			defer exported.Cleanup()
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s := benchServer(exported)
+				if err := s.view.LoadWorkspace(ctx, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCompletion measures the completion of a selector in a file that
+// was just edited.
+func BenchmarkCompletion(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("packages=%d", n), func(b *testing.B) {
+			exported := benchCorpus(b, n)
+			defer exported.Cleanup()
+			ctx := context.Background()
+			s := benchServer(exported)
+			uri, content := benchMain(b, exported)
+			params := &protocol.CompletionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)},
+					Position:     protocol.Position{Line: 6, Character: 11}, // after "t."
+				},
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				benchEdit(s.view, uri, content, i)
+				list, err := s.Completion(ctx, params)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(list.Items) == 0 {
+					b.Fatal("no completion items")
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDiagnostics measures the diagnostics of a file that was just
+// edited.
+func BenchmarkDiagnostics(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("packages=%d", n), func(b *testing.B) {
+			exported := benchCorpus(b, n)
+			defer exported.Cleanup()
+			ctx := context.Background()
+			s := benchServer(exported)
+			uri, content := benchMain(b, exported)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				benchEdit(s.view, uri, content, i)
+				reports, err := source.Diagnostics(ctx, s.view, s.view.GetFile(uri), s.view.Options().Diagnostics)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, diags := range reports {
+					for _, d := range diags {
+						b.Fatalf("unexpected diagnostic: %s", d.Message)
+					}
+				}
+			}
+		})
+	}
+}
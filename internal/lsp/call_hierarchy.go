@@ -0,0 +1,44 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"go/token"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// callHierarchyItemPos returns the file and position of the name of the
+// function of an item that was sent back by the client.
+func callHierarchyItemPos(ctx context.Context, v *source.View, item protocol.CallHierarchyItem) (*source.File, token.Pos, error) {
+	f := v.GetFile(source.URI(item.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, token.NoPos, err
+	}
+	return f, fromProtocolPosition(m, item.SelectionRange.Start), nil
+}
+
+func toProtocolCallHierarchyItem(v *source.View, item source.CallHierarchyItem) protocol.CallHierarchyItem {
+	m := fileColumnMapper(v, item.SelectionRange.Start)
+	return protocol.CallHierarchyItem{
+		Name:           item.Name,
+		Kind:           toProtocolSymbolKind(item.Kind),
+		Detail:         item.Detail,
+		URI:            protocol.DocumentURI(source.ToURI(m.filename)),
+		Range:          toProtocolRange(m, item.Range),
+		SelectionRange: toProtocolRange(m, item.SelectionRange),
+	}
+}
+
+func toProtocolRanges(v *source.View, ranges []source.Range) []protocol.Range {
+	result := make([]protocol.Range, 0, len(ranges))
+	for _, r := range ranges {
+		result = append(result, toProtocolRange(fileColumnMapper(v, r.Start), r))
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import "golang.org/x/tools/internal/lsp/protocol"
+
+// clientOptions are the features of the protocol that the client supports,
+// which the server learns from the capabilities of its initialize request.
+// The features of the server consult them to send only what the client
+// understands: the zero value is a minimal client, which only supports what
+// the protocol requires of every client.
+type clientOptions struct {
+	// configuration is set if the client supports workspace/configuration
+	// requests.
+	configuration bool
+
+	// watchedFiles is set if the client supports the dynamic registration
+	// of workspace/didChangeWatchedFiles notifications.
+	watchedFiles bool
+
+	// progress is set if the client supports the progress reports of the
+	// operations of the server.
+	progress bool
+
+	// snippets is set if the client supports snippets as the text to insert
+	// for completion items.
+	snippets bool
+
+	// hoverKind and completionDocKind are the formats of the content of
+	// hovers and of the documentation of completion items.
+	hoverKind         protocol.MarkupKind
+	completionDocKind protocol.MarkupKind
+
+	// hierarchicalSymbols is set if the client supports the tree of the
+	// symbols of a document, rather than a flat list.
+	hierarchicalSymbols bool
+
+	// prepareRename is set if the client supports prepareRename requests.
+	prepareRename bool
+
+	// relatedInformation is set if the client supports the related
+	// information of diagnostics.
+	relatedInformation bool
+
+	// lineFoldingOnly is set if the client folds whole lines only.
+	lineFoldingOnly bool
+}
+
+// parseClientOptions returns the options of a client with the given
+// capabilities.
+func parseClientOptions(caps protocol.ClientCapabilities) clientOptions {
+	td := caps.TextDocument
+	return clientOptions{
+		configuration:       caps.Workspace.Configuration,
+		watchedFiles:        caps.Workspace.DidChangeWatchedFiles.DynamicRegistration,
+		progress:            caps.Window.WorkDoneProgress,
+		snippets:            td.Completion.CompletionItem.SnippetSupport,
+		hoverKind:           markupKind(td.Hover.ContentFormat),
+		completionDocKind:   markupKind(td.Completion.CompletionItem.DocumentationFormat),
+		hierarchicalSymbols: td.DocumentSymbol.HierarchicalDocumentSymbolSupport,
+		prepareRename:       td.Rename.PrepareSupport,
+		relatedInformation:  td.PublishDiagnostics.RelatedInformation,
+		lineFoldingOnly:     td.FoldingRange.LineFoldingOnly,
+	}
+}
+
+// markupKind returns the format of the content for a client that supports
+// formats, in its order of preference: markdown if the client prefers it,
+// and plain text otherwise, which every client supports.
+func markupKind(formats []protocol.MarkupKind) protocol.MarkupKind {
+	for _, kind := range formats {
+		switch kind {
+		case protocol.Markdown, protocol.PlainText:
+			return kind
+		}
+	}
+	return protocol.PlainText
+}
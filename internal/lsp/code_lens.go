@@ -0,0 +1,169 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"path/filepath"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// codeLenses returns the code lenses of a document.
+func codeLenses(ctx context.Context, v *source.View, uri protocol.DocumentURI) ([]protocol.CodeLens, error) {
+	if source.IsModFile(source.URI(uri)) {
+		return modCodeLenses(ctx, v, uri)
+	}
+	tests, err := testCodeLenses(ctx, v, uri)
+	if err != nil {
+		return nil, err
+	}
+	generate, err := generateCodeLenses(ctx, v, uri)
+	if err != nil {
+		return nil, err
+	}
+	cgo, err := cgoCodeLenses(ctx, v, uri)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(tests, generate...), cgo...), nil
+}
+
+// testCodeLenses returns the lenses that run the test and benchmark
+// functions of a document.
+func testCodeLenses(ctx context.Context, v *source.View, uri protocol.DocumentURI) ([]protocol.CodeLens, error) {
+	f := v.GetFile(source.URI(uri))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	funcs, err := source.TestFuncs(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	var lenses []protocol.CodeLens
+	for _, fn := range funcs {
+		start := toProtocolPosition(m, fn.Range.Start)
+		lens := protocol.CodeLens{
+			Range: protocol.Range{Start: start, End: start},
+			Command: protocol.Command{
+				Title:     "run test",
+				Command:   runTestCommand,
+				Arguments: []interface{}{string(uri), fn.Name},
+			},
+		}
+		if fn.Benchmark {
+			lens.Command.Title = "run benchmark"
+			lens.Command.Command = runBenchmarkCommand
+		}
+		lenses = append(lenses, lens)
+	}
+	return lenses, nil
+}
+
+// generateCodeLenses returns the lenses that run go generate in the
+// directory of a document, and in its subdirectories, on the first
+// //go:generate directive of the document.
+func generateCodeLenses(ctx context.Context, v *source.View, uri protocol.DocumentURI) ([]protocol.CodeLens, error) {
+	f := v.GetFile(source.URI(uri))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	directives, err := source.GenerateDirectives(ctx, f)
+	if err != nil || len(directives) == 0 {
+		return nil, err
+	}
+	filename, err := f.URI.Filename()
+	if err != nil {
+		return nil, err
+	}
+	dir := string(source.ToURI(filepath.Dir(filename)))
+	rng := toProtocolRange(m, directives[0])
+	return []protocol.CodeLens{
+		{
+			Range: rng,
+			Command: protocol.Command{
+				Title:     "run go generate",
+				Command:   generateCommand,
+				Arguments: []interface{}{dir, false},
+			},
+		},
+		{
+			Range: rng,
+			Command: protocol.Command{
+				Title:     "run go generate ./...",
+				Command:   generateCommand,
+				Arguments: []interface{}{dir, true},
+			},
+		},
+	}, nil
+}
+
+// cgoCodeLenses returns the lens that regenerates the cgo definitions of
+// the package of a document, on its import of "C".
+func cgoCodeLenses(ctx context.Context, v *source.View, uri protocol.DocumentURI) ([]protocol.CodeLens, error) {
+	f := v.GetFile(source.URI(uri))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, imp := range fAST.Imports {
+		if imp.Path.Value != `"C"` {
+			continue
+		}
+		return []protocol.CodeLens{{
+			Range: toProtocolRange(m, source.Range{Start: imp.Pos(), End: imp.End()}),
+			Command: protocol.Command{
+				Title:     "regenerate cgo definitions",
+				Command:   regenerateCgoCommand,
+				Arguments: []interface{}{string(uri)},
+			},
+		}}, nil
+	}
+	return nil, nil
+}
+
+// modCodeLenses returns the lenses of a go.mod file: one that tidies the
+// module, on its module directive, and one that upgrades each of its
+// requirements.
+func modCodeLenses(ctx context.Context, v *source.View, uri protocol.DocumentURI) ([]protocol.CodeLens, error) {
+	f := v.GetFile(source.URI(uri))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	var lenses []protocol.CodeLens
+	if _, rng, err := source.ModModule(ctx, f); err == nil {
+		lenses = append(lenses, protocol.CodeLens{
+			Range: toProtocolRange(m, rng),
+			Command: protocol.Command{
+				Title:     "tidy module",
+				Command:   tidyCommand,
+				Arguments: []interface{}{string(uri)},
+			},
+		})
+	}
+	reqs, err := source.ModRequirements(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range reqs {
+		lenses = append(lenses, protocol.CodeLens{
+			Range: toProtocolRange(m, req.Range),
+			Command: protocol.Command{
+				Title:     "upgrade dependency",
+				Command:   upgradeDependencyCommand,
+				Arguments: []interface{}{string(uri), req.Path},
+			},
+		})
+	}
+	return lenses, nil
+}
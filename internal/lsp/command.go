@@ -0,0 +1,320 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// The names of the commands of the server.
+const (
+	// runTestCommand runs a test function. Its arguments are the URI of
+	// the test file and the name of the function.
+	runTestCommand = "golsp.test"
+	// runBenchmarkCommand runs a benchmark function, with the same
+	// arguments as runTestCommand.
+	runBenchmarkCommand = "golsp.benchmark"
+	// generateCommand runs go generate. Its arguments are the URI of a
+	// directory, and whether to run it in the subdirectories too.
+	generateCommand = "golsp.generate"
+	// tidyCommand runs go mod tidy. Its argument is the URI of the go.mod
+	// file, or of any file of the module.
+	tidyCommand = "golsp.tidy"
+	// upgradeDependencyCommand upgrades a module to its latest version.
+	// Its arguments are the URI of the go.mod file that requires the
+	// module, and the path of the module.
+	upgradeDependencyCommand = "golsp.upgradeDependency"
+	// regenerateCgoCommand reloads the packages of the view, which
+	// regenerates the files that cgo produces for them.
+	regenerateCgoCommand = "golsp.regenerateCgo"
+	// memoryUsageCommand logs the estimated memory that the cached packages
+	// of each view use. It has no arguments.
+	memoryUsageCommand = "golsp.memoryUsage"
+	// checkCommand type-checks and analyzes all the packages of the
+	// workspace folders, and publishes their diagnostics. It has no
+	// arguments.
+	checkCommand = "golsp.check"
+)
+
+// A command is an operation of the server that clients run with a
+// workspace/executeCommand request, usually on behalf of a code lens or
+// code action.
+type command struct {
+	// title describes the command in the messages that report its
+	// progress, such as "Running go generate".
+	title string
+	// run runs the command with the arguments of the request.
+	run func(s *server, ctx context.Context, args []interface{}) error
+}
+
+// commands is the registry of the commands of the server, by name.
+var commands = map[string]*command{
+	runTestCommand: {
+		title: "Running test",
+		run: func(s *server, ctx context.Context, args []interface{}) error {
+			return s.runTest(ctx, args, false)
+		},
+	},
+	runBenchmarkCommand: {
+		title: "Running benchmark",
+		run: func(s *server, ctx context.Context, args []interface{}) error {
+			return s.runTest(ctx, args, true)
+		},
+	},
+	generateCommand: {
+		title: "Running go generate",
+		run:   (*server).generate,
+	},
+	tidyCommand: {
+		title: "Running go mod tidy",
+		run:   (*server).tidy,
+	},
+	upgradeDependencyCommand: {
+		title: "Upgrading dependency",
+		run:   (*server).upgradeDependency,
+	},
+	regenerateCgoCommand: {
+		title: "Regenerating cgo",
+		run: func(s *server, ctx context.Context, args []interface{}) error {
+			s.reload(ctx)
+			return nil
+		},
+	},
+	memoryUsageCommand: {
+		title: "Reporting memory usage",
+		run:   (*server).memoryUsage,
+	},
+	checkCommand: {
+		title: "Checking workspace",
+		run:   (*server).checkWorkspace,
+	},
+}
+
+// commandNames returns the names of the commands of the server.
+func commandNames() []string {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	return names
+}
+
+// executeCommand runs the named command, reporting its progress to the
+// client: the start of the command is logged, its progress is reported
+// until it ends, which the user may cancel, and its outcome is shown to the
+// user. A command that fails is not an error of the request.
+func (s *server) executeCommand(ctx context.Context, name string, args []interface{}) error {
+	cmd, ok := commands[name]
+	if !ok {
+		return jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "unknown command %q", name)
+	}
+	if err := s.logf(ctx, protocol.Info, "%s...", cmd.title); err != nil {
+		return err
+	}
+	msg := &protocol.ShowMessageParams{Type: protocol.Info, Message: cmd.title + ": done"}
+	runCtx, p := s.startProgress(ctx, cmd.title, true)
+	err := cmd.run(s, runCtx, args)
+	if err != nil {
+		msg = &protocol.ShowMessageParams{Type: protocol.Error, Message: fmt.Sprintf("%s: %v", cmd.title, err)}
+		p.end(err.Error())
+	} else {
+		p.end("done")
+	}
+	return s.client.ShowMessage(ctx, msg)
+}
+
+// runTest runs the test or benchmark function named by the arguments with
+// go test.
+func (s *server) runTest(ctx context.Context, args []interface{}, benchmark bool) error {
+	if len(args) != 2 {
+		return fmt.Errorf("expected 2 arguments, got %d", len(args))
+	}
+	filename, err := filenameArg(args[0])
+	if err != nil {
+		return err
+	}
+	name, ok := args[1].(string)
+	if !ok {
+		return fmt.Errorf("expected the name of a function, got %v", args[1])
+	}
+	goArgs := []string{"test", "-run", "^" + name + "$", "."}
+	if benchmark {
+		goArgs = []string{"test", "-run", "^$", "-bench", "^" + name + "$", "."}
+	}
+	if err := s.runGo(ctx, filepath.Dir(filename), goArgs...); err != nil {
+		return fmt.Errorf("%s failed: %v", name, err)
+	}
+	return nil
+}
+
+// generate runs go generate in the directory of the arguments, and in its
+// subdirectories if requested, and reloads the packages afterwards.
+func (s *server) generate(ctx context.Context, args []interface{}) error {
+	if len(args) != 2 {
+		return fmt.Errorf("expected 2 arguments, got %d", len(args))
+	}
+	dir, err := filenameArg(args[0])
+	if err != nil {
+		return err
+	}
+	recursive, ok := args[1].(bool)
+	if !ok {
+		return fmt.Errorf("expected a boolean, got %v", args[1])
+	}
+	pattern := "."
+	if recursive {
+		pattern = "./..."
+	}
+	defer s.reload(ctx)
+	return s.runGo(ctx, dir, "generate", "-x", pattern)
+}
+
+// tidy runs go mod tidy in the directory of the file of the arguments, and
+// reloads the packages afterwards.
+func (s *server) tidy(ctx context.Context, args []interface{}) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected 1 argument, got %d", len(args))
+	}
+	filename, err := filenameArg(args[0])
+	if err != nil {
+		return err
+	}
+	dir := filename
+	if fi, err := os.Stat(filename); err == nil && !fi.IsDir() {
+		dir = filepath.Dir(filename)
+	}
+	defer s.reload(ctx)
+	return s.runGo(ctx, dir, "mod", "tidy")
+}
+
+// upgradeDependency runs go get to upgrade the module of the arguments to
+// its latest version, in the directory of their go.mod file, and reloads
+// the packages afterwards.
+func (s *server) upgradeDependency(ctx context.Context, args []interface{}) error {
+	if len(args) != 2 {
+		return fmt.Errorf("expected 2 arguments, got %d", len(args))
+	}
+	filename, err := filenameArg(args[0])
+	if err != nil {
+		return err
+	}
+	path, ok := args[1].(string)
+	if !ok {
+		return fmt.Errorf("expected a module path, got %v", args[1])
+	}
+	defer s.reload(ctx)
+	return s.runGo(ctx, filepath.Dir(filename), "get", path+"@latest")
+}
+
+// reload reloads the packages of the views, and publishes the diagnostics of
+// the open documents again, as the build configuration or the module graph
+// may have changed.
+func (s *server) reload(ctx context.Context) {
+	for _, v := range s.views() {
+		v.Reload()
+	}
+	for _, uri := range s.session.OpenFiles() {
+		s.scheduleDiagnostics(ctx, protocol.DocumentURI(uri), 0)
+	}
+}
+
+// memoryUsage logs the estimated memory that the cached packages of each view
+// use, largest first, with their total.
+func (s *server) memoryUsage(ctx context.Context, args []interface{}) error {
+	var buf bytes.Buffer
+	for _, v := range s.views() {
+		var total int64
+		fmt.Fprintf(&buf, "view %s:\n", v.Config.Dir)
+		for _, m := range v.MemoryUsage() {
+			total += m.Bytes
+			open := ""
+			if m.Open {
+				open = " (open)"
+			}
+			fmt.Fprintf(&buf, "\t%8d KB\t%s%s\n", m.Bytes>>10, m.PkgPath, open)
+		}
+		fmt.Fprintf(&buf, "\t%8d KB\ttotal\n", total>>10)
+	}
+	return s.logf(ctx, protocol.Info, "%s", buf.String())
+}
+
+// checkWorkspace publishes the diagnostics of all the packages of the
+// workspace folders, reporting the progress of their type-checking, which is
+// the first half of the work of each folder, and then of their diagnosis.
+// It fails if they have errors, so that the user is told.
+func (s *server) checkWorkspace(ctx context.Context, args []interface{}) error {
+	if len(args) != 0 {
+		return fmt.Errorf("expected no arguments, got %d", len(args))
+	}
+	var views []*source.View
+	for _, v := range s.views() {
+		if v != s.view {
+			views = append(views, v)
+		}
+	}
+	p := progressFrom(ctx)
+	percentage, errors := 0, 0
+	for i, v := range views {
+		name := filepath.Base(v.Config.Dir)
+		reports, err := source.WorkspaceDiagnostics(ctx, v, []string{"./..."}, v.Options().Diagnostics, func(stage string, done, total int) {
+			pc := done * 50 / total
+			if stage == source.DiagnoseStage {
+				pc += 50
+			}
+			// Report whole percentages of all the folders only.
+			if pc = (i*100 + pc) / len(views); pc > percentage {
+				percentage = pc
+				p.report(fmt.Sprintf("%s: %s %d/%d packages", name, stage, done, total), float64(pc))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		for filename, diagnostics := range reports {
+			for _, d := range diagnostics {
+				if d.Severity == source.SeverityError {
+					errors++
+				}
+			}
+			s.publishDiagnostics(ctx, v, filename, diagnostics)
+		}
+	}
+	if errors > 0 {
+		return fmt.Errorf("found %d errors", errors)
+	}
+	return nil
+}
+
+// runGo runs the go command in dir, with the environment of the view for
+// dir, and logs the command and its output.
+func (s *server) runGo(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Env = s.viewFor(protocol.DocumentURI(source.ToURI(dir))).Env(dir)
+	out, runErr := cmd.CombinedOutput()
+	if err := s.logf(ctx, protocol.Info, "go %s\n%s", strings.Join(args, " "), out); err != nil {
+		return err
+	}
+	return runErr
+}
+
+// filenameArg returns the filename of an argument that is a URI.
+func filenameArg(arg interface{}) (string, error) {
+	uri, ok := arg.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a URI, got %v", arg)
+	}
+	return source.URI(uri).Filename()
+}
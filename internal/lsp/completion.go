@@ -5,27 +5,104 @@
 package lsp
 
 import (
+	"encoding/json"
 	"sort"
+	"sync/atomic"
 
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
 )
 
-func toProtocolCompletionItems(items []source.CompletionItem) []protocol.CompletionItem {
+// toProtocolCompletionItems converts the completion items, which insert their
+// snippets if the client supports them, and replace their ranges, if any.
+func toProtocolCompletionItems(m *columnMapper, items []source.CompletionItem, snippets bool) []protocol.CompletionItem {
 	var results []protocol.CompletionItem
-	sort.Slice(items, func(i, j int) bool {
+	sort.SliceStable(items, func(i, j int) bool {
 		return items[i].Score > items[j].Score
 	})
 	for _, item := range items {
-		results = append(results, protocol.CompletionItem{
+		result := protocol.CompletionItem{
 			Label:  item.Label,
 			Detail: item.Detail,
 			Kind:   float64(toProtocolCompletionItemKind(item.Kind)),
-		})
+
+			FilterText:          item.FilterText,
+			AdditionalTextEdits: toProtocolEdits(m, item.AdditionalTextEdits),
+		}
+		text := item.InsertText
+		if snippets && item.Snippet != "" {
+			text = item.Snippet
+			result.InsertTextFormat = protocol.SnippetTextFormat
+		}
+		if item.Replace.Start.IsValid() {
+			result.TextEdit = &protocol.TextEdit{
+				Range:   toProtocolRange(m, item.Replace),
+				NewText: text,
+			}
+		} else {
+			result.InsertText = text
+		}
+		results = append(results, result)
 	}
 	return results
 }
 
+// completionLists numbers the lists of completion items.
+var completionLists int64
+
+// A completionList is the list of the items of a completion, which
+// completionItem/resolve resolves.
+type completionList struct {
+	id    int64
+	f     *source.File
+	items []source.CompletionItem
+}
+
+// completionData is the data of a completion item, which identifies it in
+// its list.
+type completionData struct {
+	List  int64 `json:"list"`
+	Index int   `json:"index"`
+}
+
+// rememberCompletion remembers the items of the last completion, of f, so
+// that they can be resolved, and sets the data of their results, which are
+// in the same order.
+func (s *server) rememberCompletion(f *source.File, items []source.CompletionItem, results []protocol.CompletionItem) {
+	list := &completionList{
+		id:    atomic.AddInt64(&completionLists, 1),
+		f:     f,
+		items: items,
+	}
+	for i := range results {
+		results[i].Data = completionData{List: list.id, Index: i}
+	}
+	s.completionMu.Lock()
+	s.completion = list
+	s.completionMu.Unlock()
+}
+
+// completionItem returns the item that a completion item of the last
+// completion results from, and its file, if it is one.
+func (s *server) completionItem(item *protocol.CompletionItem) (*source.File, source.CompletionItem, bool) {
+	// The data is decoded as generic JSON values.
+	b, err := json.Marshal(item.Data)
+	if err != nil {
+		return nil, source.CompletionItem{}, false
+	}
+	var data completionData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, source.CompletionItem{}, false
+	}
+	s.completionMu.Lock()
+	list := s.completion
+	s.completionMu.Unlock()
+	if list == nil || list.id != data.List || data.Index < 0 || data.Index >= len(list.items) {
+		return nil, source.CompletionItem{}, false
+	}
+	return list.f, list.items[data.Index], true
+}
+
 func toProtocolCompletionItemKind(kind source.CompletionItemKind) protocol.CompletionItemKind {
 	switch kind {
 	case source.InterfaceCompletionItem:
@@ -46,6 +123,12 @@ func toProtocolCompletionItemKind(kind source.CompletionItemKind) protocol.Compl
 		return protocol.MethodCompletion
 	case source.PackageCompletionItem:
 		return protocol.ModuleCompletion // ??
+	case source.SnippetCompletionItem:
+		return protocol.SnippetCompletion
+	case source.FileCompletionItem:
+		return protocol.FileCompletion
+	case source.FolderCompletionItem:
+		return protocol.FolderCompletion
 	default:
 		return protocol.TextCompletion
 	}
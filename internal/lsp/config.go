@@ -0,0 +1,198 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// configurationSection is the section of the client's configuration that
+// holds the settings of the server.
+const configurationSection = "golsp"
+
+// hintSettings maps the names of the settings that enable or disable each
+// kind of inlay hint to that kind.
+var hintSettings = map[string]source.InlayHintKind{
+	"parameterNames":      source.ParameterNameHint,
+	"assignVariableTypes": source.VariableTypeHint,
+}
+
+// tagCaseSettings maps the values of the setting of the naming convention of
+// added struct tags to the convention.
+var tagCaseSettings = map[string]source.TagCase{
+	"snake_case": source.SnakeCase,
+	"camelCase":  source.CamelCase,
+}
+
+// severitySettings maps the values of the settings of the severities of
+// the analyses to the severity.
+var severitySettings = map[string]source.DiagnosticSeverity{
+	"error":   source.SeverityError,
+	"warning": source.SeverityWarning,
+	"info":    source.SeverityInformation,
+	"hint":    source.SeverityHint,
+}
+
+// fetchConfiguration requests the settings of each view from the client, if
+// it supports it, and sets the options of the views to them. The settings of
+// the view of a workspace folder are those of the scope of the folder.
+func (s *server) fetchConfiguration(ctx context.Context) error {
+	if !s.clientOptions.configuration || s.client == nil {
+		return nil
+	}
+	views := s.views()
+	items := make([]protocol.ConfigurationItem, len(views))
+	for i, v := range views {
+		items[i].Section = configurationSection
+		if v != s.view {
+			items[i].ScopeURI = string(source.ToURI(v.Config.Dir))
+		}
+	}
+	configs, err := s.client.Configuration(ctx, &protocol.ConfigurationParams{Items: items})
+	if err != nil {
+		return err
+	}
+	for i, config := range configs {
+		if i >= len(views) {
+			break
+		}
+		views[i].SetOptions(parseOptions(config))
+		if views[i] == s.view {
+			// The log level is a setting of the whole server.
+			s.setLogLevel(parseLogLevel(config))
+		}
+	}
+	return nil
+}
+
+// parseOptions returns the options that the settings sent by the client set.
+// Unknown and malformed settings are ignored, and the options that they do
+// not set have their default values. For example:
+//
+//	"golsp": {
+//		"completion": {"matchCase": false, "maxResults": 100, "usePlaceholders": true},
+//		"hover": {"verbose": true},
+//		"references": {"includeImplementations": true},
+//		"analyses": {"shadow": true, "printf": false},
+//		"analysisSeverities": {"printf": "error", "unreachable": "hint"},
+//		"unusedExported": true,
+//		"templates": true,
+//		"formatOnSave": true,
+//		"organizeImportsOnSave": true,
+//		"buildFlags": ["-tags=integration"],
+//		"env": {"GOFLAGS": "-mod=vendor"},
+//		"documentationURL": "https://godoc.org",
+//		"hints": {"parameterNames": false},
+//		"structTagCase": "camelCase",
+//		"typeCheckParallelism": 4,
+//		"memoryBudget": 2048,
+//		"diagnosticsDelay": 500,
+//		"logLevel": "log"
+//	}
+//
+// The memory budget is in megabytes, and the delay of the diagnostics in
+// milliseconds. The log level is a setting of the server rather than of a
+// view, which parseLogLevel reads.
+func parseOptions(config interface{}) source.Options {
+	options := source.DefaultOptions()
+	settings, _ := config.(map[string]interface{})
+	completion, _ := settings["completion"].(map[string]interface{})
+	if matchCase, ok := completion["matchCase"].(bool); ok {
+		options.Completion.MatchCase = matchCase
+	}
+	if maxResults, ok := completion["maxResults"].(float64); ok {
+		options.Completion.MaxResults = int(maxResults)
+	}
+	if usePlaceholders, ok := completion["usePlaceholders"].(bool); ok {
+		options.Completion.UsePlaceholders = usePlaceholders
+	}
+	hover, _ := settings["hover"].(map[string]interface{})
+	if verbose, ok := hover["verbose"].(bool); ok {
+		options.Hover.Verbose = verbose
+	}
+	references, _ := settings["references"].(map[string]interface{})
+	if includeImplementations, ok := references["includeImplementations"].(bool); ok {
+		options.References.IncludeImplementations = includeImplementations
+	}
+	if analyses, ok := settings["analyses"].(map[string]interface{}); ok {
+		options.Diagnostics.Analyses = make(map[string]bool)
+		for name, enabled := range analyses {
+			if enabled, ok := enabled.(bool); ok {
+				options.Diagnostics.Analyses[name] = enabled
+			}
+		}
+	}
+	if severities, ok := settings["analysisSeverities"].(map[string]interface{}); ok {
+		options.Diagnostics.AnalysisSeverities = make(map[string]source.DiagnosticSeverity)
+		for name, severity := range severities {
+			if severity, ok := severity.(string); ok {
+				if severity, ok := severitySettings[severity]; ok {
+					options.Diagnostics.AnalysisSeverities[name] = severity
+				}
+			}
+		}
+	}
+	if unusedExported, ok := settings["unusedExported"].(bool); ok {
+		options.Diagnostics.UnusedExported = unusedExported
+	}
+	if templates, ok := settings["templates"].(bool); ok {
+		options.Templates = templates
+	}
+	if formatOnSave, ok := settings["formatOnSave"].(bool); ok {
+		options.FormatOnSave = formatOnSave
+	}
+	if organizeImports, ok := settings["organizeImportsOnSave"].(bool); ok {
+		options.OrganizeImportsOnSave = organizeImports
+	}
+	buildFlags, _ := settings["buildFlags"].([]interface{})
+	for _, flag := range buildFlags {
+		if flag, ok := flag.(string); ok {
+			options.BuildFlags = append(options.BuildFlags, flag)
+		}
+	}
+	env, _ := settings["env"].(map[string]interface{})
+	for key, value := range env {
+		if value, ok := value.(string); ok {
+			options.Env = append(options.Env, key+"="+value)
+		}
+	}
+	sort.Strings(options.Env)
+	if docURL, ok := settings["documentationURL"].(string); ok {
+		options.DocumentationURL = docURL
+	}
+	hints, _ := settings["hints"].(map[string]interface{})
+	for name, kind := range hintSettings {
+		if enabled, ok := hints[name].(bool); ok {
+			options.Hints[kind] = enabled
+		}
+	}
+	if tagCase, ok := settings["structTagCase"].(string); ok {
+		options.StructTagCase = tagCaseSettings[tagCase]
+	}
+	if parallelism, ok := settings["typeCheckParallelism"].(float64); ok {
+		options.TypeCheckParallelism = int(parallelism)
+	}
+	if memoryBudget, ok := settings["memoryBudget"].(float64); ok {
+		options.MemoryBudget = int64(memoryBudget) << 20
+	}
+	if delay, ok := settings["diagnosticsDelay"].(float64); ok {
+		options.DiagnosticsDelay = time.Duration(delay) * time.Millisecond
+	}
+	return options
+}
+
+// parseLogLevel returns the log level that the settings sent by the client
+// set, which is one of "error", "warning", "info" and "log", or zero if they
+// do not set a known one.
+func parseLogLevel(config interface{}) protocol.MessageType {
+	settings, _ := config.(map[string]interface{})
+	level, _ := settings["logLevel"].(string)
+	return logLevelSettings[level]
+}
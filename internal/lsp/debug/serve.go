@@ -0,0 +1,227 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package debug serves a web page that shows the state of the language
+// server, for its developers and for the diagnosis of its problems: its
+// sessions, their open files and cached packages, and the latencies of the
+// recent requests. It also serves the pprof profiles of the process.
+package debug
+
+import (
+	"html/template"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/telemetry"
+)
+
+// maxRecentRequests is the number of recent requests whose latency is shown.
+const maxRecentRequests = 100
+
+var (
+	mu       sync.Mutex
+	sessions []*source.Session
+	recent   []request                 // the latest requests, oldest first
+	methods  = make(map[string]*stats) // by the method of the requests
+)
+
+// A request is a request that the server has handled.
+type request struct {
+	Method   string
+	Start    time.Time
+	Duration time.Duration
+	Error    string
+}
+
+// stats are the statistics of the latencies of the requests of a method.
+type stats struct {
+	Method string
+	Count  int
+	Total  time.Duration
+	Max    time.Duration
+}
+
+func (s *stats) Mean() time.Duration { return s.Total / time.Duration(s.Count) }
+
+// AddSession adds a session to the ones that are shown.
+func AddSession(s *source.Session) {
+	mu.Lock()
+	defer mu.Unlock()
+	sessions = append(sessions, s)
+}
+
+// DropSession removes a session added by AddSession.
+func DropSession(s *source.Session) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, x := range sessions {
+		if x == s {
+			sessions = append(sessions[:i], sessions[i+1:]...)
+			return
+		}
+	}
+}
+
+// requestExporter records the latencies of the requests, from the spans of
+// their handling.
+type requestExporter struct{}
+
+func (requestExporter) ExportSpan(span *telemetry.Span) {
+	if span.ParentID != "" || !strings.HasPrefix(span.Name, "lsp.") {
+		return
+	}
+	if _, ok := span.Attributes["id"]; !ok {
+		return // a notification
+	}
+	r := request{
+		Method:   strings.TrimPrefix(span.Name, "lsp."),
+		Start:    span.Start,
+		Duration: span.Duration(),
+		Error:    span.Error,
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(recent) == maxRecentRequests {
+		recent = append(recent[:0], recent[1:]...)
+	}
+	recent = append(recent, r)
+	s := methods[r.Method]
+	if s == nil {
+		s = &stats{Method: r.Method}
+		methods[r.Method] = s
+	}
+	s.Count++
+	s.Total += r.Duration
+	if r.Duration > s.Max {
+		s.Max = r.Duration
+	}
+}
+
+// Serve serves the debug page on addr until it fails. An address without a
+// host is served on localhost only, as the page reveals the source of the
+// workspace.
+func Serve(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		addr = net.JoinHostPort("localhost", port)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	telemetry.RegisterExporter(requestExporter{})
+	defer telemetry.UnregisterExporter(requestExporter{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", render)
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return http.Serve(l, mux)
+}
+
+// The data of the debug page.
+type (
+	pageData struct {
+		Sessions []sessionData
+		Methods  []*stats
+		Recent   []request
+	}
+	sessionData struct {
+		OpenFiles []source.URI
+		Views     []viewData
+	}
+	viewData struct {
+		Dir      string
+		Packages []source.PackageMemory
+		Total    int64
+	}
+)
+
+func render(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	var data pageData
+	mu.Lock()
+	ss := append([]*source.Session(nil), sessions...)
+	for _, s := range methods {
+		c := *s
+		data.Methods = append(data.Methods, &c)
+	}
+	for i := len(recent) - 1; i >= 0; i-- {
+		data.Recent = append(data.Recent, recent[i])
+	}
+	mu.Unlock()
+	sort.Slice(data.Methods, func(i, j int) bool { return data.Methods[i].Method < data.Methods[j].Method })
+	for _, s := range ss {
+		sd := sessionData{OpenFiles: s.OpenFiles()}
+		sort.Slice(sd.OpenFiles, func(i, j int) bool { return sd.OpenFiles[i] < sd.OpenFiles[j] })
+		for _, v := range s.Views() {
+			vd := viewData{Dir: v.Config.Dir, Packages: v.MemoryUsage()}
+			for _, p := range vd.Packages {
+				vd.Total += p.Bytes
+			}
+			sd.Views = append(sd.Views, vd)
+		}
+		data.Sessions = append(data.Sessions, sd)
+	}
+	if err := page.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var page = template.Must(template.New("").Funcs(template.FuncMap{
+	"kb": func(n int64) int64 { return n >> 10 },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>golsp</title>
+<style>
+td { padding-right: 1em; }
+td.num { text-align: right; }
+</style>
+</head>
+<body>
+<h1>golsp</h1>
+<p><a href="/debug/pprof/">Profiles</a></p>
+{{range $i, $s := .Sessions}}
+<h2>Session {{$i}}</h2>
+<h3>Open files</h3>
+<ul>{{range .OpenFiles}}<li>{{.}}</li>{{else}}<li>none</li>{{end}}</ul>
+{{range .Views}}
+<h3>View {{if .Dir}}{{.Dir}}{{else}}of the files outside of the workspace{{end}}</h3>
+<table>
+<tr><th>Package</th><th>Estimated memory</th></tr>
+{{range .Packages}}<tr><td>{{.PkgPath}}{{if .Open}} (open){{end}}</td><td class="num">{{kb .Bytes}} KB</td></tr>
+{{end}}<tr><td>total</td><td class="num">{{kb .Total}} KB</td></tr>
+</table>
+{{end}}
+{{else}}
+<p>No sessions.</p>
+{{end}}
+<h2>Latencies</h2>
+<table>
+<tr><th>Method</th><th>Requests</th><th>Mean</th><th>Max</th></tr>
+{{range .Methods}}<tr><td>{{.Method}}</td><td class="num">{{.Count}}</td><td class="num">{{.Mean}}</td><td class="num">{{.Max}}</td></tr>
+{{end}}</table>
+<h2>Recent requests</h2>
+<table>
+<tr><th>Start</th><th>Method</th><th>Duration</th><th>Error</th></tr>
+{{range .Recent}}<tr><td>{{.Start.Format "15:04:05.000"}}</td><td>{{.Method}}</td><td class="num">{{.Duration}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
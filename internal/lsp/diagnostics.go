@@ -5,21 +5,117 @@
 package lsp
 
 import (
+	"context"
 	"sort"
+	"time"
 
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
 )
 
+// scheduleDiagnostics publishes the diagnostics of a document after delay,
+// unless the document changes again in the meantime, which delays them
+// again: the changes made by fast typing are diagnosed once, after the
+// last of them. Scheduling the diagnostics cancels the outdated ones in
+// progress. The diagnostics outlive the operation that schedules them, so
+// they are not cancelled with its context.
+func (s *server) scheduleDiagnostics(ctx context.Context, uri protocol.DocumentURI, delay time.Duration) {
+	ctx = detachedContext{ctx}
+	s.diagnosingMu.Lock()
+	defer s.diagnosingMu.Unlock()
+	s.cancelDiagnostics(uri)
+	if delay <= 0 {
+		go s.diagnose(ctx, uri)
+		return
+	}
+	if s.pendingDiagnostics == nil {
+		s.pendingDiagnostics = make(map[protocol.DocumentURI]*time.Timer)
+	}
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		s.diagnosingMu.Lock()
+		current := s.pendingDiagnostics[uri] == t
+		if current {
+			delete(s.pendingDiagnostics, uri)
+		}
+		s.diagnosingMu.Unlock()
+		if current {
+			s.diagnose(ctx, uri)
+		}
+	})
+	s.pendingDiagnostics[uri] = t
+}
+
+// cancelDiagnostics stops the delayed diagnostics of a document, and cancels
+// those in progress. It must be called with s.diagnosingMu held.
+func (s *server) cancelDiagnostics(uri protocol.DocumentURI) {
+	if t, ok := s.pendingDiagnostics[uri]; ok {
+		t.Stop()
+		delete(s.pendingDiagnostics, uri)
+	}
+	if d, ok := s.diagnosing[uri]; ok {
+		d.cancel()
+		delete(s.diagnosing, uri)
+	}
+}
+
+// A detachedContext has the values of its parent context, but is never
+// cancelled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (c detachedContext) Done() <-chan struct{}             { return nil }
+func (c detachedContext) Err() error                        { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// publishDiagnostics publishes the diagnostics of the file filename of v,
+// without their related information if the client does not support it.
+func (s *server) publishDiagnostics(ctx context.Context, v *source.View, filename string, diagnostics []source.Diagnostic) {
+	reports := toProtocolDiagnostics(v, diagnostics)
+	if !s.clientOptions.relatedInformation {
+		for i := range reports {
+			reports[i].Related = nil
+		}
+	}
+	s.client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
+		URI:         protocol.DocumentURI(source.ToURI(filename)),
+		Diagnostics: reports,
+	})
+}
+
 func toProtocolDiagnostics(v *source.View, diagnostics []source.Diagnostic) []protocol.Diagnostic {
 	reports := []protocol.Diagnostic{}
 	for _, diag := range diagnostics {
-		tok := v.Config.Fset.File(diag.Range.Start)
+		m := fileColumnMapper(v, diag.Range.Start)
+		var related []protocol.DiagnosticRelatedInformation
+		for _, rel := range diag.Related {
+			related = append(related, protocol.DiagnosticRelatedInformation{
+				Location: toProtocolLocation(v, rel.Range),
+				Message:  rel.Message,
+			})
+		}
+		var tags []protocol.DiagnosticTag
+		for _, tag := range diag.Tags {
+			switch tag {
+			case source.DeprecatedTag:
+				tags = append(tags, protocol.Deprecated)
+			case source.UnnecessaryTag:
+				tags = append(tags, protocol.Unnecessary)
+			}
+		}
+		source := diag.Source
+		if source == "" {
+			source = "LSP"
+		}
 		reports = append(reports, protocol.Diagnostic{
 			Message:  diag.Message,
-			Range:    toProtocolRange(tok, diag.Range),
+			Range:    toProtocolRange(m, diag.Range),
 			Severity: toProtocolSeverity(diag.Severity),
-			Source:   "LSP",
+			Source:   source,
+			Related:  related,
+			Tags:     tags,
 		})
 	}
 	return reports
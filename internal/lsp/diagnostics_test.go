@@ -0,0 +1,160 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/packages/packagestest"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// A publishClient is a client that records the diagnostics published to it.
+type publishClient struct {
+	protocol.Client
+
+	mu        sync.Mutex
+	published []*protocol.PublishDiagnosticsParams
+	// publishedc receives a value for each publication.
+	publishedc chan struct{}
+}
+
+func (c *publishClient) PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error {
+	c.mu.Lock()
+	c.published = append(c.published, params)
+	c.mu.Unlock()
+	c.publishedc <- struct{}{}
+	return nil
+}
+
+func (c *publishClient) LogMessage(context.Context, *protocol.LogMessageParams) error {
+	return nil
+}
+
+func (c *publishClient) publications() []*protocol.PublishDiagnosticsParams {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*protocol.PublishDiagnosticsParams(nil), c.published...)
+}
+
+// wait waits for a publication of diagnostics.
+func (c *publishClient) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.publishedc:
+	case <-time.After(time.Minute):
+		t.Fatal("no diagnostics were published")
+	}
+}
+
+// diagnosticsTestServer returns a server of a package with a single file,
+// its client, the URI of the file, and the function that removes the file.
+// The diagnostics of a change are delayed for longer than any test runs, so
+// they are published only when the document is saved.
+func diagnosticsTestServer(t *testing.T) (*server, *publishClient, protocol.DocumentURI, func()) {
+	exported := packagestest.Export(t, packagestest.GOPATH, []packagestest.Module{{
+		Name:  "golang.org/fake",
+		Files: map[string]interface{}{"a/a.go": "package a\n"},
+	}})
+	client := &publishClient{publishedc: make(chan struct{}, 10)}
+	s := &server{view: source.NewView(), client: client}
+	cfg := *exported.Config
+	cfg.Fset = s.view.Config.Fset
+	cfg.Mode = packages.LoadSyntax
+	s.view.Config = &cfg
+	options := s.view.Options()
+	options.DiagnosticsDelay = time.Hour
+	s.view.SetOptions(options)
+	uri := protocol.DocumentURI(source.ToURI(exported.File("golang.org/fake", "a/a.go")))
+	return s, client, uri, exported.Cleanup
+}
+
+// change changes the content of the document with the given URI.
+func change(t *testing.T, s *server, uri protocol.DocumentURI, text string) {
+	t.Helper()
+	err := s.DidChange(context.Background(), &protocol.DidChangeTextDocumentParams{
+		TextDocument:   protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}},
+		ContentChanges: []protocol.TextDocumentContentChangeEvent{{Text: text}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pending returns the number of the delayed diagnostics of s, and of those in
+// progress.
+func pending(s *server) (delayed, diagnosing int) {
+	s.diagnosingMu.Lock()
+	defer s.diagnosingMu.Unlock()
+	return len(s.pendingDiagnostics), len(s.diagnosing)
+}
+
+func TestDiagnosticsCoalescing(t *testing.T) {
+	s, client, uri, cleanup := diagnosticsTestServer(t)
+	defer cleanup()
+
+	// Fast typing: each change comes before the delay of the previous one.
+	for _, text := range []string{
+		"package a\n\nvar",
+		"package a\n\nvar x",
+		"package a\n\nvar x int",
+		"package a\n\nvar x int = ",
+		"package a\n\nvar x int = \"x\"\n",
+	} {
+		change(t, s, uri, text)
+	}
+	if delayed, _ := pending(s); delayed != 1 {
+		t.Fatalf("%d diagnostics are delayed after the changes, want 1", delayed)
+	}
+	// Saving the document publishes its delayed diagnostics at once.
+	if err := s.DidSave(context.Background(), &protocol.DidSaveTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	client.wait(t)
+	if delayed, _ := pending(s); delayed != 0 {
+		t.Errorf("%d diagnostics are still delayed after they were published", delayed)
+	}
+	published := client.publications()
+	if len(published) != 1 {
+		t.Fatalf("got %d publications of diagnostics, want 1", len(published))
+	}
+	if published[0].URI != uri || len(published[0].Diagnostics) != 1 {
+		t.Errorf("got diagnostics %v of %s, want the error of the last change of %s", published[0].Diagnostics, published[0].URI, uri)
+	}
+}
+
+func TestDiagnosticsCancelledOnClose(t *testing.T) {
+	s, client, uri, cleanup := diagnosticsTestServer(t)
+	defer cleanup()
+
+	change(t, s, uri, "package a\n\nvar x int = \"x\"\n")
+	s.diagnosingMu.Lock()
+	timer := s.pendingDiagnostics[uri]
+	s.diagnosingMu.Unlock()
+	if timer == nil {
+		t.Fatal("the diagnostics of the change are not delayed")
+	}
+	if err := s.DidClose(context.Background(), &protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if delayed, diagnosing := pending(s); delayed != 0 || diagnosing != 0 {
+		t.Errorf("%d diagnostics are delayed and %d in progress after the document was closed", delayed, diagnosing)
+	}
+	if timer.Stop() {
+		t.Errorf("the timer of the delayed diagnostics was not stopped when the document was closed")
+	}
+	if published := client.publications(); len(published) != 0 {
+		t.Errorf("got %d publications of diagnostics of a closed document, want none", len(published))
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package diff computes the differences between two sequences of lines,
+// using the algorithm described in "An O(ND) Difference Algorithm and Its
+// Variations" by Eugene W. Myers.
+package diff
+
+import "strings"
+
+type OpKind int
+
+const (
+	Delete OpKind = iota
+	Insert
+	Equal
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case Delete:
+		return "delete"
+	case Insert:
+		return "insert"
+	case Equal:
+		return "equal"
+	}
+	return "unknown"
+}
+
+// Op is an operation that transforms a run of lines of the first sequence.
+type Op struct {
+	Kind    OpKind
+	Content []string // the lines that are deleted, inserted or kept
+	I1, I2  int      // the lines of the first sequence, a[I1:I2], that the operation applies to
+	J1      int      // the index of the first line of Content in the second sequence
+}
+
+// Operations returns the operations that transform the lines of a into the
+// lines of b, with as few inserted and deleted lines as possible.
+// Consecutive lines with the same kind of operation are merged into a single
+// Op, and a deletion always precedes an insertion at the same place.
+func Operations(a, b []string) []*Op {
+	var ops []*Op
+	add := func(kind OpKind, line string, i, j int) {
+		if len(ops) > 0 {
+			last := ops[len(ops)-1]
+			if last.Kind == kind {
+				last.Content = append(last.Content, line)
+				if kind != Insert {
+					last.I2++
+				}
+				return
+			}
+		}
+		op := &Op{Kind: kind, Content: []string{line}, I1: i, I2: i, J1: j}
+		if kind != Insert {
+			op.I2++
+		}
+		ops = append(ops, op)
+	}
+	x, y := 0, 0
+	for _, e := range editScript(a, b) {
+		switch e {
+		case Equal:
+			add(Equal, a[x], x, y)
+			x++
+			y++
+		case Delete:
+			add(Delete, a[x], x, y)
+			x++
+		case Insert:
+			add(Insert, b[y], x, y)
+			y++
+		}
+	}
+	return ops
+}
+
+// editScript returns the shortest sequence of single line operations that
+// transforms a into b.
+func editScript(a, b []string) []OpKind {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+	// v[offset+k] is the furthest x reached on diagonal k = x - y.
+	offset := max
+	v := make([]int, 2*max+2)
+	// trace[d] is the part of v that is reachable with d-1 operations,
+	// for diagonals -d to d.
+	var trace [][]int
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // down: insertion
+			} else {
+				x = v[offset+k-1] + 1 // right: deletion
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	// Walk the trace backwards to recover the operations.
+	var script []OpKind
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			script = append(script, Equal)
+			x--
+			y--
+		}
+		if x == prevX {
+			script = append(script, Insert)
+			y--
+		} else {
+			script = append(script, Delete)
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		script = append(script, Equal)
+		x--
+		y--
+	}
+	for i, j := 0, len(script)-1; i < j; i, j = i+1, j-1 {
+		script[i], script[j] = script[j], script[i]
+	}
+	return script
+}
+
+// SplitLines splits text into lines, keeping the line endings.
+// The last line has no line ending if text does not end with one.
+func SplitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+var tests = []struct {
+	a, b    string
+	changed int // the number of deleted and inserted lines
+}{
+	{"", "", 0},
+	{"a\n", "a\n", 0},
+	{"", "a\nb\n", 2},
+	{"a\nb\n", "", 2},
+	{"a\nb\nc\n", "a\nc\n", 1},
+	{"a\nc\n", "a\nb\nc\n", 1},
+	{"a\nb\nc\n", "a\nB\nc\n", 2},
+	{"a\nb\nc\nd\n", "b\nc\nd\ne\n", 2},
+	{"a\nb\nc\na\nb\nb\na\n", "c\nb\na\nb\na\nc\n", 5},
+	{"a\nb", "a\nb\n", 2},
+	{"x\ny\nz\n", "z\ny\nx\n", 4},
+}
+
+func TestOperations(t *testing.T) {
+	for _, test := range tests {
+		a, b := SplitLines(test.a), SplitLines(test.b)
+		ops := Operations(a, b)
+		var got []string
+		changed := 0
+		i := 0
+		for _, op := range ops {
+			if op.I1 != i {
+				t.Errorf("Operations(%q, %q): op %v starts at line %d, want %d", test.a, test.b, op.Kind, op.I1, i)
+			}
+			switch op.Kind {
+			case Equal:
+				got = append(got, a[op.I1:op.I2]...)
+				i = op.I2
+			case Delete:
+				changed += op.I2 - op.I1
+				i = op.I2
+			case Insert:
+				if op.J1 != len(got) {
+					t.Errorf("Operations(%q, %q): insertion at line %d of the result, want %d", test.a, test.b, op.J1, len(got))
+				}
+				got = append(got, op.Content...)
+				changed += len(op.Content)
+			}
+		}
+		if result := strings.Join(got, ""); result != test.b {
+			t.Errorf("Operations(%q, %q) produced %q", test.a, test.b, result)
+		}
+		if changed != test.changed {
+			t.Errorf("Operations(%q, %q) changed %d lines, want %d", test.a, test.b, changed, test.changed)
+		}
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	for _, test := range []struct {
+		in  string
+		out []string
+	}{
+		{"", nil},
+		{"a", []string{"a"}},
+		{"a\n", []string{"a\n"}},
+		{"a\nb", []string{"a\n", "b"}},
+		{"\n\n", []string{"\n", "\n"}},
+	} {
+		got := SplitLines(test.in)
+		if strings.Join(got, "|") != strings.Join(test.out, "|") || len(got) != len(test.out) {
+			t.Errorf("SplitLines(%q) = %q, want %q", test.in, got, test.out)
+		}
+	}
+}
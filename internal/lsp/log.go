@@ -0,0 +1,57 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// defaultLogLevel is the most verbose type of the messages that the server
+// logs if the user has not set one.
+const defaultLogLevel = protocol.Info
+
+// logLevelSettings maps the values of the setting of the log level to the
+// most verbose type of the messages that the server logs. At the log level,
+// the server also logs the duration of its slower operations, such as
+// completion.
+var logLevelSettings = map[string]protocol.MessageType{
+	"error":   protocol.Error,
+	"warning": protocol.Warning,
+	"info":    protocol.Info,
+	"log":     protocol.Log,
+}
+
+// logf logs a message of the given type in the client, with a
+// window/logMessage notification, unless it is more verbose than the log
+// level of the server.
+func (s *server) logf(ctx context.Context, typ protocol.MessageType, format string, args ...interface{}) error {
+	if typ > s.getLogLevel() {
+		return nil
+	}
+	return s.client.LogMessage(ctx, &protocol.LogMessageParams{
+		Type:    typ,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (s *server) getLogLevel() protocol.MessageType {
+	s.logLevelMu.Lock()
+	defer s.logLevelMu.Unlock()
+	if s.logLevel == 0 {
+		return defaultLogLevel
+	}
+	return s.logLevel
+}
+
+// setLogLevel sets the log level of the server. Zero selects the default
+// level.
+func (s *server) setLogLevel(level protocol.MessageType) {
+	s.logLevelMu.Lock()
+	defer s.logLevelMu.Unlock()
+	s.logLevel = level
+}
@@ -7,11 +7,13 @@ package lsp
 import (
 	"bytes"
 	"context"
+	"flag"
 	"fmt"
 	"go/token"
-	"os/exec"
+	"io/ioutil"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
@@ -25,16 +27,30 @@ import (
 // for versions of Go <= 1.10.
 var goVersion111 = true
 
+var update = flag.Bool("update", false, "update the golden files of the tests with their output")
+
 func TestLSP(t *testing.T) {
 	packagestest.TestAll(t, testLSP)
 }
 
 func testLSP(t *testing.T, exporter packagestest.Exporter) {
 	const dir = "testdata"
-	const expectedCompletionsCount = 43
+	const expectedCompletionsCount = 46
 	const expectedDiagnosticsCount = 14
 	const expectedFormatCount = 3
+	const expectedImportsCount = 1
+	const expectedSuggestedFixesCount = 5
 	const expectedDefinitionsCount = 16
+	const expectedTypeDefinitionsCount = 3
+	const expectedSignaturesCount = 8
+	const expectedHoversCount = 7
+	const expectedReferencesCount = 4
+	const expectedRenamesCount = 11
+	const expectedSymbolsCount = 8
+	const expectedWorkspaceSymbolsCount = 1
+	const expectedIncomingCallsCount = 2
+	const expectedOutgoingCallsCount = 2
+	const expectedRefactoringsCount = 14
 
 	files := packagestest.MustCopyFileTree(dir)
 	for fragment, operation := range files {
@@ -52,15 +68,38 @@ func testLSP(t *testing.T, exporter packagestest.Exporter) {
 	exported := packagestest.Export(t, exporter, modules)
 	defer exported.Cleanup()
 
+	golden := &goldens{dir: dir, fragments: make(map[string]string)}
+	for fragment := range files {
+		golden.fragments[exported.File(modules[0].Name, fragment)] = fragment
+	}
+
 	// collect results for certain tests
 	expectedDiagnostics := make(diagnostics)
 	completionItems := make(completionItems)
 	expectedCompletions := make(completions)
 	expectedFormat := make(formats)
+	expectedImports := make(imports)
+	expectedSuggestedFixes := make(suggestedFixes)
 	expectedDefinitions := make(definitions)
+	expectedTypeDefinitions := make(definitions)
+	expectedSignatures := make(signatures)
+	expectedHovers := make(hovers)
+	expectedReferences := make(references)
+	expectedRenames := make(renames)
+	expectedSymbols := make(symbols)
+	expectedWorkspaceSymbols := make(workspaceSymbols)
+	expectedIncomingCalls := make(calls)
+	expectedOutgoingCalls := make(calls)
+	expectedRefactorings := make(refactorings)
 
 	s := &server{
 		view: source.NewView(),
+		clientOptions: clientOptions{
+			hoverKind:           protocol.Markdown,
+			completionDocKind:   protocol.Markdown,
+			hierarchicalSymbols: true,
+			relatedInformation:  true,
+		},
 	}
 	// merge the config objects
 	cfg := *exported.Config
@@ -78,11 +117,25 @@ func testLSP(t *testing.T, exporter packagestest.Exporter) {
 	}
 	// Collect any data that needs to be used by subsequent tests.
 	if err := exported.Expect(map[string]interface{}{
-		"diag":     expectedDiagnostics.collect,
-		"item":     completionItems.collect,
-		"complete": expectedCompletions.collect,
-		"format":   expectedFormat.collect,
-		"godef":    expectedDefinitions.collect,
+		"diag":            expectedDiagnostics.collect,
+		"item":            completionItems.collect,
+		"complete":        expectedCompletions.collect,
+		"format":          expectedFormat.collect,
+		"imports":         expectedImports.collect,
+		"suggestedfix":    expectedSuggestedFixes.collect,
+		"godef":           expectedDefinitions.collect,
+		"typdef":          expectedTypeDefinitions.collect,
+		"signature":       expectedSignatures.collect,
+		"hover":           expectedHovers.collect,
+		"refs":            expectedReferences.collect,
+		"rename":          expectedRenames.collect,
+		"renameerr":       expectedRenames.collectError,
+		"symbol":          expectedSymbols.collect,
+		"workspacesymbol": expectedWorkspaceSymbols.collect,
+		"incoming":        expectedIncomingCalls.collect,
+		"outgoing":        expectedOutgoingCalls.collect,
+		"refactor":        expectedRefactorings.collect,
+		"norefactor":      expectedRefactorings.collectNone,
 	}); err != nil {
 		t.Fatal(err)
 	}
@@ -114,7 +167,27 @@ func testLSP(t *testing.T, exporter packagestest.Exporter) {
 				t.Errorf("got %v formats expected %v", len(expectedFormat), expectedFormatCount)
 			}
 		}
-		expectedFormat.test(t, s)
+		expectedFormat.test(t, s, golden)
+	})
+
+	t.Run("Imports", func(t *testing.T) {
+		t.Helper()
+		if goVersion111 { // TODO(rstambler): Remove this when we no longer support Go 1.10.
+			if len(expectedImports) != expectedImportsCount {
+				t.Errorf("got %v imports expected %v", len(expectedImports), expectedImportsCount)
+			}
+		}
+		expectedImports.test(t, s, golden)
+	})
+
+	t.Run("SuggestedFixes", func(t *testing.T) {
+		t.Helper()
+		if goVersion111 { // TODO(rstambler): Remove this when we no longer support Go 1.10.
+			if len(expectedSuggestedFixes) != expectedSuggestedFixesCount {
+				t.Errorf("got %v suggested fixes expected %v", len(expectedSuggestedFixes), expectedSuggestedFixesCount)
+			}
+		}
+		expectedSuggestedFixes.test(t, s, golden)
 	})
 
 	t.Run("Definitions", func(t *testing.T) {
@@ -124,15 +197,140 @@ func testLSP(t *testing.T, exporter packagestest.Exporter) {
 				t.Errorf("got %v definitions expected %v", len(expectedDefinitions), expectedDefinitionsCount)
 			}
 		}
-		expectedDefinitions.test(t, s)
+		expectedDefinitions.test(t, s.Definition)
+	})
+
+	t.Run("TypeDefinitions", func(t *testing.T) {
+		t.Helper()
+		if goVersion111 { // TODO(rstambler): Remove this when we no longer support Go 1.10.
+			if len(expectedTypeDefinitions) != expectedTypeDefinitionsCount {
+				t.Errorf("got %v type definitions expected %v", len(expectedTypeDefinitions), expectedTypeDefinitionsCount)
+			}
+		}
+		expectedTypeDefinitions.test(t, s.TypeDefinition)
+	})
+
+	t.Run("Signatures", func(t *testing.T) {
+		t.Helper()
+		if goVersion111 { // TODO(rstambler): Remove this when we no longer support Go 1.10.
+			if len(expectedSignatures) != expectedSignaturesCount {
+				t.Errorf("got %v signatures expected %v", len(expectedSignatures), expectedSignaturesCount)
+			}
+		}
+		expectedSignatures.test(t, s)
+	})
+
+	t.Run("Hover", func(t *testing.T) {
+		t.Helper()
+		if goVersion111 { // TODO(rstambler): Remove this when we no longer support Go 1.10.
+			if len(expectedHovers) != expectedHoversCount {
+				t.Errorf("got %v hovers expected %v", len(expectedHovers), expectedHoversCount)
+			}
+		}
+		expectedHovers.test(t, s)
+	})
+
+	t.Run("References", func(t *testing.T) {
+		t.Helper()
+		if goVersion111 { // TODO(rstambler): Remove this when we no longer support Go 1.10.
+			if len(expectedReferences) != expectedReferencesCount {
+				t.Errorf("got %v references expected %v", len(expectedReferences), expectedReferencesCount)
+			}
+		}
+		expectedReferences.test(t, s)
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		t.Helper()
+		if goVersion111 { // TODO(rstambler): Remove this when we no longer support Go 1.10.
+			if len(expectedRenames) != expectedRenamesCount {
+				t.Errorf("got %v renames expected %v", len(expectedRenames), expectedRenamesCount)
+			}
+		}
+		expectedRenames.test(t, s)
+	})
+
+	t.Run("Symbols", func(t *testing.T) {
+		t.Helper()
+		symbolsCount := expectedSymbols.test(t, s)
+		if goVersion111 { // TODO(rstambler): Remove this when we no longer support Go 1.10.
+			if symbolsCount != expectedSymbolsCount {
+				t.Errorf("got %v symbols expected %v", symbolsCount, expectedSymbolsCount)
+			}
+		}
+	})
+
+	t.Run("WorkspaceSymbols", func(t *testing.T) {
+		t.Helper()
+		if goVersion111 { // TODO(rstambler): Remove this when we no longer support Go 1.10.
+			if len(expectedWorkspaceSymbols) != expectedWorkspaceSymbolsCount {
+				t.Errorf("got %v workspace symbols expected %v", len(expectedWorkspaceSymbols), expectedWorkspaceSymbolsCount)
+			}
+		}
+		expectedWorkspaceSymbols.test(t, s)
+	})
+
+	t.Run("CallHierarchy", func(t *testing.T) {
+		t.Helper()
+		if goVersion111 { // TODO(rstambler): Remove this when we no longer support Go 1.10.
+			if len(expectedIncomingCalls) != expectedIncomingCallsCount {
+				t.Errorf("got %v incoming calls expected %v", len(expectedIncomingCalls), expectedIncomingCallsCount)
+			}
+			if len(expectedOutgoingCalls) != expectedOutgoingCallsCount {
+				t.Errorf("got %v outgoing calls expected %v", len(expectedOutgoingCalls), expectedOutgoingCallsCount)
+			}
+		}
+		expectedIncomingCalls.test(t, s, false)
+		expectedOutgoingCalls.test(t, s, true)
+	})
+
+	t.Run("Refactorings", func(t *testing.T) {
+		t.Helper()
+		if goVersion111 { // TODO(rstambler): Remove this when we no longer support Go 1.10.
+			if len(expectedRefactorings) != expectedRefactoringsCount {
+				t.Errorf("got %v refactorings expected %v", len(expectedRefactorings), expectedRefactoringsCount)
+			}
+		}
+		expectedRefactorings.test(t, s, golden)
 	})
 }
 
 type diagnostics map[string][]protocol.Diagnostic
 type completionItems map[token.Pos]*protocol.CompletionItem
 type completions map[token.Position][]token.Pos
-type formats map[string]string
+type formats map[string]bool
+type imports map[string]bool
+type suggestedFixes map[string]protocol.Location
 type definitions map[protocol.Location]protocol.Location
+type signatures map[token.Position]signature
+type hovers map[protocol.Location]string
+type references map[protocol.Location][]protocol.Location
+type renames map[protocol.Location]rename
+type symbols map[protocol.DocumentURI][]protocol.DocumentSymbol
+type workspaceSymbols map[string][]protocol.Location
+type calls map[protocol.Location][]protocol.Location
+type refactorings map[protocol.Location]refactor
+
+type signature struct {
+	label       string
+	activeParam int64
+}
+
+// A refactor is a code action that refactors a selection, or that must not
+// be offered for it if it has no golden file.
+type refactor struct {
+	title  string
+	golden bool
+}
+
+// A rename is the expected result of a rename: the locations that it
+// replaces with the new name, or the message of the error of a rename that
+// conflicts with other declarations.
+type rename struct {
+	newName   string
+	locations []protocol.Location
+	err       string
+}
 
 func (c completions) test(t *testing.T, exported *packagestest.Exported, s *server, items completionItems) {
 	for src, itemList := range c {
@@ -155,6 +353,10 @@ func (c completions) test(t *testing.T, exported *packagestest.Exported, s *serv
 			t.Fatalf("completion failed for %s:%v:%v: %v", filepath.Base(src.Filename), src.Line, src.Column, err)
 		}
 		got := list.Items
+		// The data of the items identifies them for completionItem/resolve.
+		for i := range got {
+			got[i].Data = nil
+		}
 		if equal := reflect.DeepEqual(want, got); !equal {
 			t.Errorf(diffC(src, want, got))
 		}
@@ -198,7 +400,7 @@ func (d diagnostics) test(t *testing.T, exported *packagestest.Exported, v *sour
 	count := 0
 	for filename, want := range d {
 		f := v.GetFile(source.ToURI(filename))
-		sourceDiagnostics, err := source.Diagnostics(context.Background(), v, f)
+		sourceDiagnostics, err := source.Diagnostics(context.Background(), v, f, v.Options().Diagnostics)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -241,37 +443,164 @@ func (d diagnostics) collect(pos token.Position, msg string) {
 	d[pos.Filename] = append(d[pos.Filename], want)
 }
 
-func (f formats) test(t *testing.T, s *server) {
-	for filename, gofmted := range f {
+func (f formats) test(t *testing.T, s *server, g *goldens) {
+	for filename := range f {
+		var got []byte
 		edits, err := s.Formatting(context.Background(), &protocol.DocumentFormattingParams{
 			TextDocument: protocol.TextDocumentIdentifier{
 				URI: protocol.DocumentURI(source.ToURI(filename)),
 			},
 		})
-		if err != nil || len(edits) == 0 {
-			if gofmted != "" {
+		// The golden file of a file that cannot be formatted is empty.
+		if err == nil {
+			if got, err = applyTestEdits(filename, edits); err != nil {
 				t.Error(err)
+				continue
 			}
+		}
+		g.check(t, filename, "format", got)
+	}
+}
+
+func (f formats) collect(pos token.Position) {
+	f[pos.Filename] = true
+}
+
+func (i imports) test(t *testing.T, s *server, g *goldens) {
+	for filename := range i {
+		uri := protocol.DocumentURI(source.ToURI(filename))
+		actions, err := s.CodeAction(context.Background(), &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Context: protocol.CodeActionContext{
+				Only: []protocol.CodeActionKind{protocol.SourceOrganizeImports},
+			},
+		})
+		if err != nil {
+			t.Error(err)
 			continue
 		}
-		edit := edits[0]
-		if edit.NewText != gofmted {
-			t.Errorf("formatting failed: (got: %s), (expected: %s)", edit.NewText, gofmted)
+		var edits []protocol.TextEdit
+		for _, action := range actions {
+			edits = append(edits, action.Edit.Changes[uri]...)
+		}
+		got, err := applyTestEdits(filename, edits)
+		if err != nil {
+			t.Error(err)
+			continue
 		}
+		g.check(t, filename, "imports", got)
 	}
 }
 
-func (f formats) collect(pos token.Position) {
-	cmd := exec.Command("gofmt", pos.Filename)
-	stdout := bytes.NewBuffer(nil)
-	cmd.Stdout = stdout
-	cmd.Run() // ignore error, sometimes we have intentionally ungofmt-able files
-	f[pos.Filename] = stdout.String()
+func (i imports) collect(pos token.Position) {
+	i[pos.Filename] = true
+}
+
+func (f suggestedFixes) test(t *testing.T, s *server, g *goldens) {
+	for filename, src := range f {
+		v := s.view
+		reports, err := source.Diagnostics(context.Background(), v, v.GetFile(source.ToURI(filename)), v.Options().Diagnostics)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The client sends back the diagnostics of the range of the fix.
+		var diags []protocol.Diagnostic
+		for _, d := range toProtocolDiagnostics(v, reports[filename]) {
+			if d.Range.Start == src.Range.Start {
+				diags = append(diags, d)
+			}
+		}
+		actions, err := s.CodeAction(context.Background(), &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: src.URI},
+			Range:        src.Range,
+			Context: protocol.CodeActionContext{
+				Only:        []protocol.CodeActionKind{protocol.QuickFix},
+				Diagnostics: diags,
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if len(actions) == 0 {
+			t.Errorf("for %v got no suggested fix, expected one", src)
+			continue
+		}
+		got, err := applyTestEdits(filename, actions[0].Edit.Changes[src.URI])
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		g.check(t, filename, "suggestedfix", got)
+	}
+}
+
+// collect records that the first suggested fix of the diagnostic at src is
+// checked against the golden file of its file, which may have only one.
+func (f suggestedFixes) collect(fset *token.FileSet, src packagestest.Range) {
+	loc := testLocation(fset, src)
+	f[fset.File(src.Start).Name()] = loc
+}
+
+// applyTestEdits returns the content of filename, a file of the tests, with
+// the edits applied.
+func applyTestEdits(filename string, edits []protocol.TextEdit) ([]byte, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	// The edits refer to the original content, so apply them from last to
+	// first.
+	sorted := append([]protocol.TextEdit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return lessPosition(sorted[j].Range.Start, sorted[i].Range.Start)
+	})
+	var changes []protocol.TextDocumentContentChangeEvent
+	for i := range sorted {
+		changes = append(changes, protocol.TextDocumentContentChangeEvent{
+			Range: &sorted[i].Range,
+			Text:  sorted[i].NewText,
+		})
+	}
+	return applyContentChanges(content, changes)
+}
+
+// goldens locates the golden files of the tests, which record the expected
+// output of a kind of test of a file. The golden file of the kind "format" of
+// testdata/format/bad_format.go is testdata/format/bad_format.format.golden.
+type goldens struct {
+	dir       string            // the testdata directory
+	fragments map[string]string // the fragments of the exported files, by name
+}
+
+// check compares got, the output of the test of the given kind of filename,
+// to its golden file, which it writes instead when the -update flag is set.
+func (g *goldens) check(t *testing.T, filename, kind string, got []byte) {
+	t.Helper()
+	fragment, ok := g.fragments[filename]
+	if !ok {
+		t.Fatalf("%s is not a file of the tests", filename)
+	}
+	golden := filepath.Join(g.dir, filepath.FromSlash(strings.TrimSuffix(fragment, ".go")+"."+kind+".golden"))
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Errorf("%v (run go test -update to create the golden file)", err)
+		return
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s of %s does not match %s:\ngot:\n%s\nwant:\n%s", kind, filepath.Base(filename), golden, got, want)
+	}
 }
 
-func (d definitions) test(t *testing.T, s *server) {
+func (d definitions) test(t *testing.T, definition func(context.Context, *protocol.TextDocumentPositionParams) ([]protocol.Location, error)) {
 	for src, target := range d {
-		locs, err := s.Definition(context.Background(), &protocol.TextDocumentPositionParams{
+		locs, err := definition(context.Background(), &protocol.TextDocumentPositionParams{
 			TextDocument: protocol.TextDocumentIdentifier{
 				URI: src.URI,
 			},
@@ -290,11 +619,421 @@ func (d definitions) test(t *testing.T, s *server) {
 }
 
 func (d definitions) collect(fset *token.FileSet, src, target packagestest.Range) {
-	sRange := source.Range{Start: src.Start, End: src.End}
-	sLoc := toProtocolLocation(fset, sRange)
-	tRange := source.Range{Start: target.Start, End: target.End}
-	tLoc := toProtocolLocation(fset, tRange)
-	d[sLoc] = tLoc
+	d[testLocation(fset, src)] = testLocation(fset, target)
+}
+
+// testLocation returns the protocol location of r, a range of a file of the
+// tests, whose positions are in fset.
+func testLocation(fset *token.FileSet, r packagestest.Range) protocol.Location {
+	tok := fset.File(r.Start)
+	content, _ := ioutil.ReadFile(tok.Name())
+	m := &columnMapper{tok: tok, filename: tok.Name(), content: content}
+	return protocol.Location{
+		URI:   protocol.DocumentURI(source.ToURI(tok.Name())),
+		Range: toProtocolRange(m, source.Range{Start: r.Start, End: r.End}),
+	}
+}
+
+func (s signatures) test(t *testing.T, srv *server) {
+	for src, want := range s {
+		help, err := srv.SignatureHelp(context.Background(), &protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{
+				URI: protocol.DocumentURI(source.ToURI(src.Filename)),
+			},
+			Position: protocol.Position{
+				Line:      float64(src.Line - 1),
+				Character: float64(src.Column - 1),
+			},
+		})
+		if err != nil {
+			t.Fatalf("signature help failed for %s:%v:%v: %v", filepath.Base(src.Filename), src.Line, src.Column, err)
+		}
+		if len(help.Signatures) != 1 {
+			t.Errorf("%s:%v:%v: got %d signatures, expected 1", filepath.Base(src.Filename), src.Line, src.Column, len(help.Signatures))
+			continue
+		}
+		if got := help.Signatures[0].Label; got != want.label {
+			t.Errorf("%s:%v:%v: got label %q, expected %q", filepath.Base(src.Filename), src.Line, src.Column, got, want.label)
+		}
+		if got := int64(help.ActiveParameter); got != want.activeParam {
+			t.Errorf("%s:%v:%v: got active parameter %v, expected %v", filepath.Base(src.Filename), src.Line, src.Column, got, want.activeParam)
+		}
+	}
+}
+
+func (s signatures) collect(src token.Position, label string, activeParam int64) {
+	s[src] = signature{
+		label:       label,
+		activeParam: activeParam,
+	}
+}
+
+func (h hovers) test(t *testing.T, s *server) {
+	for src, want := range h {
+		hover, err := s.Hover(context.Background(), &protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: src.URI},
+			Position:     src.Range.Start,
+		})
+		if err != nil {
+			t.Errorf("hover failed for %v: %v", src, err)
+			continue
+		}
+		if prefix := "```go\n" + want + "\n```"; !strings.HasPrefix(hover.Contents.Value, prefix) {
+			t.Errorf("for %v got hover %q, expected the signature %q", src, hover.Contents.Value, want)
+		}
+	}
+}
+
+// collect records that the hover of src shows signature, the first block of
+// its contents.
+func (h hovers) collect(fset *token.FileSet, src packagestest.Range, signature string) {
+	h[testLocation(fset, src)] = signature
+}
+
+func (r references) test(t *testing.T, s *server) {
+	for src, want := range r {
+		got, err := s.References(context.Background(), &protocol.ReferenceParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: src.URI},
+				Position:     src.Range.Start,
+			},
+			Context: protocol.ReferenceContext{IncludeDeclaration: true},
+		})
+		if err != nil {
+			t.Errorf("references failed for %v: %v", src, err)
+			continue
+		}
+		sortLocations(got)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("for %v got references %v, expected %v", src, got, want)
+		}
+	}
+}
+
+// collect records that the references of src, including its declaration,
+// are refs.
+func (r references) collect(fset *token.FileSet, src packagestest.Range, refs []packagestest.Range) {
+	var locs []protocol.Location
+	for _, ref := range refs {
+		locs = append(locs, testLocation(fset, ref))
+	}
+	sortLocations(locs)
+	r[testLocation(fset, src)] = locs
+}
+
+func (r renames) test(t *testing.T, s *server) {
+	for src, want := range r {
+		edit, err := s.Rename(context.Background(), &protocol.RenameParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: src.URI},
+			Position:     src.Range.Start,
+			NewName:      want.newName,
+		})
+		if want.err != "" {
+			if err == nil || !strings.Contains(err.Error(), want.err) {
+				t.Errorf("for %v got the error %v, expected an error containing %q", src, err, want.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("rename failed for %v: %v", src, err)
+			continue
+		}
+		var got []protocol.Location
+		for uri, edits := range edit.Changes {
+			for _, e := range edits {
+				if e.NewText != want.newName {
+					t.Errorf("for %v got the edit %v of %s, expected the new name %q", src, e, uri, want.newName)
+				}
+				got = append(got, protocol.Location{URI: uri, Range: e.Range})
+			}
+		}
+		sortLocations(got)
+		if !reflect.DeepEqual(want.locations, got) {
+			t.Errorf("for %v got renamed %v, expected %v", src, got, want.locations)
+		}
+	}
+}
+
+// collect records that renaming src to newName replaces exactly the ranges.
+func (r renames) collect(fset *token.FileSet, src packagestest.Range, newName string, ranges []packagestest.Range) {
+	var locs []protocol.Location
+	for _, rng := range ranges {
+		locs = append(locs, testLocation(fset, rng))
+	}
+	sortLocations(locs)
+	r[testLocation(fset, src)] = rename{newName: newName, locations: locs}
+}
+
+// collectError records that renaming src to newName fails with an error
+// that contains msg.
+func (r renames) collectError(fset *token.FileSet, src packagestest.Range, newName, msg string) {
+	r[testLocation(fset, src)] = rename{newName: newName, err: msg}
+}
+
+// test compares the symbols of each file with markers to the flattened tree
+// of its document symbols, and returns the number of symbols compared.
+func (s symbols) test(t *testing.T, srv *server) int {
+	count := 0
+	for uri, want := range s {
+		tree, err := srv.DocumentSymbol(context.Background(), &protocol.DocumentSymbolParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		})
+		if err != nil {
+			t.Errorf("document symbols failed for %s: %v", uri, err)
+			continue
+		}
+		var got []protocol.DocumentSymbol
+		var flatten func([]protocol.DocumentSymbol)
+		flatten = func(tree []protocol.DocumentSymbol) {
+			for _, sym := range tree {
+				got = append(got, protocol.DocumentSymbol{Name: sym.Name, Kind: sym.Kind, SelectionRange: sym.SelectionRange})
+				flatten(sym.Children)
+			}
+		}
+		for _, sym := range tree {
+			flatten([]protocol.DocumentSymbol{sym.(protocol.DocumentSymbol)})
+		}
+		sortSymbols(got)
+		sortSymbols(want)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("for %s got symbols %v, expected %v", uri, got, want)
+		}
+
+		// A client without hierarchical symbols gets the same symbols as a
+		// flat list, which has no selection ranges.
+		srv.clientOptions.hierarchicalSymbols = false
+		list, err := srv.DocumentSymbol(context.Background(), &protocol.DocumentSymbolParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		})
+		srv.clientOptions.hierarchicalSymbols = true
+		if err != nil {
+			t.Errorf("flat document symbols failed for %s: %v", uri, err)
+			continue
+		}
+		var gotNames, wantNames []string
+		for _, sym := range list {
+			info := sym.(protocol.SymbolInformation)
+			gotNames = append(gotNames, fmt.Sprintf("%s %v", info.Name, info.Kind))
+		}
+		for _, sym := range want {
+			wantNames = append(wantNames, fmt.Sprintf("%s %v", sym.Name, float64(sym.Kind)))
+		}
+		sort.Strings(gotNames)
+		sort.Strings(wantNames)
+		if !reflect.DeepEqual(wantNames, gotNames) {
+			t.Errorf("for %s got flat symbols %v, expected %v", uri, gotNames, wantNames)
+		}
+		count += len(want)
+	}
+	return count
+}
+
+// collect records that a symbol called name of the given kind is selected by
+// src, the name in its declaration.
+func (s symbols) collect(fset *token.FileSet, src packagestest.Range, name, kind string) {
+	var k protocol.SymbolKind
+	switch kind {
+	case "package":
+		k = protocol.PackageSymbol
+	case "struct":
+		k = protocol.StructSymbol
+	case "interface":
+		k = protocol.InterfaceSymbol
+	case "class":
+		k = protocol.ClassSymbol
+	case "field":
+		k = protocol.FieldSymbol
+	case "method":
+		k = protocol.MethodSymbol
+	case "function":
+		k = protocol.FunctionSymbol
+	case "variable":
+		k = protocol.VariableSymbol
+	case "constant":
+		k = protocol.ConstantSymbol
+	}
+	loc := testLocation(fset, src)
+	s[loc.URI] = append(s[loc.URI], protocol.DocumentSymbol{Name: name, Kind: k, SelectionRange: loc.Range})
+}
+
+// test compares the locations of the symbols that match each query to the
+// expected ones, the first of which is the best match. The packages of the
+// symbols are loaded first, as only the loaded packages are searched.
+func (w workspaceSymbols) test(t *testing.T, s *server) {
+	ctx := context.Background()
+	for query, want := range w {
+		for _, loc := range want {
+			if _, err := s.view.GetFile(source.URI(loc.URI)).GetPackage(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		symbols, err := s.Symbols(ctx, &protocol.WorkspaceSymbolParams{Query: query})
+		if err != nil {
+			t.Errorf("workspace symbols failed for %q: %v", query, err)
+			continue
+		}
+		// The symbols of the packages outside of the tests, such as those
+		// of the standard library, may match too.
+		var got []protocol.Location
+		for _, sym := range symbols {
+			if strings.HasPrefix(sym.ContainerName, "golang.org/x/tools/internal/lsp/") {
+				got = append(got, sym.Location)
+			}
+		}
+		if len(got) > 0 && got[0] != want[0] {
+			t.Errorf("for %q got the best match %v, expected %v", query, got[0], want[0])
+		}
+		sortLocations(got)
+		sorted := append([]protocol.Location(nil), want...)
+		sortLocations(sorted)
+		if !reflect.DeepEqual(sorted, got) {
+			t.Errorf("for %q got symbols at %v, expected %v", query, got, sorted)
+		}
+	}
+}
+
+// collect records that the symbols of the query are those declared at
+// decls, in order of decreasing score for the first.
+func (w workspaceSymbols) collect(fset *token.FileSet, query string, decls []packagestest.Range) {
+	for _, decl := range decls {
+		w[query] = append(w[query], testLocation(fset, decl))
+	}
+}
+
+// test compares the names of the functions that call, or are called by if
+// outgoing is set, the function of each src to the expected ones.
+func (c calls) test(t *testing.T, s *server, outgoing bool) {
+	ctx := context.Background()
+	for src, want := range c {
+		items, err := s.PrepareCallHierarchy(ctx, &protocol.CallHierarchyPrepareParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: src.URI},
+				Position:     src.Range.Start,
+			},
+		})
+		if err != nil {
+			t.Errorf("call hierarchy failed for %v: %v", src, err)
+			continue
+		}
+		var got []protocol.Location
+		if outgoing {
+			calls, err := s.OutgoingCalls(ctx, &protocol.CallHierarchyOutgoingCallsParams{Item: items[0]})
+			if err != nil {
+				t.Errorf("outgoing calls failed for %v: %v", src, err)
+				continue
+			}
+			for _, call := range calls {
+				got = append(got, protocol.Location{URI: call.To.URI, Range: call.To.SelectionRange})
+			}
+		} else {
+			calls, err := s.IncomingCalls(ctx, &protocol.CallHierarchyIncomingCallsParams{Item: items[0]})
+			if err != nil {
+				t.Errorf("incoming calls failed for %v: %v", src, err)
+				continue
+			}
+			for _, call := range calls {
+				got = append(got, protocol.Location{URI: call.From.URI, Range: call.From.SelectionRange})
+			}
+		}
+		sortLocations(got)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("for %v got calls %v, expected %v", src, got, want)
+		}
+	}
+}
+
+// collect records that the functions that call, or are called by, the
+// function of src are those whose names are funcs.
+func (c calls) collect(fset *token.FileSet, src packagestest.Range, funcs []packagestest.Range) {
+	var locs []protocol.Location
+	for _, fn := range funcs {
+		locs = append(locs, testLocation(fset, fn))
+	}
+	sortLocations(locs)
+	c[testLocation(fset, src)] = locs
+}
+
+// test applies the code action of each selection whose title starts with
+// the expected one, and compares the result to the golden file of its file,
+// or checks that there is no such code action.
+func (r refactorings) test(t *testing.T, s *server, g *goldens) {
+	for src, want := range r {
+		actions, err := s.CodeAction(context.Background(), &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: src.URI},
+			Range:        src.Range,
+			Context: protocol.CodeActionContext{
+				Only: []protocol.CodeActionKind{protocol.Refactor},
+			},
+		})
+		if err != nil {
+			t.Errorf("code actions failed for %v: %v", src, err)
+			continue
+		}
+		var action *protocol.CodeAction
+		for i := range actions {
+			if strings.HasPrefix(actions[i].Title, want.title) {
+				action = &actions[i]
+				break
+			}
+		}
+		if !want.golden {
+			if action != nil {
+				t.Errorf("for %v got the code action %q, expected none", src, action.Title)
+			}
+			continue
+		}
+		if action == nil {
+			t.Errorf("for %v got no code action %q", src, want.title)
+			continue
+		}
+		filename, err := source.URI(src.URI).Filename()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := applyTestEdits(filename, action.Edit.Changes[src.URI])
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		g.check(t, filename, "refactor", got)
+	}
+}
+
+// collect records that the code action whose title starts with title
+// refactors the selection from start to end as the golden file of its file,
+// which may have only one.
+func (r refactorings) collect(fset *token.FileSet, start, end packagestest.Range, title string) {
+	r[testLocation(fset, packagestest.Range{Start: start.Start, End: end.End})] = refactor{title: title, golden: true}
+}
+
+// collectNone records that no code action whose title starts with title is
+// offered for the selection from start to end.
+func (r refactorings) collectNone(fset *token.FileSet, start, end packagestest.Range, title string) {
+	r[testLocation(fset, packagestest.Range{Start: start.Start, End: end.End})] = refactor{title: title}
+}
+
+// sortLocations sorts locs by file and position.
+func sortLocations(locs []protocol.Location) {
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].URI != locs[j].URI {
+			return locs[i].URI < locs[j].URI
+		}
+		return lessPosition(locs[i].Range.Start, locs[j].Range.Start)
+	})
+}
+
+// sortSymbols sorts symbols by the position of their names.
+func sortSymbols(symbols []protocol.DocumentSymbol) {
+	sort.Slice(symbols, func(i, j int) bool {
+		return lessPosition(symbols[i].SelectionRange.Start, symbols[j].SelectionRange.Start)
+	})
+}
+
+func lessPosition(a, b protocol.Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Character < b.Character
 }
 
 // diffD prints the diff between expected and actual diagnostics test results.
@@ -0,0 +1,49 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux darwin freebsd openbsd netbsd
+
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+)
+
+func TestSocketOfAnotherUser(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("giving a file to another user requires root")
+	}
+	dir, err := ioutil.TempDir("", "golsp-socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The file is in the way of the socket, and no server listens on it.
+	addr := filepath.Join(dir, "golsp.sock")
+	if err := ioutil.WriteFile(addr, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ownedByUser(info) {
+		t.Fatalf("%s, which the user created, is not owned by the user", addr)
+	}
+	if err := os.Chown(addr, 65534, 65534); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunServerOnAddress(context.Background(), "unix", addr, jsonrpc2.NewHeaderStream); err == nil {
+		t.Errorf("the server listened on the address of a file of another user")
+	}
+	if _, err := os.Lstat(addr); err != nil {
+		t.Errorf("the file of another user was removed: %v", err)
+	}
+}
@@ -5,39 +5,79 @@
 package lsp
 
 import (
+	"context"
 	"go/token"
+	"path/filepath"
 
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/span"
 )
 
+// A columnMapper converts between the positions of a file, in tok, and
+// those of the protocol, whose columns count UTF-16 code units, with the
+// content of the file named filename, whose lines those of tok are. It is
+// the file that the line directives of a file that cgo generated refer to.
+type columnMapper struct {
+	tok      *token.File
+	filename string
+	content  []byte
+}
+
+// newColumnMapper returns the column mapper of f, a Go file.
+func newColumnMapper(ctx context.Context, f *source.File) (*columnMapper, error) {
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	return &columnMapper{tok: tok, filename: tok.Name(), content: content}, nil
+}
+
+// fileColumnMapper returns the column mapper of the file of pos in v, which
+// is the file that the line directive of pos refers to, if any. The columns
+// of a file that can't be read are converted as if it were ASCII.
+func fileColumnMapper(v *source.View, pos token.Pos) *columnMapper {
+	tok := v.Config.Fset.File(pos)
+	filename := tok.Position(pos).Filename
+	if !filepath.IsAbs(filename) {
+		filename = tok.Name()
+	}
+	content, _ := v.GetFile(source.ToURI(filename)).Read()
+	return &columnMapper{tok: tok, filename: filename, content: content}
+}
+
 // fromProtocolLocation converts from a protocol location to a source range.
 // It will return an error if the file of the location was not valid.
 // It uses fromProtocolRange to convert the start and end positions.
-func fromProtocolLocation(v *source.View, loc protocol.Location) (source.Range, error) {
-	f := v.GetFile(source.URI(loc.URI))
-	tok, err := f.GetToken()
+func fromProtocolLocation(ctx context.Context, v *source.View, loc protocol.Location) (source.Range, error) {
+	m, err := newColumnMapper(ctx, v.GetFile(source.URI(loc.URI)))
 	if err != nil {
 		return source.Range{}, err
 	}
-	return fromProtocolRange(tok, loc.Range), nil
+	return fromProtocolRange(m, loc.Range), nil
 }
 
 // toProtocolLocation converts from a source range back to a protocol location.
-func toProtocolLocation(fset *token.FileSet, r source.Range) protocol.Location {
-	tokFile := fset.File(r.Start)
-	uri := source.ToURI(tokFile.Name())
+// The location of a range in a file that cgo generated is in the file that
+// it was generated from, which the line directives of the file record, as
+// are the line and column numbers that toProtocolRange converts.
+func toProtocolLocation(v *source.View, r source.Range) protocol.Location {
+	m := fileColumnMapper(v, r.Start)
 	return protocol.Location{
-		URI:   protocol.DocumentURI(uri),
-		Range: toProtocolRange(tokFile, r),
+		URI:   protocol.DocumentURI(source.ToURI(m.filename)),
+		Range: toProtocolRange(m, r),
 	}
 }
 
 // fromProtocolRange converts a protocol range to a source range.
 // It uses fromProtocolPosition to convert the start and end positions, which
-// requires the token file the positions belongs to.
-func fromProtocolRange(f *token.File, r protocol.Range) source.Range {
-	start := fromProtocolPosition(f, r.Start)
+// requires the column mapper of the file the positions belongs to.
+func fromProtocolRange(m *columnMapper, r protocol.Range) source.Range {
+	start := fromProtocolPosition(m, r.Start)
 	var end token.Pos
 	switch {
 	case r.End == r.Start:
@@ -45,7 +85,7 @@ func fromProtocolRange(f *token.File, r protocol.Range) source.Range {
 	case r.End.Line < 0:
 		end = token.NoPos
 	default:
-		end = fromProtocolPosition(f, r.End)
+		end = fromProtocolPosition(m, r.End)
 	}
 	return source.Range{
 		Start: start,
@@ -54,32 +94,63 @@ func fromProtocolRange(f *token.File, r protocol.Range) source.Range {
 }
 
 // toProtocolRange converts from a source range back to a protocol range.
-func toProtocolRange(f *token.File, r source.Range) protocol.Range {
+func toProtocolRange(m *columnMapper, r source.Range) protocol.Range {
 	return protocol.Range{
-		Start: toProtocolPosition(f, r.Start),
-		End:   toProtocolPosition(f, r.End),
+		Start: toProtocolPosition(m, r.Start),
+		End:   toProtocolPosition(m, r.End),
 	}
 }
 
-// fromProtocolPosition converts a protocol position (0-based line and column
-// number) to a token.Pos (byte offset value).
-// It requires the token file the pos belongs to in order to do this.
-func fromProtocolPosition(f *token.File, pos protocol.Position) token.Pos {
-	line := lineStart(f, int(pos.Line)+1)
-	return line + token.Pos(pos.Character) // TODO: this is wrong, bytes not characters
+// fromProtocolPosition converts a protocol position (0-based line and UTF-16
+// column) to a token.Pos (byte offset value). A column past the end of the
+// line refers to the end of the line.
+// It requires the column mapper of the file the pos belongs to in order to
+// do this.
+func fromProtocolPosition(m *columnMapper, pos protocol.Position) token.Pos {
+	line := lineStart(m.tok, int(pos.Line)+1)
+	col := int(pos.Character)
+	if text, _, ok := span.Line(m.content, int(pos.Line)); ok {
+		col = span.ByteColumn(text, col)
+	}
+	return line + token.Pos(col)
 }
 
 // toProtocolPosition converts from a token pos (byte offset) to a protocol
-// position  (0-based line and column number)
-// It requires the token file the pos belongs to in order to do this.
-func toProtocolPosition(f *token.File, pos token.Pos) protocol.Position {
+// position (0-based line and UTF-16 column).
+// It requires the column mapper of the file the pos belongs to in order to
+// do this.
+func toProtocolPosition(m *columnMapper, pos token.Pos) protocol.Position {
 	if !pos.IsValid() {
 		return protocol.Position{Line: -1.0, Character: -1.0}
 	}
-	p := f.Position(pos)
+	p := m.tok.Position(pos)
+	col := p.Column - 1
+	if p.Filename == m.filename {
+		if text, _, ok := span.Line(m.content, p.Line-1); ok {
+			col = span.UTF16Column(text, col)
+		}
+	}
 	return protocol.Position{
 		Line:      float64(p.Line - 1),
-		Character: float64(p.Column - 1),
+		Character: float64(col),
+	}
+}
+
+// contentOffset converts a protocol position to a byte offset in content.
+// It is used for content that has not been parsed yet, so it does not
+// require a token file.
+// A position past the end of a line refers to the end of that line.
+func contentOffset(content []byte, pos protocol.Position) (int, error) {
+	return span.Offset(content, int(pos.Line), int(pos.Character))
+}
+
+// offsetPosition converts a byte offset in content to a protocol position,
+// as contentOffset converts it back.
+func offsetPosition(content []byte, offset int) protocol.Position {
+	line, col := span.Position(content, offset)
+	return protocol.Position{
+		Line:      float64(line),
+		Character: float64(col),
 	}
 }
 
@@ -0,0 +1,116 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// progressTokens numbers the progress tokens that the server creates.
+var progressTokens int64
+
+// A progress reports the progress of a long operation of the server to the
+// client, as a progress bar, if the client supports it. Otherwise, its
+// methods do nothing.
+type progress struct {
+	s     *server
+	ctx   context.Context // the context of the operation, before it is made cancellable
+	token string
+}
+
+// progressKey is the key of the progress of an operation in its context.
+type progressKey struct{}
+
+// progressFrom returns the progress of the operation of ctx, which
+// startProgress started, or a progress that reports nothing.
+func progressFrom(ctx context.Context) *progress {
+	if p, ok := ctx.Value(progressKey{}).(*progress); ok {
+		return p
+	}
+	return &progress{}
+}
+
+// startProgress starts reporting the progress of an operation with the given
+// title. If cancellable is set, the user may cancel the operation in the
+// client, which cancels the returned context, from which progressFrom
+// returns the progress.
+// The progress must be ended, which releases the returned context.
+func (s *server) startProgress(ctx context.Context, title string, cancellable bool) (context.Context, *progress) {
+	p := &progress{s: s, ctx: ctx}
+	if s.clientOptions.progress {
+		token := fmt.Sprintf("golsp-%d", atomic.AddInt64(&progressTokens, 1))
+		err := s.client.WorkDoneProgressCreate(ctx, &protocol.WorkDoneProgressCreateParams{Token: token})
+		if err == nil {
+			p.token = token
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.progressMu.Lock()
+	if s.inProgress == nil {
+		s.inProgress = make(map[*progress]context.CancelFunc)
+	}
+	s.inProgress[p] = cancel
+	s.progressMu.Unlock()
+	ctx = context.WithValue(ctx, progressKey{}, p)
+	if p.token != "" {
+		s.client.Progress(p.ctx, &protocol.ProgressParams{
+			Token: p.token,
+			Value: &protocol.WorkDoneProgressBegin{
+				Kind:        "begin",
+				Title:       title,
+				Cancellable: cancellable,
+			},
+		})
+	}
+	return ctx, p
+}
+
+// report reports the progress of the operation with a message and, if it is
+// positive, the percentage of the operation that is done.
+func (p *progress) report(message string, percentage float64) {
+	if p.token == "" {
+		return
+	}
+	p.s.client.Progress(p.ctx, &protocol.ProgressParams{
+		Token: p.token,
+		Value: &protocol.WorkDoneProgressReport{
+			Kind:       "report",
+			Message:    message,
+			Percentage: percentage,
+		},
+	})
+}
+
+// end ends the operation with a final message.
+func (p *progress) end(message string) {
+	p.s.progressMu.Lock()
+	cancel := p.s.inProgress[p]
+	delete(p.s.inProgress, p)
+	p.s.progressMu.Unlock()
+	cancel()
+	if p.token == "" {
+		return
+	}
+	p.s.client.Progress(p.ctx, &protocol.ProgressParams{
+		Token: p.token,
+		Value: &protocol.WorkDoneProgressEnd{Kind: "end", Message: message},
+	})
+}
+
+// cancelProgress cancels the operation whose progress is reported with the
+// given token.
+func (s *server) cancelProgress(token protocol.ProgressToken) {
+	s.progressMu.Lock()
+	defer s.progressMu.Unlock()
+	for p, cancel := range s.inProgress {
+		if p.token != "" && p.token == token {
+			cancel()
+		}
+	}
+}
@@ -98,6 +98,13 @@ type Diagnostic struct {
 	 * a scope collide all definitions can be marked via this property.
 	 */
 	Related []DiagnosticRelatedInformation `json:"relatedInformation,omitempty"`
+
+	/**
+	 * Additional metadata about the diagnostic.
+	 *
+	 * Since 3.15.0
+	 */
+	Tags []DiagnosticTag `json:"tags,omitempty"`
 }
 
 // DiagnosticSeverity indicates the severity of a Diagnostic message.
@@ -122,6 +129,29 @@ const (
 	SeverityHint DiagnosticSeverity = 4
 )
 
+/**
+ * The diagnostic tags.
+ *
+ * Since 3.15.0
+ */
+type DiagnosticTag float64
+
+const (
+	/**
+	 * Unused or unnecessary code.
+	 *
+	 * Clients are allowed to render diagnostics with this tag faded out instead of having
+	 * an error squiggle.
+	 */
+	Unnecessary DiagnosticTag = 1
+	/**
+	 * Deprecated or obsolete code.
+	 *
+	 * Clients are allowed to rendered diagnostics with this tag strike through.
+	 */
+	Deprecated DiagnosticTag = 2
+)
+
 // DiagnosticRelatedInformation represents a related message and source code
 // location for a diagnostic.
 // This should be used to point to code locations that cause or related to a
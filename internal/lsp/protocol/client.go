@@ -22,19 +22,13 @@ type Client interface {
 	Configuration(context.Context, *ConfigurationParams) ([]interface{}, error)
 	ApplyEdit(context.Context, *ApplyWorkspaceEditParams) (bool, error)
 	PublishDiagnostics(context.Context, *PublishDiagnosticsParams) error
+	WorkDoneProgressCreate(context.Context, *WorkDoneProgressCreateParams) error
+	Progress(context.Context, *ProgressParams) error
 }
 
 func clientHandler(client Client) jsonrpc2.Handler {
 	return func(ctx context.Context, conn *jsonrpc2.Conn, r *jsonrpc2.Request) {
 		switch r.Method {
-		case "$/cancelRequest":
-			var params CancelParams
-			if err := json.Unmarshal(*r.Params, &params); err != nil {
-				sendParseError(ctx, conn, r, err)
-				return
-			}
-			conn.Cancel(params.ID)
-
 		case "window/showMessage":
 			var params ShowMessageParams
 			if err := json.Unmarshal(*r.Params, &params); err != nil {
@@ -118,6 +112,23 @@ func clientHandler(client Client) jsonrpc2.Handler {
 			}
 			unhandledError(client.PublishDiagnostics(ctx, &params))
 
+		case "window/workDoneProgress/create":
+			var params WorkDoneProgressCreateParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			err := client.WorkDoneProgressCreate(ctx, &params)
+			unhandledError(conn.Reply(ctx, r, nil, err))
+
+		case "$/progress":
+			var params ProgressParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			unhandledError(client.Progress(ctx, &params))
+
 		default:
 			if r.IsNotify() {
 				conn.Reply(ctx, r, nil, jsonrpc2.NewErrorf(jsonrpc2.CodeMethodNotFound, "method %q not found", r.Method))
@@ -185,3 +196,11 @@ func (c *clientDispatcher) ApplyEdit(ctx context.Context, params *ApplyWorkspace
 func (c *clientDispatcher) PublishDiagnostics(ctx context.Context, params *PublishDiagnosticsParams) error {
 	return c.Conn.Notify(ctx, "textDocument/publishDiagnostics", params)
 }
+
+func (c *clientDispatcher) WorkDoneProgressCreate(ctx context.Context, params *WorkDoneProgressCreateParams) error {
+	return c.Conn.Call(ctx, "window/workDoneProgress/create", params, nil)
+}
+
+func (c *clientDispatcher) Progress(ctx context.Context, params *ProgressParams) error {
+	return c.Conn.Notify(ctx, "$/progress", params)
+}
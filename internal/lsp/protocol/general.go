@@ -485,6 +485,13 @@ type TextDocumentClientCapabilities struct {
 		 * Whether rename supports dynamic registration.
 		 */
 		DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+		/**
+		 * Client supports testing for validity of rename operations
+		 * before execution.
+		 *
+		 * Since 3.12.0
+		 */
+		PrepareSupport bool `json:"prepareSupport,omitempty"`
 	} `json:"rename,omitempty"`
 
 	/**
@@ -550,12 +557,28 @@ type ClientCapabilities struct {
 	 */
 	TextDocument TextDocumentClientCapabilities `json:"textDocument,omitempty"`
 
+	/**
+	 * Window specific client capabilities.
+	 */
+	Window WindowClientCapabilities `json:"window,omitempty"`
+
 	/**
 	 * Experimental client capabilities.
 	 */
 	Experimental interface{} `json:"experimental,omitempty"`
 }
 
+/**
+ * Window specific client capabilities.
+ */
+type WindowClientCapabilities struct {
+	/**
+	 * Whether client supports server initiated progress using the
+	 * `window/workDoneProgress/create` request.
+	 */
+	WorkDoneProgress bool `json:"workDoneProgress,omitempty"`
+}
+
 type InitializeResult struct {
 	/**
 	 * The capabilities the language server provides.
@@ -682,6 +705,16 @@ type ColorProviderOptions struct {
 type FoldingRangeProviderOptions struct {
 }
 
+/**
+ * Rename options
+ */
+type RenameOptions struct {
+	/**
+	 * Renames should be checked and tested before being executed.
+	 */
+	PrepareProvider bool `json:"prepareProvider,omitempty"`
+}
+
 type TextDocumentSyncOptions struct {
 	/**
 	 * Open and close notifications are sent to the server.
@@ -788,9 +821,11 @@ type ServerCapabilities struct {
 	 */
 	DocumentOnTypeFormattingProvider DocumentOnTypeFormattingOptions `json:"documentOnTypeFormattingProvider,omitempty"`
 	/**
-	 * The server provides rename support.
+	 * The server provides rename support. RenameOptions may only be
+	 * specified if the client states that it supports
+	 * `prepareSupport` in its initial `initialize` request.
 	 */
-	RenameProvider bool `json:"renameProvider,omitempty"`
+	RenameProvider interface{} `json:"renameProvider,omitempty"` // boolean | RenameOptions
 	/**
 	 * The server provides document link support.
 	 */
@@ -809,6 +844,36 @@ type ServerCapabilities struct {
 	 */
 	//TODO: complex union type to decode here
 	FoldingRangeProvider interface{} `json:"foldingRangeProvider,omitempty"` // boolean | FoldingRangeProviderOptions | (FoldingRangeProviderOptions & TextDocumentRegistrationOptions & StaticRegistrationOptions)
+	/**
+	 * The server provides semantic tokens support.
+	 *
+	 * Since 3.16.0
+	 */
+	SemanticTokensProvider *SemanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+	/**
+	 * The server provides linked editing range support.
+	 *
+	 * Since 3.16.0
+	 */
+	LinkedEditingRangeProvider bool `json:"linkedEditingRangeProvider,omitempty"`
+	/**
+	 * The server provides inlay hints.
+	 *
+	 * Since 3.17.0
+	 */
+	InlayHintProvider bool `json:"inlayHintProvider,omitempty"`
+	/**
+	 * The server provides call hierarchy support.
+	 *
+	 * Since 3.16.0
+	 */
+	CallHierarchyProvider bool `json:"callHierarchyProvider,omitempty"`
+	/**
+	 * The server provides type hierarchy support.
+	 *
+	 * Since 3.17.0
+	 */
+	TypeHierarchyProvider bool `json:"typeHierarchyProvider,omitempty"`
 	/**
 	 * The server provides execute command support.
 	 */
@@ -0,0 +1,649 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package generate generates the Go form of the Language Server Protocol
+// from the meta model of its specification, metaModel.json, which describes
+// its structures, enumerations, requests and notifications: the types of the
+// protocol package, the Server and Client interfaces, and the code that
+// dispatches the messages of a connection to them.
+//
+// The generated code follows the conventions of the protocol package:
+// names are exported, with the initialisms of Go, the fields have JSON tags
+// with the names of the specification, optional fields are "omitempty", and
+// the structures that are optional or null are pointers.
+// Union types, other than those with null, have no Go form, and are
+// interface{}, with the union in a comment.
+// The proposed features of the specification are left out.
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+// Model is the meta model of the specification, as in metaModel.json.
+type Model struct {
+	MetaData struct {
+		Version string `json:"version"`
+	} `json:"metaData"`
+	Requests      []*Message     `json:"requests"`
+	Notifications []*Message     `json:"notifications"`
+	Structures    []*Structure   `json:"structures"`
+	Enumerations  []*Enumeration `json:"enumerations"`
+	TypeAliases   []*TypeAlias   `json:"typeAliases"`
+}
+
+// Message is a request or a notification.
+type Message struct {
+	Method        string          `json:"method"`
+	Params        json.RawMessage `json:"params"` // a Type, or a list of them
+	Result        *Type           `json:"result"` // for requests only
+	Direction     string          `json:"messageDirection"`
+	Documentation string          `json:"documentation"`
+	Proposed      bool            `json:"proposed"`
+}
+
+// Structure is a structure of the specification, an interface in its
+// TypeScript form.
+type Structure struct {
+	Name          string      `json:"name"`
+	Extends       []*Type     `json:"extends"`
+	Mixins        []*Type     `json:"mixins"`
+	Properties    []*Property `json:"properties"`
+	Documentation string      `json:"documentation"`
+	Proposed      bool        `json:"proposed"`
+}
+
+// Property is a property of a structure or of a literal type.
+type Property struct {
+	Name          string `json:"name"`
+	Type          *Type  `json:"type"`
+	Optional      bool   `json:"optional"`
+	Documentation string `json:"documentation"`
+	Proposed      bool   `json:"proposed"`
+}
+
+// Enumeration is an enumeration of strings or integers.
+type Enumeration struct {
+	Name   string `json:"name"`
+	Type   *Type  `json:"type"`
+	Values []struct {
+		Name          string          `json:"name"`
+		Value         json.RawMessage `json:"value"`
+		Documentation string          `json:"documentation"`
+		Proposed      bool            `json:"proposed"`
+	} `json:"values"`
+	Documentation string `json:"documentation"`
+	Proposed      bool   `json:"proposed"`
+}
+
+// TypeAlias is another name of a type.
+type TypeAlias struct {
+	Name          string `json:"name"`
+	Type          *Type  `json:"type"`
+	Documentation string `json:"documentation"`
+	Proposed      bool   `json:"proposed"`
+}
+
+// Type is a type of the meta model. Its fields depend on its kind.
+type Type struct {
+	Kind    string          `json:"kind"`
+	Name    string          `json:"name"`    // base and reference
+	Element *Type           `json:"element"` // array
+	Key     *Type           `json:"key"`     // map
+	Value   json.RawMessage `json:"value"`   // map, literal and the literals of base types
+	Items   []*Type         `json:"items"`   // and, or and tuple
+}
+
+// Generate returns the Go source of the Go form of the protocol described by
+// model, the content of metaModel.json, as files of the package pkg, by name:
+// tsprotocol.go for the types, and tsserver.go and tsclient.go for the
+// messages to the server and to the client.
+// The generated files rely on the helpers of protocol.go: reply,
+// sendParseError and unhandledError.
+func Generate(model []byte, pkg string) (map[string][]byte, error) {
+	var m Model
+	if err := json.Unmarshal(model, &m); err != nil {
+		return nil, fmt.Errorf("decoding the meta model: %v", err)
+	}
+	g := &generator{
+		model:      &m,
+		structures: make(map[string]bool),
+	}
+	for _, s := range m.Structures {
+		g.structures[s.Name] = true
+	}
+	files := make(map[string][]byte)
+	for name, gen := range map[string]func(*bytes.Buffer) error{
+		"tsprotocol.go": g.types,
+		"tsserver.go":   func(b *bytes.Buffer) error { return g.messages(b, "Server", "clientToServer") },
+		"tsclient.go":   func(b *bytes.Buffer) error { return g.messages(b, "Client", "serverToClient") },
+	} {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "// Code generated by the generate package from the LSP meta model %s. DO NOT EDIT.\n\n", m.MetaData.Version)
+		fmt.Fprintf(&b, "package %s\n\n", pkg)
+		if err := gen(&b); err != nil {
+			return nil, fmt.Errorf("generating %s: %v", name, err)
+		}
+		src, err := format.Source(b.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("formatting %s: %v", name, err)
+		}
+		files[name] = src
+	}
+	return files, nil
+}
+
+type generator struct {
+	model      *Model
+	structures map[string]bool // the names of the structures
+}
+
+// types writes the declarations of the types of the model.
+func (g *generator) types(b *bytes.Buffer) error {
+	fmt.Fprint(b, "// DocumentURI is the URI of a document.\ntype DocumentURI string\n\n")
+	fmt.Fprint(b, "// URI is a URI that is not that of a document.\ntype URI string\n\n")
+	for _, s := range g.model.Structures {
+		if s.Proposed {
+			continue
+		}
+		writeDoc(b, s.Documentation, "")
+		fmt.Fprintf(b, "type %s struct {\n", goName(s.Name))
+		for _, t := range append(append([]*Type(nil), s.Extends...), s.Mixins...) {
+			if t.Kind != "reference" {
+				return fmt.Errorf("%s extends a %s type", s.Name, t.Kind)
+			}
+			fmt.Fprintf(b, "\t%s\n", goName(t.Name))
+		}
+		if err := g.fields(b, s.Properties); err != nil {
+			return fmt.Errorf("%s: %v", s.Name, err)
+		}
+		fmt.Fprint(b, "}\n\n")
+	}
+	for _, e := range g.model.Enumerations {
+		if e.Proposed {
+			continue
+		}
+		base, err := g.goType(e.Type)
+		if err != nil {
+			return fmt.Errorf("%s: %v", e.Name, err)
+		}
+		name := goName(e.Name)
+		writeDoc(b, e.Documentation, "")
+		fmt.Fprintf(b, "type %s %s\n\nconst (\n", name, base)
+		for _, v := range e.Values {
+			if v.Proposed {
+				continue
+			}
+			writeDoc(b, v.Documentation, "\t")
+			fmt.Fprintf(b, "\t%s%s %s = %s\n", name, goName(v.Name), name, v.Value)
+		}
+		fmt.Fprint(b, ")\n\n")
+	}
+	for _, a := range g.model.TypeAliases {
+		if a.Proposed {
+			continue
+		}
+		t, err := g.goType(a.Type)
+		if err != nil {
+			return fmt.Errorf("%s: %v", a.Name, err)
+		}
+		writeDoc(b, a.Documentation, "")
+		fmt.Fprintf(b, "type %s = %s%s\n\n", goName(a.Name), t, unionComment(a.Type))
+	}
+	return nil
+}
+
+// fields writes the fields of a struct for properties.
+func (g *generator) fields(b *bytes.Buffer, properties []*Property) error {
+	for _, p := range properties {
+		if p.Proposed {
+			continue
+		}
+		t, err := g.goType(p.Type)
+		if err != nil {
+			return fmt.Errorf("%s: %v", p.Name, err)
+		}
+		if (p.Optional || nullable(p.Type)) && g.isStruct(p.Type) {
+			t = "*" + t
+		}
+		tag := p.Name
+		if p.Optional {
+			tag += ",omitempty"
+		}
+		if p.Documentation != "" {
+			fmt.Fprint(b, "\t/**\n")
+			for _, line := range strings.Split(strings.TrimSpace(p.Documentation), "\n") {
+				fmt.Fprintf(b, "\t * %s\n", strings.TrimRight(line, " "))
+			}
+			fmt.Fprint(b, "\t */\n")
+		}
+		fmt.Fprintf(b, "\t%s %s `json:\"%s\"`%s\n", goName(p.Name), t, tag, unionComment(p.Type))
+	}
+	return nil
+}
+
+// messages writes the interface called name of the receiver of the messages
+// of the given direction, the handler that dispatches them to it, and the
+// dispatcher that sends them over a connection.
+func (g *generator) messages(b *bytes.Buffer, name, direction string) error {
+	var messages []*method
+	for i, list := range [][]*Message{g.model.Requests, g.model.Notifications} {
+		for _, msg := range list {
+			if msg.Proposed || (msg.Direction != direction && msg.Direction != "both") {
+				continue
+			}
+			// the cancellation of requests is handled by the connection
+			if msg.Method == "$/cancelRequest" {
+				continue
+			}
+			m, err := g.method(msg, i == 0)
+			if err != nil {
+				return fmt.Errorf("%s: %v", msg.Method, err)
+			}
+			messages = append(messages, m)
+		}
+	}
+	uniqueNames(messages)
+	receiver := strings.ToLower(name)
+
+	imports := "\t\"context\"\n"
+	for _, m := range messages {
+		if m.params != "" {
+			imports += "\t\"encoding/json\"\n"
+			break
+		}
+	}
+	fmt.Fprintf(b, "import (\n%s\n\t\"golang.org/x/tools/internal/jsonrpc2\"\n)\n\n", imports)
+	fmt.Fprintf(b, "type %s interface {\n", name)
+	for _, m := range messages {
+		writeDoc(b, m.doc, "\t")
+		fmt.Fprintf(b, "\t%s\n", m.signature(false))
+	}
+	fmt.Fprint(b, "}\n\n")
+
+	fmt.Fprintf(b, "func %sHandler(%s %s) jsonrpc2.Handler {\n", receiver, receiver, name)
+	fmt.Fprint(b, "\treturn func(ctx context.Context, conn *jsonrpc2.Conn, r *jsonrpc2.Request) {\n\t\tswitch r.Method {\n")
+	for _, m := range messages {
+		fmt.Fprintf(b, "\t\tcase %q:\n", m.wire)
+		if m.params == "" {
+			fmt.Fprint(b, "\t\t\tif r.Params != nil {\n\t\t\t\tconn.Reply(ctx, r, nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, \"Expected no params\"))\n\t\t\t\treturn\n\t\t\t}\n")
+		} else {
+			fmt.Fprintf(b, "\t\t\tvar params %s\n", strings.TrimPrefix(m.params, "*"))
+			fmt.Fprint(b, "\t\t\tif err := json.Unmarshal(*r.Params, &params); err != nil {\n\t\t\t\tsendParseError(ctx, conn, r, err)\n\t\t\t\treturn\n\t\t\t}\n")
+		}
+		args := "ctx"
+		if m.params != "" {
+			args += ", &params"
+			if !strings.HasPrefix(m.params, "*") {
+				args = "ctx, params"
+			}
+		}
+		switch {
+		case !m.request:
+			fmt.Fprintf(b, "\t\t\tunhandledError(%s.%s(%s))\n\n", receiver, m.name, args)
+		case m.result == "":
+			fmt.Fprintf(b, "\t\t\terr := %s.%s(%s)\n\t\t\tunhandledError(reply(ctx, conn, r, nil, err))\n\n", receiver, m.name, args)
+		default:
+			fmt.Fprintf(b, "\t\t\tresp, err := %s.%s(%s)\n\t\t\tunhandledError(reply(ctx, conn, r, resp, err))\n\n", receiver, m.name, args)
+		}
+	}
+	fmt.Fprintf(b, "\t\tdefault:\n\t\t\tif !r.IsNotify() {\n\t\t\t\tconn.Reply(ctx, r, nil, jsonrpc2.NewErrorf(jsonrpc2.CodeMethodNotFound, \"method %%q not found\", r.Method))\n\t\t\t}\n\t\t}\n\t}\n}\n\n")
+
+	dispatcher := receiver + "Dispatcher"
+	fmt.Fprintf(b, "type %s struct {\n\t*jsonrpc2.Conn\n}\n\n", dispatcher)
+	for _, m := range messages {
+		fmt.Fprintf(b, "func (s *%s) %s {\n", dispatcher, m.signature(true))
+		params := "nil"
+		if m.params != "" {
+			params = "params"
+		}
+		switch {
+		case !m.request:
+			fmt.Fprintf(b, "\treturn s.Conn.Notify(ctx, %q, %s)\n", m.wire, params)
+		case m.result == "":
+			fmt.Fprintf(b, "\treturn s.Conn.Call(ctx, %q, %s, nil)\n", m.wire, params)
+		default:
+			result := "result"
+			if strings.HasPrefix(m.result, "*") {
+				result = "&result"
+			}
+			fmt.Fprintf(b, "\tvar result %s\n", strings.TrimPrefix(m.result, "*"))
+			fmt.Fprintf(b, "\tif err := s.Conn.Call(ctx, %q, %s, &result); err != nil {\n\t\treturn %s, err\n\t}\n", m.wire, params, zero(m.result))
+			fmt.Fprintf(b, "\treturn %s, nil\n", result)
+		}
+		fmt.Fprint(b, "}\n\n")
+	}
+	return nil
+}
+
+// method is the Go form of a message.
+type method struct {
+	wire    string // the method of the message on the wire
+	name    string
+	doc     string
+	request bool
+	params  string // the type of the parameters, if any
+	result  string // the type of the result, if any
+}
+
+// method returns the Go form of msg.
+func (g *generator) method(msg *Message, request bool) (*method, error) {
+	m := &method{
+		wire:    msg.Method,
+		name:    shortName(msg.Method),
+		doc:     msg.Documentation,
+		request: request,
+	}
+	if len(msg.Params) > 0 && string(msg.Params) != "null" {
+		var params Type
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, fmt.Errorf("positional parameters are not supported")
+		}
+		t, err := g.goType(&params)
+		if err != nil {
+			return nil, err
+		}
+		if g.isStruct(&params) {
+			t = "*" + t
+		}
+		m.params = t
+	}
+	if request && msg.Result != nil && !(msg.Result.Kind == "base" && msg.Result.Name == "null") {
+		t, err := g.goType(msg.Result)
+		if err != nil {
+			return nil, err
+		}
+		if g.isStruct(msg.Result) {
+			t = "*" + t
+		}
+		m.result = t
+	}
+	return m, nil
+}
+
+// signature returns the signature of the method m, with the names of its
+// parameters if named is set.
+func (m *method) signature(named bool) string {
+	params := "context.Context"
+	if named {
+		params = "ctx context.Context"
+	}
+	if m.params != "" {
+		if named {
+			params += ", params " + m.params
+		} else {
+			params += ", " + m.params
+		}
+	}
+	results := "error"
+	if m.result != "" {
+		results = "(" + m.result + ", error)"
+	}
+	return fmt.Sprintf("%s(%s) %s", m.name, params, results)
+}
+
+// uniqueNames qualifies the names of the methods that are the same, such as
+// the didOpen notifications of text and notebook documents, with their
+// namespace, unless it is that of text documents.
+func uniqueNames(methods []*method) {
+	count := make(map[string]int)
+	for _, m := range methods {
+		count[m.name]++
+	}
+	for _, m := range methods {
+		if count[m.name] > 1 && !strings.HasPrefix(m.wire, "textDocument/") {
+			m.name = qualifiedName(m.wire)
+		}
+	}
+	sort.SliceStable(methods, func(i, j int) bool { return methods[i].wire < methods[j].wire })
+}
+
+// namespaces are the prefixes of the methods that their Go names leave out.
+var namespaces = map[string]bool{
+	"$":                true,
+	"client":           true,
+	"notebookDocument": true,
+	"telemetry":        true,
+	"textDocument":     true,
+	"window":           true,
+	"workspace":        true,
+}
+
+// shortName returns the Go name of the method of a message, without its
+// namespace: textDocument/semanticTokens/full is SemanticTokensFull.
+func shortName(wire string) string {
+	parts := strings.Split(wire, "/")
+	if len(parts) > 1 && namespaces[parts[0]] {
+		parts = parts[1:]
+	}
+	return joinNames(parts)
+}
+
+// qualifiedName returns the Go name of the method of a message, with its
+// namespace.
+func qualifiedName(wire string) string {
+	return joinNames(strings.Split(strings.TrimPrefix(wire, "$/"), "/"))
+}
+
+func joinNames(parts []string) string {
+	var name strings.Builder
+	for _, p := range parts {
+		name.WriteString(goName(p))
+	}
+	return name.String()
+}
+
+// goType returns the Go form of t.
+func (g *generator) goType(t *Type) (string, error) {
+	switch t.Kind {
+	case "base":
+		switch t.Name {
+		case "string", "RegExp":
+			return "string", nil
+		case "integer":
+			return "int32", nil
+		case "uinteger":
+			return "uint32", nil
+		case "decimal":
+			return "float64", nil
+		case "boolean":
+			return "bool", nil
+		case "URI":
+			return "URI", nil
+		case "DocumentUri":
+			return "DocumentURI", nil
+		case "null":
+			return "interface{}", nil
+		}
+		return "", fmt.Errorf("unknown base type %s", t.Name)
+	case "reference":
+		return goName(t.Name), nil
+	case "array":
+		elem, err := g.goType(t.Element)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + elem, nil
+	case "map":
+		key, err := g.goType(t.Key)
+		if err != nil {
+			return "", err
+		}
+		var value Type
+		if err := json.Unmarshal(t.Value, &value); err != nil {
+			return "", fmt.Errorf("invalid value of a map type: %v", err)
+		}
+		v, err := g.goType(&value)
+		if err != nil {
+			return "", err
+		}
+		return "map[" + key + "]" + v, nil
+	case "and":
+		var b strings.Builder
+		b.WriteString("struct {\n")
+		for _, item := range t.Items {
+			if item.Kind != "reference" {
+				return "", fmt.Errorf("intersection with a %s type", item.Kind)
+			}
+			fmt.Fprintf(&b, "%s\n", goName(item.Name))
+		}
+		b.WriteString("}")
+		return b.String(), nil
+	case "or":
+		var items []*Type
+		for _, item := range t.Items {
+			if !(item.Kind == "base" && item.Name == "null") {
+				items = append(items, item)
+			}
+		}
+		if len(items) == 1 {
+			return g.goType(items[0])
+		}
+		strs := true
+		for _, item := range items {
+			strs = strs && (item.Kind == "stringLiteral" || (item.Kind == "base" && item.Name == "string"))
+		}
+		if strs {
+			return "string", nil
+		}
+		return "interface{}", nil
+	case "tuple":
+		return "[]interface{}", nil
+	case "literal":
+		var literal struct {
+			Properties []*Property `json:"properties"`
+		}
+		if err := json.Unmarshal(t.Value, &literal); err != nil {
+			return "", fmt.Errorf("invalid literal type: %v", err)
+		}
+		var b bytes.Buffer
+		b.WriteString("struct {\n")
+		if err := g.fields(&b, literal.Properties); err != nil {
+			return "", err
+		}
+		b.WriteString("}")
+		return b.String(), nil
+	case "stringLiteral":
+		return "string", nil
+	case "integerLiteral":
+		return "int32", nil
+	case "booleanLiteral":
+		return "bool", nil
+	}
+	return "", fmt.Errorf("unknown kind of type %s", t.Kind)
+}
+
+// isStruct reports whether the Go form of t, without its null, is a struct.
+func (g *generator) isStruct(t *Type) bool {
+	switch t.Kind {
+	case "reference":
+		return g.structures[t.Name]
+	case "literal", "and":
+		return true
+	case "or":
+		var items []*Type
+		for _, item := range t.Items {
+			if !(item.Kind == "base" && item.Name == "null") {
+				items = append(items, item)
+			}
+		}
+		return len(items) == 1 && g.isStruct(items[0])
+	}
+	return false
+}
+
+// nullable reports whether t is a union with null.
+func nullable(t *Type) bool {
+	if t.Kind != "or" {
+		return false
+	}
+	for _, item := range t.Items {
+		if item.Kind == "base" && item.Name == "null" {
+			return true
+		}
+	}
+	return false
+}
+
+// unionComment returns the comment that describes the union t, whose Go form
+// is interface{}, or "".
+func unionComment(t *Type) string {
+	if t.Kind != "or" {
+		return ""
+	}
+	var names []string
+	for _, item := range t.Items {
+		switch item.Kind {
+		case "base", "reference":
+			names = append(names, item.Name)
+		case "array":
+			if item.Element.Kind == "base" || item.Element.Kind == "reference" {
+				names = append(names, item.Element.Name+"[]")
+				continue
+			}
+			return ""
+		case "stringLiteral":
+			names = append(names, string(item.Value))
+		default:
+			return ""
+		}
+	}
+	return " // " + strings.Join(names, " | ")
+}
+
+// zero returns the zero value of the Go type t.
+func zero(t string) string {
+	switch t {
+	case "string", "URI", "DocumentURI":
+		return `""`
+	case "bool":
+		return "false"
+	case "int32", "uint32", "float64":
+		return "0"
+	}
+	return "nil"
+}
+
+// initialisms are the words of the names of the specification that are
+// written in capitals in Go.
+var initialisms = []string{"Uri", "Id", "Url", "Json", "Lsp"}
+
+// goName returns the exported Go form of the name of the specification.
+func goName(name string) string {
+	name = strings.TrimPrefix(name, "_")
+	if name == "" {
+		return name
+	}
+	name = strings.ToUpper(name[:1]) + name[1:]
+	for _, word := range initialisms {
+		for i := strings.Index(name, word); i >= 0; {
+			end := i + len(word)
+			// the word must end there, or be followed by another word
+			if end == len(name) || (name[end] >= 'A' && name[end] <= 'Z') {
+				name = name[:i] + strings.ToUpper(word) + name[end:]
+			}
+			next := strings.Index(name[end:], word)
+			if next < 0 {
+				break
+			}
+			i = end + next
+		}
+	}
+	return name
+}
+
+// writeDoc writes the documentation doc as a comment, indented by indent.
+func writeDoc(b *bytes.Buffer, doc, indent string) {
+	if doc = strings.TrimSpace(doc); doc == "" {
+		return
+	}
+	for _, line := range strings.Split(doc, "\n") {
+		fmt.Fprintf(b, "%s// %s\n", indent, strings.TrimRight(line, " "))
+	}
+}
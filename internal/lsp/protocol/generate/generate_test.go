@@ -0,0 +1,188 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generate
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const model = `{
+	"metaData": {"version": "3.17.0"},
+	"requests": [
+		{
+			"method": "textDocument/hover",
+			"params": {"kind": "reference", "name": "HoverParams"},
+			"result": {"kind": "or", "items": [{"kind": "reference", "name": "Hover"}, {"kind": "base", "name": "null"}]},
+			"messageDirection": "clientToServer",
+			"documentation": "The hover request is sent from the client to the server."
+		},
+		{
+			"method": "shutdown",
+			"result": {"kind": "base", "name": "null"},
+			"messageDirection": "clientToServer"
+		},
+		{
+			"method": "workspace/workspaceFolders",
+			"result": {"kind": "or", "items": [{"kind": "array", "element": {"kind": "reference", "name": "WorkspaceFolder"}}, {"kind": "base", "name": "null"}]},
+			"messageDirection": "serverToClient"
+		},
+		{
+			"method": "textDocument/inlineValue",
+			"params": {"kind": "reference", "name": "HoverParams"},
+			"messageDirection": "clientToServer",
+			"proposed": true
+		}
+	],
+	"notifications": [
+		{
+			"method": "textDocument/didOpen",
+			"params": {"kind": "reference", "name": "DidOpenTextDocumentParams"},
+			"messageDirection": "clientToServer"
+		},
+		{
+			"method": "notebookDocument/didOpen",
+			"params": {"kind": "reference", "name": "DidOpenTextDocumentParams"},
+			"messageDirection": "clientToServer"
+		},
+		{
+			"method": "$/cancelRequest",
+			"params": {"kind": "reference", "name": "DidOpenTextDocumentParams"},
+			"messageDirection": "both"
+		}
+	],
+	"structures": [
+		{
+			"name": "TextDocumentPositionParams",
+			"properties": [
+				{"name": "textDocument", "type": {"kind": "reference", "name": "TextDocumentIdentifier"}},
+				{"name": "position", "type": {"kind": "reference", "name": "Position"}}
+			]
+		},
+		{
+			"name": "HoverParams",
+			"extends": [{"kind": "reference", "name": "TextDocumentPositionParams"}],
+			"properties": []
+		},
+		{
+			"name": "Hover",
+			"properties": [
+				{"name": "contents", "type": {"kind": "or", "items": [{"kind": "reference", "name": "MarkupContent"}, {"kind": "base", "name": "string"}]}},
+				{"name": "range", "type": {"kind": "reference", "name": "Range"}, "optional": true, "documentation": "An optional range."}
+			]
+		},
+		{
+			"name": "TextDocumentIdentifier",
+			"properties": [{"name": "uri", "type": {"kind": "base", "name": "DocumentUri"}}]
+		},
+		{
+			"name": "Position",
+			"properties": [
+				{"name": "line", "type": {"kind": "base", "name": "uinteger"}},
+				{"name": "character", "type": {"kind": "base", "name": "uinteger"}}
+			]
+		},
+		{
+			"name": "Range",
+			"properties": [
+				{"name": "start", "type": {"kind": "reference", "name": "Position"}},
+				{"name": "end", "type": {"kind": "reference", "name": "Position"}}
+			]
+		},
+		{
+			"name": "MarkupContent",
+			"properties": [
+				{"name": "kind", "type": {"kind": "reference", "name": "MarkupKind"}},
+				{"name": "value", "type": {"kind": "base", "name": "string"}}
+			]
+		},
+		{
+			"name": "WorkspaceFolder",
+			"properties": [
+				{"name": "uri", "type": {"kind": "base", "name": "URI"}},
+				{"name": "name", "type": {"kind": "base", "name": "string"}}
+			]
+		},
+		{
+			"name": "DidOpenTextDocumentParams",
+			"properties": [
+				{"name": "textDocument", "type": {"kind": "reference", "name": "TextDocumentIdentifier"}},
+				{"name": "options", "type": {"kind": "literal", "value": {"properties": [
+					{"name": "resolveId", "type": {"kind": "base", "name": "boolean"}, "optional": true}
+				]}}, "optional": true}
+			]
+		}
+	],
+	"enumerations": [
+		{
+			"name": "MarkupKind",
+			"type": {"kind": "base", "name": "string"},
+			"values": [
+				{"name": "PlainText", "value": "plaintext"},
+				{"name": "Markdown", "value": "markdown"}
+			]
+		}
+	],
+	"typeAliases": [
+		{
+			"name": "ProgressToken",
+			"type": {"kind": "or", "items": [{"kind": "base", "name": "integer"}, {"kind": "base", "name": "string"}]}
+		}
+	]
+}`
+
+func TestGenerate(t *testing.T) {
+	files, err := Generate([]byte(model), "protocol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string][]string{
+		"tsprotocol.go": {
+			"type HoverParams struct {\n\tTextDocumentPositionParams\n}",
+			"Contents interface{} `json:\"contents\"` // MarkupContent | string",
+			"Range *Range `json:\"range,omitempty\"`",
+			"URI DocumentURI `json:\"uri\"`",
+			"Character uint32 `json:\"character\"`",
+			"Options      *struct {",
+			"ResolveID bool `json:\"resolveId,omitempty\"`",
+			"type MarkupKind string",
+			"MarkupKindPlainText MarkupKind = \"plaintext\"",
+			"type ProgressToken = interface{} // integer | string",
+		},
+		"tsserver.go": {
+			"Hover(context.Context, *HoverParams) (*Hover, error)",
+			"Shutdown(context.Context) error",
+			"DidOpen(context.Context, *DidOpenTextDocumentParams) error",
+			"NotebookDocumentDidOpen(context.Context, *DidOpenTextDocumentParams) error",
+			"func serverHandler(server Server) jsonrpc2.Handler {",
+			`case "textDocument/hover":`,
+			"return &result, nil",
+			`return s.Conn.Notify(ctx, "textDocument/didOpen", params)`,
+		},
+		"tsclient.go": {
+			"WorkspaceFolders(context.Context) ([]WorkspaceFolder, error)",
+			"type clientDispatcher struct {",
+		},
+	} {
+		src := string(files[name])
+		if _, err := parser.ParseFile(token.NewFileSet(), name, src, 0); err != nil {
+			t.Errorf("%s does not parse: %v", name, err)
+		}
+		for _, w := range want {
+			if !strings.Contains(src, w) {
+				t.Errorf("%s does not contain %q:\n%s", name, w, src)
+			}
+		}
+	}
+	for _, name := range []string{"tsserver.go", "tsclient.go"} {
+		for _, absent := range []string{"InlineValue", "$/cancelRequest"} {
+			if strings.Contains(string(files[name]), absent) {
+				t.Errorf("%s contains %q", name, absent)
+			}
+		}
+	}
+}
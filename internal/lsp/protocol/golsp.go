@@ -0,0 +1,49 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// This file contains the extensions of golsp to the protocol: requests that
+// are not part of the specification, for the features of Go editors that it
+// has no message for. Their methods are prefixed with "golsp/".
+
+/**
+ * The parameters of a `golsp/listKnownPackages` request, which returns the
+ * import paths of the packages that the document may import and does not
+ * import yet, so that editors can offer them to add an import.
+ */
+type ListKnownPackagesParams struct {
+	/**
+	 * The document that would import the packages.
+	 */
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+/**
+ * The result of a `golsp/listKnownPackages` request.
+ */
+type ListKnownPackagesResult struct {
+	/**
+	 * The sorted import paths of the packages.
+	 */
+	Packages []string `json:"packages"`
+}
+
+/**
+ * The parameters of a `golsp/addImport` request, which adds the import of a
+ * package to a document, with a `workspace/applyEdit` request to the client.
+ * The request succeeds without an edit if the document imports the package
+ * already.
+ */
+type AddImportParams struct {
+	/**
+	 * The document to add the import to.
+	 */
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+
+	/**
+	 * The import path of the package, such as `net/http`.
+	 */
+	ImportPath string `json:"importPath"`
+}
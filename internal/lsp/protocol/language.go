@@ -959,6 +959,36 @@ type RenameParams struct {
 	NewName string `json:"newName"`
 }
 
+/**
+ * The result of a prepare rename request: the range of the string to
+ * rename, and the text of the string, to use as the placeholder of the
+ * new name.
+ */
+type PrepareRenameResult struct {
+	Range       Range  `json:"range"`
+	Placeholder string `json:"placeholder"`
+}
+
+/**
+ * The result of a linked editing range request.
+ *
+ * Since 3.16.0
+ */
+type LinkedEditingRanges struct {
+	/**
+	 * A list of ranges that can be edited together. The ranges must have
+	 * identical length and contain identical text content. The ranges cannot overlap.
+	 */
+	Ranges []Range `json:"ranges"`
+
+	/**
+	 * An optional word pattern (regular expression) that describes valid contents for
+	 * the given ranges. If no pattern is provided, the client configuration's word
+	 * pattern will be used.
+	 */
+	WordPattern string `json:"wordPattern,omitempty"`
+}
+
 type FoldingRangeRequestParam struct {
 	/**
 	 * The text document.
@@ -1018,3 +1048,280 @@ type FoldingRange struct {
 	 */
 	Kind string `json:"kind,omitempty"`
 }
+
+/**
+ * The legend of the semantic token types and modifiers used by the server.
+ * Tokens refer to types and modifiers by their index in the legend.
+ *
+ * Since 3.16.0
+ */
+type SemanticTokensLegend struct {
+	/**
+	 * The token types the server uses.
+	 */
+	TokenTypes []string `json:"tokenTypes"`
+
+	/**
+	 * The token modifiers the server uses.
+	 */
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+type SemanticTokensOptions struct {
+	/**
+	 * The legend used by the server.
+	 */
+	Legend SemanticTokensLegend `json:"legend"`
+
+	/**
+	 * Server supports providing semantic tokens for a specific range
+	 * of a document.
+	 */
+	Range bool `json:"range,omitempty"` // boolean | {}
+
+	/**
+	 * Server supports providing semantic tokens for a full document.
+	 */
+	Full bool `json:"full,omitempty"` // boolean | { delta?: boolean }
+}
+
+type SemanticTokensParams struct {
+	/**
+	 * The text document.
+	 */
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type SemanticTokensRangeParams struct {
+	/**
+	 * The text document.
+	 */
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+
+	/**
+	 * The range the semantic tokens are requested for.
+	 */
+	Range Range `json:"range"`
+}
+
+type SemanticTokens struct {
+	/**
+	 * An optional result id. If provided and clients support delta updating
+	 * the client will include the result id in the next semantic token request.
+	 */
+	ResultID string `json:"resultId,omitempty"`
+
+	/**
+	 * The encoded tokens. Each token is encoded as five integers: the line
+	 * delta and the start character delta relative to the previous token,
+	 * the length, the index of the token type in the legend, and the bit set
+	 * of the indexes of the token modifiers in the legend.
+	 */
+	Data []float64 `json:"data"`
+}
+
+type InlayHintParams struct {
+	/**
+	 * The text document.
+	 */
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+
+	/**
+	 * The visible document range for which inlay hints should be computed.
+	 */
+	Range Range `json:"range"`
+}
+
+/**
+ * Inlay hint kinds.
+ *
+ * Since 3.17.0
+ */
+type InlayHintKind float64
+
+const (
+	/**
+	 * An inlay hint that is for a type annotation.
+	 */
+	TypeHint InlayHintKind = 1
+
+	/**
+	 * An inlay hint that is for a parameter.
+	 */
+	ParameterHint InlayHintKind = 2
+)
+
+/**
+ * Inlay hint information.
+ *
+ * Since 3.17.0
+ */
+type InlayHint struct {
+	/**
+	 * The position of this hint.
+	 */
+	Position Position `json:"position"`
+
+	/**
+	 * The label of this hint.
+	 */
+	Label string `json:"label"` // string | InlayHintLabelPart[]
+
+	/**
+	 * The kind of this hint. Can be omitted in which case the client
+	 * should fall back to a reasonable default.
+	 */
+	Kind InlayHintKind `json:"kind,omitempty"`
+
+	/**
+	 * Render padding before the hint.
+	 */
+	PaddingLeft bool `json:"paddingLeft,omitempty"`
+
+	/**
+	 * Render padding after the hint.
+	 */
+	PaddingRight bool `json:"paddingRight,omitempty"`
+}
+
+/**
+ * Represents programming constructs like functions or constructors in the
+ * context of call hierarchy.
+ *
+ * Since 3.16.0
+ */
+type CallHierarchyItem struct {
+	/**
+	 * The name of this item.
+	 */
+	Name string `json:"name"`
+
+	/**
+	 * The kind of this item.
+	 */
+	Kind SymbolKind `json:"kind"`
+
+	/**
+	 * More detail for this item, e.g. the signature of a function.
+	 */
+	Detail string `json:"detail,omitempty"`
+
+	/**
+	 * The resource identifier of this item.
+	 */
+	URI DocumentURI `json:"uri"`
+
+	/**
+	 * The range enclosing this symbol not including leading/trailing
+	 * whitespace but everything else, e.g. comments and code.
+	 */
+	Range Range `json:"range"`
+
+	/**
+	 * The range that should be selected and revealed when this symbol is
+	 * being picked, e.g. the name of a function.
+	 * Must be contained by the `range`.
+	 */
+	SelectionRange Range `json:"selectionRange"`
+}
+
+type CallHierarchyPrepareParams struct {
+	TextDocumentPositionParams
+}
+
+type CallHierarchyIncomingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+/**
+ * Represents an incoming call, e.g. a caller of a method or constructor.
+ *
+ * Since 3.16.0
+ */
+type CallHierarchyIncomingCall struct {
+	/**
+	 * The item that makes the call.
+	 */
+	From CallHierarchyItem `json:"from"`
+
+	/**
+	 * The ranges at which the calls appear. This is relative to the caller
+	 * denoted by `from`.
+	 */
+	FromRanges []Range `json:"fromRanges"`
+}
+
+type CallHierarchyOutgoingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+/**
+ * Represents an outgoing call, e.g. calling a getter from a method or a
+ * method from a constructor etc.
+ *
+ * Since 3.16.0
+ */
+type CallHierarchyOutgoingCall struct {
+	/**
+	 * The item that is called.
+	 */
+	To CallHierarchyItem `json:"to"`
+
+	/**
+	 * The range at which this item is called. This is the range relative to
+	 * the caller, i.e. the item passed to the outgoing calls request.
+	 */
+	FromRanges []Range `json:"fromRanges"`
+}
+
+/**
+ * Represents an item of a type hierarchy, such as a class or an interface.
+ *
+ * Since 3.17.0
+ */
+type TypeHierarchyItem struct {
+	/**
+	 * The name of this item.
+	 */
+	Name string `json:"name"`
+
+	/**
+	 * The kind of this item.
+	 */
+	Kind SymbolKind `json:"kind"`
+
+	/**
+	 * More detail for this item, e.g. the signature of a function.
+	 */
+	Detail string `json:"detail,omitempty"`
+
+	/**
+	 * The resource identifier of this item.
+	 */
+	URI DocumentURI `json:"uri"`
+
+	/**
+	 * The range enclosing this symbol not including leading/trailing
+	 * whitespace but everything else, e.g. comments and code.
+	 */
+	Range Range `json:"range"`
+
+	/**
+	 * The range that should be selected and revealed when this symbol is
+	 * being picked, e.g. the name of a function.
+	 * Must be contained by the `range`.
+	 */
+	SelectionRange Range `json:"selectionRange"`
+}
+
+type TypeHierarchyPrepareParams struct {
+	TextDocumentPositionParams
+}
+
+type TypeHierarchySupertypesParams struct {
+	Item TypeHierarchyItem `json:"item"`
+}
+
+type TypeHierarchySubtypesParams struct {
+	Item TypeHierarchyItem `json:"item"`
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build ignore
+
+// Command makeprotocol writes the Go form of the Language Server Protocol,
+// generated from the meta model of its specification, to tsprotocol.go,
+// tsserver.go and tsclient.go.
+// Usage: go run makeprotocol.go path/to/metaModel.json
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/tools/internal/lsp/protocol/generate"
+)
+
+func main() {
+	if err := makeprotocol(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func makeprotocol() error {
+	if len(os.Args) != 2 {
+		return fmt.Errorf("usage: go run makeprotocol.go path/to/metaModel.json")
+	}
+	model, err := ioutil.ReadFile(os.Args[1])
+	if err != nil {
+		return err
+	}
+	files, err := generate.Generate(model, "protocol")
+	if err != nil {
+		return err
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(name, src, 0666); err != nil {
+			return fmt.Errorf("error while writing %s: %v", name, err)
+		}
+	}
+	return nil
+}
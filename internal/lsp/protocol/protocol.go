@@ -6,27 +6,87 @@ package protocol
 
 import (
 	"context"
+	"encoding/json"
 	"log"
+	"strings"
 
 	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/telemetry"
 )
 
-func canceller(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
-	conn.Notify(context.Background(), "$/cancelRequest", &CancelParams{ID: *req.ID})
-}
-
 func RunClient(ctx context.Context, stream jsonrpc2.Stream, client Client, opts ...interface{}) (*jsonrpc2.Conn, Server) {
-	opts = append([]interface{}{clientHandler(client), jsonrpc2.Canceler(canceller)}, opts...)
+	opts = append([]interface{}{clientHandler(client)}, opts...)
 	conn := jsonrpc2.NewConn(ctx, stream, opts...)
 	return conn, &serverDispatcher{Conn: conn}
 }
 
 func RunServer(ctx context.Context, stream jsonrpc2.Stream, server Server, opts ...interface{}) (*jsonrpc2.Conn, Client) {
-	opts = append([]interface{}{serverHandler(server), jsonrpc2.Canceler(canceller)}, opts...)
+	opts = append([]interface{}{serverHandler(server), jsonrpc2.Middleware(jsonrpc2.Recover), jsonrpc2.Middleware(traced), jsonrpc2.Sequencer(sequence)}, opts...)
 	conn := jsonrpc2.NewConn(ctx, stream, opts...)
 	return conn, &clientDispatcher{Conn: conn}
 }
 
+// sequence is the order of the messages to the server: the messages about a
+// document are queued after the changes of the document that precede them,
+// and the other messages after all that precede them, except for those that
+// only read the state of the server, so that a slow request about a document
+// does not block the changes and diagnostics of the others.
+func sequence(r *jsonrpc2.Request) (queue string, changes bool) {
+	switch r.Method {
+	case "textDocument/didOpen", "textDocument/didChange", "textDocument/didClose", "textDocument/didSave", "textDocument/willSave":
+		return documentOf(r), true
+	case "workspace/symbol", "completionItem/resolve", "codeLens/resolve", "documentLink/resolve", "window/workDoneProgress/cancel":
+		return "", false
+	}
+	if strings.HasPrefix(r.Method, "textDocument/") || strings.HasPrefix(r.Method, "golsp/") {
+		if uri := documentOf(r); uri != "" {
+			return uri, false
+		}
+	}
+	return "", true
+}
+
+// documentOf returns the URI of the document that the message of r is
+// about, or "" if there is none.
+func documentOf(r *jsonrpc2.Request) string {
+	var params struct {
+		TextDocument TextDocumentIdentifier `json:"textDocument"`
+	}
+	if r.Params == nil || json.Unmarshal(*r.Params, &params) != nil {
+		return ""
+	}
+	return string(params.TextDocument.URI)
+}
+
+// traced returns a handler that records a span for the handling of each
+// message by handler, named after its method. The spans of the operations of
+// the handler are its children.
+func traced(handler jsonrpc2.Handler) jsonrpc2.Handler {
+	return func(ctx context.Context, conn *jsonrpc2.Conn, r *jsonrpc2.Request) {
+		ctx, span := telemetry.StartSpan(ctx, "lsp."+r.Method)
+		defer span.Finish()
+		if r.ID != nil {
+			span.SetAttribute("id", r.ID.String())
+		}
+		handler(ctx, conn, r)
+	}
+}
+
+// reply replies to a call. The reply to a call that was cancelled is the
+// CodeRequestCancelled error, whatever the outcome of the call.
+func reply(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, result interface{}, err error) error {
+	span := telemetry.FromContext(ctx)
+	if ctx.Err() != nil {
+		// The context of the call is done, but the reply must still be sent.
+		ctx = context.Background()
+		result, err = nil, jsonrpc2.NewErrorf(CodeRequestCancelled, "request cancelled")
+	}
+	if span != nil {
+		span.SetError(err)
+	}
+	return conn.Reply(ctx, req, result, err)
+}
+
 func sendParseError(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, err error) {
 	if _, ok := err.(*jsonrpc2.Error); !ok {
 		err = jsonrpc2.NewErrorf(jsonrpc2.CodeParseError, "%v", err)
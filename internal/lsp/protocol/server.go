@@ -19,6 +19,7 @@ type Server interface {
 	DidChangeWorkspaceFolders(context.Context, *DidChangeWorkspaceFoldersParams) error
 	DidChangeConfiguration(context.Context, *DidChangeConfigurationParams) error
 	DidChangeWatchedFiles(context.Context, *DidChangeWatchedFilesParams) error
+	WorkDoneProgressCancel(context.Context, *WorkDoneProgressCancelParams) error
 	Symbols(context.Context, *WorkspaceSymbolParams) ([]SymbolInformation, error)
 	ExecuteCommand(context.Context, *ExecuteCommandParams) (interface{}, error)
 	DidOpen(context.Context, *DidOpenTextDocumentParams) error
@@ -36,7 +37,7 @@ type Server interface {
 	Implementation(context.Context, *TextDocumentPositionParams) ([]Location, error)
 	References(context.Context, *ReferenceParams) ([]Location, error)
 	DocumentHighlight(context.Context, *TextDocumentPositionParams) ([]DocumentHighlight, error)
-	DocumentSymbol(context.Context, *DocumentSymbolParams) ([]DocumentSymbol, error)
+	DocumentSymbol(context.Context, *DocumentSymbolParams) ([]interface{}, error)
 	CodeAction(context.Context, *CodeActionParams) ([]CodeAction, error)
 	CodeLens(context.Context, *CodeLensParams) ([]CodeLens, error)
 	CodeLensResolve(context.Context, *CodeLens) (*CodeLens, error)
@@ -47,8 +48,21 @@ type Server interface {
 	Formatting(context.Context, *DocumentFormattingParams) ([]TextEdit, error)
 	RangeFormatting(context.Context, *DocumentRangeFormattingParams) ([]TextEdit, error)
 	OnTypeFormatting(context.Context, *DocumentOnTypeFormattingParams) ([]TextEdit, error)
-	Rename(context.Context, *RenameParams) ([]WorkspaceEdit, error)
+	Rename(context.Context, *RenameParams) (*WorkspaceEdit, error)
+	PrepareRename(context.Context, *TextDocumentPositionParams) (*PrepareRenameResult, error)
+	LinkedEditingRange(context.Context, *TextDocumentPositionParams) (*LinkedEditingRanges, error)
 	FoldingRanges(context.Context, *FoldingRangeRequestParam) ([]FoldingRange, error)
+	SemanticTokensFull(context.Context, *SemanticTokensParams) (*SemanticTokens, error)
+	SemanticTokensRange(context.Context, *SemanticTokensRangeParams) (*SemanticTokens, error)
+	InlayHint(context.Context, *InlayHintParams) ([]InlayHint, error)
+	PrepareCallHierarchy(context.Context, *CallHierarchyPrepareParams) ([]CallHierarchyItem, error)
+	IncomingCalls(context.Context, *CallHierarchyIncomingCallsParams) ([]CallHierarchyIncomingCall, error)
+	OutgoingCalls(context.Context, *CallHierarchyOutgoingCallsParams) ([]CallHierarchyOutgoingCall, error)
+	PrepareTypeHierarchy(context.Context, *TypeHierarchyPrepareParams) ([]TypeHierarchyItem, error)
+	Supertypes(context.Context, *TypeHierarchySupertypesParams) ([]TypeHierarchyItem, error)
+	Subtypes(context.Context, *TypeHierarchySubtypesParams) ([]TypeHierarchyItem, error)
+	ListKnownPackages(context.Context, *ListKnownPackagesParams) (*ListKnownPackagesResult, error)
+	AddImport(context.Context, *AddImportParams) error
 }
 
 func serverHandler(server Server) jsonrpc2.Handler {
@@ -61,7 +75,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.Initialize(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "initialized":
 			var params InitializedParams
@@ -76,7 +90,8 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				conn.Reply(ctx, r, nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "Expected no params"))
 				return
 			}
-			unhandledError(server.Shutdown(ctx))
+			err := server.Shutdown(ctx)
+			unhandledError(reply(ctx, conn, r, nil, err))
 
 		case "exit":
 			if r.Params != nil {
@@ -85,14 +100,6 @@ func serverHandler(server Server) jsonrpc2.Handler {
 			}
 			unhandledError(server.Exit(ctx))
 
-		case "$/cancelRequest":
-			var params CancelParams
-			if err := json.Unmarshal(*r.Params, &params); err != nil {
-				sendParseError(ctx, conn, r, err)
-				return
-			}
-			conn.Cancel(params.ID)
-
 		case "workspace/didChangeWorkspaceFolders":
 			var params DidChangeWorkspaceFoldersParams
 			if err := json.Unmarshal(*r.Params, &params); err != nil {
@@ -117,6 +124,14 @@ func serverHandler(server Server) jsonrpc2.Handler {
 			}
 			unhandledError(server.DidChangeWatchedFiles(ctx, &params))
 
+		case "window/workDoneProgress/cancel":
+			var params WorkDoneProgressCancelParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			unhandledError(server.WorkDoneProgressCancel(ctx, &params))
+
 		case "workspace/symbol":
 			var params WorkspaceSymbolParams
 			if err := json.Unmarshal(*r.Params, &params); err != nil {
@@ -124,7 +139,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.Symbols(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "workspace/executeCommand":
 			var params ExecuteCommandParams
@@ -133,7 +148,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.ExecuteCommand(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/didOpen":
 			var params DidOpenTextDocumentParams
@@ -166,7 +181,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.WillSaveWaitUntil(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/didSave":
 			var params DidSaveTextDocumentParams
@@ -191,7 +206,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.Completion(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "completionItem/resolve":
 			var params CompletionItem
@@ -200,7 +215,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.CompletionResolve(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/hover":
 			var params TextDocumentPositionParams
@@ -209,7 +224,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.Hover(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/signatureHelp":
 			var params TextDocumentPositionParams
@@ -218,7 +233,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.SignatureHelp(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/definition":
 			var params TextDocumentPositionParams
@@ -227,7 +242,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.Definition(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/typeDefinition":
 			var params TextDocumentPositionParams
@@ -236,7 +251,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.TypeDefinition(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/implementation":
 			var params TextDocumentPositionParams
@@ -245,7 +260,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.Implementation(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/references":
 			var params ReferenceParams
@@ -254,7 +269,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.References(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/documentHighlight":
 			var params TextDocumentPositionParams
@@ -263,7 +278,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.DocumentHighlight(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/documentSymbol":
 			var params DocumentSymbolParams
@@ -272,7 +287,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.DocumentSymbol(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/codeAction":
 			var params CodeActionParams
@@ -281,7 +296,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.CodeAction(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/codeLens":
 			var params CodeLensParams
@@ -290,7 +305,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.CodeLens(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "codeLens/resolve":
 			var params CodeLens
@@ -299,7 +314,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.CodeLensResolve(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/documentLink":
 			var params DocumentLinkParams
@@ -308,7 +323,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.DocumentLink(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "documentLink/resolve":
 			var params DocumentLink
@@ -317,7 +332,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.DocumentLinkResolve(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/documentColor":
 			var params DocumentColorParams
@@ -326,7 +341,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.DocumentColor(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/colorPresentation":
 			var params ColorPresentationParams
@@ -335,7 +350,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.ColorPresentation(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/formatting":
 			var params DocumentFormattingParams
@@ -344,7 +359,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.Formatting(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/rangeFormatting":
 			var params DocumentRangeFormattingParams
@@ -353,7 +368,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.RangeFormatting(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/onTypeFormatting":
 			var params DocumentOnTypeFormattingParams
@@ -362,7 +377,7 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.OnTypeFormatting(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
 
 		case "textDocument/rename":
 			var params RenameParams
@@ -371,16 +386,133 @@ func serverHandler(server Server) jsonrpc2.Handler {
 				return
 			}
 			resp, err := server.Rename(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "textDocument/prepareRename":
+			var params TextDocumentPositionParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			resp, err := server.PrepareRename(ctx, &params)
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "textDocument/linkedEditingRange":
+			var params TextDocumentPositionParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			resp, err := server.LinkedEditingRange(ctx, &params)
+			unhandledError(reply(ctx, conn, r, resp, err))
 
-		case "textDocument/foldingRanges":
+		case "textDocument/foldingRange":
 			var params FoldingRangeRequestParam
 			if err := json.Unmarshal(*r.Params, &params); err != nil {
 				sendParseError(ctx, conn, r, err)
 				return
 			}
 			resp, err := server.FoldingRanges(ctx, &params)
-			unhandledError(conn.Reply(ctx, r, resp, err))
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "textDocument/semanticTokens/full":
+			var params SemanticTokensParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			resp, err := server.SemanticTokensFull(ctx, &params)
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "textDocument/semanticTokens/range":
+			var params SemanticTokensRangeParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			resp, err := server.SemanticTokensRange(ctx, &params)
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "textDocument/inlayHint":
+			var params InlayHintParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			resp, err := server.InlayHint(ctx, &params)
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "textDocument/prepareCallHierarchy":
+			var params CallHierarchyPrepareParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			resp, err := server.PrepareCallHierarchy(ctx, &params)
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "callHierarchy/incomingCalls":
+			var params CallHierarchyIncomingCallsParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			resp, err := server.IncomingCalls(ctx, &params)
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "callHierarchy/outgoingCalls":
+			var params CallHierarchyOutgoingCallsParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			resp, err := server.OutgoingCalls(ctx, &params)
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "textDocument/prepareTypeHierarchy":
+			var params TypeHierarchyPrepareParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			resp, err := server.PrepareTypeHierarchy(ctx, &params)
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "typeHierarchy/supertypes":
+			var params TypeHierarchySupertypesParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			resp, err := server.Supertypes(ctx, &params)
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "typeHierarchy/subtypes":
+			var params TypeHierarchySubtypesParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			resp, err := server.Subtypes(ctx, &params)
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "golsp/listKnownPackages":
+			var params ListKnownPackagesParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			resp, err := server.ListKnownPackages(ctx, &params)
+			unhandledError(reply(ctx, conn, r, resp, err))
+
+		case "golsp/addImport":
+			var params AddImportParams
+			if err := json.Unmarshal(*r.Params, &params); err != nil {
+				sendParseError(ctx, conn, r, err)
+				return
+			}
+			err := server.AddImport(ctx, &params)
+			unhandledError(reply(ctx, conn, r, nil, err))
 		default:
 			if r.IsNotify() {
 				conn.Reply(ctx, r, nil, jsonrpc2.NewErrorf(jsonrpc2.CodeMethodNotFound, "method %q not found", r.Method))
@@ -425,6 +557,10 @@ func (s *serverDispatcher) DidChangeWatchedFiles(ctx context.Context, params *Di
 	return s.Conn.Notify(ctx, "workspace/didChangeWatchedFiles", params)
 }
 
+func (s *serverDispatcher) WorkDoneProgressCancel(ctx context.Context, params *WorkDoneProgressCancelParams) error {
+	return s.Conn.Notify(ctx, "window/workDoneProgress/cancel", params)
+}
+
 func (s *serverDispatcher) Symbols(ctx context.Context, params *WorkspaceSymbolParams) ([]SymbolInformation, error) {
 	var result []SymbolInformation
 	if err := s.Conn.Call(ctx, "workspace/symbol", params, &result); err != nil {
@@ -541,8 +677,8 @@ func (s *serverDispatcher) DocumentHighlight(ctx context.Context, params *TextDo
 	return result, nil
 }
 
-func (s *serverDispatcher) DocumentSymbol(ctx context.Context, params *DocumentSymbolParams) ([]DocumentSymbol, error) {
-	var result []DocumentSymbol
+func (s *serverDispatcher) DocumentSymbol(ctx context.Context, params *DocumentSymbolParams) ([]interface{}, error) {
+	var result []interface{}
 	if err := s.Conn.Call(ctx, "textDocument/documentSymbol", params, &result); err != nil {
 		return nil, err
 	}
@@ -629,18 +765,118 @@ func (s *serverDispatcher) OnTypeFormatting(ctx context.Context, params *Documen
 	return result, nil
 }
 
-func (s *serverDispatcher) Rename(ctx context.Context, params *RenameParams) ([]WorkspaceEdit, error) {
-	var result []WorkspaceEdit
+func (s *serverDispatcher) Rename(ctx context.Context, params *RenameParams) (*WorkspaceEdit, error) {
+	var result WorkspaceEdit
 	if err := s.Conn.Call(ctx, "textDocument/rename", params, &result); err != nil {
 		return nil, err
 	}
+	return &result, nil
+}
+
+func (s *serverDispatcher) PrepareRename(ctx context.Context, params *TextDocumentPositionParams) (*PrepareRenameResult, error) {
+	var result *PrepareRenameResult
+	if err := s.Conn.Call(ctx, "textDocument/prepareRename", params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *serverDispatcher) LinkedEditingRange(ctx context.Context, params *TextDocumentPositionParams) (*LinkedEditingRanges, error) {
+	var result *LinkedEditingRanges
+	if err := s.Conn.Call(ctx, "textDocument/linkedEditingRange", params, &result); err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
 func (s *serverDispatcher) FoldingRanges(ctx context.Context, params *FoldingRangeRequestParam) ([]FoldingRange, error) {
 	var result []FoldingRange
-	if err := s.Conn.Call(ctx, "textDocument/foldingRanges", params, &result); err != nil {
+	if err := s.Conn.Call(ctx, "textDocument/foldingRange", params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *serverDispatcher) SemanticTokensFull(ctx context.Context, params *SemanticTokensParams) (*SemanticTokens, error) {
+	var result SemanticTokens
+	if err := s.Conn.Call(ctx, "textDocument/semanticTokens/full", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (s *serverDispatcher) SemanticTokensRange(ctx context.Context, params *SemanticTokensRangeParams) (*SemanticTokens, error) {
+	var result SemanticTokens
+	if err := s.Conn.Call(ctx, "textDocument/semanticTokens/range", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (s *serverDispatcher) InlayHint(ctx context.Context, params *InlayHintParams) ([]InlayHint, error) {
+	var result []InlayHint
+	if err := s.Conn.Call(ctx, "textDocument/inlayHint", params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *serverDispatcher) PrepareCallHierarchy(ctx context.Context, params *CallHierarchyPrepareParams) ([]CallHierarchyItem, error) {
+	var result []CallHierarchyItem
+	if err := s.Conn.Call(ctx, "textDocument/prepareCallHierarchy", params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *serverDispatcher) IncomingCalls(ctx context.Context, params *CallHierarchyIncomingCallsParams) ([]CallHierarchyIncomingCall, error) {
+	var result []CallHierarchyIncomingCall
+	if err := s.Conn.Call(ctx, "callHierarchy/incomingCalls", params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *serverDispatcher) OutgoingCalls(ctx context.Context, params *CallHierarchyOutgoingCallsParams) ([]CallHierarchyOutgoingCall, error) {
+	var result []CallHierarchyOutgoingCall
+	if err := s.Conn.Call(ctx, "callHierarchy/outgoingCalls", params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *serverDispatcher) PrepareTypeHierarchy(ctx context.Context, params *TypeHierarchyPrepareParams) ([]TypeHierarchyItem, error) {
+	var result []TypeHierarchyItem
+	if err := s.Conn.Call(ctx, "textDocument/prepareTypeHierarchy", params, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
+
+func (s *serverDispatcher) Supertypes(ctx context.Context, params *TypeHierarchySupertypesParams) ([]TypeHierarchyItem, error) {
+	var result []TypeHierarchyItem
+	if err := s.Conn.Call(ctx, "typeHierarchy/supertypes", params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *serverDispatcher) Subtypes(ctx context.Context, params *TypeHierarchySubtypesParams) ([]TypeHierarchyItem, error) {
+	var result []TypeHierarchyItem
+	if err := s.Conn.Call(ctx, "typeHierarchy/subtypes", params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *serverDispatcher) ListKnownPackages(ctx context.Context, params *ListKnownPackagesParams) (*ListKnownPackagesResult, error) {
+	var result ListKnownPackagesResult
+	if err := s.Conn.Call(ctx, "golsp/listKnownPackages", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (s *serverDispatcher) AddImport(ctx context.Context, params *AddImportParams) error {
+	return s.Conn.Call(ctx, "golsp/addImport", params, nil)
+}
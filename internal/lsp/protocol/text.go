@@ -37,8 +37,9 @@ type DidChangeTextDocumentParams struct {
 type TextDocumentContentChangeEvent struct {
 	/**
 	 * The range of the document that changed.
+	 * If it is nil, the text is the full content of the document.
 	 */
-	Range Range `json:"range,omitempty"`
+	Range *Range `json:"range,omitempty"`
 
 	/**
 	 * The length of the range that got replaced.
@@ -111,7 +112,7 @@ type DidSaveTextDocumentParams struct {
 	 * Optional the content when saved. Depends on the includeText value
 	 * when the save notification was requested.
 	 */
-	Text string `json:"text,omitempty"`
+	Text *string `json:"text,omitempty"`
 }
 
 type TextDocumentSaveRegistrationOptions struct {
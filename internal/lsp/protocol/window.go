@@ -75,3 +75,100 @@ type LogMessageParams struct {
 	 */
 	Message string `json:"message"`
 }
+
+type WorkDoneProgressCreateParams struct {
+	/**
+	 * The token to be used to report progress.
+	 */
+	Token ProgressToken `json:"token"`
+}
+
+type WorkDoneProgressCancelParams struct {
+	/**
+	 * The token to be used to report progress.
+	 */
+	Token ProgressToken `json:"token"`
+}
+
+/**
+ * A token that identifies the progress of an operation: a string or a
+ * number.
+ */
+type ProgressToken interface{}
+
+type ProgressParams struct {
+	/**
+	 * The progress token provided by the client or server.
+	 */
+	Token ProgressToken `json:"token"`
+
+	/**
+	 * The progress data: a WorkDoneProgressBegin, WorkDoneProgressReport or
+	 * WorkDoneProgressEnd.
+	 */
+	Value interface{} `json:"value"`
+}
+
+type WorkDoneProgressBegin struct {
+	/**
+	 * Always "begin".
+	 */
+	Kind string `json:"kind"`
+
+	/**
+	 * Mandatory title of the progress operation. Used to briefly inform about
+	 * the kind of operation being performed.
+	 */
+	Title string `json:"title"`
+
+	/**
+	 * Controls if a cancel button should show to allow the user to cancel the
+	 * long running operation.
+	 */
+	Cancellable bool `json:"cancellable,omitempty"`
+
+	/**
+	 * Optional, more detailed associated progress message.
+	 */
+	Message string `json:"message,omitempty"`
+
+	/**
+	 * Optional progress percentage to display (value 100 is considered 100%).
+	 */
+	Percentage float64 `json:"percentage,omitempty"`
+}
+
+type WorkDoneProgressReport struct {
+	/**
+	 * Always "report".
+	 */
+	Kind string `json:"kind"`
+
+	/**
+	 * Controls enablement state of a cancel button.
+	 */
+	Cancellable bool `json:"cancellable,omitempty"`
+
+	/**
+	 * Optional, more detailed associated progress message.
+	 */
+	Message string `json:"message,omitempty"`
+
+	/**
+	 * Optional progress percentage to display (value 100 is considered 100%).
+	 */
+	Percentage float64 `json:"percentage,omitempty"`
+}
+
+type WorkDoneProgressEnd struct {
+	/**
+	 * Always "end".
+	 */
+	Kind string `json:"kind"`
+
+	/**
+	 * Optional, a final message indicating to for example indicate the outcome
+	 * of the operation.
+	 */
+	Message string `json:"message,omitempty"`
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// A client is the client side of the connection to the server: it answers
+// the requests of the server as an editor does, and records the
+// notifications of the server, which the test awaits.
+type client struct {
+	settings map[string]interface{} // the settings of each workspace folder
+
+	mu          sync.Mutex
+	changed     chan struct{} // closed when the recorded state changes
+	diagnostics map[protocol.DocumentURI][]protocol.Diagnostic
+	titles      map[string]string // the titles of the progress reports, by token
+	ended       map[string]int    // the number of ended progress reports, by title
+	logs        []string
+	edits       []protocol.WorkspaceEdit // the edits of the server, which the test applies
+}
+
+func newClient(settings map[string]interface{}) *client {
+	return &client{
+		settings:    settings,
+		changed:     make(chan struct{}),
+		diagnostics: make(map[protocol.DocumentURI][]protocol.Diagnostic),
+		titles:      make(map[string]string),
+		ended:       make(map[string]int),
+	}
+}
+
+// wait waits until cond, which is called with the lock of c held, holds.
+// It fails when ctx is done.
+func (c *client) wait(ctx context.Context, cond func() bool) error {
+	for {
+		c.mu.Lock()
+		ok, changed := cond(), c.changed
+		c.mu.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// update records a change of the state of c, with its lock held.
+func (c *client) update(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f()
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *client) ShowMessage(ctx context.Context, params *protocol.ShowMessageParams) error {
+	c.update(func() { c.logs = append(c.logs, params.Message) })
+	return nil
+}
+
+func (c *client) ShowMessageRequest(context.Context, *protocol.ShowMessageRequestParams) (*protocol.MessageActionItem, error) {
+	return nil, nil
+}
+
+func (c *client) LogMessage(ctx context.Context, params *protocol.LogMessageParams) error {
+	c.update(func() { c.logs = append(c.logs, params.Message) })
+	return nil
+}
+
+func (c *client) Telemetry(context.Context, interface{}) error {
+	return nil
+}
+
+func (c *client) RegisterCapability(context.Context, *protocol.RegistrationParams) error {
+	return nil
+}
+
+func (c *client) UnregisterCapability(context.Context, *protocol.UnregistrationParams) error {
+	return nil
+}
+
+func (c *client) WorkspaceFolders(context.Context) ([]protocol.WorkspaceFolder, error) {
+	return nil, nil
+}
+
+func (c *client) Configuration(ctx context.Context, params *protocol.ConfigurationParams) ([]interface{}, error) {
+	configs := make([]interface{}, len(params.Items))
+	for i := range configs {
+		configs[i] = c.settings
+	}
+	return configs, nil
+}
+
+func (c *client) ApplyEdit(ctx context.Context, params *protocol.ApplyWorkspaceEditParams) (bool, error) {
+	c.update(func() { c.edits = append(c.edits, params.Edit) })
+	return true, nil
+}
+
+func (c *client) PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error {
+	c.update(func() { c.diagnostics[params.URI] = params.Diagnostics })
+	return nil
+}
+
+func (c *client) WorkDoneProgressCreate(context.Context, *protocol.WorkDoneProgressCreateParams) error {
+	return nil
+}
+
+func (c *client) Progress(ctx context.Context, params *protocol.ProgressParams) error {
+	// The value is decoded from JSON, as a map.
+	value, _ := params.Value.(map[string]interface{})
+	token := fmt.Sprint(params.Token)
+	c.update(func() {
+		switch value["kind"] {
+		case "begin":
+			c.titles[token], _ = value["title"].(string)
+		case "end":
+			c.ended[c.titles[token]]++
+			delete(c.titles, token)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,469 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package regtest runs end-to-end tests of the language server. It starts a
+// server that speaks JSON-RPC over a pipe, as it does with an editor, on a
+// workspace of files, and simulates the operations of an editor on them,
+// such as opening, editing and saving them, while the test awaits the
+// diagnostics that the server publishes.
+package regtest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/packages/packagestest"
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/span"
+)
+
+// Module is the import path of the workspace of the tests, which is in a
+// GOPATH.
+const Module = "example.com/regtest"
+
+// timeout is the time after which an awaited condition fails.
+const timeout = 30 * time.Second
+
+// An Env is the environment of a test: the server, and the editor that
+// operates on the files of the workspace. The paths of the files are
+// relative to the workspace folder, with slashes.
+// The methods of an Env fail the test if the operation fails.
+type Env struct {
+	T      *testing.T
+	Ctx    context.Context
+	Server protocol.Server
+
+	dir     string // the workspace folder
+	client  *client
+	buffers map[string]*buffer // the open files, by path
+}
+
+// A buffer is a file that is open in the editor.
+type buffer struct {
+	version uint64
+	content string
+}
+
+// Run runs test on a workspace of the given files, by path, with a new
+// server, in the GOPATH mode.
+func Run(t *testing.T, files map[string]string, test func(env *Env)) {
+	t.Helper()
+	RunWithSettings(t, files, nil, test)
+}
+
+// RunWithSettings is like Run, with the settings of the server, as they are
+// configured in the editor.
+func RunWithSettings(t *testing.T, files map[string]string, settings map[string]interface{}, test func(env *Env)) {
+	t.Helper()
+	written := make(map[string]interface{})
+	for path, content := range files {
+		written[path] = content
+	}
+	exported := packagestest.Export(t, packagestest.GOPATH, []packagestest.Module{{Name: Module, Files: written}})
+	defer exported.Cleanup()
+
+	// The go command of the server runs in the GOPATH of the workspace.
+	env := make(map[string]interface{})
+	for _, kv := range exported.Config.Env {
+		if i := strings.Index(kv, "="); i > 0 && strings.HasPrefix(kv, "GO") {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	config := map[string]interface{}{"env": env}
+	for name, value := range settings {
+		config[name] = value
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	go lsp.RunServer(ctx, jsonrpc2.NewHeaderStream(serverConn, serverConn))
+	c := newClient(config)
+	_, server := protocol.RunClient(ctx, jsonrpc2.NewHeaderStream(clientConn, clientConn), c)
+	e := &Env{
+		T:       t,
+		Ctx:     ctx,
+		Server:  server,
+		dir:     filepath.Join(exported.Config.Dir, Module),
+		client:  c,
+		buffers: make(map[string]*buffer),
+	}
+	e.initialize()
+	test(e)
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}
+
+// initialize initializes the server with the workspace folder, and waits
+// until it loaded its packages.
+func (e *Env) initialize() {
+	e.T.Helper()
+	params := &protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{{URI: string(source.ToURI(e.dir)), Name: "regtest"}},
+	}
+	params.Capabilities.Workspace.Configuration = true
+	params.Capabilities.Workspace.DidChangeWatchedFiles.DynamicRegistration = true
+	params.Capabilities.Window.WorkDoneProgress = true
+	if _, err := e.Server.Initialize(e.Ctx, params); err != nil {
+		e.T.Fatalf("initialize: %v", err)
+	}
+	if err := e.Server.Initialized(e.Ctx, &protocol.InitializedParams{}); err != nil {
+		e.T.Fatalf("initialized: %v", err)
+	}
+	// The server loads the folder once it has its configuration.
+	e.await("the loading of the workspace", func() bool {
+		for title, n := range e.client.ended {
+			if strings.HasPrefix(title, "Loading") && n > 0 {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// await waits until cond, which is called with the lock of the client held,
+// holds, and fails the test after a timeout.
+func (e *Env) await(what string, cond func() bool) {
+	e.T.Helper()
+	ctx, cancel := context.WithTimeout(e.Ctx, timeout)
+	defer cancel()
+	if err := e.client.wait(ctx, cond); err != nil {
+		e.client.mu.Lock()
+		logs := strings.Join(e.client.logs, "\n")
+		e.client.mu.Unlock()
+		e.T.Fatalf("waiting for %s: %v\nlogs of the server:\n%s", what, err, logs)
+	}
+}
+
+// A DiagnosticExpectation is a condition on the diagnostics that the server
+// published last for a file.
+type DiagnosticExpectation struct {
+	description string
+	check       func(diags []protocol.Diagnostic) bool
+}
+
+// NoDiagnostics expects that the file has no diagnostics, once the server
+// published some for it.
+func NoDiagnostics() DiagnosticExpectation {
+	return DiagnosticExpectation{
+		description: "no diagnostics",
+		check:       func(diags []protocol.Diagnostic) bool { return len(diags) == 0 },
+	}
+}
+
+// DiagnosticContaining expects that a diagnostic of the file contains msg
+// in its message.
+func DiagnosticContaining(msg string) DiagnosticExpectation {
+	return DiagnosticExpectation{
+		description: fmt.Sprintf("a diagnostic containing %q", msg),
+		check: func(diags []protocol.Diagnostic) bool {
+			for _, d := range diags {
+				if strings.Contains(d.Message, msg) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Await waits until the diagnostics of the file of path meet want.
+func (e *Env) Await(path string, want DiagnosticExpectation) {
+	e.T.Helper()
+	uri := e.uri(path)
+	e.await(fmt.Sprintf("%s in %s", want.description, path), func() bool {
+		diags, ok := e.client.diagnostics[uri]
+		return ok && want.check(diags)
+	})
+}
+
+// Diagnostics returns the diagnostics that the server published last for
+// the file of path.
+func (e *Env) Diagnostics(path string) []protocol.Diagnostic {
+	e.client.mu.Lock()
+	defer e.client.mu.Unlock()
+	return e.client.diagnostics[e.uri(path)]
+}
+
+// uri returns the URI of the file of path.
+func (e *Env) uri(path string) protocol.DocumentURI {
+	return protocol.DocumentURI(source.ToURI(e.filename(path)))
+}
+
+// filename returns the name of the file of path.
+func (e *Env) filename(path string) string {
+	return filepath.Join(e.dir, filepath.FromSlash(path))
+}
+
+// ReadWorkspaceFile returns the content of the file of path on disk.
+func (e *Env) ReadWorkspaceFile(path string) string {
+	e.T.Helper()
+	content, err := ioutil.ReadFile(e.filename(path))
+	if err != nil {
+		e.T.Fatal(err)
+	}
+	return string(content)
+}
+
+// WriteWorkspaceFile writes the file of path on disk, as another program
+// does, and notifies the server that watches it.
+func (e *Env) WriteWorkspaceFile(path, content string) {
+	e.T.Helper()
+	filename := e.filename(path)
+	change := protocol.Changed
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		change = protocol.Created
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		e.T.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		e.T.Fatal(err)
+	}
+	e.fileChanged(path, change)
+}
+
+// RemoveWorkspaceFile removes the file of path from disk, and notifies the
+// server that watches it.
+func (e *Env) RemoveWorkspaceFile(path string) {
+	e.T.Helper()
+	if err := os.Remove(e.filename(path)); err != nil {
+		e.T.Fatal(err)
+	}
+	e.fileChanged(path, protocol.Deleted)
+}
+
+// fileChanged notifies the server of a change of the file of path on disk.
+func (e *Env) fileChanged(path string, change protocol.FileChangeType) {
+	e.T.Helper()
+	params := &protocol.DidChangeWatchedFilesParams{
+		Changes: []protocol.FileEvent{{URI: e.uri(path), Type: float64(change)}},
+	}
+	if err := e.Server.DidChangeWatchedFiles(e.Ctx, params); err != nil {
+		e.T.Fatalf("didChangeWatchedFiles: %v", err)
+	}
+}
+
+// OpenFile opens the file of path, with its content on disk.
+func (e *Env) OpenFile(path string) {
+	e.T.Helper()
+	e.CreateBuffer(path, e.ReadWorkspaceFile(path))
+}
+
+// CreateBuffer opens the file of path with the given content, which it may
+// not have on disk, or not exist there at all.
+func (e *Env) CreateBuffer(path, content string) {
+	e.T.Helper()
+	b := &buffer{version: 1, content: content}
+	e.buffers[path] = b
+	params := &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:        e.uri(path),
+			LanguageID: "go",
+			Version:    float64(b.version),
+			Text:       content,
+		},
+	}
+	if err := e.Server.DidOpen(e.Ctx, params); err != nil {
+		e.T.Fatalf("didOpen: %v", err)
+	}
+}
+
+// CloseBuffer closes the file of path.
+func (e *Env) CloseBuffer(path string) {
+	e.T.Helper()
+	e.buffer(path)
+	delete(e.buffers, path)
+	params := &protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: e.uri(path)},
+	}
+	if err := e.Server.DidClose(e.Ctx, params); err != nil {
+		e.T.Fatalf("didClose: %v", err)
+	}
+}
+
+// buffer returns the buffer of the open file of path.
+func (e *Env) buffer(path string) *buffer {
+	e.T.Helper()
+	b, ok := e.buffers[path]
+	if !ok {
+		e.T.Fatalf("%s is not open", path)
+	}
+	return b
+}
+
+// BufferText returns the content of the open file of path.
+func (e *Env) BufferText(path string) string {
+	e.T.Helper()
+	return e.buffer(path).content
+}
+
+// EditBuffer applies the edits, one after the other, to the open file of
+// path, and sends them to the server as the ranges of the content that
+// changed.
+func (e *Env) EditBuffer(path string, edits ...protocol.TextEdit) {
+	e.T.Helper()
+	b := e.buffer(path)
+	var changes []protocol.TextDocumentContentChangeEvent
+	for _, edit := range edits {
+		content := []byte(b.content)
+		start, err := span.Offset(content, int(edit.Range.Start.Line), int(edit.Range.Start.Character))
+		if err != nil {
+			e.T.Fatalf("editing %s: %v", path, err)
+		}
+		end, err := span.Offset(content, int(edit.Range.End.Line), int(edit.Range.End.Character))
+		if err != nil {
+			e.T.Fatalf("editing %s: %v", path, err)
+		}
+		b.content = b.content[:start] + edit.NewText + b.content[end:]
+		rng := edit.Range
+		changes = append(changes, protocol.TextDocumentContentChangeEvent{Range: &rng, Text: edit.NewText})
+	}
+	e.didChange(path, changes)
+}
+
+// SetBufferContent replaces the content of the open file of path.
+func (e *Env) SetBufferContent(path, content string) {
+	e.T.Helper()
+	e.buffer(path).content = content
+	e.didChange(path, []protocol.TextDocumentContentChangeEvent{{Text: content}})
+}
+
+// didChange sends the changes of the open file of path to the server, with
+// a new version.
+func (e *Env) didChange(path string, changes []protocol.TextDocumentContentChangeEvent) {
+	e.T.Helper()
+	b := e.buffer(path)
+	b.version++
+	version := b.version
+	params := &protocol.DidChangeTextDocumentParams{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: e.uri(path)},
+			Version:                &version,
+		},
+		ContentChanges: changes,
+	}
+	if err := e.Server.DidChange(e.Ctx, params); err != nil {
+		e.T.Fatalf("didChange: %v", err)
+	}
+}
+
+// SaveBuffer saves the open file of path as an editor does: it applies the
+// edits that the server returns before the save, writes the file on disk,
+// and notifies the server of the save, with its content, and of the change
+// of the file on disk.
+func (e *Env) SaveBuffer(path string) {
+	e.T.Helper()
+	uri := e.uri(path)
+	edits, err := e.Server.WillSaveWaitUntil(e.Ctx, &protocol.WillSaveTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Reason:       protocol.Manual,
+	})
+	if err != nil {
+		e.T.Fatalf("willSaveWaitUntil: %v", err)
+	}
+	e.applyEdits(path, edits)
+	content := e.BufferText(path)
+	if err := ioutil.WriteFile(e.filename(path), []byte(content), 0644); err != nil {
+		e.T.Fatal(err)
+	}
+	params := &protocol.DidSaveTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Text:         &content,
+	}
+	if err := e.Server.DidSave(e.Ctx, params); err != nil {
+		e.T.Fatalf("didSave: %v", err)
+	}
+	e.fileChanged(path, protocol.Changed)
+}
+
+// applyEdits applies the edits of the server to the open file of path. The
+// edits apply to the content before all of them, so they are applied from
+// the last one, which leaves the ranges of the others unchanged.
+func (e *Env) applyEdits(path string, edits []protocol.TextEdit) {
+	e.T.Helper()
+	sort.SliceStable(edits, func(i, j int) bool {
+		p, q := edits[i].Range.Start, edits[j].Range.Start
+		return p.Line > q.Line || p.Line == q.Line && p.Character > q.Character
+	})
+	if len(edits) > 0 {
+		e.EditBuffer(path, edits...)
+	}
+}
+
+// RegexpSearch returns the position of the first match of re in the open
+// file of path.
+func (e *Env) RegexpSearch(path, re string) protocol.Position {
+	e.T.Helper()
+	content := e.BufferText(path)
+	loc := regexp.MustCompile(re).FindStringIndex(content)
+	if loc == nil {
+		e.T.Fatalf("no match of %q in %s", re, path)
+	}
+	line, col := span.Position([]byte(content), loc[0])
+	return protocol.Position{Line: float64(line), Character: float64(col)}
+}
+
+// Hover returns the hover of the position pos of the open file of path.
+func (e *Env) Hover(path string, pos protocol.Position) *protocol.Hover {
+	e.T.Helper()
+	hover, err := e.Server.Hover(e.Ctx, &protocol.TextDocumentPositionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: e.uri(path)},
+		Position:     pos,
+	})
+	if err != nil {
+		e.T.Fatalf("hover: %v", err)
+	}
+	return hover
+}
+
+// ListKnownPackages returns the packages that the open file of path may
+// import.
+func (e *Env) ListKnownPackages(path string) []string {
+	e.T.Helper()
+	result, err := e.Server.ListKnownPackages(e.Ctx, &protocol.ListKnownPackagesParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: e.uri(path)},
+	})
+	if err != nil {
+		e.T.Fatalf("listKnownPackages: %v", err)
+	}
+	return result.Packages
+}
+
+// AddImport adds the import of the package importPath to the open file of
+// path, and applies the edits that the server requests for it.
+func (e *Env) AddImport(path, importPath string) {
+	e.T.Helper()
+	uri := e.uri(path)
+	if err := e.Server.AddImport(e.Ctx, &protocol.AddImportParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		ImportPath:   importPath,
+	}); err != nil {
+		e.T.Fatalf("addImport: %v", err)
+	}
+	// The server waits for the client to apply its edits before it
+	// replies.
+	e.client.mu.Lock()
+	edits := e.client.edits
+	e.client.edits = nil
+	e.client.mu.Unlock()
+	for _, edit := range edits {
+		e.applyEdits(path, edit.Changes[uri])
+	}
+}
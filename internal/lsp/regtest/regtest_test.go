@@ -0,0 +1,102 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regtest
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+const undefined = `package p
+
+func F() int {
+	return x
+}
+`
+
+func TestDiagnosticsOfEdits(t *testing.T) {
+	Run(t, map[string]string{"p/p.go": undefined}, func(env *Env) {
+		env.OpenFile("p/p.go")
+		env.Await("p/p.go", DiagnosticContaining("undefined: x"))
+		pos := env.RegexpSearch("p/p.go", "x\n")
+		env.EditBuffer("p/p.go", protocol.TextEdit{
+			Range:   protocol.Range{Start: pos, End: protocol.Position{Line: pos.Line, Character: pos.Character + 1}},
+			NewText: "1",
+		})
+		env.Await("p/p.go", NoDiagnostics())
+		if got, want := env.BufferText("p/p.go"), strings.Replace(undefined, "return x", "return 1", 1); got != want {
+			t.Errorf("buffer = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestWatchedFiles(t *testing.T) {
+	Run(t, map[string]string{"p/p.go": undefined}, func(env *Env) {
+		env.OpenFile("p/p.go")
+		env.Await("p/p.go", DiagnosticContaining("undefined: x"))
+		env.WriteWorkspaceFile("p/x.go", "package p\n\nconst x = 1\n")
+		env.Await("p/p.go", NoDiagnostics())
+		env.RemoveWorkspaceFile("p/x.go")
+		env.Await("p/p.go", DiagnosticContaining("undefined: x"))
+	})
+}
+
+func TestFormatOnSave(t *testing.T) {
+	const unformatted = "package p\n\nfunc F()  int {\nreturn 1\n}\n"
+	const formatted = "package p\n\nfunc F() int {\n\treturn 1\n}\n"
+	settings := map[string]interface{}{"formatOnSave": true}
+	RunWithSettings(t, map[string]string{"p/p.go": unformatted}, settings, func(env *Env) {
+		env.OpenFile("p/p.go")
+		env.SaveBuffer("p/p.go")
+		if got := env.BufferText("p/p.go"); got != formatted {
+			t.Errorf("buffer = %q, want %q", got, formatted)
+		}
+		if got := env.ReadWorkspaceFile("p/p.go"); got != formatted {
+			t.Errorf("file = %q, want %q", got, formatted)
+		}
+	})
+}
+
+func TestHover(t *testing.T) {
+	files := map[string]string{
+		"p/p.go": "package p\n\n// F returns one.\nfunc F() int { return 1 }\n\nvar _ = F()\n",
+	}
+	Run(t, files, func(env *Env) {
+		env.OpenFile("p/p.go")
+		hover := env.Hover("p/p.go", env.RegexpSearch("p/p.go", "F\\(\\)\n"))
+		if !strings.Contains(hover.Contents.Value, "func F() int") || !strings.Contains(hover.Contents.Value, "F returns one.") {
+			t.Errorf("hover = %q, want the declaration and documentation of F", hover.Contents.Value)
+		}
+	})
+}
+
+func TestAddImport(t *testing.T) {
+	files := map[string]string{
+		"p/p.go": "package p\n\nfunc F() {}\n",
+		"q/q.go": "package q\n",
+	}
+	Run(t, files, func(env *Env) {
+		env.OpenFile("p/p.go")
+		known := make(map[string]bool)
+		for _, p := range env.ListKnownPackages("p/p.go") {
+			known[p] = true
+		}
+		if !known["fmt"] || !known[Module+"/q"] || known[Module+"/p"] {
+			t.Errorf("known packages = %v, want fmt and %s/q, but not %s/p", known, Module, Module)
+		}
+		env.AddImport("p/p.go", Module+"/q")
+		want := "package p\n\nimport \"" + Module + "/q\"\n\nfunc F() {}\n"
+		if got := env.BufferText("p/p.go"); got != want {
+			t.Errorf("buffer = %q, want %q", got, want)
+		}
+		// The import is only added once.
+		env.AddImport("p/p.go", Module+"/q")
+		if got := env.BufferText("p/p.go"); got != want {
+			t.Errorf("buffer after a second import = %q, want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// semanticTokensLegend is the legend of the semantic tokens sent by the
+// server. The token types and modifiers are in the order of the
+// corresponding source constants, so that their values are their indexes.
+var semanticTokensLegend = protocol.SemanticTokensLegend{
+	TokenTypes: []string{
+		source.NamespaceToken: "namespace",
+		source.TypeToken:      "type",
+		source.FunctionToken:  "function",
+		source.VariableToken:  "variable",
+		source.ParameterToken: "parameter",
+		source.ConstantToken:  "constant",
+		source.PropertyToken:  "property",
+	},
+	TokenModifiers: []string{
+		"declaration",
+		"readonly",
+		"defaultLibrary",
+	},
+}
+
+// toProtocolSemanticTokens encodes the tokens, which must be sorted, as
+// described by the protocol: each token is encoded relative to the previous
+// one.
+func toProtocolSemanticTokens(m *columnMapper, tokens []source.SemanticToken) *protocol.SemanticTokens {
+	data := make([]float64, 0, 5*len(tokens))
+	var line, char float64
+	for _, t := range tokens {
+		rng := toProtocolRange(m, t.Range)
+		deltaLine := rng.Start.Line - line
+		deltaChar := rng.Start.Character
+		if deltaLine == 0 {
+			deltaChar -= char
+		}
+		line, char = rng.Start.Line, rng.Start.Character
+		data = append(data,
+			deltaLine,
+			deltaChar,
+			rng.End.Character-rng.Start.Character,
+			float64(t.Type),
+			float64(t.Modifiers),
+		)
+	}
+	return &protocol.SemanticTokens{Data: data}
+}
@@ -5,12 +5,18 @@
 package lsp
 
 import (
+	"bytes"
 	"context"
-	"go/token"
+	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/debug"
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
 )
@@ -18,19 +24,134 @@ import (
 // RunServer starts an LSP server on the supplied stream, and waits until the
 // stream is closed.
 func RunServer(ctx context.Context, stream jsonrpc2.Stream, opts ...interface{}) error {
-	s := &server{}
+	return (&server{}).run(ctx, stream, opts...)
+}
+
+// RunServerOnAddress listens for clients on addr in the given network, "tcp"
+// or "unix", and serves each client that connects with a server of its own,
+// with a session of its own, until listening fails. The messages of the
+// clients are framed by framer. The exit of a client closes its connection
+// only.
+// The sessions share their cache, so that the packages that one client
+// loaded are not loaded again for another, as long as the contents of their
+// files are the same for both. A unix socket file left by a server of the
+// user that no longer runs is replaced.
+func RunServerOnAddress(ctx context.Context, network, addr string, framer jsonrpc2.Framer, opts ...interface{}) error {
+	l, err := net.Listen(network, addr)
+	if err != nil && network == "unix" {
+		if conn, dialErr := net.Dial(network, addr); dialErr == nil {
+			conn.Close()
+		} else if info, statErr := os.Lstat(addr); statErr == nil && ownedByUser(info) && os.Remove(addr) == nil {
+			l, err = net.Listen(network, addr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	cache := source.NewCache()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go serveConn(ctx, cache, framer(conn, conn), conn, opts...)
+	}
+}
+
+// RunServerOnWebSocket is like RunServerOnAddress, for the clients that
+// connect to the WebSocket server on the TCP address addr, such as browsers
+// whose pages have one of the origins, or the address.
+func RunServerOnWebSocket(ctx context.Context, addr string, origins []string, opts ...interface{}) error {
+	cache := source.NewCache()
+	return http.ListenAndServe(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, conn, err := jsonrpc2.AcceptWebSocket(w, r, origins)
+		if err != nil {
+			return // the handshake failed, and the client was told why
+		}
+		serveConn(ctx, cache, stream, conn, opts...)
+	}))
+}
+
+// serveConn serves the client of a connection with a server of its own until
+// the connection is closed.
+func serveConn(ctx context.Context, cache *source.Cache, stream jsonrpc2.Stream, conn net.Conn, opts ...interface{}) {
+	defer conn.Close()
+	s := &server{cache: cache, exit: func(int) { conn.Close() }}
+	s.run(ctx, stream, opts...)
+}
+
+// run serves the client of the stream until the stream is closed.
+func (s *server) run(ctx context.Context, stream jsonrpc2.Stream, opts ...interface{}) error {
 	conn, client := protocol.RunServer(ctx, stream, s, opts...)
+	// The connection is handling the messages of the client already, the
+	// first of which, initialize, takes the lock too.
+	s.initializedMu.Lock()
 	s.client = client
-	return conn.Wait(ctx)
+	s.initializedMu.Unlock()
+	err := conn.Wait(ctx)
+	if s.session != nil {
+		debug.DropSession(s.session)
+	}
+	return err
 }
 
 type server struct {
 	client protocol.Client
 
+	// cache is the cache of the session of the server, which it shares with
+	// the other servers of the process. If it is nil, the session has a
+	// cache of its own.
+	cache *source.Cache
+
+	// exit ends the server when the client exits, with the given status.
+	// If it is nil, the process exits.
+	exit func(status int)
+
 	initializedMu sync.Mutex
 	initialized   bool // set once the server has received "initialize" request
 
+	// session holds the views of the workspace and the contents of the
+	// open documents.
+	session *source.Session
+
+	// view is the view of the documents that are in no workspace folder.
 	view *source.View
+
+	viewsMu sync.Mutex
+	// folders holds the view of each workspace folder, by its directory.
+	folders map[string]*source.View
+
+	// clientOptions are the features of the protocol that the client
+	// supports.
+	clientOptions clientOptions
+
+	completionMu sync.Mutex
+	// completion holds the items of the last completion, which
+	// completionItem/resolve resolves.
+	completion *completionList
+
+	diagnosingMu sync.Mutex
+	// diagnosing holds the diagnostics in progress, by document.
+	diagnosing map[protocol.DocumentURI]*diagnosis
+	// pendingDiagnostics holds the timers of the delayed diagnostics, by
+	// document.
+	pendingDiagnostics map[protocol.DocumentURI]*time.Timer
+
+	progressMu sync.Mutex
+	// inProgress holds the functions that cancel the operations whose
+	// progress is reported.
+	inProgress map[*progress]context.CancelFunc
+
+	logLevelMu sync.Mutex
+	// logLevel is the most verbose type of the messages that are logged in
+	// the client, or zero for the default level.
+	logLevel protocol.MessageType
+}
+
+// A diagnosis is the computation of the diagnostics of a document.
+type diagnosis struct {
+	cancel context.CancelFunc
 }
 
 func (s *server) Initialize(ctx context.Context, params *protocol.InitializeParams) (*protocol.InitializeResult, error) {
@@ -39,29 +160,92 @@ func (s *server) Initialize(ctx context.Context, params *protocol.InitializePara
 	if s.initialized {
 		return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidRequest, "server already initialized")
 	}
-	s.view = source.NewView()
+	if s.cache != nil {
+		s.session = s.cache.NewSession()
+	} else {
+		s.session = source.NewSession()
+	}
+	s.view = s.session.NewView()
+	debug.AddSession(s.session)
+	folders := params.WorkspaceFolders
+	if len(folders) == 0 && params.RootURI != nil {
+		folders = []protocol.WorkspaceFolder{{URI: string(*params.RootURI)}}
+	}
+	for _, folder := range folders {
+		if err := s.addFolder(folder.URI); err != nil {
+			return nil, err
+		}
+	}
+	s.clientOptions = parseClientOptions(params.Capabilities)
 	s.initialized = true
-	return &protocol.InitializeResult{
+	// The options of rename, which enable prepareRename, may only be sent
+	// to the clients that support them.
+	var renameProvider interface{} = true
+	if s.clientOptions.prepareRename {
+		renameProvider = protocol.RenameOptions{PrepareProvider: true}
+	}
+	result := &protocol.InitializeResult{
 		Capabilities: protocol.ServerCapabilities{
+			CallHierarchyProvider: true,
+			TypeHierarchyProvider: true,
+			CodeActionProvider:    true,
 			CompletionProvider: protocol.CompletionOptions{
 				TriggerCharacters: []string{"."},
+				ResolveProvider:   true,
 			},
 			DefinitionProvider:              true,
+			DocumentHighlightProvider:       true,
+			DocumentSymbolProvider:          true,
 			DocumentFormattingProvider:      true,
 			DocumentRangeFormattingProvider: true,
+			FoldingRangeProvider:            true,
+			HoverProvider:                   true,
+			ImplementationProvider:          true,
+			InlayHintProvider:               true,
+			LinkedEditingRangeProvider:      true,
+			ReferencesProvider:              true,
+			RenameProvider:                  renameProvider,
+			TypeDefinitionProvider:          true,
+			WorkspaceSymbolProvider:         true,
+			ExecuteCommandProvider: protocol.ExecuteCommandOptions{
+				Commands: commandNames(),
+			},
+			DocumentOnTypeFormattingProvider: protocol.DocumentOnTypeFormattingOptions{
+				FirstTriggerCharacter: "}",
+				MoreTriggerCharacter:  []string{"\n"},
+			},
+			SemanticTokensProvider: &protocol.SemanticTokensOptions{
+				Legend: semanticTokensLegend,
+				Full:   true,
+				Range:  true,
+			},
 			SignatureHelpProvider: protocol.SignatureHelpOptions{
 				TriggerCharacters: []string{"("},
 			},
 			TextDocumentSync: protocol.TextDocumentSyncOptions{
-				Change:    float64(protocol.Full), // full contents of file sent on each update
-				OpenClose: true,
+				Change:            float64(protocol.Incremental), // only the changed ranges are sent on each update
+				OpenClose:         true,
+				WillSaveWaitUntil: true,
+				Save:              protocol.SaveOptions{IncludeText: true},
 			},
 		},
-	}, nil
+	}
+	result.Capabilities.Workspace.WorkspaceFolders.Supported = true
+	result.Capabilities.Workspace.WorkspaceFolders.ChangeNotifications = true
+	return result, nil
 }
 
-func (s *server) Initialized(context.Context, *protocol.InitializedParams) error {
-	return nil // ignore
+func (s *server) Initialized(ctx context.Context, params *protocol.InitializedParams) error {
+	s.inBackground(ctx, func() error {
+		if err := s.watchFiles(ctx); err != nil {
+			return err
+		}
+		if err := s.fetchConfiguration(ctx); err != nil {
+			return err
+		}
+		return s.loadFolders(ctx, s.views())
+	})
+	return nil
 }
 
 func (s *server) Shutdown(context.Context) error {
@@ -71,121 +255,354 @@ func (s *server) Shutdown(context.Context) error {
 		return jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidRequest, "server not initialized")
 	}
 	s.initialized = false
+	debug.DropSession(s.session)
 	return nil
 }
 
 func (s *server) Exit(ctx context.Context) error {
+	exit := s.exit
+	if exit == nil {
+		exit = os.Exit
+	}
+	// The status is 1 if the client did not shut the server down first.
 	if s.initialized {
-		os.Exit(1)
+		exit(1)
+	} else {
+		exit(0)
 	}
-	os.Exit(0)
 	return nil
 }
 
-func (s *server) DidChangeWorkspaceFolders(context.Context, *protocol.DidChangeWorkspaceFoldersParams) error {
-	return notImplemented("DidChangeWorkspaceFolders")
+func (s *server) DidChangeWorkspaceFolders(ctx context.Context, params *protocol.DidChangeWorkspaceFoldersParams) error {
+	for _, folder := range params.Event.Removed {
+		if err := s.removeFolder(folder.URI); err != nil {
+			return err
+		}
+	}
+	var added []*source.View
+	for _, folder := range params.Event.Added {
+		if err := s.addFolder(folder.URI); err != nil {
+			return err
+		}
+		added = append(added, s.viewFor(protocol.DocumentURI(folder.URI)))
+	}
+	if len(added) > 0 {
+		s.inBackground(ctx, func() error {
+			if err := s.fetchConfiguration(ctx); err != nil {
+				return err
+			}
+			return s.loadFolders(ctx, added)
+		})
+	}
+	return nil
 }
 
-func (s *server) DidChangeConfiguration(context.Context, *protocol.DidChangeConfigurationParams) error {
-	return notImplemented("DidChangeConfiguration")
+func (s *server) DidChangeConfiguration(ctx context.Context, params *protocol.DidChangeConfigurationParams) error {
+	if !s.clientOptions.configuration {
+		// The notification holds the settings of all the folders.
+		settings, _ := params.Settings.(map[string]interface{})
+		options := parseOptions(settings[configurationSection])
+		s.setLogLevel(parseLogLevel(settings[configurationSection]))
+		for _, v := range s.views() {
+			v.SetOptions(options)
+		}
+		return nil
+	}
+	// The settings in the notification are not necessarily the ones of the
+	// server, so request them instead.
+	s.inBackground(ctx, func() error {
+		return s.fetchConfiguration(ctx)
+	})
+	return nil
 }
 
-func (s *server) DidChangeWatchedFiles(context.Context, *protocol.DidChangeWatchedFilesParams) error {
-	return notImplemented("DidChangeWatchedFiles")
+// inBackground runs f, which sends requests to the client, in a goroutine of
+// its own, and logs its error. The notifications of the client are handled
+// one at a time, by the goroutine that reads its messages, so it could not
+// read the responses to these requests while it handles one.
+func (s *server) inBackground(ctx context.Context, f func() error) {
+	go func() {
+		if err := f(); err != nil {
+			s.logf(ctx, protocol.Error, "%v", err)
+		}
+	}()
 }
 
-func (s *server) Symbols(context.Context, *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
-	return nil, notImplemented("Symbols")
+func (s *server) DidChangeWatchedFiles(ctx context.Context, params *protocol.DidChangeWatchedFilesParams) error {
+	return s.filesChanged(ctx, params.Changes)
 }
 
-func (s *server) ExecuteCommand(context.Context, *protocol.ExecuteCommandParams) (interface{}, error) {
-	return nil, notImplemented("ExecuteCommand")
+func (s *server) WorkDoneProgressCancel(ctx context.Context, params *protocol.WorkDoneProgressCancelParams) error {
+	s.cancelProgress(params.Token)
+	return nil
+}
+
+func (s *server) Symbols(ctx context.Context, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
+	var result []protocol.SymbolInformation
+	for _, v := range s.views() {
+		symbols, err := source.WorkspaceSymbols(ctx, v, params.Query)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, toProtocolSymbolInformation(v, symbols)...)
+	}
+	return result, nil
+}
+
+func (s *server) ExecuteCommand(ctx context.Context, params *protocol.ExecuteCommandParams) (interface{}, error) {
+	return nil, s.executeCommand(ctx, params.Command, params.Arguments)
 }
 
 func (s *server) DidOpen(ctx context.Context, params *protocol.DidOpenTextDocumentParams) error {
-	s.cacheAndDiagnoseFile(ctx, params.TextDocument.URI, params.TextDocument.Text)
+	s.cacheAndDiagnoseFile(ctx, params.TextDocument.URI, params.TextDocument.Text, 0)
 	return nil
 }
 
 func (s *server) DidChange(ctx context.Context, params *protocol.DidChangeTextDocumentParams) error {
+	v := s.viewFor(params.TextDocument.URI)
 	if len(params.ContentChanges) < 1 {
 		return jsonrpc2.NewErrorf(jsonrpc2.CodeInternalError, "no content changes provided")
 	}
-	// We expect the full content of file, i.e. a single change with no range.
-	if change := params.ContentChanges[0]; change.RangeLength == 0 {
-		s.cacheAndDiagnoseFile(ctx, params.TextDocument.URI, change.Text)
+	// Fast path for the common case of a single change with the full content.
+	if change := params.ContentChanges[0]; len(params.ContentChanges) == 1 && change.Range == nil {
+		s.cacheAndDiagnoseFile(ctx, params.TextDocument.URI, change.Text, v.Options().DiagnosticsDelay)
+		return nil
+	}
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	content, err := f.Read()
+	if err != nil {
+		return jsonrpc2.NewErrorf(jsonrpc2.CodeInternalError, "unable to read %s: %v", params.TextDocument.URI, err)
+	}
+	content, err = applyContentChanges(content, params.ContentChanges)
+	if err != nil {
+		return jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%v", err)
 	}
+	s.cacheAndDiagnoseFile(ctx, params.TextDocument.URI, string(content), v.Options().DiagnosticsDelay)
 	return nil
 }
 
-func (s *server) cacheAndDiagnoseFile(ctx context.Context, uri protocol.DocumentURI, text string) {
-	f := s.view.GetFile(source.URI(uri))
-	f.SetContent([]byte(text))
-	go func() {
-		f := s.view.GetFile(source.URI(uri))
-		reports, err := source.Diagnostics(ctx, s.view, f)
+// applyContentChanges applies the changes, in order, to the given content.
+// A change without a range replaces the whole content.
+func applyContentChanges(content []byte, changes []protocol.TextDocumentContentChangeEvent) ([]byte, error) {
+	for _, change := range changes {
+		if change.Range == nil {
+			content = []byte(change.Text)
+			continue
+		}
+		start, err := contentOffset(content, change.Range.Start)
 		if err != nil {
-			return // handle error?
+			return nil, err
 		}
-		for filename, diagnostics := range reports {
-			s.client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
-				URI:         protocol.DocumentURI(source.ToURI(filename)),
-				Diagnostics: toProtocolDiagnostics(s.view, diagnostics),
-			})
+		end, err := contentOffset(content, change.Range.End)
+		if err != nil {
+			return nil, err
+		}
+		if start > end {
+			return nil, fmt.Errorf("invalid range for content change: %v", *change.Range)
 		}
+		var buf bytes.Buffer
+		buf.Grow(len(content) - (end - start) + len(change.Text))
+		buf.Write(content[:start])
+		buf.WriteString(change.Text)
+		buf.Write(content[end:])
+		content = buf.Bytes()
+	}
+	return content, nil
+}
+
+// cacheAndDiagnoseFile sets the content of a document, and publishes its
+// diagnostics after the given delay.
+func (s *server) cacheAndDiagnoseFile(ctx context.Context, uri protocol.DocumentURI, text string, delay time.Duration) {
+	f := s.viewFor(uri).GetFile(source.URI(uri))
+	f.SetContent([]byte(text))
+	s.scheduleDiagnostics(ctx, uri, delay)
+}
+
+// diagnose publishes the diagnostics of the package of a document.
+// It cancels the diagnostics of the document that are in progress, which
+// are outdated.
+func (s *server) diagnose(ctx context.Context, uri protocol.DocumentURI) {
+	ctx, cancel := context.WithCancel(ctx)
+	d := &diagnosis{cancel: cancel}
+	s.diagnosingMu.Lock()
+	if s.diagnosing == nil {
+		s.diagnosing = make(map[protocol.DocumentURI]*diagnosis)
+	}
+	if prev, ok := s.diagnosing[uri]; ok {
+		prev.cancel()
+	}
+	s.diagnosing[uri] = d
+	s.diagnosingMu.Unlock()
+	defer func() {
+		s.diagnosingMu.Lock()
+		if s.diagnosing[uri] == d {
+			delete(s.diagnosing, uri)
+		}
+		s.diagnosingMu.Unlock()
+		cancel()
 	}()
+
+	if _, filename, content, ok := s.templateFile(uri); ok {
+		s.templateDiagnostics(ctx, uri, filename, content)
+		return
+	}
+	v := s.viewFor(uri)
+	f := v.GetFile(source.URI(uri))
+	reports, err := source.Diagnostics(ctx, v, f, v.Options().Diagnostics)
+	if err != nil || ctx.Err() != nil {
+		return // handle error?
+	}
+	for filename, diagnostics := range reports {
+		s.publishDiagnostics(ctx, v, filename, diagnostics)
+	}
 }
 
 func (s *server) WillSave(context.Context, *protocol.WillSaveTextDocumentParams) error {
 	return notImplemented("WillSave")
 }
 
-func (s *server) WillSaveWaitUntil(context.Context, *protocol.WillSaveTextDocumentParams) ([]protocol.TextEdit, error) {
-	return nil, notImplemented("WillSaveWaitUntil")
+func (s *server) WillSaveWaitUntil(ctx context.Context, params *protocol.WillSaveTextDocumentParams) ([]protocol.TextEdit, error) {
+	return saveEdits(ctx, s.viewFor(params.TextDocument.URI), params.TextDocument.URI, params.Reason), nil
 }
 
-func (s *server) DidSave(context.Context, *protocol.DidSaveTextDocumentParams) error {
-	// TODO(rstambler): Should we clear the cache here?
-	return nil // ignore
+// saveEdits returns the edits that the options of v apply to a Go file
+// before it is saved: the organization of its imports, which formats it as
+// goimports does, or its formatting. The automatic saves after a delay are
+// left alone, as they happen while the user types. A file that does not
+// parse is saved as it is.
+func saveEdits(ctx context.Context, v *source.View, uri protocol.DocumentURI, reason protocol.TextDocumentSaveReason) []protocol.TextEdit {
+	if reason == protocol.AfterDelay || !strings.HasSuffix(string(uri), ".go") {
+		return nil
+	}
+	var edits []protocol.TextEdit
+	var err error
+	switch options := v.Options(); {
+	case options.OrganizeImportsOnSave:
+		edits, err = organizeImports(ctx, v, uri)
+	case options.FormatOnSave:
+		edits, err = formatRange(ctx, v, uri, nil)
+	}
+	if err != nil {
+		return nil
+	}
+	return edits
+}
+
+func (s *server) DidSave(ctx context.Context, params *protocol.DidSaveTextDocumentParams) error {
+	// The text that the client includes is the content of the document as
+	// it was saved, which replaces the content of the server if a change
+	// was missed.
+	if params.Text != nil {
+		content, err := s.viewFor(params.TextDocument.URI).GetFile(source.URI(params.TextDocument.URI)).Read()
+		if err != nil || string(content) != *params.Text {
+			s.cacheAndDiagnoseFile(ctx, params.TextDocument.URI, *params.Text, 0)
+			return nil
+		}
+	}
+	// Publish the diagnostics that are delayed at once.
+	s.diagnosingMu.Lock()
+	_, pending := s.pendingDiagnostics[params.TextDocument.URI]
+	s.diagnosingMu.Unlock()
+	if pending {
+		s.scheduleDiagnostics(ctx, params.TextDocument.URI, 0)
+	}
+	return nil
 }
 
 func (s *server) DidClose(ctx context.Context, params *protocol.DidCloseTextDocumentParams) error {
-	s.view.GetFile(source.URI(params.TextDocument.URI)).SetContent(nil)
+	// The diagnostics of the content of the closed document are outdated.
+	s.diagnosingMu.Lock()
+	s.cancelDiagnostics(params.TextDocument.URI)
+	s.diagnosingMu.Unlock()
+	s.viewFor(params.TextDocument.URI).GetFile(source.URI(params.TextDocument.URI)).SetContent(nil)
 	return nil
 }
 
 func (s *server) Completion(ctx context.Context, params *protocol.CompletionParams) (*protocol.CompletionList, error) {
-	f := s.view.GetFile(source.URI(params.TextDocument.URI))
-	tok, err := f.GetToken()
+	if v, filename, content, ok := s.templateFile(params.TextDocument.URI); ok {
+		return templateCompletion(v, filename, content, params.Position)
+	}
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
 	if err != nil {
 		return nil, err
 	}
-	pos := fromProtocolPosition(tok, params.Position)
-	items, err := source.Completion(ctx, f, pos)
+	pos := fromProtocolPosition(m, params.Position)
+	opts := v.Options().Completion
+	start := time.Now()
+	items, err := source.Completion(ctx, f, pos, opts)
 	if err != nil {
 		return nil, err
 	}
+	s.logf(ctx, protocol.Log, "completion at %s:%d:%d: %d candidates in %v",
+		params.TextDocument.URI, int(params.Position.Line)+1, int(params.Position.Character)+1, len(items), time.Since(start))
+	results := toProtocolCompletionItems(m, items, s.clientOptions.snippets)
+	incomplete := opts.MaxResults > 0 && len(results) > opts.MaxResults
+	if incomplete {
+		results = results[:opts.MaxResults]
+	}
+	s.rememberCompletion(f, items[:len(results)], results)
 	return &protocol.CompletionList{
-		IsIncomplete: false,
-		Items:        toProtocolCompletionItems(items),
+		IsIncomplete: incomplete,
+		Items:        results,
 	}, nil
 }
 
-func (s *server) CompletionResolve(context.Context, *protocol.CompletionItem) (*protocol.CompletionItem, error) {
-	return nil, notImplemented("CompletionResolve")
+func (s *server) CompletionResolve(ctx context.Context, item *protocol.CompletionItem) (*protocol.CompletionItem, error) {
+	f, sourceItem, ok := s.completionItem(item)
+	if !ok {
+		return item, nil // an item of an earlier completion
+	}
+	info, err := source.CompletionDocumentation(ctx, f, sourceItem)
+	if err != nil {
+		return item, nil
+	}
+	resolved := *item
+	resolved.Detail = info.Signature
+	if doc := strings.TrimSpace(info.Doc); doc != "" {
+		// The doc comment reads the same as markdown and as plain text.
+		resolved.Documentation = protocol.MarkupContent{
+			Kind:  s.clientOptions.completionDocKind,
+			Value: doc,
+		}
+	}
+	return &resolved, nil
 }
 
-func (s *server) Hover(context.Context, *protocol.TextDocumentPositionParams) (*protocol.Hover, error) {
-	return nil, notImplemented("Hover")
+func (s *server) Hover(ctx context.Context, params *protocol.TextDocumentPositionParams) (*protocol.Hover, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pos := fromProtocolPosition(m, params.Position)
+	info, err := source.Hover(ctx, f, pos, v.Options().Hover)
+	if err != nil {
+		return nil, err
+	}
+	contents := protocol.MarkupContent{
+		Kind:  s.clientOptions.hoverKind,
+		Value: info.PlainText(),
+	}
+	if contents.Kind == protocol.Markdown {
+		contents.Value = info.Markdown()
+	}
+	return &protocol.Hover{
+		Contents: contents,
+		Range:    toProtocolRange(m, info.Range),
+	}, nil
 }
 
 func (s *server) SignatureHelp(ctx context.Context, params *protocol.TextDocumentPositionParams) (*protocol.SignatureHelp, error) {
-	f := s.view.GetFile(source.URI(params.TextDocument.URI))
-	tok, err := f.GetToken()
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
 	if err != nil {
 		return nil, err
 	}
-	pos := fromProtocolPosition(tok, params.Position)
+	pos := fromProtocolPosition(m, params.Position)
 	info, err := source.SignatureHelp(ctx, f, pos)
 	if err != nil {
 		return nil, err
@@ -194,53 +611,423 @@ func (s *server) SignatureHelp(ctx context.Context, params *protocol.TextDocumen
 }
 
 func (s *server) Definition(ctx context.Context, params *protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
-	f := s.view.GetFile(source.URI(params.TextDocument.URI))
-	tok, err := f.GetToken()
+	if v, filename, content, ok := s.templateFile(params.TextDocument.URI); ok {
+		return templateDefinition(v, filename, content, params.Position)
+	}
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
 	if err != nil {
 		return nil, err
 	}
-	pos := fromProtocolPosition(tok, params.Position)
+	pos := fromProtocolPosition(m, params.Position)
+	if files, ok, err := source.EmbeddedFiles(ctx, f, pos); ok || err != nil {
+		if err != nil {
+			return nil, err
+		}
+		locations := make([]protocol.Location, 0, len(files))
+		for _, filename := range files {
+			locations = append(locations, protocol.Location{URI: protocol.DocumentURI(source.ToURI(filename))})
+		}
+		return locations, nil
+	}
 	r, err := source.Definition(ctx, f, pos)
 	if err != nil {
 		return nil, err
 	}
-	return []protocol.Location{toProtocolLocation(s.view.Config.Fset, r)}, nil
+	return []protocol.Location{toProtocolLocation(v, r)}, nil
+}
+
+func (s *server) TypeDefinition(ctx context.Context, params *protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pos := fromProtocolPosition(m, params.Position)
+	r, err := source.TypeDefinition(ctx, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	return []protocol.Location{toProtocolLocation(v, r)}, nil
+}
+
+func (s *server) Implementation(ctx context.Context, params *protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pos := fromProtocolPosition(m, params.Position)
+	impls, err := source.Implementation(ctx, v, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	locations := make([]protocol.Location, 0, len(impls))
+	for _, r := range impls {
+		locations = append(locations, toProtocolLocation(v, r))
+	}
+	return locations, nil
+}
+
+func (s *server) References(ctx context.Context, params *protocol.ReferenceParams) ([]protocol.Location, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pos := fromProtocolPosition(m, params.Position)
+	refs, err := source.References(ctx, v, f, pos, params.Context.IncludeDeclaration, v.Options().References)
+	if err != nil {
+		return nil, err
+	}
+	var locations []protocol.Location
+	for _, r := range refs {
+		locations = append(locations, toProtocolLocation(v, r))
+	}
+	return locations, nil
+}
+
+func (s *server) DocumentHighlight(ctx context.Context, params *protocol.TextDocumentPositionParams) ([]protocol.DocumentHighlight, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pos := fromProtocolPosition(m, params.Position)
+	highlights, err := source.Highlights(ctx, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]protocol.DocumentHighlight, 0, len(highlights))
+	for _, h := range highlights {
+		result = append(result, protocol.DocumentHighlight{
+			Range: toProtocolRange(m, h.Range),
+			Kind:  float64(toProtocolHighlightKind(h.Kind)),
+		})
+	}
+	return result, nil
+}
+
+func toProtocolHighlightKind(kind source.HighlightKind) protocol.DocumentHighlightKind {
+	switch kind {
+	case source.ReadHighlight:
+		return protocol.ReadHighlight
+	case source.WriteHighlight:
+		return protocol.WriteHighlight
+	}
+	return protocol.TextHighlight // default
+}
+
+func (s *server) DocumentSymbol(ctx context.Context, params *protocol.DocumentSymbolParams) ([]interface{}, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := source.DocumentSymbols(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	var result []interface{}
+	if s.clientOptions.hierarchicalSymbols {
+		for _, sym := range toProtocolDocumentSymbols(m, symbols) {
+			result = append(result, sym)
+		}
+	} else {
+		for _, sym := range toProtocolFlatSymbols(params.TextDocument.URI, m, symbols, "") {
+			result = append(result, sym)
+		}
+	}
+	return result, nil
+}
+
+func (s *server) CodeAction(ctx context.Context, params *protocol.CodeActionParams) ([]protocol.CodeAction, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	if wantsKind(params.Context.Only, sourceNewFile) {
+		// A new file that is still blank is not parsed yet, so the other
+		// actions do not apply to it.
+		if edit, err := newFileContent(v, params.TextDocument.URI); err == nil {
+			title := "Add package clause"
+			if strings.HasSuffix(string(params.TextDocument.URI), "_test.go") {
+				title = "Add package clause and test function"
+			}
+			return []protocol.CodeAction{{
+				Title: title,
+				Kind:  sourceNewFile,
+				Edit: protocol.WorkspaceEdit{
+					Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+						params.TextDocument.URI: {*edit},
+					},
+				},
+			}}, nil
+		}
+	}
+	var actions []protocol.CodeAction
+	if wantsKind(params.Context.Only, protocol.SourceOrganizeImports) {
+		edits, err := organizeImports(ctx, v, params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		if len(edits) > 0 {
+			actions = append(actions, protocol.CodeAction{
+				Title: "Organize Imports",
+				Kind:  protocol.SourceOrganizeImports,
+				Edit: protocol.WorkspaceEdit{
+					Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+						params.TextDocument.URI: edits,
+					},
+				},
+			})
+		}
+	}
+	if wantsKind(params.Context.Only, protocol.QuickFix) && len(params.Context.Diagnostics) > 0 {
+		fixes, err := quickFixes(ctx, v, params.TextDocument.URI, params.Context.Diagnostics)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, fixes...)
+	}
+	if wantsKind(params.Context.Only, refactorExtractFunction) && params.Range.Start != params.Range.End {
+		// The selection is usually not extractable, which is not an error.
+		if edits, err := extractFunction(ctx, v, params.TextDocument.URI, params.Range); err == nil {
+			actions = append(actions, protocol.CodeAction{
+				Title: "Extract to function",
+				Kind:  refactorExtractFunction,
+				Edit: protocol.WorkspaceEdit{
+					Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+						params.TextDocument.URI: edits,
+					},
+				},
+			})
+		}
+	}
+	if wantsKind(params.Context.Only, refactorExtractVariable) && params.Range.Start != params.Range.End {
+		if action, err := refactoring(ctx, v, params.TextDocument.URI, params.Range, refactorExtractVariable, source.ExtractVariable); err == nil {
+			actions = append(actions, *action)
+		}
+	}
+	if wantsKind(params.Context.Only, protocol.RefactorRewrite) {
+		refactorings := []func(context.Context, *source.File, source.Range) (*source.SuggestedFix, error){
+			source.FillStruct,
+			source.AddDocComment,
+		}
+		tagCase := v.Options().StructTagCase
+		for _, key := range structTagKeys {
+			key := key
+			refactorings = append(refactorings, func(ctx context.Context, f *source.File, rng source.Range) (*source.SuggestedFix, error) {
+				return source.AddStructTags(ctx, f, rng, key, tagCase)
+			})
+		}
+		refactorings = append(refactorings, source.RemoveStructTags)
+		for _, refactor := range refactorings {
+			if action, err := refactoring(ctx, v, params.TextDocument.URI, params.Range, protocol.RefactorRewrite, refactor); err == nil {
+				actions = append(actions, *action)
+			}
+		}
+	}
+	return actions, nil
+}
+
+// structTagKeys are the keys of the struct tags that code actions add.
+var structTagKeys = []string{"json", "yaml", "xml"}
+
+// The kinds of the code actions that extract the selected statements into a
+// new function, and the selected expression into a new variable.
+const (
+	refactorExtractFunction = protocol.RefactorExtract + ".function"
+	refactorExtractVariable = protocol.RefactorExtract + ".variable"
+)
+
+// sourceNewFile is the kind of the code action that fills a blank Go file
+// with a package clause.
+const sourceNewFile = protocol.Source + ".newFile"
+
+// newFileContent returns the edit that replaces the content of a blank Go
+// file, which may not be parsed yet, with a package clause.
+func newFileContent(v *source.View, uri protocol.DocumentURI) (*protocol.TextEdit, error) {
+	f := v.GetFile(source.URI(uri))
+	content, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	newContent, err := source.NewFileContent(f)
+	if err != nil {
+		return nil, err
+	}
+	// The blank content is made of ASCII whitespace, whose characters are
+	// each a UTF-16 code unit.
+	lines := strings.Split(string(content), "\n")
+	return &protocol.TextEdit{
+		Range: protocol.Range{
+			End: protocol.Position{
+				Line:      float64(len(lines) - 1),
+				Character: float64(len(lines[len(lines)-1])),
+			},
+		},
+		NewText: newContent,
+	}, nil
 }
 
-func (s *server) TypeDefinition(context.Context, *protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
-	return nil, notImplemented("TypeDefinition")
+// refactoring returns the code action of the given kind for the fix that
+// the refactoring computes for rng.
+func refactoring(ctx context.Context, v *source.View, uri protocol.DocumentURI, rng protocol.Range, kind protocol.CodeActionKind, refactor func(context.Context, *source.File, source.Range) (*source.SuggestedFix, error)) (*protocol.CodeAction, error) {
+	f := v.GetFile(source.URI(uri))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	fix, err := refactor(ctx, f, fromProtocolRange(m, rng))
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.CodeAction{
+		Title: fix.Title,
+		Kind:  kind,
+		Edit: protocol.WorkspaceEdit{
+			Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+				uri: toProtocolEdits(m, fix.Edits),
+			},
+		},
+	}, nil
 }
 
-func (s *server) Implementation(context.Context, *protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
-	return nil, notImplemented("Implementation")
+// extractFunction returns the edits that extract the statements selected by
+// rng into a new function.
+func extractFunction(ctx context.Context, v *source.View, uri protocol.DocumentURI, rng protocol.Range) ([]protocol.TextEdit, error) {
+	f := v.GetFile(source.URI(uri))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	edits, err := source.ExtractFunction(ctx, f, fromProtocolRange(m, rng))
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolEdits(m, edits), nil
 }
 
-func (s *server) References(context.Context, *protocol.ReferenceParams) ([]protocol.Location, error) {
-	return nil, notImplemented("References")
+// quickFixes returns the code actions for the suggested fixes of the given
+// diagnostics of a document.
+func quickFixes(ctx context.Context, v *source.View, uri protocol.DocumentURI, diagnostics []protocol.Diagnostic) ([]protocol.CodeAction, error) {
+	f := v.GetFile(source.URI(uri))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	filename, err := f.URI.Filename()
+	if err != nil {
+		return nil, err
+	}
+	reports, err := source.Diagnostics(ctx, v, f, v.Options().Diagnostics)
+	if err != nil {
+		return nil, err
+	}
+	var actions []protocol.CodeAction
+	for _, diag := range reports[filename] {
+		if len(diag.SuggestedFixes) == 0 {
+			continue
+		}
+		rng := toProtocolRange(m, diag.Range)
+		for _, d := range diagnostics {
+			// The client sends back the diagnostics we published, so they are
+			// matched by their range and message.
+			if d.Range != rng || d.Message != diag.Message {
+				continue
+			}
+			for _, fix := range diag.SuggestedFixes {
+				actions = append(actions, protocol.CodeAction{
+					Title:       fix.Title,
+					Kind:        protocol.QuickFix,
+					Diagnostics: []protocol.Diagnostic{d},
+					Edit:        toWorkspaceEdit(v, fix.Edits),
+				})
+			}
+		}
+	}
+	return actions, nil
 }
 
-func (s *server) DocumentHighlight(context.Context, *protocol.TextDocumentPositionParams) ([]protocol.DocumentHighlight, error) {
-	return nil, notImplemented("DocumentHighlight")
+// toWorkspaceEdit returns the workspace edit that applies the edits, which
+// may be in different files.
+func toWorkspaceEdit(v *source.View, edits []source.TextEdit) protocol.WorkspaceEdit {
+	changes := make(map[protocol.DocumentURI][]protocol.TextEdit)
+	for _, edit := range edits {
+		m := fileColumnMapper(v, edit.Range.Start)
+		uri := protocol.DocumentURI(source.ToURI(m.filename))
+		changes[uri] = append(changes[uri], toProtocolEdits(m, []source.TextEdit{edit})...)
+	}
+	return protocol.WorkspaceEdit{Changes: changes}
 }
 
-func (s *server) DocumentSymbol(context.Context, *protocol.DocumentSymbolParams) ([]protocol.DocumentSymbol, error) {
-	return nil, notImplemented("DocumentSymbol")
+// wantsKind reports whether code actions of the given kind were requested.
+// Kinds are hierarchical, so requesting "source" includes
+// "source.organizeImports". An empty list requests all kinds.
+func wantsKind(only []protocol.CodeActionKind, kind protocol.CodeActionKind) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, k := range only {
+		if k == kind || strings.HasPrefix(string(kind), string(k)+".") {
+			return true
+		}
+	}
+	return false
 }
 
-func (s *server) CodeAction(context.Context, *protocol.CodeActionParams) ([]protocol.CodeAction, error) {
-	return nil, notImplemented("CodeAction")
+// organizeImports returns the edits that fix the imports of a document.
+func organizeImports(ctx context.Context, v *source.View, uri protocol.DocumentURI) ([]protocol.TextEdit, error) {
+	f := v.GetFile(source.URI(uri))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	r := source.Range{
+		Start: m.tok.Pos(0),
+		End:   m.tok.Pos(m.tok.Size()),
+	}
+	edits, err := source.Imports(ctx, f, r)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolEdits(m, edits), nil
 }
 
-func (s *server) CodeLens(context.Context, *protocol.CodeLensParams) ([]protocol.CodeLens, error) {
-	return nil, nil // ignore
+func (s *server) CodeLens(ctx context.Context, params *protocol.CodeLensParams) ([]protocol.CodeLens, error) {
+	return codeLenses(ctx, s.viewFor(params.TextDocument.URI), params.TextDocument.URI)
 }
 
 func (s *server) CodeLensResolve(context.Context, *protocol.CodeLens) (*protocol.CodeLens, error) {
 	return nil, notImplemented("CodeLensResolve")
 }
 
-func (s *server) DocumentLink(context.Context, *protocol.DocumentLinkParams) ([]protocol.DocumentLink, error) {
-	return nil, nil // ignore
+func (s *server) DocumentLink(ctx context.Context, params *protocol.DocumentLinkParams) ([]protocol.DocumentLink, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	if source.IsModFile(f.URI) {
+		return nil, nil
+	}
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	links, err := source.Links(ctx, f, v.Options().DocumentationURL)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]protocol.DocumentLink, 0, len(links))
+	for _, link := range links {
+		result = append(result, protocol.DocumentLink{
+			Range:  toProtocolRange(m, link.Range),
+			Target: protocol.DocumentURI(link.Target),
+		})
+	}
+	return result, nil
 }
 
 func (s *server) DocumentLinkResolve(context.Context, *protocol.DocumentLink) (*protocol.DocumentLink, error) {
@@ -256,58 +1043,365 @@ func (s *server) ColorPresentation(context.Context, *protocol.ColorPresentationP
 }
 
 func (s *server) Formatting(ctx context.Context, params *protocol.DocumentFormattingParams) ([]protocol.TextEdit, error) {
-	return formatRange(ctx, s.view, params.TextDocument.URI, nil)
+	return formatRange(ctx, s.viewFor(params.TextDocument.URI), params.TextDocument.URI, nil)
 }
 
 func (s *server) RangeFormatting(ctx context.Context, params *protocol.DocumentRangeFormattingParams) ([]protocol.TextEdit, error) {
-	return formatRange(ctx, s.view, params.TextDocument.URI, &params.Range)
+	return formatRange(ctx, s.viewFor(params.TextDocument.URI), params.TextDocument.URI, &params.Range)
 }
 
 // formatRange formats a document with a given range.
 func formatRange(ctx context.Context, v *source.View, uri protocol.DocumentURI, rng *protocol.Range) ([]protocol.TextEdit, error) {
 	f := v.GetFile(source.URI(uri))
-	tok, err := f.GetToken()
+	m, err := newColumnMapper(ctx, f)
 	if err != nil {
 		return nil, err
 	}
 	var r source.Range
 	if rng == nil {
-		r.Start = tok.Pos(0)
-		r.End = tok.Pos(tok.Size())
+		r.Start = m.tok.Pos(0)
+		r.End = m.tok.Pos(m.tok.Size())
 	} else {
-		r = fromProtocolRange(tok, *rng)
+		r = fromProtocolRange(m, *rng)
 	}
 	edits, err := source.Format(ctx, f, r)
 	if err != nil {
 		return nil, err
 	}
-	return toProtocolEdits(tok, edits), nil
+	return toProtocolEdits(m, edits), nil
 }
 
-func toProtocolEdits(f *token.File, edits []source.TextEdit) []protocol.TextEdit {
+func toProtocolEdits(m *columnMapper, edits []source.TextEdit) []protocol.TextEdit {
 	if edits == nil {
 		return nil
 	}
 	result := make([]protocol.TextEdit, len(edits))
 	for i, edit := range edits {
 		result[i] = protocol.TextEdit{
-			Range:   toProtocolRange(f, edit.Range),
+			Range:   toProtocolRange(m, edit.Range),
 			NewText: edit.NewText,
 		}
 	}
 	return result
 }
 
-func (s *server) OnTypeFormatting(context.Context, *protocol.DocumentOnTypeFormattingParams) ([]protocol.TextEdit, error) {
-	return nil, notImplemented("OnTypeFormatting")
+func (s *server) OnTypeFormatting(ctx context.Context, params *protocol.DocumentOnTypeFormattingParams) ([]protocol.TextEdit, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pos := fromProtocolPosition(m, params.Position)
+	edits, err := source.FormatOnType(ctx, f, pos, params.Ch)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolEdits(m, edits), nil
+}
+
+func (s *server) Rename(ctx context.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pos := fromProtocolPosition(m, params.Position)
+	ctx, p := s.startProgress(ctx, "Renaming to "+params.NewName, true)
+	edits, err := source.Rename(ctx, v, f, pos, params.NewName)
+	p.end("")
+	if err != nil {
+		return nil, err
+	}
+	changes := make(map[protocol.DocumentURI][]protocol.TextEdit)
+	for uri, uriEdits := range edits {
+		if len(uriEdits) == 0 {
+			continue
+		}
+		changes[protocol.DocumentURI(uri)] = toProtocolEdits(fileColumnMapper(v, uriEdits[0].Range.Start), uriEdits)
+	}
+	return &protocol.WorkspaceEdit{Changes: changes}, nil
+}
+
+func (s *server) PrepareRename(ctx context.Context, params *protocol.TextDocumentPositionParams) (*protocol.PrepareRenameResult, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	rng, err := source.PrepareRename(ctx, v, f, fromProtocolPosition(m, params.Position))
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.PrepareRenameResult{
+		Range:       toProtocolRange(m, rng),
+		Placeholder: string(content[m.tok.Offset(rng.Start):m.tok.Offset(rng.End)]),
+	}, nil
 }
 
-func (s *server) Rename(context.Context, *protocol.RenameParams) ([]protocol.WorkspaceEdit, error) {
-	return nil, notImplemented("Rename")
+// identifierPattern matches the names of Go identifiers, to which the linked
+// editing ranges are restricted.
+const identifierPattern = `[\p{L}_][\p{L}\p{Nd}_]*`
+
+func (s *server) LinkedEditingRange(ctx context.Context, params *protocol.TextDocumentPositionParams) (*protocol.LinkedEditingRanges, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := source.LinkedEditingRanges(ctx, f, fromProtocolPosition(m, params.Position))
+	if err != nil || len(ranges) == 0 {
+		return nil, err
+	}
+	result := &protocol.LinkedEditingRanges{WordPattern: identifierPattern}
+	for _, rng := range ranges {
+		result.Ranges = append(result.Ranges, toProtocolRange(m, rng))
+	}
+	return result, nil
 }
 
-func (s *server) FoldingRanges(context.Context, *protocol.FoldingRangeRequestParam) ([]protocol.FoldingRange, error) {
-	return nil, notImplemented("FoldingRanges")
+func (s *server) FoldingRanges(ctx context.Context, params *protocol.FoldingRangeRequestParam) ([]protocol.FoldingRange, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := source.FoldingRanges(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]protocol.FoldingRange, 0, len(ranges))
+	for _, r := range ranges {
+		rng := toProtocolRange(m, r.Range)
+		if s.clientOptions.lineFoldingOnly {
+			// The last line stays visible, as it usually closes the range.
+			if rng.End.Line--; rng.End.Line <= rng.Start.Line {
+				continue
+			}
+			rng.Start.Character, rng.End.Character = 0, 0
+		}
+		result = append(result, protocol.FoldingRange{
+			StartLine:      rng.Start.Line,
+			StartCharacter: rng.Start.Character,
+			EndLine:        rng.End.Line,
+			EndCharacter:   rng.End.Character,
+			Kind:           string(toProtocolFoldingRangeKind(r.Kind)),
+		})
+	}
+	return result, nil
+}
+
+func toProtocolFoldingRangeKind(kind source.FoldingRangeKind) protocol.FoldingRangeKind {
+	switch kind {
+	case source.CommentFolding:
+		return protocol.Comment
+	case source.ImportsFolding:
+		return protocol.Imports
+	}
+	return "" // there is no standard kind for code
+}
+
+func (s *server) SemanticTokensFull(ctx context.Context, params *protocol.SemanticTokensParams) (*protocol.SemanticTokens, error) {
+	return semanticTokens(ctx, s.viewFor(params.TextDocument.URI), params.TextDocument.URI, nil)
+}
+
+func (s *server) SemanticTokensRange(ctx context.Context, params *protocol.SemanticTokensRangeParams) (*protocol.SemanticTokens, error) {
+	return semanticTokens(ctx, s.viewFor(params.TextDocument.URI), params.TextDocument.URI, &params.Range)
+}
+
+// semanticTokens returns the semantic tokens of a document within a given
+// range, or of the whole document if the range is nil.
+func semanticTokens(ctx context.Context, v *source.View, uri protocol.DocumentURI, rng *protocol.Range) (*protocol.SemanticTokens, error) {
+	f := v.GetFile(source.URI(uri))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	var r source.Range
+	if rng != nil {
+		r = fromProtocolRange(m, *rng)
+	}
+	tokens, err := source.SemanticTokens(ctx, f, r)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolSemanticTokens(m, tokens), nil
+}
+
+func (s *server) InlayHint(ctx context.Context, params *protocol.InlayHintParams) ([]protocol.InlayHint, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	rng := fromProtocolRange(m, params.Range)
+	hints, err := source.InlayHints(ctx, f, rng, v.Options().Hints)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]protocol.InlayHint, 0, len(hints))
+	for _, h := range hints {
+		hint := protocol.InlayHint{
+			Position: toProtocolPosition(m, h.Pos),
+			Label:    h.Label,
+		}
+		switch h.Kind {
+		case source.ParameterNameHint:
+			hint.Kind = protocol.ParameterHint
+			hint.PaddingRight = true
+		case source.VariableTypeHint:
+			hint.Kind = protocol.TypeHint
+			hint.PaddingLeft = true
+		}
+		result = append(result, hint)
+	}
+	return result, nil
+}
+
+func (s *server) PrepareCallHierarchy(ctx context.Context, params *protocol.CallHierarchyPrepareParams) ([]protocol.CallHierarchyItem, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pos := fromProtocolPosition(m, params.Position)
+	item, err := source.PrepareCallHierarchy(ctx, v, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	return []protocol.CallHierarchyItem{toProtocolCallHierarchyItem(v, *item)}, nil
+}
+
+func (s *server) IncomingCalls(ctx context.Context, params *protocol.CallHierarchyIncomingCallsParams) ([]protocol.CallHierarchyIncomingCall, error) {
+	v := s.viewFor(params.Item.URI)
+	f, pos, err := callHierarchyItemPos(ctx, v, params.Item)
+	if err != nil {
+		return nil, err
+	}
+	calls, err := source.IncomingCalls(ctx, v, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]protocol.CallHierarchyIncomingCall, 0, len(calls))
+	for _, c := range calls {
+		result = append(result, protocol.CallHierarchyIncomingCall{
+			From:       toProtocolCallHierarchyItem(v, c.Item),
+			FromRanges: toProtocolRanges(v, c.Ranges),
+		})
+	}
+	return result, nil
+}
+
+func (s *server) OutgoingCalls(ctx context.Context, params *protocol.CallHierarchyOutgoingCallsParams) ([]protocol.CallHierarchyOutgoingCall, error) {
+	v := s.viewFor(params.Item.URI)
+	f, pos, err := callHierarchyItemPos(ctx, v, params.Item)
+	if err != nil {
+		return nil, err
+	}
+	calls, err := source.OutgoingCalls(ctx, v, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]protocol.CallHierarchyOutgoingCall, 0, len(calls))
+	for _, c := range calls {
+		result = append(result, protocol.CallHierarchyOutgoingCall{
+			To:         toProtocolCallHierarchyItem(v, c.Item),
+			FromRanges: toProtocolRanges(v, c.Ranges),
+		})
+	}
+	return result, nil
+}
+
+func (s *server) PrepareTypeHierarchy(ctx context.Context, params *protocol.TypeHierarchyPrepareParams) ([]protocol.TypeHierarchyItem, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pos := fromProtocolPosition(m, params.Position)
+	item, err := source.PrepareTypeHierarchy(ctx, v, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	return []protocol.TypeHierarchyItem{toProtocolTypeHierarchyItem(v, *item)}, nil
+}
+
+func (s *server) Supertypes(ctx context.Context, params *protocol.TypeHierarchySupertypesParams) ([]protocol.TypeHierarchyItem, error) {
+	v := s.viewFor(params.Item.URI)
+	f, pos, err := typeHierarchyItemPos(ctx, v, params.Item)
+	if err != nil {
+		return nil, err
+	}
+	items, err := source.Supertypes(ctx, v, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolTypeHierarchyItems(v, items), nil
+}
+
+func (s *server) Subtypes(ctx context.Context, params *protocol.TypeHierarchySubtypesParams) ([]protocol.TypeHierarchyItem, error) {
+	v := s.viewFor(params.Item.URI)
+	f, pos, err := typeHierarchyItemPos(ctx, v, params.Item)
+	if err != nil {
+		return nil, err
+	}
+	items, err := source.Subtypes(ctx, v, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolTypeHierarchyItems(v, items), nil
+}
+
+func (s *server) ListKnownPackages(ctx context.Context, params *protocol.ListKnownPackagesParams) (*protocol.ListKnownPackagesResult, error) {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	packages, err := source.KnownPackages(ctx, v, f)
+	if err != nil {
+		return nil, err
+	}
+	if packages == nil {
+		packages = []string{}
+	}
+	return &protocol.ListKnownPackagesResult{Packages: packages}, nil
+}
+
+func (s *server) AddImport(ctx context.Context, params *protocol.AddImportParams) error {
+	v := s.viewFor(params.TextDocument.URI)
+	f := v.GetFile(source.URI(params.TextDocument.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return err
+	}
+	edits, err := source.AddImport(ctx, f, params.ImportPath)
+	if err != nil || len(edits) == 0 {
+		return err
+	}
+	applied, err := s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+		Label: fmt.Sprintf("Add import %q", params.ImportPath),
+		Edit: protocol.WorkspaceEdit{
+			Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+				params.TextDocument.URI: toProtocolEdits(m, edits),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return fmt.Errorf("the client did not add the import of %q", params.ImportPath)
+	}
+	return nil
 }
 
 func notImplemented(method string) *jsonrpc2.Error {
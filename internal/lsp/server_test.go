@@ -0,0 +1,78 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+func TestApplyContentChanges(t *testing.T) {
+	rng := func(startLine, startChar, endLine, endChar float64) *protocol.Range {
+		return &protocol.Range{
+			Start: protocol.Position{Line: startLine, Character: startChar},
+			End:   protocol.Position{Line: endLine, Character: endChar},
+		}
+	}
+	for _, test := range []struct {
+		name    string
+		content string
+		changes []protocol.TextDocumentContentChangeEvent
+		want    string
+	}{
+		{
+			name:    "full",
+			content: "package a\n",
+			changes: []protocol.TextDocumentContentChangeEvent{{Text: "package b\n"}},
+			want:    "package b\n",
+		},
+		{
+			name:    "insert",
+			content: "package a\n\nfunc f() {}\n",
+			changes: []protocol.TextDocumentContentChangeEvent{{Range: rng(2, 7, 2, 7), Text: "x int"}},
+			want:    "package a\n\nfunc f(x int) {}\n",
+		},
+		{
+			name:    "delete across lines",
+			content: "package a\n\nvar x = 1\nvar y = 2\n",
+			changes: []protocol.TextDocumentContentChangeEvent{{Range: rng(2, 4, 3, 4), Text: ""}},
+			want:    "package a\n\nvar y = 2\n",
+		},
+		{
+			name:    "sequence",
+			content: "package a\n",
+			changes: []protocol.TextDocumentContentChangeEvent{
+				{Range: rng(1, 0, 1, 0), Text: "\nvar x int\n"},
+				{Range: rng(2, 4, 2, 5), Text: "yy"},
+			},
+			want: "package a\n\nvar yy int\n",
+		},
+		{
+			name:    "past end of line",
+			content: "package a\nvar x int\n",
+			changes: []protocol.TextDocumentContentChangeEvent{{Range: rng(0, 100, 0, 100), Text: " // a"}},
+			want:    "package a // a\nvar x int\n",
+		},
+		{
+			name:    "utf16 columns",
+			content: "package a\n\nvar s = \"é😀x\"\n",
+			changes: []protocol.TextDocumentContentChangeEvent{{Range: rng(2, 10, 2, 12), Text: "y"}},
+			want:    "package a\n\nvar s = \"éyx\"\n",
+		},
+	} {
+		got, err := applyContentChanges([]byte(test.content), test.changes)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+	if _, err := applyContentChanges([]byte("package a\n"), []protocol.TextDocumentContentChangeEvent{{Range: rng(5, 0, 5, 0)}}); err == nil {
+		t.Errorf("expected an error for a change past the end of the content")
+	}
+}
@@ -10,15 +10,17 @@ import (
 )
 
 func toProtocolSignatureHelp(info *source.SignatureInformation) *protocol.SignatureHelp {
+	sig := protocol.SignatureInformation{
+		Label:      info.Label,
+		Parameters: toProtocolParameterInformation(info.Parameters),
+	}
+	if info.Documentation != "" {
+		sig.Documentation = info.Documentation
+	}
 	return &protocol.SignatureHelp{
 		ActiveParameter: float64(info.ActiveParameter),
 		ActiveSignature: 0, // there is only ever one possible signature
-		Signatures: []protocol.SignatureInformation{
-			{
-				Label:      info.Label,
-				Parameters: toProtocolParameterInformation(info.Parameters),
-			},
-		},
+		Signatures:      []protocol.SignatureInformation{sig},
 	}
 }
 
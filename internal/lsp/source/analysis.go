@@ -0,0 +1,224 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/types"
+	"reflect"
+	"sort"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/assign"
+	"golang.org/x/tools/go/analysis/passes/atomic"
+	"golang.org/x/tools/go/analysis/passes/bools"
+	"golang.org/x/tools/go/analysis/passes/composite"
+	"golang.org/x/tools/go/analysis/passes/copylock"
+	"golang.org/x/tools/go/analysis/passes/httpresponse"
+	"golang.org/x/tools/go/analysis/passes/loopclosure"
+	"golang.org/x/tools/go/analysis/passes/lostcancel"
+	"golang.org/x/tools/go/analysis/passes/missingdoc"
+	"golang.org/x/tools/go/analysis/passes/nilfunc"
+	"golang.org/x/tools/go/analysis/passes/nilness"
+	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/analysis/passes/shadow"
+	"golang.org/x/tools/go/analysis/passes/shift"
+	"golang.org/x/tools/go/analysis/passes/simplifycompositelit"
+	"golang.org/x/tools/go/analysis/passes/simplifyrange"
+	"golang.org/x/tools/go/analysis/passes/simplifyslice"
+	"golang.org/x/tools/go/analysis/passes/stdmethods"
+	"golang.org/x/tools/go/analysis/passes/structtag"
+	"golang.org/x/tools/go/analysis/passes/tests"
+	"golang.org/x/tools/go/analysis/passes/unmarshal"
+	"golang.org/x/tools/go/analysis/passes/unreachable"
+	"golang.org/x/tools/go/analysis/passes/unsafeptr"
+	"golang.org/x/tools/go/analysis/passes/unusedparams"
+	"golang.org/x/tools/go/analysis/passes/unusedresult"
+	"golang.org/x/tools/go/analysis/passes/unusedwrite"
+	"golang.org/x/tools/go/packages"
+)
+
+// analyzers are the analyzers that report diagnostics, with whether they
+// are enabled unless DiagnosticsOptions.Analyses names them, and the severity of their
+// diagnostics unless DiagnosticsOptions.AnalysisSeverities names them. They are those
+// of go vet that inspect Go syntax, whose findings are warnings, and a few
+// more: those that report too many false positives, or matters of style, to
+// be enabled by default, and those whose findings are mere hints at unneeded
+// code, such as the simplifications of gofmt -s.
+var analyzers = []struct {
+	*analysis.Analyzer
+	enabled  bool
+	severity DiagnosticSeverity
+}{
+	{assign.Analyzer, true, SeverityWarning},
+	{atomic.Analyzer, true, SeverityWarning},
+	{bools.Analyzer, true, SeverityWarning},
+	{composite.Analyzer, true, SeverityWarning},
+	{copylock.Analyzer, true, SeverityWarning},
+	{httpresponse.Analyzer, true, SeverityWarning},
+	{loopclosure.Analyzer, true, SeverityWarning},
+	{lostcancel.Analyzer, true, SeverityWarning},
+	{nilfunc.Analyzer, true, SeverityWarning},
+	{printf.Analyzer, true, SeverityWarning},
+	{shift.Analyzer, true, SeverityWarning},
+	{stdmethods.Analyzer, true, SeverityWarning},
+	{structtag.Analyzer, true, SeverityWarning},
+	{tests.Analyzer, true, SeverityWarning},
+	{unmarshal.Analyzer, true, SeverityWarning},
+	{unreachable.Analyzer, true, SeverityWarning},
+	{unsafeptr.Analyzer, true, SeverityWarning},
+	{unusedresult.Analyzer, true, SeverityWarning},
+	{nilness.Analyzer, false, SeverityWarning},
+	{shadow.Analyzer, false, SeverityWarning},
+	{missingdoc.Analyzer, false, SeverityInformation},
+	{unusedparams.Analyzer, true, SeverityHint},
+	{unusedwrite.Analyzer, true, SeverityHint},
+	{simplifycompositelit.Analyzer, true, SeverityHint},
+	{simplifyrange.Analyzer, true, SeverityHint},
+	{simplifyslice.Analyzer, true, SeverityHint},
+}
+
+// analyzerSeverity returns the severity of the diagnostics of the analyzer
+// named name, unless severities, the AnalysisSeverities of the options,
+// overrides it.
+func analyzerSeverity(name string, severities map[string]DiagnosticSeverity) DiagnosticSeverity {
+	if severity, ok := severities[name]; ok {
+		return severity
+	}
+	for _, a := range analyzers {
+		if a.Name == name {
+			return a.severity
+		}
+	}
+	return SeverityWarning
+}
+
+// enabledAnalyzers returns the analyzers that enabled, the Analyses of the
+// options, enables, or that are enabled by default if it does not name them.
+func enabledAnalyzers(enabled map[string]bool) []*analysis.Analyzer {
+	var result []*analysis.Analyzer
+	for _, a := range analyzers {
+		on, ok := enabled[a.Name]
+		if !ok {
+			on = a.enabled
+		}
+		if on {
+			result = append(result, a.Analyzer)
+		}
+	}
+	return result
+}
+
+// An analysisDiagnostic is a diagnostic that an analyzer reported.
+type analysisDiagnostic struct {
+	analysis.Diagnostic
+	analyzer *analysis.Analyzer
+}
+
+// analyze runs the analyzers, and those they require, over pkg, which is
+// type-checked already, and returns the diagnostics they report, in the
+// order of their positions.
+// The facts of the analyzers are only shared within the package, as its
+// dependencies are type-checked without their function bodies, which the
+// analyzers would need to export theirs.
+func analyze(ctx context.Context, pkg *packages.Package, analyzers []*analysis.Analyzer) ([]analysisDiagnostic, error) {
+	r := &analysisRunner{
+		pkg:     pkg,
+		results: make(map[*analysis.Analyzer]*analysisResult),
+	}
+	for _, a := range analyzers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r.run(a)
+	}
+	sort.SliceStable(r.diagnostics, func(i, j int) bool {
+		return r.diagnostics[i].Pos < r.diagnostics[j].Pos
+	})
+	return r.diagnostics, nil
+}
+
+// An analysisRunner runs analyzers over a package, each once.
+type analysisRunner struct {
+	pkg         *packages.Package
+	results     map[*analysis.Analyzer]*analysisResult
+	diagnostics []analysisDiagnostic
+}
+
+// An analysisResult is the result of running an analyzer over a package.
+type analysisResult struct {
+	value interface{}
+	err   error
+}
+
+// factKey identifies a fact of an object, or of the package if obj is nil.
+type factKey struct {
+	obj types.Object
+	typ reflect.Type
+}
+
+// run runs a over the package, after the analyzers it requires, unless it
+// ran already, and returns its result.
+func (r *analysisRunner) run(a *analysis.Analyzer) *analysisResult {
+	if result, ok := r.results[a]; ok {
+		return result
+	}
+	result := new(analysisResult)
+	r.results[a] = result
+	inputs := make(map[*analysis.Analyzer]interface{})
+	for _, req := range a.Requires {
+		reqResult := r.run(req)
+		if reqResult.err != nil {
+			result.err = fmt.Errorf("failed prerequisite %s: %v", req.Name, reqResult.err)
+			return result
+		}
+		inputs[req] = reqResult.value
+	}
+	if r.pkg.IllTyped && !a.RunDespiteErrors {
+		result.err = fmt.Errorf("analysis skipped due to errors in package")
+		return result
+	}
+	facts := make(map[factKey]analysis.Fact)
+	importFact := func(key factKey, ptr analysis.Fact) bool {
+		fact, ok := facts[key]
+		if ok {
+			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(fact).Elem())
+		}
+		return ok
+	}
+	pass := &analysis.Pass{
+		Analyzer:   a,
+		Fset:       r.pkg.Fset,
+		Files:      r.pkg.Syntax,
+		OtherFiles: r.pkg.OtherFiles,
+		Pkg:        r.pkg.Types,
+		TypesInfo:  r.pkg.TypesInfo,
+		ResultOf:   inputs,
+		Report: func(d analysis.Diagnostic) {
+			r.diagnostics = append(r.diagnostics, analysisDiagnostic{d, a})
+		},
+		ImportObjectFact: func(obj types.Object, ptr analysis.Fact) bool {
+			return obj != nil && importFact(factKey{obj, reflect.TypeOf(ptr)}, ptr)
+		},
+		ExportObjectFact: func(obj types.Object, fact analysis.Fact) {
+			facts[factKey{obj, reflect.TypeOf(fact)}] = fact
+		},
+		ImportPackageFact: func(pkg *types.Package, ptr analysis.Fact) bool {
+			return pkg == r.pkg.Types && importFact(factKey{nil, reflect.TypeOf(ptr)}, ptr)
+		},
+		ExportPackageFact: func(fact analysis.Fact) {
+			facts[factKey{nil, reflect.TypeOf(fact)}] = fact
+		},
+	}
+	// A failing analyzer must not bring the server down.
+	defer func() {
+		if p := recover(); p != nil {
+			result.err = fmt.Errorf("analyzer %s panicked: %v", a.Name, p)
+		}
+	}()
+	result.value, result.err = a.Run(pass)
+	return result
+}
@@ -0,0 +1,301 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A Cache holds the state that the sessions of a server share: the syntax
+// trees of the files, and the type-checked packages. A package that a view
+// of one session type-checked is used by the views of the other sessions
+// that load it with the same configuration, if its files have the same
+// contents in their sessions.
+type Cache struct {
+	fset   *token.FileSet
+	parsed parseCache
+
+	mu       sync.Mutex
+	packages map[packageKey]*cachedPackage
+}
+
+// A packageKey identifies a cached package of a Cache: the package of a file
+// loaded with a configuration.
+type packageKey struct {
+	config   string // as returned by configKey
+	filename string
+}
+
+// NewCache returns an empty cache.
+func NewCache() *Cache {
+	return &Cache{
+		fset:     token.NewFileSet(),
+		packages: make(map[packageKey]*cachedPackage),
+	}
+}
+
+// NewSession returns a session without views or open documents, that shares
+// the cache with the other sessions of the cache.
+func (c *Cache) NewSession() *Session {
+	return &Session{
+		cache:    c,
+		overlays: make(map[URI][]byte),
+	}
+}
+
+// configKey returns a string that identifies the configuration with which
+// the packages are loaded, in so far as it affects the result.
+func configKey(cfg *packages.Config) string {
+	return fmt.Sprintf("%d %t %s %q %q", cfg.Mode, cfg.Tests, cfg.Dir, cfg.BuildFlags, cfg.Env)
+}
+
+// addPackage adds a package loaded with the configuration of the given key
+// to the cache, for each of its files.
+func (c *Cache) addPackage(config string, cp *cachedPackage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, filename := range cp.pkg.GoFiles {
+		c.packages[packageKey{config, filename}] = cp
+	}
+}
+
+// cachedPackage returns the package of the file named filename loaded with
+// the configuration of the given key, if the cache has it. Its files may
+// have changed since.
+func (c *Cache) cachedPackage(config, filename string) (*cachedPackage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cp, ok := c.packages[packageKey{config, filename}]
+	return cp, ok
+}
+
+// forgetPackage discards a package from the cache.
+func (c *Cache) forgetPackage(pkg *packages.Package) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, cp := range c.packages {
+		if cp.pkg == pkg {
+			delete(c.packages, key)
+		}
+	}
+}
+
+// forgetNewFile discards the packages of the directory of the file named
+// filename that do not have it, as it was created since they were loaded.
+func (c *Cache) forgetNewFile(filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dir := filepath.Dir(filename)
+	for key, cp := range c.packages {
+		if _, ok := cp.hashes[filename]; ok || len(cp.pkg.GoFiles) == 0 || filepath.Dir(cp.pkg.GoFiles[0]) != dir {
+			continue
+		}
+		delete(c.packages, key)
+	}
+}
+
+// forgetPackages discards all the packages of the cache.
+func (c *Cache) forgetPackages() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.packages = make(map[packageKey]*cachedPackage)
+}
+
+// A parseCache holds the syntax trees of the files that the views of a cache
+// parsed, so that each version of a file is parsed once, however many loads,
+// packages and views include it.
+type parseCache struct {
+	mu    sync.Mutex
+	files map[string]*parsedFile // by filename
+}
+
+// A parsedFile is the result of parsing a version of a file.
+type parsedFile struct {
+	hash [sha256.Size]byte // the hash of the content of the file
+	file *ast.File
+	err  error
+}
+
+// parseFile parses a file like the default ParseFile of packages.Config,
+// unless it has parsed the same content before.
+// It is safe to call from several goroutines, as packages.Load does.
+func (c *parseCache) parseFile(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+	hash := sha256.Sum256(src)
+	c.mu.Lock()
+	p, ok := c.files[filename]
+	c.mu.Unlock()
+	if ok && p.hash == hash {
+		return p.file, p.err
+	}
+	const mode = parser.AllErrors | parser.ParseComments
+	file, err := parser.ParseFile(fset, filename, src, mode)
+	if file != nil && !file.Package.IsValid() {
+		file = packageClauseFile(fset, filename, src)
+	}
+	c.mu.Lock()
+	if c.files == nil {
+		c.files = make(map[string]*parsedFile)
+	}
+	c.files[filename] = &parsedFile{hash: hash, file: file, err: err}
+	c.mu.Unlock()
+	return file, err
+}
+
+// packageClauseFile returns the syntax tree of a file whose package clause
+// could not be parsed, such as a new file whose package is not named yet.
+// The parser gives up on such a file, without positions. The tree has the
+// position of the package clause, if any, and the name of the package is
+// empty.
+func packageClauseFile(fset *token.FileSet, filename string, src []byte) *ast.File {
+	tok := fset.AddFile(filename, -1, len(src))
+	var s scanner.Scanner
+	s.Init(tok, src, nil, scanner.ScanComments)
+	file := &ast.File{Package: tok.Pos(0), Name: &ast.Ident{}}
+	for {
+		pos, t, _ := s.Scan()
+		if t == token.COMMENT {
+			continue
+		}
+		if t == token.PACKAGE {
+			file.Package = pos
+			file.Name.NamePos = pos + token.Pos(len("package"))
+		}
+		break
+	}
+	tok.SetLinesForContent(src)
+	return file
+}
+
+// forget discards the syntax trees of the files with the given names.
+func (c *parseCache) forget(filenames []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, filename := range filenames {
+		delete(c.files, filename)
+	}
+}
+
+// A cachedPackage is a type-checked package, with the hashes of the
+// contents of the files it was type-checked from: its own files and those
+// of the packages it depends on, except the standard library.
+// It is valid as long as none of these contents change, so a change of a
+// file invalidates only the packages that depend on it.
+type cachedPackage struct {
+	pkg    *packages.Package
+	hashes map[string][sha256.Size]byte // by filename
+
+	size     int64  // the estimated memory that the package uses
+	lastUsed uint64 // the value of the use counter of the view when it was last used
+}
+
+// cachePackage caches a package that was just loaded with the configuration
+// of the given key, for each of its files, in the view and in the cache of
+// its session.
+func (v *View) cachePackage(config string, pkg *packages.Package) {
+	v.uses++
+	c := &cachedPackage{
+		pkg:      pkg,
+		hashes:   make(map[string][sha256.Size]byte),
+		size:     packageSize(pkg),
+		lastUsed: v.uses,
+	}
+	seen := make(map[*packages.Package]bool)
+	var addFiles func(p *packages.Package)
+	addFiles = func(p *packages.Package) {
+		if seen[p] {
+			return
+		}
+		seen[p] = true
+		for _, filename := range p.GoFiles {
+			if inGoroot(filename) {
+				return
+			}
+			if hash, ok := v.fileHash(filename); ok {
+				c.hashes[filename] = hash
+			}
+		}
+		for _, imp := range p.Imports {
+			addFiles(imp)
+		}
+	}
+	addFiles(pkg)
+	for _, filename := range pkg.GoFiles {
+		v.pkgCache[filename] = c
+	}
+	v.session.cache.addPackage(config, c)
+}
+
+// cachedPackage returns the cached package that contains the file named
+// filename, if its files are unchanged since it was type-checked. A package
+// that the view does not have is looked up in the cache of its session, for
+// the configuration of the given key.
+func (v *View) cachedPackage(config, filename string) (*packages.Package, bool) {
+	c, ok := v.pkgCache[filename]
+	shared := false
+	if !ok {
+		if c, ok = v.session.cache.cachedPackage(config, filename); !ok {
+			return nil, false
+		}
+		shared = true
+	}
+	for filename, hash := range c.hashes {
+		if h, ok := v.fileHash(filename); !ok || h != hash {
+			return nil, false
+		}
+	}
+	if shared {
+		// The use of the package is counted by each view apart.
+		copy := *c
+		c = &copy
+		for _, filename := range c.pkg.GoFiles {
+			v.pkgCache[filename] = c
+		}
+	}
+	v.uses++
+	c.lastUsed = v.uses
+	return c.pkg, true
+}
+
+// fileHash returns the hash of the current content of the file named
+// filename, as it is open in the editor or on disk.
+func (v *View) fileHash(filename string) ([sha256.Size]byte, bool) {
+	content, ok := v.fileContent(filename)
+	if !ok {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256(content), true
+}
+
+// fileContent returns the current content of the file named filename, as it
+// is open in the editor or on disk.
+func (v *View) fileContent(filename string) ([]byte, bool) {
+	content, ok := v.session.overlay(ToURI(filename))
+	if !ok {
+		var err error
+		if content, err = ioutil.ReadFile(filename); err != nil {
+			return nil, false
+		}
+	}
+	return content, true
+}
+
+// inGoroot reports whether the file named filename is in the standard
+// library, whose files are not expected to change.
+func inGoroot(filename string) bool {
+	goroot := filepath.Clean(runtime.GOROOT())
+	return goroot != "." && strings.HasPrefix(filename, goroot+string(filepath.Separator))
+}
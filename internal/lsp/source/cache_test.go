@@ -0,0 +1,93 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/packages/packagestest"
+)
+
+// cacheTestFiles are the files of the module of the tests of the cache:
+// b imports a, and c is independent of both.
+var cacheTestFiles = map[string]interface{}{
+	"a/a.go": "package a\n\nconst A = 1\n",
+	"b/b.go": "package b\n\nimport \"golang.org/fake/a\"\n\nconst B = a.A\n",
+	"c/c.go": "package c\n\nconst C = 1\n",
+}
+
+// testExport exports the files of a module in GOPATH mode.
+func testExport(t *testing.T, files map[string]interface{}) *packagestest.Exported {
+	return packagestest.Export(t, packagestest.GOPATH, []packagestest.Module{{
+		Name:  "golang.org/fake",
+		Files: files,
+	}})
+}
+
+// testView returns a view of the exported files, in a session of its own.
+func testView(exported *packagestest.Exported) *View {
+	v := NewView()
+	cfg := *exported.Config
+	cfg.Fset = v.Config.Fset
+	cfg.Mode = packages.LoadSyntax
+	v.Config = &cfg
+	return v
+}
+
+// testPackage returns the package of the file of the module with the given
+// name.
+func testPackage(t *testing.T, v *View, exported *packagestest.Exported, name string) *packages.Package {
+	t.Helper()
+	pkg, err := v.GetFile(ToURI(exported.File("golang.org/fake", name))).GetPackage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	exported := testExport(t, cacheTestFiles)
+	defer exported.Cleanup()
+	v := testView(exported)
+	a := testPackage(t, v, exported, "a/a.go")
+	b := testPackage(t, v, exported, "b/b.go")
+	c := testPackage(t, v, exported, "c/c.go")
+
+	// Loading the packages again uses the cache.
+	if got := testPackage(t, v, exported, "b/b.go"); got != b {
+		t.Errorf("b was type-checked again, while no file changed")
+	}
+
+	// Editing a invalidates a and b, which imports it, but not c.
+	v.GetFile(ToURI(exported.File("golang.org/fake", "a/a.go"))).SetContent([]byte("package a\n\nconst A, A2 = 1, 2\n"))
+	if got := testPackage(t, v, exported, "a/a.go"); got == a {
+		t.Errorf("a was not type-checked again after it changed")
+	}
+	newB := testPackage(t, v, exported, "b/b.go")
+	if newB == b {
+		t.Errorf("b was not type-checked again after a, which it imports, changed")
+	} else if newB.Imports["golang.org/fake/a"].Types.Scope().Lookup("A2") == nil {
+		t.Errorf("b was type-checked against the previous content of a")
+	}
+	if got := testPackage(t, v, exported, "c/c.go"); got != c {
+		t.Errorf("c was type-checked again after a, which it does not import, changed")
+	}
+	filename := exported.File("golang.org/fake", "c/c.go")
+	if _, ok := v.cachedPackage(configKey(v.configFor(filename)), filename); !ok {
+		t.Errorf("the cached package of c was discarded after a changed")
+	}
+
+	// Editing b invalidates only b.
+	a = testPackage(t, v, exported, "a/a.go")
+	v.GetFile(ToURI(exported.File("golang.org/fake", "b/b.go"))).SetContent([]byte("package b\n\nimport \"golang.org/fake/a\"\n\nconst B = a.A2\n"))
+	if got := testPackage(t, v, exported, "b/b.go"); got == newB {
+		t.Errorf("b was not type-checked again after it changed")
+	}
+	if got := testPackage(t, v, exported, "a/a.go"); got != a {
+		t.Errorf("a was type-checked again after b, which imports it, changed")
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// CallHierarchyItem is a function or method in a call hierarchy.
+type CallHierarchyItem struct {
+	Name           string
+	Detail         string // the package path, qualified by the receiver type for methods
+	Kind           SymbolKind
+	Range          Range // the whole declaration
+	SelectionRange Range // the name of the function
+}
+
+// Call is a function of a call hierarchy together with the calls that relate
+// it to the function the hierarchy was requested for.
+type Call struct {
+	Item CallHierarchyItem
+	// Ranges are the ranges of the calls. For incoming calls, they are in
+	// the caller; for outgoing calls, in the function the hierarchy was
+	// requested for.
+	Ranges []Range
+}
+
+// PrepareCallHierarchy returns the function or method declared or called by
+// the identifier at pos.
+func PrepareCallHierarchy(ctx context.Context, v *View, f *File, pos token.Pos) (*CallHierarchyItem, error) {
+	fn, err := funcAt(ctx, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	item := v.callHierarchyItem(fn)
+	return &item, nil
+}
+
+// IncomingCalls returns the functions that call the function at pos, with
+// the calls they make to it, across all the packages loaded in the view.
+// Calls from function literals are attributed to the enclosing function.
+func IncomingCalls(ctx context.Context, v *View, f *File, pos token.Pos) ([]Call, error) {
+	fn, err := funcAt(ctx, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	key := v.keyOf(fn)
+	calls := make(map[objKey]*Call)
+	seen := make(map[token.Position]bool)
+	for _, pkg := range v.packages() {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		for _, id := range v.index(pkg).refs[key] {
+			// The same file may be type-checked as part of several packages,
+			// so deduplicate by position.
+			posn := v.Config.Fset.Position(id.Pos())
+			if seen[posn] {
+				continue
+			}
+			seen[posn] = true
+			file := syntaxFile(pkg, id.Pos())
+			if file == nil {
+				continue
+			}
+			path, _ := astutil.PathEnclosingInterval(file, id.Pos(), id.End())
+			call := enclosingCall(path)
+			if call == nil {
+				continue
+			}
+			var caller *types.Func
+			for _, n := range path {
+				if decl, ok := n.(*ast.FuncDecl); ok {
+					caller, _ = pkg.TypesInfo.Defs[decl.Name].(*types.Func)
+					break
+				}
+			}
+			if caller == nil {
+				continue // a call in a package-level declaration
+			}
+			callerKey := v.keyOf(caller)
+			c, ok := calls[callerKey]
+			if !ok {
+				c = &Call{Item: v.callHierarchyItem(caller)}
+				calls[callerKey] = c
+			}
+			c.Ranges = append(c.Ranges, Range{Start: id.Pos(), End: id.End()})
+		}
+	}
+	return sortedCalls(v, calls), nil
+}
+
+// OutgoingCalls returns the functions that the function at pos calls, with
+// the calls it makes to them.
+// Only static calls of declared functions and methods are reported, which
+// excludes the methods of the universe, such as the Error method of error.
+func OutgoingCalls(ctx context.Context, v *View, f *File, pos token.Pos) ([]Call, error) {
+	fn, err := funcAt(ctx, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	decl, pkg := v.funcDecl(fn)
+	if decl == nil || decl.Body == nil {
+		return nil, nil
+	}
+	calls := make(map[objKey]*Call)
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var id *ast.Ident
+		switch fun := astutil.Unparen(call.Fun).(type) {
+		case *ast.Ident:
+			id = fun
+		case *ast.SelectorExpr:
+			id = fun.Sel
+		default:
+			return true
+		}
+		callee, ok := pkg.TypesInfo.Uses[id].(*types.Func)
+		if !ok || callee.Pkg() == nil {
+			return true
+		}
+		key := v.keyOf(callee)
+		c, ok := calls[key]
+		if !ok {
+			c = &Call{Item: v.callHierarchyItem(callee)}
+			calls[key] = c
+		}
+		c.Ranges = append(c.Ranges, Range{Start: id.Pos(), End: id.End()})
+		return true
+	})
+	return sortedCalls(v, calls), nil
+}
+
+// funcAt returns the function or method denoted by the identifier at pos.
+func funcAt(ctx context.Context, f *File, pos token.Pos) (*types.Func, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i, err := findIdentifier(fAST, pos)
+	if err != nil {
+		return nil, err
+	}
+	if i.ident == nil {
+		return nil, fmt.Errorf("call hierarchy was not a valid identifier")
+	}
+	fn, ok := pkg.TypesInfo.ObjectOf(i.ident).(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return nil, fmt.Errorf("%s is not a function", i.ident.Name)
+	}
+	return fn, nil
+}
+
+// callHierarchyItem returns the item for fn. If the declaration of fn is
+// not available, its range is the range of its name.
+func (v *View) callHierarchyItem(fn *types.Func) CallHierarchyItem {
+	name := Range{Start: fn.Pos(), End: fn.Pos() + token.Pos(len(fn.Name()))}
+	item := CallHierarchyItem{
+		Name:           fn.Name(),
+		Kind:           FunctionSymbol,
+		Range:          name,
+		SelectionRange: name,
+	}
+	if fn.Pkg() != nil {
+		item.Detail = fn.Pkg().Path()
+	}
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		item.Kind = MethodSymbol
+		if named, ok := deref(recv.Type()).(*types.Named); ok {
+			item.Detail += "." + named.Obj().Name()
+		}
+	}
+	if decl, _ := v.funcDecl(fn); decl != nil {
+		item.Range = Range{Start: decl.Pos(), End: decl.End()}
+	}
+	return item
+}
+
+// funcDecl returns the declaration of fn and the package that declares it, if
+// its syntax is available, which it never is for the methods of the universe.
+func (v *View) funcDecl(fn *types.Func) (*ast.FuncDecl, *packages.Package) {
+	if fn.Pkg() == nil {
+		return nil, nil
+	}
+	for _, pkg := range v.packages() {
+		if pkg.Types == nil || pkg.Types.Path() != fn.Pkg().Path() {
+			continue
+		}
+		file := syntaxFile(pkg, fn.Pos())
+		if file == nil {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(file, fn.Pos(), fn.Pos())
+		for _, n := range path {
+			if decl, ok := n.(*ast.FuncDecl); ok && decl.Name.Pos() == fn.Pos() {
+				return decl, pkg
+			}
+		}
+	}
+	return nil, nil
+}
+
+// syntaxFile returns the file of pkg that contains pos.
+func syntaxFile(pkg *packages.Package, pos token.Pos) *ast.File {
+	tok := pkg.Fset.File(pos)
+	for _, file := range pkg.Syntax {
+		if pkg.Fset.File(file.Pos()) == tok {
+			return file
+		}
+	}
+	return nil
+}
+
+// enclosingCall returns the call whose function is the identifier path[0],
+// possibly as the selector of a qualified identifier or method value.
+func enclosingCall(path []ast.Node) *ast.CallExpr {
+	if len(path) < 2 {
+		return nil
+	}
+	fun := path[0]
+	i := 1
+	if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == path[0] {
+		fun = sel
+		i++
+	}
+	for ; i < len(path); i++ {
+		if _, ok := path[i].(*ast.ParenExpr); !ok {
+			break
+		}
+	}
+	if i >= len(path) {
+		return nil
+	}
+	call, ok := path[i].(*ast.CallExpr)
+	if !ok || astutil.Unparen(call.Fun) != fun {
+		return nil
+	}
+	return call
+}
+
+func sortedCalls(v *View, calls map[objKey]*Call) []Call {
+	result := make([]Call, 0, len(calls))
+	for _, c := range calls {
+		result = append(result, *c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		pi := v.Config.Fset.Position(result[i].Item.SelectionRange.Start)
+		pj := v.Config.Fset.Position(result[j].Item.SelectionRange.Start)
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	return result
+}
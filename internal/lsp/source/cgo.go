@@ -0,0 +1,104 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// cgoFiles returns the names of the Go files of pkg that cgo processes,
+// which are compiled as the files that cgo generates from them instead.
+func cgoFiles(pkg *packages.Package) []string {
+	compiled := make(map[string]bool)
+	for _, filename := range pkg.CompiledGoFiles {
+		compiled[filename] = true
+	}
+	var filenames []string
+	for _, filename := range pkg.GoFiles {
+		if !compiled[filename] {
+			filenames = append(filenames, filename)
+		}
+	}
+	return filenames
+}
+
+// addCgoInfo adds the type information of the identifiers of file, a file
+// of pkg that cgo processes, to that of pkg: the types and objects of the
+// identifiers at the same positions in the files that cgo generates from
+// file, as their line directives record them. A reference to C, such as
+// C.f, has those of the name that cgo substitutes for it, such as
+// _Cfunc_f.
+func addCgoInfo(fset *token.FileSet, pkg *packages.Package, file *ast.File) {
+	filename := fset.Position(file.Pos()).Filename
+	type lineCol struct{ line, col int }
+	at := func(pos token.Pos) lineCol {
+		posn := fset.Position(pos)
+		return lineCol{posn.Line, posn.Column}
+	}
+	generated := make(map[lineCol]*ast.Ident)
+	selectors := make(map[*ast.Ident]*ast.SelectorExpr)
+	for _, f := range pkg.Syntax {
+		if fset.Position(f.Package).Filename != filename {
+			continue
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Ident:
+				if fset.Position(n.Pos()).Filename == filename {
+					generated[at(n.Pos())] = n
+				}
+			case *ast.SelectorExpr:
+				selectors[n.Sel] = n
+			}
+			return true
+		})
+	}
+	if len(generated) == 0 {
+		return
+	}
+	info := pkg.TypesInfo
+	add := func(id, g *ast.Ident) {
+		if obj, ok := info.Defs[g]; ok {
+			info.Defs[id] = obj
+		}
+		if obj, ok := info.Uses[g]; ok {
+			info.Uses[id] = obj
+		}
+		if tv, ok := info.Types[g]; ok {
+			info.Types[id] = tv
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok && x.Name == "C" {
+				// The name that cgo substitutes is at the position of C.
+				if g := generated[at(x.Pos())]; g != nil {
+					add(n.Sel, g)
+					if tv, ok := info.Types[g]; ok {
+						info.Types[n] = tv
+					}
+				}
+				return false
+			}
+			if g := generated[at(n.Sel.Pos())]; g != nil && selectors[g] != nil {
+				if sel, ok := info.Selections[selectors[g]]; ok {
+					info.Selections[n] = sel
+				}
+				if tv, ok := info.Types[selectors[g]]; ok {
+					info.Types[n] = tv
+				}
+			}
+		case *ast.Ident:
+			if g := generated[at(n.Pos())]; g != nil && g.Name == n.Name {
+				add(n, g)
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,254 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/lsp/telemetry"
+)
+
+// load loads the packages that match the patterns, and type checks them and
+// their dependencies from source.
+// The packages are listed with packages.LoadImports, and type-checked by
+// the view itself, in dependency order, so that up to the
+// TypeCheckParallelism of its options packages that do not depend on each
+// other are type-checked at the same time. The function bodies of the
+// dependencies are only type-checked in the packages.LoadAllSyntax mode.
+// If report is not nil, it is called after each package is type-checked,
+// with the number of packages type-checked so far and their total number.
+// When ctx is cancelled, the packages that are not type-checked yet are
+// skipped and load returns the error of ctx.
+func (v *View) load(ctx context.Context, cfg *packages.Config, report func(checked, total int), patterns ...string) ([]*packages.Package, error) {
+	ctx, span := telemetry.StartSpan(ctx, "source.load")
+	defer span.Finish()
+	span.SetAttribute("patterns", strings.Join(patterns, " "))
+	mode := cfg.Mode
+	cfg.Mode = packages.LoadImports
+	cfg.Context = ctx
+	roots, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	isRoot := make(map[*packages.Package]bool)
+	for _, pkg := range roots {
+		isRoot[pkg] = true
+	}
+	total := 0
+	packages.Visit(roots, nil, func(*packages.Package) { total++ })
+	span.SetAttribute("packages", total)
+	parallelism := v.options.TypeCheckParallelism
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	c := &checker{
+		ctx:     ctx,
+		cfg:     cfg,
+		sizes:   types.SizesFor("gc", goarch(cfg.Env)),
+		limit:   make(chan struct{}, parallelism),
+		done:    make(map[*packages.Package]chan struct{}),
+		onStack: make(map[*packages.Package]bool),
+		parsed:  v.parsed,
+		allBody: mode >= packages.LoadAllSyntax,
+		isRoot:  isRoot,
+		report:  report,
+		total:   total,
+	}
+	for _, pkg := range roots {
+		c.start(pkg)
+	}
+	c.wg.Wait()
+	if err := ctx.Err(); err != nil {
+		// The packages may be partially type-checked.
+		span.SetError(err)
+		return nil, err
+	}
+	return roots, nil
+}
+
+// A checker type-checks a graph of packages, each as soon as its
+// dependencies are.
+type checker struct {
+	ctx     context.Context
+	cfg     *packages.Config
+	sizes   types.Sizes
+	limit   chan struct{} // a counting semaphore of the type-checks in progress
+	parsed  *parseCache
+	allBody bool // type-check the function bodies of the dependencies
+	isRoot  map[*packages.Package]bool
+	report  func(checked, total int)
+	total   int // the number of packages to type-check
+
+	// onStack holds the packages whose dependencies start is starting, to
+	// break import cycles, which go list reports as errors.
+	onStack map[*packages.Package]bool
+
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	done    map[*packages.Package]chan struct{} // closed when the package is type-checked
+	checked int                                 // the number of packages type-checked so far
+}
+
+// start starts the type-checking of pkg and of its dependencies, if it is
+// not started already, and returns the channel that is closed when pkg is
+// type-checked. It is called by a single goroutine.
+func (c *checker) start(pkg *packages.Package) chan struct{} {
+	c.mu.Lock()
+	done, ok := c.done[pkg]
+	if !ok {
+		done = make(chan struct{})
+		c.done[pkg] = done
+	}
+	c.mu.Unlock()
+	if ok {
+		return done
+	}
+	var deps []chan struct{}
+	c.onStack[pkg] = true
+	for _, imp := range pkg.Imports {
+		if !c.onStack[imp] {
+			deps = append(deps, c.start(imp))
+		}
+	}
+	delete(c.onStack, pkg)
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer close(done)
+		for _, dep := range deps {
+			<-dep
+		}
+		select {
+		case c.limit <- struct{}{}:
+		case <-c.ctx.Done():
+			return
+		}
+		defer func() { <-c.limit }()
+		if c.ctx.Err() != nil {
+			return
+		}
+		c.check(pkg)
+		c.mu.Lock()
+		c.checked++
+		if c.report != nil {
+			c.report(c.checked, c.total)
+		}
+		c.mu.Unlock()
+	}()
+	return done
+}
+
+// check type-checks pkg, whose dependencies are type-checked already.
+func (c *checker) check(pkg *packages.Package) {
+	_, span := telemetry.StartSpan(c.ctx, "source.typeCheck")
+	defer span.Finish()
+	span.SetAttribute("package", pkg.PkgPath)
+	pkg.Fset = c.cfg.Fset
+	if pkg.PkgPath == "unsafe" {
+		pkg.Types = types.Unsafe
+		pkg.Syntax = []*ast.File{}
+		pkg.TypesInfo = new(types.Info)
+		return
+	}
+	pkg.Types = types.NewPackage(pkg.PkgPath, pkg.Name)
+	appendError := func(err error) {
+		switch err := err.(type) {
+		case scanner.ErrorList:
+			for _, err := range err {
+				pkg.Errors = append(pkg.Errors, packages.Error{Pos: err.Pos.String(), Msg: err.Msg, Kind: packages.ParseError})
+			}
+		case types.Error:
+			pos := err.Fset.Position(err.Pos).String()
+			pkg.Errors = append(pkg.Errors, packages.Error{Pos: pos, Msg: err.Msg, Kind: packages.TypeError})
+		case *os.PathError:
+			pkg.Errors = append(pkg.Errors, packages.Error{Pos: err.Path + ":1", Msg: err.Err.Error(), Kind: packages.ParseError})
+		default:
+			pkg.Errors = append(pkg.Errors, packages.Error{Pos: "-", Msg: err.Error(), Kind: packages.UnknownError})
+		}
+	}
+	for _, filename := range pkg.CompiledGoFiles {
+		file, err := c.parse(filename)
+		if err != nil {
+			appendError(err)
+		}
+		if file != nil {
+			pkg.Syntax = append(pkg.Syntax, file)
+		}
+	}
+	pkg.TypesInfo = &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Scopes:     make(map[ast.Node]*types.Scope),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	importer := importerFunc(func(path string) (*types.Package, error) {
+		if path == "unsafe" {
+			return types.Unsafe, nil
+		}
+		imp, ok := pkg.Imports[path]
+		if !ok || imp.Types == nil {
+			return nil, fmt.Errorf("no metadata for %s", path)
+		}
+		return imp.Types, nil
+	})
+	tc := &types.Config{
+		Importer:         importer,
+		IgnoreFuncBodies: !c.allBody && !c.isRoot[pkg],
+		Error:            appendError,
+		Sizes:            c.sizes,
+	}
+	types.NewChecker(tc, c.cfg.Fset, pkg.Types, pkg.TypesInfo).Files(pkg.Syntax)
+	// The errors of the files that cgo processes are those of the files
+	// that it generates, whose positions are in the original files already.
+	for _, filename := range cgoFiles(pkg) {
+		if file, _ := c.parse(filename); file != nil {
+			addCgoInfo(c.cfg.Fset, pkg, file)
+		}
+	}
+	pkg.IllTyped = len(pkg.Errors) > 0
+	for _, imp := range pkg.Imports {
+		if imp.IllTyped {
+			pkg.IllTyped = true
+		}
+	}
+}
+
+// parse returns the syntax tree of the file named filename, with its content
+// in the overlay of the configuration or on disk.
+func (c *checker) parse(filename string) (*ast.File, error) {
+	src, ok := c.cfg.Overlay[filename]
+	if !ok {
+		var err error
+		if src, err = ioutil.ReadFile(filename); err != nil {
+			return nil, err
+		}
+	}
+	return c.parsed.parseFile(c.cfg.Fset, filename, src)
+}
+
+// importerFunc implements types.Importer with a function.
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// goarch returns the architecture that the environment env selects.
+func goarch(env []string) string {
+	if arch := getenv(env, "GOARCH"); arch != "" {
+		return arch
+	}
+	return runtime.GOARCH
+}
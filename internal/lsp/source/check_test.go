@@ -0,0 +1,102 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// checkTestFiles are the files of the module of the tests of the checker: a
+// diamond of packages, and independent packages, one of which has an error.
+var checkTestFiles = map[string]interface{}{
+	"base/base.go":   "package base\n\ntype T struct{ X int }\n\nfunc New() *T { return &T{} }\n",
+	"left/left.go":   "package left\n\nimport \"golang.org/fake/base\"\n\nfunc L() *base.T { return base.New() }\n",
+	"right/right.go": "package right\n\nimport \"golang.org/fake/base\"\n\nvar R = base.T{X: 1}\n",
+	"top/top.go": `package top
+
+import (
+	"golang.org/fake/left"
+	"golang.org/fake/right"
+)
+
+var Top = left.L().X + right.R.X
+`,
+	"x/x.go":   "package x\n\nconst X = 1 << 10\n",
+	"y/y.go":   "package y\n\nimport \"strings\"\n\nvar Y = strings.Repeat(\"y\", 3)\n",
+	"bad/z.go": "package bad\n\nvar Z int = \"z\"\n",
+}
+
+// describePackages returns a description of the packages that were loaded
+// in the view, and of their dependencies: the objects of their scopes, and
+// their errors.
+func describePackages(pkgs []*packages.Package) []string {
+	var lines []string
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if pkg.Types == nil || !strings.HasPrefix(pkg.PkgPath, "golang.org/fake/") {
+			return
+		}
+		qual := types.RelativeTo(pkg.Types)
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			lines = append(lines, fmt.Sprintf("%s: %s", pkg.PkgPath, types.ObjectString(scope.Lookup(name), qual)))
+		}
+		for _, err := range pkg.Errors {
+			lines = append(lines, fmt.Sprintf("%s: error %s: %s", pkg.PkgPath, err.Pos, err.Msg))
+		}
+		lines = append(lines, fmt.Sprintf("%s: ill-typed %t", pkg.PkgPath, pkg.IllTyped))
+		// The packages are type-checked against the types of the packages
+		// that they import.
+		for path, imp := range pkg.Imports {
+			found := false
+			for _, p := range pkg.Types.Imports() {
+				found = found || p == imp.Types
+			}
+			lines = append(lines, fmt.Sprintf("%s: imports the types of %s %t", pkg.PkgPath, path, found))
+		}
+	})
+	sort.Strings(lines)
+	return lines
+}
+
+func TestParallelTypeCheck(t *testing.T) {
+	ctx := context.Background()
+	exported := testExport(t, checkTestFiles)
+	defer exported.Cleanup()
+	var want []string
+	for _, parallelism := range []int{1, 2, 8} {
+		// Each view has a cache of its own.
+		v := testView(exported)
+		options := v.Options()
+		options.TypeCheckParallelism = parallelism
+		v.SetOptions(options)
+		checked, total := 0, 0
+		pkgs, err := v.loadPatterns(ctx, func(c, t int) { checked, total = c, t }, "./...")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if checked != total || total == 0 {
+			t.Errorf("parallelism %d: %d of %d packages were reported type-checked", parallelism, checked, total)
+		}
+		got := describePackages(pkgs)
+		if want == nil {
+			want = got
+			if len(want) == 0 {
+				t.Fatalf("no packages were type-checked")
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parallelism %d: got\n%s\nwant, as with parallelism 1,\n%s", parallelism, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
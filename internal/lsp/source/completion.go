@@ -7,15 +7,43 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"math"
 	"strings"
 
 	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/telemetry"
 )
 
 type CompletionItem struct {
 	Label, Detail string
 	Kind          CompletionItemKind
 	Score         float64
+
+	// InsertText is the text to insert for the candidate, if it is not its
+	// label, and Snippet the text to insert as a snippet, with a
+	// placeholder for each parameter of a function, if
+	// CompletionOptions.UsePlaceholders is set.
+	InsertText, Snippet string
+
+	// FilterText is the text with which the client filters the candidate,
+	// if it is not its label.
+	FilterText string
+
+	// Replace is the range of the text that the candidate replaces, if it
+	// is not the identifier at the position of the completion.
+	Replace Range
+
+	// AdditionalTextEdits are the edits to apply to the file along with the
+	// completion, such as the import of the package of the candidate.
+	AdditionalTextEdits []TextEdit
+
+	// importPath is the path of the package of the candidate, if the file
+	// does not import it yet, and importName the name of the package.
+	importPath, importName string
+
+	// obj is the object of the candidate, if any, whose documentation
+	// CompletionDocumentation returns.
+	obj types.Object
 }
 
 type CompletionItemKind int
@@ -32,31 +60,136 @@ const (
 	FunctionCompletionItem
 	MethodCompletionItem
 	PackageCompletionItem
+	SnippetCompletionItem
+	FileCompletionItem
+	FolderCompletionItem
 )
 
-func Completion(ctx context.Context, f *File, pos token.Pos) ([]CompletionItem, error) {
-	file, err := f.GetAST()
+// Completion returns the candidates for the completion of the identifier,
+// selector, import path or package clause of f at pos, the best ones first,
+// as opts configure it. Their ranges are those of the text that they
+// replace, if it is not the prefix of the identifier at pos.
+func Completion(ctx context.Context, f *File, pos token.Pos, opts CompletionOptions) (items []CompletionItem, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "source.Completion")
+	defer func() {
+		span.SetAttribute("candidates", len(items))
+		span.SetError(err)
+		span.Finish()
+	}()
+	file, err := f.GetAST(ctx)
 	if err != nil {
 		return nil, err
 	}
-	pkg, err := f.GetPackage()
+	filename, err := f.URI.Filename()
 	if err != nil {
 		return nil, err
 	}
-	items, _, err := completions(file, pos, pkg.Fset, pkg.Types, pkg.TypesInfo)
-	return items, err
+	// The package clause and the import paths are completed without the
+	// package, as a new file may not type check yet.
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if items, ok := packageClauseCompletion(tok, file, filename, pos); ok {
+		return items, nil
+	}
+	if items, ok := importPathCompletion(f.view, file, filename, pos); ok {
+		return items, nil
+	}
+	if items, ok := embedCompletion(file, filename, pos); ok {
+		return items, nil
+	}
+	if strings.HasSuffix(filename, "_test.go") {
+		src, err := f.Read()
+		if err != nil {
+			return nil, err
+		}
+		if items, ok := testFunctionCompletion(tok, file, src, pos); ok {
+			return addImportEdits(tok, file, items), nil
+		}
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unimported := func(name string) *types.Package {
+		return f.view.unimportedPackage(ctx, filename, name)
+	}
+	items, _, err = completions(file, pos, pkg.Fset, pkg.Types, pkg.TypesInfo, unimported, opts)
+	if err != nil {
+		return nil, err
+	}
+	return addImportEdits(tok, file, items), nil
+}
+
+// addImportEdits adds the edits that import the packages that the items use
+// and the file does not import yet.
+func addImportEdits(tok *token.File, file *ast.File, items []CompletionItem) []CompletionItem {
+	for i := range items {
+		if items[i].importPath == "" {
+			continue
+		}
+		items[i].AdditionalTextEdits = []TextEdit{importEdit(tok, file, items[i].importName, items[i].importPath)}
+	}
+	return items
+}
+
+// CompletionDocumentation returns the declaration and the doc comment of the
+// candidate of item, an item that Completion returned for f. The items are
+// returned without them, as only the few that the user selects need them.
+func CompletionDocumentation(ctx context.Context, f *File, item CompletionItem) (*HoverInformation, error) {
+	if item.obj == nil {
+		return nil, fmt.Errorf("%s has no declaration", item.Label)
+	}
+	file, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	qf := qualifier(file, pkg.Types, pkg.TypesInfo)
+	return &HoverInformation{
+		Signature: objectString(item.obj, qf),
+		Doc:       objectDoc(pkg, item.obj),
+	}, nil
 }
 
 const stdScore float64 = 1.0
 
+// recentUses returns the score of the objects that are used in the file
+// before pos: up to 1.05 for the last one used before pos, and closer to 1
+// for the others the further from pos they are used. It is small enough to
+// only order the candidates that are otherwise equal.
+func recentUses(file *ast.File, pos token.Pos, info *types.Info) map[types.Object]float64 {
+	scores := make(map[types.Object]float64)
+	span := float64(pos - file.Pos())
+	for id, obj := range info.Uses {
+		if id.Pos() < file.Pos() || id.End() >= pos {
+			continue // in another file, or at or after the position
+		}
+		score := 1 + 0.05*(1-float64(pos-id.End())/span)
+		if score > scores[obj] {
+			scores[obj] = score
+		}
+	}
+	return scores
+}
+
 type finder func(types.Object, float64, []CompletionItem) []CompletionItem
 
 // completions returns the map of possible candidates for completion, given a
 // position, a file AST, and type information. The prefix is computed based on
 // the preceding identifier and can be used by the client to score the quality
 // of the completion. For instance, some clients may tolerate imperfect matches
-// as valid completion results, since users may make typos.
-func completions(file *ast.File, pos token.Pos, fset *token.FileSet, pkg *types.Package, info *types.Info) (items []CompletionItem, prefix string, err error) {
+// as valid completion results, since users may make typos. The candidates
+// match the prefix fuzzily, as fuzzyMatch does, and are ranked by the quality
+// of the match, by whether their type is the one expected at the position,
+// by the depth of their scope and by how recently the file uses them.
+// The members of the packages that the file does not import yet are
+// candidates too, if unimported finds the package of a name.
+func completions(file *ast.File, pos token.Pos, fset *token.FileSet, pkg *types.Package, info *types.Info, unimported func(name string) *types.Package, opts CompletionOptions) (items []CompletionItem, prefix string, err error) {
 	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
 	if path == nil {
 		return nil, "", fmt.Errorf("cannot find node enclosing position")
@@ -80,6 +213,8 @@ func completions(file *ast.File, pos token.Pos, fset *token.FileSet, pkg *types.
 	sig := enclosingFunction(path, pos, info)
 	pkgStringer := qualifier(file, pkg, info)
 
+	recent := recentUses(file, pos, info)
+
 	seen := make(map[types.Object]bool)
 
 	// found adds a candidate completion.
@@ -93,12 +228,21 @@ func completions(file *ast.File, pos token.Pos, fset *token.FileSet, pkg *types.
 			if typ != nil && matchingTypes(typ, obj.Type()) {
 				weight *= 10.0
 			}
-			if !strings.HasPrefix(obj.Name(), prefix) {
+			match, ok := fuzzyMatch(prefix, obj.Name())
+			if !ok || opts.MatchCase && !isSubsequence(prefix, obj.Name()) {
 				return items
 			}
+			weight *= match
+			if score, ok := recent[obj]; ok {
+				weight *= score
+			}
 			item := formatCompletion(obj, pkgStringer, weight, func(v *types.Var) bool {
 				return isParameter(sig, v)
 			})
+			if opts.UsePlaceholders {
+				item.Snippet = functionSnippet(obj, pkgStringer)
+			}
+			item.obj = obj
 			items = append(items, item)
 		}
 		return items
@@ -115,7 +259,12 @@ func completions(file *ast.File, pos token.Pos, fset *token.FileSet, pkg *types.
 
 		// Is this the Sel part of a selector?
 		if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == n {
-			return selector(sel, info, found)
+			items, selPrefix, err := selector(sel, info, unimported, found)
+			if err != nil {
+				return nil, "", err
+			}
+			items = append(items, postfix(file, path[1:], sel, fset, info, prefix, pos)...)
+			return items, selPrefix, nil
 		}
 		// reject defining identifiers
 		if obj, ok := info.Defs[n]; ok {
@@ -132,19 +281,21 @@ func completions(file *ast.File, pos token.Pos, fset *token.FileSet, pkg *types.
 		}
 
 		items = append(items, lexical(path, pos, pkg, info, found)...)
+		items = append(items, literal(typ, pkgStringer, prefix, pos)...)
 
 	// The function name hasn't been typed yet, but the parens are there:
 	//   recv.‸(arg)
 	case *ast.TypeAssertExpr:
 		// Create a fake selector expression.
-		return selector(&ast.SelectorExpr{X: n.X}, info, found)
+		return selector(&ast.SelectorExpr{X: n.X}, info, unimported, found)
 
 	case *ast.SelectorExpr:
-		return selector(n, info, found)
+		return selector(n, info, unimported, found)
 
 	default:
 		// fallback to lexical completions
-		return lexical(path, pos, pkg, info, found), "", nil
+		items = lexical(path, pos, pkg, info, found)
+		return append(items, literal(typ, pkgStringer, "", pos)...), "", nil
 	}
 
 	return items, prefix, nil
@@ -153,7 +304,7 @@ func completions(file *ast.File, pos token.Pos, fset *token.FileSet, pkg *types.
 // selector finds completions for
 // the specified selector expression.
 // TODO(rstambler): Set the prefix filter correctly for selectors.
-func selector(sel *ast.SelectorExpr, info *types.Info, found finder) (items []CompletionItem, prefix string, err error) {
+func selector(sel *ast.SelectorExpr, info *types.Info, unimported func(name string) *types.Package, found finder) (items []CompletionItem, prefix string, err error) {
 	// Is sel a qualified identifier?
 	if id, ok := sel.X.(*ast.Ident); ok {
 		if pkgname, ok := info.Uses[id].(*types.PkgName); ok {
@@ -166,6 +317,20 @@ func selector(sel *ast.SelectorExpr, info *types.Info, found finder) (items []Co
 			}
 			return items, prefix, nil
 		}
+		// Is it the name of a package that is not imported yet?
+		if _, ok := info.Uses[id]; !ok && unimported != nil {
+			if imported := unimported(id.Name); imported != nil {
+				scope := imported.Scope()
+				for _, name := range scope.Names() {
+					items = found(scope.Lookup(name), stdScore, items)
+				}
+				for i := range items {
+					items[i].importPath = imported.Path()
+					items[i].importName = imported.Name()
+				}
+				return items, prefix, nil
+			}
+		}
 	}
 
 	// Inv: sel is a true selector.
@@ -211,10 +376,12 @@ func lexical(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 	scopes = append(scopes, pkg.Scope())
 
 	// Process scopes innermost first.
+	depth := 0 // the number of the scopes processed
 	for i, scope := range scopes {
 		if scope == nil {
 			continue
 		}
+		depth++
 		for _, name := range scope.Names() {
 			declScope, obj := scope.LookupParent(name, pos)
 			if declScope != scope {
@@ -238,7 +405,8 @@ func lexical(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 				}
 			}
 
-			score := stdScore
+			// Rank the names of the inner scopes higher.
+			score := stdScore * math.Max(0.5, 1-0.1*float64(depth-1))
 			// Rank builtins significantly lower than other results.
 			if scope == types.Universe {
 				score *= 0.1
@@ -293,6 +461,9 @@ func complit(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 		// We handle both of these cases, showing composite literal completions only if
 		// the cursor position for the key-value expression is before the colon.
 		if len(path) > 1 {
+			if _, ok := path[1].(*ast.SelectorExpr); ok {
+				return nil, false // a qualified identifier or a selector
+			}
 			if l, ok := path[1].(*ast.CompositeLit); ok {
 				lit = l
 			} else if len(path) > 2 {
@@ -315,45 +486,80 @@ func complit(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 		return nil, false
 	}
 	// Mark fields of the composite literal that have already been set,
-	// except for the current field.
+	// except for the current field, and the keys of a map literal.
 	hasKeys := false // true if the composite literal already has key-value pairs
 	addedFields := make(map[*types.Var]bool)
+	addedKeys := make(map[types.Object]bool)
 	for _, el := range lit.Elts {
 		if kv, ok := el.(*ast.KeyValueExpr); ok {
 			hasKeys = true
 			if kv.Pos() <= pos && pos <= kv.End() {
 				continue
 			}
-			if key, ok := kv.Key.(*ast.Ident); ok {
+			key := kv.Key
+			if sel, ok := key.(*ast.SelectorExpr); ok {
+				key = sel.Sel
+			}
+			if key, ok := key.(*ast.Ident); ok {
 				if used, ok := info.Uses[key]; ok {
 					if usedVar, ok := used.(*types.Var); ok {
 						addedFields[usedVar] = true
 					}
+					addedKeys[used] = true
 				}
 			}
 		}
 	}
-	// If the underlying type of the composite literal is a struct,
-	// collect completions for the fields of this struct.
-	if tv, ok := info.Types[lit]; ok {
+	tv, ok := info.Types[lit]
+	if !ok {
+		return items, false
+	}
+	switch t := deref(tv.Type).Underlying().(type) {
+	case *types.Struct:
+		// Collect completions for the fields of the struct, ranked above
+		// the others.
 		var structPkg *types.Package // package containing the struct type declaration
-		if s, ok := tv.Type.Underlying().(*types.Struct); ok {
-			for i := 0; i < s.NumFields(); i++ {
-				field := s.Field(i)
-				if i == 0 {
-					structPkg = field.Pkg()
-				}
-				if !addedFields[field] {
-					items = found(field, 10.0, items)
-				}
+		for i := 0; i < t.NumFields(); i++ {
+			field := t.Field(i)
+			if i == 0 {
+				structPkg = field.Pkg()
 			}
-			// Add lexical completions if the user hasn't typed a key value expression
-			// and if the struct fields are defined in the same package as the user is in.
-			if !hasKeys && structPkg == pkg {
-				items = append(items, lexical(path, pos, pkg, info, found)...)
+			if !addedFields[field] {
+				items = found(field, 10.0, items)
 			}
-			return items, true
 		}
+		// Add lexical completions if the user hasn't typed a key value expression
+		// and if the struct fields are defined in the same package as the user is in.
+		if !hasKeys && structPkg == pkg {
+			items = append(items, lexical(path, pos, pkg, info, found)...)
+		}
+		return items, true
+	case *types.Map:
+		// If the keys are of a named type that has constants, the
+		// candidates are the constants that are not keys yet.
+		named, ok := t.Key().(*types.Named)
+		if !ok || named.Obj().Pkg() == nil {
+			return items, false
+		}
+		keyPkg := named.Obj().Pkg()
+		scope := keyPkg.Scope()
+		constants := false // whether the type has constants
+		for _, name := range scope.Names() {
+			c, ok := scope.Lookup(name).(*types.Const)
+			if !ok || !types.Identical(c.Type(), named) {
+				continue
+			}
+			constants = true
+			if addedKeys[c] {
+				continue
+			}
+			n := len(items)
+			items = found(c, 10.0, items)
+			if keyPkg != pkg && len(items) > n {
+				items[n].Label = keyPkg.Name() + "." + items[n].Label
+			}
+		}
+		return items, constants
 	}
 	return items, false
 }
@@ -455,12 +661,51 @@ func formatParams(t *types.Tuple, variadic bool, qualifier types.Qualifier) stri
 		if variadic && i == t.Len()-1 {
 			typ = strings.Replace(typ, "[]", "...", 1)
 		}
-		fmt.Fprintf(&b, "%v %v", el.Name(), typ)
+		if el.Name() == "" {
+			b.WriteString(typ)
+		} else {
+			fmt.Fprintf(&b, "%v %v", el.Name(), typ)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// functionSnippet returns the snippet of a call of obj, if it is a function
+// or a method, with a placeholder for each of its parameters, such as
+// Foo(${1:x int}, ${2:y string}).
+func functionSnippet(obj types.Object, qualifier types.Qualifier) string {
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return ""
+	}
+	sig := fn.Type().(*types.Signature)
+	params := sig.Params()
+	var b bytes.Buffer
+	b.WriteString(obj.Name())
+	b.WriteByte('(')
+	for i := 0; i < params.Len(); i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		el := params.At(i)
+		typ := types.TypeString(el.Type(), qualifier)
+		if sig.Variadic() && i == params.Len()-1 {
+			typ = strings.Replace(typ, "[]", "...", 1)
+		}
+		if el.Name() != "" {
+			typ = el.Name() + " " + typ
+		}
+		fmt.Fprintf(&b, "${%d:%s}", i+1, snippetEscaper.Replace(typ))
 	}
 	b.WriteByte(')')
 	return b.String()
 }
 
+// snippetEscaper escapes the characters that are special in the
+// placeholders of snippets.
+var snippetEscaper = strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`)
+
 // isParameter returns true if the given *types.Var is a parameter to the given
 // *types.Signature.
 func isParameter(sig *types.Signature, v *types.Var) bool {
@@ -492,14 +737,14 @@ func qualifier(f *ast.File, pkg *types.Package, info *types.Info) types.Qualifie
 		}
 	}
 	// Define qualifier to replace full package paths with names of the imports.
-	return func(pkg *types.Package) string {
-		if pkg == pkg {
+	return func(p *types.Package) string {
+		if p == pkg {
 			return ""
 		}
-		if name, ok := imports[pkg]; ok {
+		if name, ok := imports[p]; ok {
 			return name
 		}
-		return pkg.Name()
+		return p.Name()
 	}
 }
 
@@ -5,23 +5,29 @@
 package source
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/token"
 	"go/types"
 	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
 
 	"golang.org/x/tools/go/ast/astutil"
 )
 
+// Definition returns the range of the name of the declaration of the object
+// of the identifier of f at pos, which may be in another file of the view or
+// of its dependencies.
 func Definition(ctx context.Context, f *File, pos token.Pos) (Range, error) {
-	fAST, err := f.GetAST()
+	fAST, err := f.GetAST(ctx)
 	if err != nil {
 		return Range{}, err
 	}
-	pkg, err := f.GetPackage()
+	pkg, err := f.GetPackage(ctx)
 	if err != nil {
 		return Range{}, err
 	}
@@ -45,7 +51,54 @@ func Definition(ctx context.Context, f *File, pos token.Pos) (Range, error) {
 			}
 		}
 	}
-	return objToRange(f.view.Config.Fset, obj), nil
+	return f.view.objToRange(obj)
+}
+
+// TypeDefinition returns the range of the declaration of the type of the
+// identifier at pos, following pointers to the named type they point to.
+func TypeDefinition(ctx context.Context, f *File, pos token.Pos) (Range, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return Range{}, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return Range{}, err
+	}
+	i, err := findIdentifier(fAST, pos)
+	if err != nil {
+		return Range{}, err
+	}
+	if i.ident == nil {
+		return Range{}, fmt.Errorf("type definition was not a valid identifier")
+	}
+	obj := pkg.TypesInfo.ObjectOf(i.ident)
+	if obj == nil {
+		return Range{}, fmt.Errorf("no object")
+	}
+	typObj := typeToObject(obj.Type())
+	if typObj == nil {
+		return Range{}, fmt.Errorf("no type definition for %s", i.ident.Name)
+	}
+	return f.view.objToRange(typObj)
+}
+
+// typeToObject returns the declaration of the named type that typ is or
+// points to, or nil if there is none.
+func typeToObject(typ types.Type) types.Object {
+	for {
+		switch t := typ.(type) {
+		case *types.Named:
+			if t.Obj().Pkg() == nil {
+				return nil // the error type has no declaration
+			}
+			return t.Obj()
+		case *types.Pointer:
+			typ = t.Elem()
+		default:
+			return nil
+		}
+	}
 }
 
 // ident returns the ident plus any extra information needed
@@ -93,29 +146,135 @@ func checkIdentifier(f *ast.File, pos token.Pos) (ident, error) {
 	return result, nil
 }
 
-func objToRange(fSet *token.FileSet, obj types.Object) Range {
+// objToRange returns the range of the identifier that declares obj.
+// Positions without a column, as export data may record them, and the
+// declarations that have no position, such as those of the builtins, are
+// located by parsing the file that declares them on demand: the file of the
+// position, or the files of the package of obj, or the builtin package of
+// GOROOT for the builtins.
+func (v *View) objToRange(obj types.Object) (Range, error) {
+	fset := v.Config.Fset
 	p := obj.Pos()
-	f := fSet.File(p)
-	pos := f.Position(p)
-	if pos.Column == 1 {
-		// Column is 1, so we probably do not have full position information
-		// Currently exportdata does not store the column.
-		// For now we attempt to read the original source and  find the identifier
-		// within the line. If we find it we patch the column to match its offset.
-		// TODO: we have probably already added the full data for the file to the
-		// fileset, we ought to track it rather than adding it over and over again
-		// TODO: if we parse from source, we will never need this hack
-		if src, err := ioutil.ReadFile(pos.Filename); err == nil {
-			newF := fSet.AddFile(pos.Filename, -1, len(src))
-			newF.SetLinesForContent(src)
-			lineStart := lineStart(newF, pos.Line)
-			offset := newF.Offset(lineStart)
-			col := bytes.Index(src[offset:], []byte(obj.Name()))
-			p = newF.Pos(offset + col)
+	line := 0
+	var filenames []string
+	if tok := fset.File(p); tok != nil {
+		pos := tok.Position(p)
+		if pos.Column != 1 {
+			return Range{
+				Start: p,
+				End:   p + token.Pos(len([]byte(obj.Name()))), // TODO: use real range of obj
+			}, nil
+		}
+		line = pos.Line
+		filenames = []string{expandGoroot(pos.Filename)}
+	} else {
+		filenames = v.packageFiles(obj.Pkg())
+	}
+	for _, filename := range filenames {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			continue
 		}
+		file, _ := v.parsed.parseFile(fset, filename, src)
+		if file == nil {
+			continue
+		}
+		if id := declaringIdent(fset, file, obj, line); id != nil {
+			return Range{Start: id.Pos(), End: id.End()}, nil
+		}
+	}
+	return Range{}, fmt.Errorf("no declaration of %s found", obj.Name())
+}
+
+// packageFiles returns the names of the Go files of pkg, or of the builtin
+// package of GOROOT if pkg is nil.
+func (v *View) packageFiles(pkg *types.Package) []string {
+	if pkg == nil {
+		return []string{filepath.Join(runtime.GOROOT(), "src", "builtin", "builtin.go")}
+	}
+	for _, p := range v.packages() {
+		if p.Types == pkg {
+			return p.CompiledGoFiles
+		}
+	}
+	bp, err := build.Import(pkg.Path(), "", 0)
+	if err != nil {
+		return nil
+	}
+	var filenames []string
+	for _, name := range bp.GoFiles {
+		filenames = append(filenames, filepath.Join(bp.Dir, name))
+	}
+	return filenames
+}
+
+// expandGoroot replaces the $GOROOT prefix with which the export data of the
+// standard library may record its files with the GOROOT.
+func expandGoroot(filename string) string {
+	const prefix = "$GOROOT"
+	if strings.HasPrefix(filename, prefix) {
+		return filepath.Join(runtime.GOROOT(), filename[len(prefix):])
 	}
-	return Range{
-		Start: p,
-		End:   p + token.Pos(len([]byte(obj.Name()))), // TODO: use real range of obj
+	return filename
+}
+
+// declaringIdent returns the identifier of file that declares an object
+// like obj, on the given line if it is not 0, preferring the declarations
+// at the top level, or nil if there is none. Methods are matched with their
+// receiver type.
+func declaringIdent(fset *token.FileSet, file *ast.File, obj types.Object, line int) *ast.Ident {
+	var recv string
+	if fn, ok := obj.(*types.Func); ok {
+		if sig := fn.Type().(*types.Signature); sig.Recv() != nil {
+			if named, ok := deref(sig.Recv().Type()).(*types.Named); ok {
+				recv = named.Obj().Name()
+			}
+		}
 	}
+	matches := func(id *ast.Ident) bool {
+		return id != nil && id.Name == obj.Name() && (line == 0 || fset.Position(id.Pos()).Line == line)
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !matches(decl.Name) {
+				continue
+			}
+			if receiverName(decl) == recv {
+				return decl.Name
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if matches(spec.Name) {
+						return spec.Name
+					}
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if matches(name) {
+							return name
+						}
+					}
+				}
+			}
+		}
+	}
+	// Fall back to the other declarations, such as those of fields and of
+	// the methods of interfaces.
+	var found *ast.Ident
+	ast.Inspect(file, func(n ast.Node) bool {
+		if found != nil {
+			return false
+		}
+		if field, ok := n.(*ast.Field); ok {
+			for _, name := range field.Names {
+				if matches(name) {
+					found = name
+				}
+			}
+		}
+		return true
+	})
+	return found
 }
@@ -0,0 +1,95 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// deprecationDiagnostics adds the diagnostics of the imports of deprecated
+// packages, and of the uses of deprecated objects of other packages, in the
+// files of pkg to reports. Those whose doc comment has a paragraph that
+// starts with "Deprecated: " are deprecated, as go doc renders them.
+func (v *View) deprecationDiagnostics(pkg *packages.Package, reports map[string][]Diagnostic) {
+	fset := v.Config.Fset
+	deprecated := make(map[types.Object]string)
+	for _, file := range pkg.Syntax {
+		tok := fset.File(file.Pos())
+		if tok == nil {
+			continue
+		}
+		filename := tok.Name()
+		if _, ok := reports[filename]; !ok {
+			continue
+		}
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			imp := pkg.Imports[path]
+			if imp == nil {
+				continue
+			}
+			for _, f := range imp.Syntax {
+				if msg := deprecation(f.Doc.Text()); msg != "" {
+					reports[filename] = append(reports[filename], Diagnostic{
+						Range:    Range{Start: spec.Path.Pos(), End: spec.Path.End()},
+						Severity: SeverityHint,
+						Message:  fmt.Sprintf("package %s is deprecated: %s", path, msg),
+						Tags:     []DiagnosticTag{DeprecatedTag},
+					})
+					break
+				}
+			}
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			obj := pkg.TypesInfo.Uses[id]
+			// The package that deprecates an object may still use it.
+			if obj == nil || obj.Pkg() == nil || obj.Pkg() == pkg.Types {
+				return true
+			}
+			if _, ok := obj.(*types.PkgName); ok {
+				return true
+			}
+			msg, ok := deprecated[obj]
+			if !ok {
+				msg = deprecation(objectDoc(pkg, obj))
+				deprecated[obj] = msg
+			}
+			if msg != "" {
+				reports[filename] = append(reports[filename], Diagnostic{
+					Range:    Range{Start: id.Pos(), End: id.End()},
+					Severity: SeverityHint,
+					Message:  fmt.Sprintf("%s is deprecated: %s", id.Name, msg),
+					Tags:     []DiagnosticTag{DeprecatedTag},
+				})
+			}
+			return true
+		})
+	}
+}
+
+// deprecation returns the text of the paragraph of doc, a doc comment, that
+// starts with "Deprecated: ", on a single line, or "" if there is none.
+func deprecation(doc string) string {
+	for _, para := range strings.Split(doc, "\n\n") {
+		para = strings.TrimSpace(para)
+		if strings.HasPrefix(para, "Deprecated: ") {
+			return strings.Join(strings.Fields(strings.TrimPrefix(para, "Deprecated: ")), " ")
+		}
+	}
+	return ""
+}
@@ -6,7 +6,9 @@ package source
 
 import (
 	"context"
+	"fmt"
 	"go/token"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -14,9 +16,38 @@ import (
 )
 
 type Diagnostic struct {
-	Range    Range
-	Severity DiagnosticSeverity
-	Message  string
+	Range          Range
+	Severity       DiagnosticSeverity
+	Message        string
+	SuggestedFixes []SuggestedFix
+
+	// Source is the name of the analyzer that reported the diagnostic, or
+	// empty for the errors of the package.
+	Source string
+
+	// Related are the errors that cause the diagnostic, such as those of
+	// a dependency that fails to type-check.
+	Related []RelatedInformation
+
+	// Tags describe the code of the diagnostic to clients, which may render
+	// it accordingly.
+	Tags []DiagnosticTag
+}
+
+// A DiagnosticTag describes the code of a diagnostic.
+type DiagnosticTag int
+
+const (
+	// DeprecatedTag marks a use of a deprecated package or object.
+	DeprecatedTag DiagnosticTag = iota
+	// UnnecessaryTag marks unused code.
+	UnnecessaryTag
+)
+
+// RelatedInformation is an error related to a diagnostic, in any file.
+type RelatedInformation struct {
+	Range   Range
+	Message string
 }
 
 type DiagnosticSeverity int
@@ -28,11 +59,26 @@ const (
 	SeverityInformation
 )
 
-func Diagnostics(ctx context.Context, v *View, f *File) (map[string][]Diagnostic, error) {
-	pkg, err := f.GetPackage()
+// Diagnostics returns the diagnostics of the files of the package of f, by
+// filename: its parse errors or, if it has none, its type errors, the errors
+// of its imports, and the diagnostics of the analyses that opts enable. Every
+// file of the package has an entry, empty if it has no diagnostics, so that
+// the diagnostics it had before can be cleared.
+// In a go.mod file, they are the errors of the file.
+func Diagnostics(ctx context.Context, v *View, f *File, opts DiagnosticsOptions) (map[string][]Diagnostic, error) {
+	if IsModFile(f.URI) {
+		return modDiagnostics(ctx, f)
+	}
+	pkg, err := f.GetPackage(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return v.packageDiagnostics(ctx, pkg, opts)
+}
+
+// packageDiagnostics returns the diagnostics of the files of pkg, by
+// filename, as Diagnostics does.
+func (v *View) packageDiagnostics(ctx context.Context, pkg *packages.Package, opts DiagnosticsOptions) (map[string][]Diagnostic, error) {
 	// Prepare the reports we will send for this package.
 	reports := make(map[string][]Diagnostic)
 	for _, filename := range pkg.GoFiles {
@@ -56,7 +102,7 @@ func Diagnostics(ctx context.Context, v *View, f *File) (map[string][]Diagnostic
 		diags = parseErrors
 	}
 	for _, diag := range diags {
-		filename, start := v.errorPos(diag)
+		filename, start := v.errorPos(ctx, diag)
 		// TODO(rstambler): Add support for diagnostic ranges.
 		end := start
 		diagnostic := Diagnostic{
@@ -67,14 +113,238 @@ func Diagnostics(ctx context.Context, v *View, f *File) (map[string][]Diagnostic
 			Message:  diag.Msg,
 			Severity: SeverityError,
 		}
-		if _, ok := reports[filename]; ok {
-			reports[filename] = append(reports[filename], diagnostic)
+		if _, ok := reports[filename]; !ok {
+			continue
+		}
+		if diag.Kind == packages.TypeError {
+			diagnostic.SuggestedFixes = v.suggestedFixes(ctx, filename, start)
+		}
+		reports[filename] = append(reports[filename], diagnostic)
+	}
+	if len(parseErrors) == 0 {
+		v.importErrors(pkg, reports)
+		v.deprecationDiagnostics(pkg, reports)
+		v.embedDiagnostics(pkg, reports)
+	}
+	if err := v.analysisDiagnostics(ctx, pkg, opts, reports); err != nil {
+		return nil, err
+	}
+	// The references of an ill-typed package may be missing.
+	if opts.UnusedExported && !pkg.IllTyped {
+		v.unusedExportedDiagnostics(pkg, reports)
+	}
+	return reports, nil
+}
+
+// The stages of WorkspaceDiagnostics, which it reports.
+const (
+	TypeCheckStage = "type-checked"
+	DiagnoseStage  = "diagnosed"
+)
+
+// WorkspaceDiagnostics loads and type-checks the packages that match the
+// patterns, resolved in the directory of v, and returns the diagnostics of
+// all their files, by filename, as Diagnostics does for each of them. A file
+// of several packages, such as a package and its test variant, has the
+// diagnostics of the first of them.
+// If report is not nil, it is called as the packages and their dependencies
+// are type-checked, with TypeCheckStage, and then as the packages are
+// diagnosed, with DiagnoseStage, with the number of packages done so far and
+// their total number.
+func WorkspaceDiagnostics(ctx context.Context, v *View, patterns []string, opts DiagnosticsOptions, report func(stage string, done, total int)) (map[string][]Diagnostic, error) {
+	var checked func(checked, total int)
+	if report != nil {
+		checked = func(n, total int) { report(TypeCheckStage, n, total) }
+	}
+	pkgs, err := v.loadPatterns(ctx, checked, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	// The main packages that go test generates have no files to diagnose.
+	var roots []*packages.Package
+	for _, pkg := range pkgs {
+		if !strings.HasSuffix(pkg.ID, ".test") {
+			roots = append(roots, pkg)
+		}
+	}
+	reports := make(map[string][]Diagnostic)
+	for i, pkg := range roots {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		diags, err := v.packageDiagnostics(ctx, pkg, opts)
+		if err != nil {
+			return nil, err
+		}
+		for filename, d := range diags {
+			if _, ok := reports[filename]; !ok {
+				reports[filename] = d
+			}
+		}
+		if report != nil {
+			report(DiagnoseStage, i+1, len(roots))
 		}
 	}
 	return reports, nil
 }
 
-func (v *View) errorPos(pkgErr packages.Error) (string, token.Pos) {
+// analysisDiagnostics adds the diagnostics that the analyzers that opts
+// enable report for pkg to reports, with the severities of the analyzers and
+// the fixes that they suggest.
+func (v *View) analysisDiagnostics(ctx context.Context, pkg *packages.Package, opts DiagnosticsOptions, reports map[string][]Diagnostic) error {
+	diags, err := analyze(ctx, pkg, enabledAnalyzers(opts.Analyses))
+	if err != nil {
+		return err
+	}
+	for _, diag := range diags {
+		filename := v.Config.Fset.Position(diag.Pos).Filename
+		if _, ok := reports[filename]; !ok {
+			continue
+		}
+		var fixes []SuggestedFix
+		for _, fix := range diag.SuggestedFixes {
+			var edits []TextEdit
+			for _, edit := range fix.TextEdits {
+				end := edit.End
+				if !end.IsValid() {
+					end = edit.Pos
+				}
+				edits = append(edits, TextEdit{
+					Range:   Range{Start: edit.Pos, End: end},
+					NewText: string(edit.NewText),
+				})
+			}
+			fixes = append(fixes, SuggestedFix{Title: fix.Message, Edits: edits})
+		}
+		reports[filename] = append(reports[filename], Diagnostic{
+			Range:          Range{Start: diag.Pos, End: diag.Pos},
+			Severity:       analyzerSeverity(diag.analyzer.Name, opts.AnalysisSeverities),
+			Message:        diag.Message,
+			SuggestedFixes: fixes,
+			Source:         diag.analyzer.Name,
+		})
+	}
+	return nil
+}
+
+// importErrors adds the diagnostics of the imports of pkg whose packages, or
+// their dependencies, have errors to reports, at the import paths, unless
+// they have diagnostics already, such as those of the packages that could
+// not be imported at all. Their related information locates the first error.
+func (v *View) importErrors(pkg *packages.Package, reports map[string][]Diagnostic) {
+	fset := v.Config.Fset
+	for _, file := range pkg.Syntax {
+		tok := fset.File(file.Pos())
+		if tok == nil {
+			continue
+		}
+		filename := tok.Name()
+		if _, ok := reports[filename]; !ok {
+			continue
+		}
+	imports:
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			imp := pkg.Imports[path]
+			if imp == nil {
+				continue
+			}
+			for _, diag := range reports[filename] {
+				if spec.Pos() <= diag.Range.Start && diag.Range.Start <= spec.End() {
+					continue imports
+				}
+			}
+			errPkg, pkgErr := firstError(imp)
+			if errPkg == nil {
+				continue
+			}
+			msg := fmt.Sprintf("could not type-check %q: %s", path, pkgErr.Msg)
+			if errPkg != imp {
+				msg = fmt.Sprintf("%q depends on %q, which has errors: %s", path, errPkg.PkgPath, pkgErr.Msg)
+			}
+			diagnostic := Diagnostic{
+				Range:    Range{Start: spec.Path.Pos(), End: spec.Path.End()},
+				Severity: SeverityError,
+				Message:  msg,
+			}
+			if start := packageErrorPos(fset, errPkg, pkgErr); start.IsValid() {
+				diagnostic.Related = []RelatedInformation{{
+					Range:   Range{Start: start, End: start},
+					Message: pkgErr.Msg,
+				}}
+			}
+			reports[filename] = append(reports[filename], diagnostic)
+		}
+	}
+}
+
+// firstError returns the first of the errors of pkg, or of its dependencies
+// if it has none, and the package that has it, or nil if there is none.
+func firstError(pkg *packages.Package) (*packages.Package, packages.Error) {
+	seen := make(map[*packages.Package]bool)
+	var find func(pkg *packages.Package) (*packages.Package, packages.Error)
+	find = func(pkg *packages.Package) (*packages.Package, packages.Error) {
+		seen[pkg] = true
+		if len(pkg.Errors) > 0 {
+			return pkg, pkg.Errors[0]
+		}
+		if !pkg.IllTyped {
+			return nil, packages.Error{}
+		}
+		for _, path := range importPaths(pkg) {
+			imp := pkg.Imports[path]
+			if seen[imp] {
+				continue
+			}
+			if errPkg, err := find(imp); errPkg != nil {
+				return errPkg, err
+			}
+		}
+		return nil, packages.Error{}
+	}
+	return find(pkg)
+}
+
+// importPaths returns the paths of the imports of pkg, sorted, so that the
+// errors of the dependencies are found in the same order each time.
+func importPaths(pkg *packages.Package) []string {
+	paths := make([]string, 0, len(pkg.Imports))
+	for path := range pkg.Imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// packageErrorPos returns the position of the error of pkg in the syntax
+// trees of its files, or NoPos if it has none.
+func packageErrorPos(fset *token.FileSet, pkg *packages.Package, pkgErr packages.Error) token.Pos {
+	remainder1, first, hasLine := chop(pkgErr.Pos)
+	remainder2, second, hasColumn := chop(remainder1)
+	pos := token.Position{Filename: remainder1, Line: first}
+	if hasLine && hasColumn {
+		pos = token.Position{Filename: remainder2, Line: second, Column: first}
+	} else if !hasLine {
+		return token.NoPos
+	}
+	for _, file := range pkg.Syntax {
+		if tok := fset.File(file.Pos()); tok != nil && tok.Name() == pos.Filename {
+			if pos.Line > tok.LineCount() {
+				return token.NoPos
+			}
+			if pos.Column == 0 {
+				pos.Column = 1
+			}
+			return fromTokenPosition(tok, pos)
+		}
+	}
+	return token.NoPos
+}
+
+func (v *View) errorPos(ctx context.Context, pkgErr packages.Error) (string, token.Pos) {
 	remainder1, first, hasLine := chop(pkgErr.Pos)
 	remainder2, second, hasColumn := chop(remainder1)
 	var pos token.Position
@@ -90,7 +360,7 @@ func (v *View) errorPos(pkgErr packages.Error) (string, token.Pos) {
 	if f == nil {
 		return "", token.NoPos
 	}
-	tok, err := f.GetToken()
+	tok, err := f.GetToken(ctx)
 	if err != nil {
 		return "", token.NoPos
 	}
@@ -110,11 +380,11 @@ func chop(text string) (remainder string, value int, ok bool) {
 }
 
 // fromTokenPosition converts a token.Position (1-based line and column
-// number) to a token.Pos (byte offset value).
+// number, in bytes) to a token.Pos (byte offset value).
 // It requires the token file the pos belongs to in order to do this.
 func fromTokenPosition(f *token.File, pos token.Position) token.Pos {
 	line := lineStart(f, pos.Line)
-	return line + token.Pos(pos.Column-1) // TODO: this is wrong, bytes not characters
+	return line + token.Pos(pos.Column-1)
 }
 
 // this functionality was borrowed from the analysisutil package
@@ -0,0 +1,39 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package source implements the features of the language server on Go
+// source code, independently of the Language Server Protocol, so that other
+// tools, such as editors that embed them, can use them directly.
+//
+// A Session holds the documents open in an editor, and its Views the
+// directories of the workspace. The Files of a View are Go and go.mod files,
+// which are loaded, parsed and type-checked on demand, and cached until
+// they change:
+//
+//	v := source.NewView()
+//	v.Config.Dir = dir
+//	f := v.GetFile(source.ToURI(filename))
+//
+// The features are functions of a File and, for those that need more than
+// its package, of its View, such as
+//
+//	func Completion(ctx context.Context, f *File, pos token.Pos, opts CompletionOptions) ([]CompletionItem, error)
+//	func Diagnostics(ctx context.Context, v *View, f *File, opts DiagnosticsOptions) (map[string][]Diagnostic, error)
+//	func Definition(ctx context.Context, f *File, pos token.Pos) (Range, error)
+//	func Format(ctx context.Context, f *File, rng Range) ([]TextEdit, error)
+//
+// The features that have settings take them as an options struct, such as
+// CompletionOptions, rather than reading them from the View, so that the
+// caller decides them for each call. The Options of a View, which
+// DefaultOptions returns unless SetOptions changes them, group the options
+// of all the features, as a language server configures them for a
+// workspace folder.
+//
+// The positions of the arguments and results are token.Pos values of the
+// token.FileSet of the View, v.Config.Fset. The features return their
+// results as they are, in the positions of the file, with no conversion to
+// the lines and UTF-16 columns of the protocol, which the caller does.
+// Their errors describe why they have no result, such as a position that is
+// not in an identifier.
+package source
@@ -0,0 +1,107 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// AddDocComment returns the fix that inserts the stub of a doc comment,
+// "// Name ...", above the exported declaration that encloses rng, if it has
+// no doc comment. The doc comment of a grouped variable or constant may be
+// that of its group.
+func AddDocComment(ctx context.Context, f *File, rng Range) (*SuggestedFix, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	path, _ := astutil.PathEnclosingInterval(fAST, rng.Start, rng.End)
+	pos, name := undocumentedDecl(path)
+	if name == nil {
+		return nil, fmt.Errorf("no undocumented exported declaration at the selection")
+	}
+	indent := strings.Repeat("\t", tok.Position(pos).Column-1)
+	return &SuggestedFix{
+		Title: "Add documentation comment",
+		Edits: []TextEdit{{
+			Range:   Range{Start: pos, End: pos},
+			NewText: fmt.Sprintf("// %s ...\n%s", name.Name, indent),
+		}},
+	}, nil
+}
+
+// undocumentedDecl returns the start of the package-level declaration that
+// encloses path[0], which its doc comment precedes, and the exported name
+// that it declares without a doc comment, if any.
+func undocumentedDecl(path []ast.Node) (token.Pos, *ast.Ident) {
+	for i, n := range path {
+		var (
+			decl *ast.GenDecl
+			spec ast.Spec
+		)
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Doc != nil || !n.Name.IsExported() {
+				return token.NoPos, nil
+			}
+			return n.Pos(), n.Name
+		case *ast.GenDecl:
+			// The keyword of an ungrouped declaration is selected.
+			if len(n.Specs) != 1 || n.Lparen.IsValid() {
+				return token.NoPos, nil
+			}
+			decl, spec = n, n.Specs[0]
+			i++
+		case ast.Spec:
+			decl, spec = path[i+1].(*ast.GenDecl), n
+			i += 2
+		default:
+			continue
+		}
+		if _, ok := path[i].(*ast.File); !ok {
+			return token.NoPos, nil // a declaration in a function
+		}
+		name := undocumentedName(decl, spec)
+		if decl.Lparen.IsValid() {
+			return spec.Pos(), name
+		}
+		return decl.Pos(), name
+	}
+	return token.NoPos, nil
+}
+
+// undocumentedName returns the exported name that spec, a specification of
+// decl, declares, if neither has a doc comment.
+func undocumentedName(decl *ast.GenDecl, spec ast.Spec) *ast.Ident {
+	if decl.Doc != nil && !decl.Lparen.IsValid() {
+		return nil
+	}
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		if spec.Doc == nil && spec.Name.IsExported() {
+			return spec.Name
+		}
+	case *ast.ValueSpec:
+		if spec.Doc != nil || decl.Doc != nil {
+			return nil
+		}
+		for _, name := range spec.Names {
+			if name.IsExported() {
+				return name
+			}
+		}
+	}
+	return nil
+}
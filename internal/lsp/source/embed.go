@@ -0,0 +1,236 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const embedDirective = "//go:embed"
+
+// An embedPattern is a pattern of a //go:embed directive, with the range of
+// its text in the directive, which may quote it.
+type embedPattern struct {
+	pattern    string
+	start, end token.Pos
+}
+
+// embedPatterns returns the patterns of the //go:embed directives of file.
+func embedPatterns(file *ast.File) []embedPattern {
+	var patterns []embedPattern
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			if !strings.HasPrefix(c.Text, embedDirective+" ") && !strings.HasPrefix(c.Text, embedDirective+"\t") {
+				continue
+			}
+			patterns = append(patterns, parseEmbedPatterns(c)...)
+		}
+	}
+	return patterns
+}
+
+// parseEmbedPatterns returns the patterns of the //go:embed directive c,
+// which are separated by spaces and may be quoted as Go strings are.
+func parseEmbedPatterns(c *ast.Comment) []embedPattern {
+	var patterns []embedPattern
+	text := c.Text
+	for i := len(embedDirective); i < len(text); {
+		if text[i] == ' ' || text[i] == '\t' {
+			i++
+			continue
+		}
+		end := i
+		switch text[i] {
+		case '"':
+			for end++; end < len(text) && text[end] != '"'; end++ {
+				if text[end] == '\\' {
+					end++
+				}
+			}
+			end++
+		case '`':
+			end = strings.IndexByte(text[i+1:], '`') + i + 2
+			if end == i+1 {
+				end = len(text) + 1
+			}
+		default:
+			for end < len(text) && text[end] != ' ' && text[end] != '\t' {
+				end++
+			}
+		}
+		if end > len(text) {
+			return patterns // an unterminated string
+		}
+		pattern := text[i:end]
+		if pattern[0] == '"' || pattern[0] == '`' {
+			var err error
+			if pattern, err = strconv.Unquote(pattern); err != nil {
+				return patterns
+			}
+		}
+		patterns = append(patterns, embedPattern{
+			pattern: pattern,
+			start:   c.Pos() + token.Pos(i),
+			end:     c.Pos() + token.Pos(end),
+		})
+		i = end
+	}
+	return patterns
+}
+
+// embeddedFiles returns the files that pattern, a pattern of a //go:embed
+// directive of a file of dir, embeds: the files that it matches, and the
+// files of the directories that it matches, except those whose names start
+// with '.' or '_', unless the pattern starts with "all:".
+func embeddedFiles(dir, pattern string) ([]string, error) {
+	all := strings.HasPrefix(pattern, "all:")
+	pattern = strings.TrimPrefix(pattern, "all:")
+	if pattern == "" || pattern == "." || path.Clean(pattern) != pattern ||
+		strings.HasPrefix(pattern, "/") || pattern == ".." || strings.HasPrefix(pattern, "../") {
+		return nil, fmt.Errorf("invalid pattern syntax")
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern syntax")
+	}
+	var files []string
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			files = append(files, match)
+			continue
+		}
+		filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if name := info.Name(); path != match && !all && (name[0] == '.' || name[0] == '_') {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() {
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no matching files found")
+	}
+	return files, nil
+}
+
+// embedDiagnostics adds the diagnostics of the patterns of the //go:embed
+// directives of the files of pkg that embed no files to reports.
+func (v *View) embedDiagnostics(pkg *packages.Package, reports map[string][]Diagnostic) {
+	for _, file := range pkg.Syntax {
+		filename := v.Config.Fset.Position(file.Package).Filename
+		if _, ok := reports[filename]; !ok {
+			continue
+		}
+		for _, p := range embedPatterns(file) {
+			if _, err := embeddedFiles(filepath.Dir(filename), p.pattern); err != nil {
+				reports[filename] = append(reports[filename], Diagnostic{
+					Range:    Range{Start: p.start, End: p.end},
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("pattern %s: %v", p.pattern, err),
+				})
+			}
+		}
+	}
+}
+
+// EmbeddedFiles returns the names of the files that the pattern of a
+// //go:embed directive of f at pos embeds, which are its definitions, and
+// whether there is such a pattern.
+func EmbeddedFiles(ctx context.Context, f *File, pos token.Pos) ([]string, bool, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	filename, err := f.URI.Filename()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, p := range embedPatterns(fAST) {
+		if p.start <= pos && pos <= p.end {
+			files, err := embeddedFiles(filepath.Dir(filename), p.pattern)
+			if err != nil {
+				return nil, true, fmt.Errorf("pattern %s: %v", p.pattern, err)
+			}
+			return files, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// embedCompletion returns the completion of the path of a file or directory
+// of the directory of the file named filename, in a pattern of a //go:embed
+// directive of file at pos, and whether pos is in one.
+func embedCompletion(file *ast.File, filename string, pos token.Pos) ([]CompletionItem, bool) {
+	var c *ast.Comment
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if comment.Pos() < pos && pos <= comment.End() && strings.HasPrefix(comment.Text, embedDirective) {
+				c = comment
+			}
+		}
+	}
+	if c == nil || pos <= c.Pos()+token.Pos(len(embedDirective)) {
+		return nil, false
+	}
+	// The completed path starts after the last space or quote before pos.
+	text := c.Text[:pos-c.Pos()]
+	start := strings.LastIndexAny(text, " \t\"`") + 1
+	if start <= len(embedDirective) {
+		return nil, false
+	}
+	if q := text[start-1]; (q == '"' || q == '`') && strings.Count(text[len(embedDirective):], string(q))%2 == 0 {
+		return nil, true // after the closing quote of a pattern
+	}
+	prefix := text[start:]
+	sub, partial := path.Split(strings.TrimPrefix(prefix, "all:"))
+	infos, err := ioutil.ReadDir(filepath.Join(filepath.Dir(filename), filepath.FromSlash(sub)))
+	if err != nil {
+		return nil, true
+	}
+	var items []CompletionItem
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasPrefix(name, partial) || name[0] == '.' && !strings.HasPrefix(partial, ".") {
+			continue
+		}
+		p := prefix[:len(prefix)-len(partial)] + name
+		kind := FileCompletionItem
+		if info.IsDir() {
+			kind = FolderCompletionItem
+		}
+		items = append(items, CompletionItem{
+			Label:      sub + name,
+			Kind:       kind,
+			Score:      stdScore,
+			InsertText: p,
+			FilterText: p,
+			Replace:    Range{Start: c.Pos() + token.Pos(start), End: pos},
+		})
+	}
+	return items, true
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// bytesPerSourceByte is the estimated ratio of the memory that the syntax
+// trees and type information of a package use to the size of its source.
+const bytesPerSourceByte = 30
+
+// A PackageMemory is the estimated memory that a cached package uses.
+type PackageMemory struct {
+	PkgPath string
+	// Bytes is the estimated memory that the package and the dependencies
+	// that were type-checked with it use.
+	Bytes int64
+	// Open is set if a file of the package is open in the editor, which
+	// prevents its eviction.
+	Open bool
+}
+
+// MemoryUsage returns the estimated memory that each cached package of the
+// view uses, largest first.
+func (v *View) MemoryUsage() []PackageMemory {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var usage []PackageMemory
+	for _, c := range v.cachedPackages() {
+		usage = append(usage, PackageMemory{
+			PkgPath: c.pkg.PkgPath,
+			Bytes:   c.size,
+			Open:    v.isOpen(c),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Bytes != usage[j].Bytes {
+			return usage[i].Bytes > usage[j].Bytes
+		}
+		return usage[i].PkgPath < usage[j].PkgPath
+	})
+	return usage
+}
+
+// evict evicts the least recently used cached packages until the others fit
+// in the memory budget of the options of the view. The packages of the files that are open
+// in the editor are not evicted, as they are likely to be used again soon,
+// nor is the package of the file named keep, which is in use.
+// The sizes of the packages include those of their dependencies, so shared
+// dependencies are counted more than once and the estimate errs on the side
+// of evicting too much.
+// It must be called with v.mu held.
+func (v *View) evict(keep string) {
+	if v.options.MemoryBudget <= 0 {
+		return
+	}
+	cached := v.cachedPackages()
+	var total int64
+	for _, c := range cached {
+		total += c.size
+	}
+	sort.Slice(cached, func(i, j int) bool { return cached[i].lastUsed < cached[j].lastUsed })
+	for _, c := range cached {
+		if total <= v.options.MemoryBudget {
+			break
+		}
+		if v.pkgCache[keep] == c || v.isOpen(c) {
+			continue
+		}
+		v.evictPackage(c)
+		total -= c.size
+	}
+}
+
+// evictPackage discards a cached package, and the state of the files of
+// the view that refers to it.
+func (v *View) evictPackage(c *cachedPackage) {
+	for filename, cc := range v.pkgCache {
+		if cc == c {
+			delete(v.pkgCache, filename)
+		}
+	}
+	for _, f := range v.files {
+		if f.pkg != c.pkg {
+			continue
+		}
+		v.invalidate(f.pkg)
+		f.ast = nil
+		f.token = nil
+		f.pkg = nil
+	}
+	v.parsed.forget(c.pkg.CompiledGoFiles)
+	v.parsed.forget(cgoFiles(c.pkg))
+	v.session.cache.forgetPackage(c.pkg)
+}
+
+// cachedPackages returns the cached packages of the view, once each.
+func (v *View) cachedPackages() []*cachedPackage {
+	seen := make(map[*cachedPackage]bool)
+	var cached []*cachedPackage
+	for _, c := range v.pkgCache {
+		if !seen[c] {
+			seen[c] = true
+			cached = append(cached, c)
+		}
+	}
+	return cached
+}
+
+// isOpen reports whether a file of a cached package is open in the editor.
+func (v *View) isOpen(c *cachedPackage) bool {
+	for _, filename := range c.pkg.GoFiles {
+		if _, ok := v.session.overlay(ToURI(filename)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// packageSize returns the estimated memory that pkg and the dependencies
+// that were type-checked with it use.
+func packageSize(pkg *packages.Package) int64 {
+	seen := make(map[*packages.Package]bool)
+	var size int64
+	var add func(p *packages.Package)
+	add = func(p *packages.Package) {
+		if seen[p] {
+			return
+		}
+		seen[p] = true
+		for _, file := range p.Syntax {
+			if tok := p.Fset.File(file.Pos()); tok != nil {
+				size += int64(tok.Size())
+			}
+		}
+		for _, imp := range p.Imports {
+			add(imp)
+		}
+	}
+	add(pkg)
+	return size * bytesPerSourceByte
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import "testing"
+
+func TestEviction(t *testing.T) {
+	exported := testExport(t, cacheTestFiles)
+	defer exported.Cleanup()
+	v := testView(exported)
+	// Any package is over the budget, so only the package in use and those
+	// of the open files are kept.
+	options := v.Options()
+	options.MemoryBudget = 1
+	v.SetOptions(options)
+	cached := func(name string) bool {
+		_, ok := v.pkgCache[exported.File("golang.org/fake", name)]
+		return ok
+	}
+
+	a := testPackage(t, v, exported, "a/a.go")
+	if !cached("a/a.go") {
+		t.Errorf("the package of a, which is in use, was evicted")
+	}
+	c := testPackage(t, v, exported, "c/c.go")
+	if cached("a/a.go") || !cached("c/c.go") {
+		t.Errorf("a was not evicted in favor of c")
+	}
+	if got := testPackage(t, v, exported, "a/a.go"); got == a {
+		t.Errorf("a was not type-checked again after it was evicted")
+	}
+
+	// The package of an open file is not evicted.
+	content := []byte("package c\n\nconst C = 2\n")
+	v.GetFile(ToURI(exported.File("golang.org/fake", "c/c.go"))).SetContent(content)
+	c = testPackage(t, v, exported, "c/c.go")
+	testPackage(t, v, exported, "a/a.go")
+	if !cached("c/c.go") {
+		t.Errorf("c, which is open, was evicted")
+	}
+	if got := testPackage(t, v, exported, "c/c.go"); got != c {
+		t.Errorf("c, which is open, was type-checked again")
+	}
+	usage := v.MemoryUsage()
+	if len(usage) != 2 {
+		t.Fatalf("got the memory usage of %d packages, want 2: %v", len(usage), usage)
+	}
+	for _, u := range usage {
+		if u.Bytes <= 0 || u.Open != (u.PkgPath == "golang.org/fake/c") {
+			t.Errorf("got memory usage %+v", u)
+		}
+	}
+}
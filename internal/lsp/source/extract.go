@@ -0,0 +1,775 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// ExtractFunction returns the edits that move the statements selected by rng
+// into a new function, declared after the enclosing declaration, and replace
+// them with a call to it.
+// The variables declared outside of the selection that the statements use
+// become the parameters of the new function, and the variables that are
+// declared or assigned by the statements and used afterwards become its
+// results.
+// A selection that ends with a return statement is replaced by a return of
+// the call. Otherwise the return statements of the selection make the new
+// function also return true and the values to return, after which the call
+// returns them.
+func ExtractFunction(ctx context.Context, f *File, rng Range) ([]TextEdit, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) != tok.Size() {
+		return nil, fmt.Errorf("%s has changed since it was parsed", f.URI)
+	}
+	info := pkg.TypesInfo
+
+	stmts, path, err := selectedStmts(tok, content, fAST, rng)
+	if err != nil {
+		return nil, err
+	}
+	decl := path[len(path)-2] // the top-level declaration
+	start, end := stmts[0].Pos(), stmts[len(stmts)-1].End()
+	if err := checkExtractable(stmts); err != nil {
+		return nil, err
+	}
+	sig := enclosingSignature(info, path)
+	if sig == nil {
+		return nil, fmt.Errorf("the selection is not within a function body")
+	}
+
+	// Classify the local variables that the selection refers to.
+	inSelection := func(pos token.Pos) bool { return start <= pos && pos < end }
+	isLocal := func(obj types.Object) bool {
+		return obj.Pos() >= decl.Pos() && obj.Pos() < decl.End() && obj.Parent() != pkg.Types.Scope()
+	}
+	var params []*types.Var
+	isParam := make(map[*types.Var]bool)
+	assigned := make(map[*types.Var]bool)
+	declared := make(map[*types.Var]bool)
+	for _, stmt := range stmts {
+		for id := range assignedIdents(info, stmt) {
+			if v, ok := info.Uses[id].(*types.Var); ok {
+				assigned[v] = true
+			}
+		}
+		var err error
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if err != nil {
+				return false
+			}
+			// A copy of a variable that is passed as a parameter does not
+			// have the same address, so neither an explicit address
+			// operation nor a call of a method with a pointer receiver,
+			// which takes the address implicitly, can be extracted.
+			var addressed ast.Expr
+			switch n := n.(type) {
+			case *ast.UnaryExpr:
+				if n.Op == token.AND {
+					addressed = n.X
+				}
+			case *ast.SelectorExpr:
+				if sel, ok := info.Selections[n]; ok && sel.Kind() == types.MethodVal {
+					recv := sel.Obj().Type().(*types.Signature).Recv()
+					if _, ok := recv.Type().(*types.Pointer); ok && !sel.Indirect() {
+						if _, ok := sel.Recv().Underlying().(*types.Pointer); !ok {
+							addressed = n.X
+						}
+					}
+				}
+			}
+			if id, ok := astutil.Unparen(addressed).(*ast.Ident); ok {
+				if v, ok := info.Uses[id].(*types.Var); ok && isLocal(v) && !inSelection(v.Pos()) {
+					err = fmt.Errorf("cannot extract a function that takes the address of %s", id.Name)
+				}
+			}
+			id, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if obj := info.Defs[id]; obj != nil {
+				if v, ok := obj.(*types.Var); ok {
+					declared[v] = true
+				}
+				return true
+			}
+			obj := info.Uses[id]
+			if obj == nil || !isLocal(obj) || inSelection(obj.Pos()) {
+				return true
+			}
+			switch obj := obj.(type) {
+			case *types.Var:
+				if !obj.IsField() && !isParam[obj] {
+					isParam[obj] = true
+					params = append(params, obj)
+				}
+			case *types.TypeName, *types.Const:
+				err = fmt.Errorf("cannot extract a function that uses the local declaration of %s", id.Name)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The variables used outside of the selection, after their declaration.
+	usedOutside := make(map[types.Object]bool)
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if n == nil || (start <= n.Pos() && n.End() <= end) {
+			return false
+		}
+		if id, ok := n.(*ast.Ident); ok {
+			if obj := info.Uses[id]; obj != nil {
+				usedOutside[obj] = true
+			}
+		}
+		return true
+	})
+	for _, stmt := range stmts {
+		var err error
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				switch obj := info.Defs[id].(type) {
+				case *types.TypeName, *types.Const:
+					if usedOutside[obj] {
+						err = fmt.Errorf("cannot extract the declaration of %s, which is used after the selection", id.Name)
+					}
+				}
+			}
+			return err == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	var results, newResults []*types.Var
+	for v := range declared {
+		if usedOutside[v] {
+			results = append(results, v)
+			newResults = append(newResults, v)
+		}
+	}
+	for _, v := range params {
+		if assigned[v] && usedOutside[v] {
+			results = append(results, v)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Pos() < results[j].Pos() })
+	sort.Slice(newResults, func(i, j int) bool { return newResults[i].Pos() < newResults[j].Pos() })
+
+	// A selection that ends with a return statement always returns, so the
+	// new function returns what the enclosing one does, and only that.
+	_, alwaysReturns := stmts[len(stmts)-1].(*ast.ReturnStmt)
+	alwaysReturns = alwaysReturns && len(results) == 0
+	returns, err := selectedReturns(sig, stmts, !alwaysReturns)
+	if err != nil {
+		return nil, err
+	}
+
+	qf := packageQualifier(pkg.Types)
+	name := newFunctionName(fAST, pkg.Types)
+	s, e := tok.Offset(start), tok.Offset(end)
+	indent := lineIndent(content, s)
+
+	// Generate the new function.
+	var paramList, args, resultTypes, resultNames, zeros []string
+	for _, v := range params {
+		paramList = append(paramList, v.Name()+" "+types.TypeString(v.Type(), qf))
+		args = append(args, v.Name())
+	}
+	for _, v := range results {
+		resultTypes = append(resultTypes, types.TypeString(v.Type(), qf))
+		resultNames = append(resultNames, v.Name())
+		zeros = append(zeros, zeroValue(v.Type(), qf))
+	}
+	var returnTypes, returnZeros, returnNames []string
+	for i := 0; i < sig.Results().Len(); i++ {
+		typ := sig.Results().At(i).Type()
+		returnTypes = append(returnTypes, types.TypeString(typ, qf))
+		returnZeros = append(returnZeros, zeroValue(typ, qf))
+	}
+	shouldReturn := ""
+	body := string(content[s:e])
+	switch {
+	case alwaysReturns:
+		resultTypes = returnTypes
+	case len(returns) > 0:
+		// The names of the values that the call returns must not conflict
+		// with those of the scope of the selection, including the
+		// variables that it declares.
+		scope := pkg.Types.Scope().Innermost(start)
+		taken := make(map[string]bool)
+		for _, name := range resultNames {
+			taken[name] = true
+		}
+		shouldReturn = freshName(scope, "shouldReturn", taken)
+		for range returnTypes {
+			returnNames = append(returnNames, freshName(scope, "returnValue", taken))
+		}
+		resultTypes = append(append(resultTypes, "bool"), returnTypes...)
+		// Each return statement returns the zero values of the results,
+		// true, and the values that it returned.
+		var buf bytes.Buffer
+		last := s
+		for _, ret := range returns {
+			values := append(append([]string(nil), zeros...), "true")
+			if len(ret.Results) == 0 {
+				values = append(values, returnZeros...)
+			}
+			for _, result := range ret.Results {
+				values = append(values, string(content[tok.Offset(result.Pos()):tok.Offset(result.End())]))
+			}
+			buf.Write(content[last:tok.Offset(ret.Pos())])
+			buf.WriteString("return " + strings.Join(values, ", "))
+			last = tok.Offset(ret.End())
+		}
+		buf.Write(content[last:e])
+		body = buf.String()
+	}
+	var fn bytes.Buffer
+	fmt.Fprintf(&fn, "func %s(%s) ", name, strings.Join(paramList, ", "))
+	switch len(resultTypes) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&fn, "%s ", resultTypes[0])
+	default:
+		fmt.Fprintf(&fn, "(%s) ", strings.Join(resultTypes, ", "))
+	}
+	fn.WriteString("{\n")
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimPrefix(line, indent)
+		if line != "" {
+			fn.WriteString("\t" + line)
+		}
+		fn.WriteString("\n")
+	}
+	switch {
+	case alwaysReturns:
+	case shouldReturn != "":
+		values := append(append(append([]string(nil), resultNames...), "false"), returnZeros...)
+		fmt.Fprintf(&fn, "\treturn %s\n", strings.Join(values, ", "))
+	case len(resultNames) > 0:
+		fmt.Fprintf(&fn, "\treturn %s\n", strings.Join(resultNames, ", "))
+	}
+	fn.WriteString("}")
+	newFunc := fn.Bytes()
+	if formatted, err := format.Source(newFunc); err == nil {
+		newFunc = formatted
+	}
+
+	// Generate the call that replaces the selection.
+	call := fmt.Sprintf("%s(%s)", name, strings.Join(args, ", "))
+	lhs := resultNames
+	if shouldReturn != "" {
+		lhs = append(append(append([]string(nil), lhs...), shouldReturn), returnNames...)
+	}
+	switch {
+	case alwaysReturns && len(returnTypes) == 0:
+		call += "\n" + indent + "return"
+	case alwaysReturns:
+		call = "return " + call
+	case len(lhs) == 0:
+	case len(newResults) == len(results):
+		call = strings.Join(lhs, ", ") + " := " + call
+	default:
+		// Declare the new variables, so that the others can be assigned.
+		var decls []string
+		for _, v := range newResults {
+			decls = append(decls, fmt.Sprintf("var %s %s\n%s", v.Name(), types.TypeString(v.Type(), qf), indent))
+		}
+		if shouldReturn != "" {
+			decls = append(decls, fmt.Sprintf("var %s bool\n%s", shouldReturn, indent))
+			for i, name := range returnNames {
+				decls = append(decls, fmt.Sprintf("var %s %s\n%s", name, returnTypes[i], indent))
+			}
+		}
+		call = strings.Join(decls, "") + strings.Join(lhs, ", ") + " = " + call
+	}
+	if shouldReturn != "" {
+		ret := "return"
+		if len(returnNames) > 0 {
+			ret += " " + strings.Join(returnNames, ", ")
+		}
+		call += fmt.Sprintf("\n%sif %s {\n%s\t%s\n%s}", indent, shouldReturn, indent, ret, indent)
+	}
+	return []TextEdit{
+		{
+			Range:   Range{Start: start, End: end},
+			NewText: call,
+		},
+		{
+			Range:   Range{Start: decl.End(), End: decl.End()},
+			NewText: "\n\n" + string(newFunc),
+		},
+	}, nil
+}
+
+// selectedStmts returns the statements of a block, or of a case or comm
+// clause, that are selected by rng, ignoring the surrounding white space,
+// together with the path to the block.
+func selectedStmts(tok *token.File, content []byte, fAST *ast.File, rng Range) ([]ast.Stmt, []ast.Node, error) {
+	s, e := tok.Offset(rng.Start), tok.Offset(rng.End)
+	for s < e && isSpace(content[s]) {
+		s++
+	}
+	for e > s && isSpace(content[e-1]) {
+		e--
+	}
+	if s == e {
+		return nil, nil, fmt.Errorf("no statements selected")
+	}
+	start, end := tok.Pos(s), tok.Pos(e)
+	path, _ := astutil.PathEnclosingInterval(fAST, start, end)
+	for i, n := range path {
+		var list []ast.Stmt
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			list = n.List
+		case *ast.CaseClause:
+			list = n.Body
+		case *ast.CommClause:
+			list = n.Body
+		default:
+			continue
+		}
+		var stmts []ast.Stmt
+		for _, stmt := range list {
+			if stmt.End() <= start || end <= stmt.Pos() {
+				continue
+			}
+			if stmt.Pos() < start || end < stmt.End() {
+				return nil, nil, fmt.Errorf("the selection must consist of whole statements")
+			}
+			stmts = append(stmts, stmt)
+		}
+		if len(stmts) == 0 {
+			return nil, nil, fmt.Errorf("no statements selected")
+		}
+		return stmts, path[i:], nil
+	}
+	return nil, nil, fmt.Errorf("no statements selected")
+}
+
+// checkExtractable returns an error if the statements cannot be moved into a
+// function of their own because they transfer control outside of the
+// selection other than by returning, or because they contain a multi-line
+// string, whose contents must not be re-indented.
+func checkExtractable(stmts []ast.Stmt) error {
+	var err error
+	// loops and breakables are the number of loops, and of statements that
+	// a break applies to, that enclose n within the selection.
+	var check func(n ast.Node, loops, breakables int)
+	check = func(n ast.Node, loops, breakables int) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			if err != nil {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.DeferStmt:
+				err = fmt.Errorf("cannot extract a defer statement")
+			case *ast.BranchStmt:
+				switch {
+				case n.Label != nil:
+					err = fmt.Errorf("cannot extract a labeled %s statement", n.Tok)
+				case n.Tok == token.BREAK && breakables == 0,
+					n.Tok == token.CONTINUE && loops == 0,
+					n.Tok == token.GOTO, n.Tok == token.FALLTHROUGH:
+					err = fmt.Errorf("cannot extract a %s statement that leaves the selection", n.Tok)
+				}
+			case *ast.BasicLit:
+				if n.Kind == token.STRING && strings.Contains(n.Value, "\n") {
+					err = fmt.Errorf("cannot extract a multi-line string")
+				}
+			case *ast.ForStmt:
+				check(n.Body, loops+1, breakables+1)
+				return false
+			case *ast.RangeStmt:
+				check(n.Body, loops+1, breakables+1)
+				return false
+			case *ast.SwitchStmt:
+				check(n.Body, loops, breakables+1)
+				return false
+			case *ast.TypeSwitchStmt:
+				check(n.Body, loops, breakables+1)
+				return false
+			case *ast.SelectStmt:
+				check(n.Body, loops, breakables+1)
+				return false
+			}
+			return true
+		})
+	}
+	for _, stmt := range stmts {
+		check(stmt, 0, 0)
+	}
+	return err
+}
+
+// enclosingSignature returns the signature of the innermost function of
+// path, or nil if there is none.
+func enclosingSignature(info *types.Info, path []ast.Node) *types.Signature {
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			sig, _ := info.TypeOf(n.Name).(*types.Signature)
+			return sig
+		case *ast.FuncLit:
+			sig, _ := info.TypeOf(n).(*types.Signature)
+			return sig
+		}
+	}
+	return nil
+}
+
+// selectedReturns returns the return statements of the statements, outside
+// of function literals, in order. None may be a bare return of the named
+// results of the function of signature sig, which encloses the statements,
+// as those are not variables of the new function. If the return statements
+// are to be rewritten, each must also return the results one by one.
+func selectedReturns(sig *types.Signature, stmts []ast.Stmt, rewrite bool) ([]*ast.ReturnStmt, error) {
+	var returns []*ast.ReturnStmt
+	var err error
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.ReturnStmt:
+				switch {
+				case len(n.Results) == 0 && sig.Results().Len() > 0:
+					err = fmt.Errorf("cannot extract a return statement without the values of named results")
+				case rewrite && len(n.Results) != sig.Results().Len():
+					err = fmt.Errorf("cannot extract a return statement of a call with multiple results")
+				}
+				returns = append(returns, n)
+			}
+			return err == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return returns, nil
+}
+
+// freshName returns base, or base followed by a number, whichever is first
+// neither declared in scope or its parents nor taken, and takes it.
+func freshName(scope *types.Scope, base string, taken map[string]bool) string {
+	for i := 0; ; i++ {
+		name := base
+		if i > 0 {
+			name += fmt.Sprint(i)
+		}
+		if taken[name] {
+			continue
+		}
+		if scope != nil {
+			if _, obj := scope.LookupParent(name, token.NoPos); obj != nil {
+				continue
+			}
+		}
+		taken[name] = true
+		return name
+	}
+}
+
+// assignedIdents returns the identifiers of the variables that are assigned
+// to, rather than declared, within n, including those whose fields or array
+// elements are assigned to.
+func assignedIdents(info *types.Info, n ast.Node) map[*ast.Ident]bool {
+	ids := make(map[*ast.Ident]bool)
+	add := func(exprs ...ast.Expr) {
+		for _, e := range exprs {
+			for e != nil {
+				switch x := astutil.Unparen(e).(type) {
+				case *ast.Ident:
+					ids[x] = true
+					e = nil
+				case *ast.SelectorExpr:
+					e = nil
+					if sel, ok := info.Selections[x]; ok && sel.Kind() == types.FieldVal && !sel.Indirect() {
+						if _, ok := sel.Recv().Underlying().(*types.Pointer); !ok {
+							e = x.X
+						}
+					}
+				case *ast.IndexExpr:
+					e = nil
+					if tv, ok := info.Types[x.X]; ok {
+						if _, ok := tv.Type.Underlying().(*types.Array); ok {
+							e = x.X
+						}
+					}
+				default:
+					e = nil
+				}
+			}
+		}
+	}
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			add(n.Lhs...)
+		case *ast.IncDecStmt:
+			add(n.X)
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				add(n.Key, n.Value)
+			}
+		}
+		return true
+	})
+	return ids
+}
+
+// newFunctionName returns a name for an extracted function that does not
+// conflict with the declarations of pkg or the imports of f.
+func newFunctionName(f *ast.File, pkg *types.Package) string {
+	for i := 0; ; i++ {
+		name := "newFunction"
+		if i > 0 {
+			name += fmt.Sprint(i)
+		}
+		if pkg.Scope().Lookup(name) != nil {
+			continue
+		}
+		conflict := false
+		for _, imp := range f.Imports {
+			if imp.Name != nil && imp.Name.Name == name {
+				conflict = true
+			}
+		}
+		if !conflict {
+			return name
+		}
+	}
+}
+
+// lineIndent returns the white space at the start of the line that contains
+// offset.
+func lineIndent(content []byte, offset int) string {
+	start := offset
+	for start > 0 && content[start-1] != '\n' {
+		start--
+	}
+	end := start
+	for end < offset && (content[end] == ' ' || content[end] == '\t') {
+		end++
+	}
+	return string(content[start:end])
+}
+
+// ExtractVariable returns the fix that moves the expression selected by rng
+// into a new local variable, declared before the statement that contains it,
+// and replaces the expression with the variable. The expression becomes a
+// constant instead if its value is constant.
+func ExtractVariable(ctx context.Context, f *File, rng Range) (*SuggestedFix, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) != tok.Size() {
+		return nil, fmt.Errorf("%s has changed since it was parsed", f.URI)
+	}
+	info := pkg.TypesInfo
+
+	s, e := tok.Offset(rng.Start), tok.Offset(rng.End)
+	for s < e && isSpace(content[s]) {
+		s++
+	}
+	for e > s && isSpace(content[e-1]) {
+		e--
+	}
+	path, _ := astutil.PathEnclosingInterval(fAST, tok.Pos(s), tok.Pos(e))
+	if len(path) < 2 || path[0].Pos() != tok.Pos(s) || path[0].End() != tok.Pos(e) {
+		return nil, fmt.Errorf("the selection must be an expression")
+	}
+	expr, ok := path[0].(ast.Expr)
+	if !ok {
+		return nil, fmt.Errorf("the selection must be an expression")
+	}
+	tv, ok := info.Types[expr]
+	if !ok || !tv.IsValue() {
+		return nil, fmt.Errorf("the selection is not a value")
+	}
+	if _, ok := tv.Type.(*types.Tuple); ok {
+		return nil, fmt.Errorf("cannot extract a call with multiple results")
+	}
+	// Replace the parentheses around the expression too.
+	for len(path) > 2 {
+		if _, ok := path[1].(*ast.ParenExpr); !ok {
+			break
+		}
+		path = path[1:]
+	}
+	switch parent := path[1].(type) {
+	case *ast.AssignStmt:
+		for _, lhs := range parent.Lhs {
+			if lhs == path[0] {
+				return nil, fmt.Errorf("cannot extract the left-hand side of an assignment")
+			}
+		}
+	case *ast.IncDecStmt:
+		return nil, fmt.Errorf("cannot extract the operand of an increment or decrement")
+	case *ast.UnaryExpr:
+		if parent.Op == token.AND {
+			return nil, fmt.Errorf("cannot extract the operand of an address operation")
+		}
+	case *ast.SelectorExpr:
+		if parent.Sel == path[0] {
+			return nil, fmt.Errorf("cannot extract a selector")
+		}
+	case *ast.KeyValueExpr:
+		if parent.Key == path[0] {
+			return nil, fmt.Errorf("cannot extract the key of a composite literal element")
+		}
+	}
+
+	// Find the statement to declare the variable before: the one that
+	// contains the expression in a list of statements.
+	var stmt ast.Stmt
+	for i := 1; i < len(path) && stmt == nil; i++ {
+		child := path[i-1]
+		switch n := path[i].(type) {
+		case *ast.BlockStmt:
+			stmt, _ = child.(ast.Stmt)
+		case *ast.CaseClause:
+			if stmt, ok = child.(ast.Stmt); !ok {
+				return nil, fmt.Errorf("cannot extract the expression of a case clause")
+			}
+		case *ast.CommClause:
+			if child == n.Comm {
+				return nil, fmt.Errorf("cannot extract the communication of a select case")
+			}
+			stmt, _ = child.(ast.Stmt)
+		case *ast.ForStmt:
+			if child == n.Cond || child == n.Post {
+				return nil, fmt.Errorf("cannot extract an expression that is evaluated on each iteration")
+			}
+		case *ast.IfStmt:
+			if child == n.Else {
+				return nil, fmt.Errorf("cannot extract an expression of an else branch")
+			}
+		case *ast.FuncDecl, *ast.GenDecl:
+			return nil, fmt.Errorf("the selection is not within a function body")
+		}
+	}
+	if stmt == nil {
+		return nil, fmt.Errorf("the selection is not within a function body")
+	}
+	// The expression must not refer to variables declared by the statement,
+	// such as in the initialization of an if statement.
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if obj := info.Uses[id]; obj != nil && stmt.Pos() <= obj.Pos() && obj.Pos() < expr.Pos() {
+				err = fmt.Errorf("cannot extract an expression that uses %s, which is declared by the enclosing statement", id.Name)
+			}
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	name := newVariableName(info, path)
+	indent := lineIndent(content, tok.Offset(stmt.Pos()))
+	text := string(content[s:e])
+	fix := &SuggestedFix{Title: "Extract to variable"}
+	decl := fmt.Sprintf("%s := %s\n%s", name, text, indent)
+	if tv.Value != nil {
+		fix.Title = "Extract to constant"
+		decl = fmt.Sprintf("const %s = %s\n%s", name, text, indent)
+	}
+	fix.Edits = []TextEdit{
+		{
+			Range:   Range{Start: stmt.Pos(), End: stmt.Pos()},
+			NewText: decl,
+		},
+		{
+			Range:   Range{Start: path[0].Pos(), End: path[0].End()},
+			NewText: name,
+		},
+	}
+	return fix, nil
+}
+
+// newVariableName returns a name for an extracted variable that is not used
+// by any identifier in the enclosing function, so that it neither conflicts
+// with nor shadows another declaration, and that does not refer to anything
+// in the scope of path[0].
+func newVariableName(info *types.Info, path []ast.Node) string {
+	used := make(map[string]bool)
+	for _, n := range path {
+		if _, ok := n.(*ast.FuncDecl); ok {
+			ast.Inspect(n, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					used[id.Name] = true
+				}
+				return true
+			})
+			break
+		}
+	}
+	var scope *types.Scope
+	for _, n := range path {
+		if scope = info.Scopes[n]; scope != nil {
+			break
+		}
+	}
+	for i := 0; ; i++ {
+		name := "x"
+		if i > 0 {
+			name += fmt.Sprint(i)
+		}
+		if used[name] {
+			continue
+		}
+		if scope != nil {
+			if _, obj := scope.LookupParent(name, token.NoPos); obj != nil {
+				continue
+			}
+		}
+		return name
+	}
+}
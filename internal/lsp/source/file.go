@@ -5,6 +5,7 @@
 package source
 
 import (
+	"context"
 	"fmt"
 	"go/ast"
 	"go/token"
@@ -17,11 +18,11 @@ import (
 type File struct {
 	URI     URI
 	view    *View
-	active  bool
 	content []byte
 	ast     *ast.File
 	token   *token.File
 	pkg     *packages.Package
+	mod     *modFile // the parsed content of a go.mod file
 }
 
 // Range represents a start and end position.
@@ -40,33 +41,11 @@ type TextEdit struct {
 	NewText string
 }
 
-// SetContent sets the overlay contents for a file.
-// Setting it to nil will revert it to the on disk contents, and remove it
-// from the active set.
+// SetContent sets the content of the file as it is open in the editor,
+// which overlays the content on disk in all the views of the session.
+// Setting it to nil reverts it to the on disk contents.
 func (f *File) SetContent(content []byte) {
-	f.view.mu.Lock()
-	defer f.view.mu.Unlock()
-	f.content = content
-	// the ast and token fields are invalid
-	f.ast = nil
-	f.token = nil
-	f.pkg = nil
-	// and we might need to update the overlay
-	switch {
-	case f.active && content == nil:
-		// we were active, and want to forget the content
-		f.active = false
-		if filename, err := f.URI.Filename(); err == nil {
-			delete(f.view.Config.Overlay, filename)
-		}
-		f.content = nil
-	case content != nil:
-		// an active overlay, update the map
-		f.active = true
-		if filename, err := f.URI.Filename(); err == nil {
-			f.view.Config.Overlay[filename] = f.content
-		}
-	}
+	f.view.session.SetOverlay(f.URI, content)
 }
 
 // Read returns the contents of the file, reading it from file system if needed.
@@ -76,11 +55,22 @@ func (f *File) Read() ([]byte, error) {
 	return f.read()
 }
 
-func (f *File) GetToken() (*token.File, error) {
+func (f *File) GetToken(ctx context.Context) (*token.File, error) {
+	f.view.mu.RLock()
+	tok := f.token
+	f.view.mu.RUnlock()
+	if tok != nil {
+		return tok, nil
+	}
 	f.view.mu.Lock()
 	defer f.view.mu.Unlock()
+	if f.token == nil && IsModFile(f.URI) {
+		if err := f.view.parseMod(f); err != nil {
+			return nil, err
+		}
+	}
 	if f.token == nil {
-		if err := f.view.parse(f.URI); err != nil {
+		if err := f.view.parse(ctx, f.URI); err != nil {
 			return nil, err
 		}
 		if f.token == nil {
@@ -90,24 +80,39 @@ func (f *File) GetToken() (*token.File, error) {
 	return f.token, nil
 }
 
-func (f *File) GetAST() (*ast.File, error) {
+func (f *File) GetAST(ctx context.Context) (*ast.File, error) {
+	f.view.mu.RLock()
+	fAST := f.ast
+	f.view.mu.RUnlock()
+	if fAST != nil {
+		return fAST, nil
+	}
 	f.view.mu.Lock()
 	defer f.view.mu.Unlock()
 	if f.ast == nil {
-		if err := f.view.parse(f.URI); err != nil {
+		if err := f.view.parse(ctx, f.URI); err != nil {
 			return nil, err
 		}
 	}
 	return f.ast, nil
 }
 
-func (f *File) GetPackage() (*packages.Package, error) {
+func (f *File) GetPackage(ctx context.Context) (*packages.Package, error) {
+	f.view.mu.RLock()
+	pkg := f.pkg
+	f.view.mu.RUnlock()
+	if pkg != nil {
+		return pkg, nil
+	}
 	f.view.mu.Lock()
 	defer f.view.mu.Unlock()
 	if f.pkg == nil {
-		if err := f.view.parse(f.URI); err != nil {
+		if err := f.view.parse(ctx, f.URI); err != nil {
 			return nil, err
 		}
+		if f.pkg == nil {
+			return nil, fmt.Errorf("no package found for %v", f.URI)
+		}
 	}
 	return f.pkg, nil
 }
@@ -117,6 +122,10 @@ func (f *File) read() ([]byte, error) {
 	if f.content != nil {
 		return f.content, nil
 	}
+	if content, ok := f.view.session.overlay(f.URI); ok {
+		f.content = content
+		return f.content, nil
+	}
 	// we don't know the content yet, so read it
 	filename, err := f.URI.Filename()
 	if err != nil {
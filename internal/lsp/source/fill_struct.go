@@ -0,0 +1,148 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// FillStruct returns the fix that adds the fields that are missing from the
+// struct literal that encloses rng, set to their zero values, in the order in
+// which the struct type declares them.
+// Only fields that may be set from the package of f are added.
+func FillStruct(ctx context.Context, f *File, rng Range) (*SuggestedFix, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) != tok.Size() {
+		return nil, fmt.Errorf("%s has changed since it was parsed", f.URI)
+	}
+	path, _ := astutil.PathEnclosingInterval(fAST, rng.Start, rng.End)
+	var lit *ast.CompositeLit
+	for _, n := range path {
+		if n, ok := n.(*ast.CompositeLit); ok && n.Lbrace < rng.Start && rng.End <= n.Rbrace {
+			lit = n
+			break
+		}
+	}
+	if lit == nil {
+		return nil, fmt.Errorf("no composite literal at the selection")
+	}
+	tv, ok := pkg.TypesInfo.Types[lit]
+	if !ok {
+		return nil, fmt.Errorf("no type information for the composite literal")
+	}
+	typ := deref(tv.Type)
+	st, ok := typ.Underlying().(*types.Struct)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a struct type", typ)
+	}
+	set := make(map[string]bool)
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, fmt.Errorf("cannot fill a struct literal without field names")
+		}
+		if id, ok := kv.Key.(*ast.Ident); ok {
+			set[id.Name] = true
+		}
+	}
+	qf := packageQualifier(pkg.Types)
+	var fields []string
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		if set[field.Name()] || field.Name() == "_" {
+			continue
+		}
+		if !field.Exported() && field.Pkg() != pkg.Types {
+			continue
+		}
+		fields = append(fields, fmt.Sprintf("%s: %s,", field.Name(), zeroValue(field.Type(), qf)))
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("the struct literal has no missing fields")
+	}
+
+	// Write one field per line.
+	name := "struct"
+	if named, ok := typ.(*types.Named); ok {
+		name = named.Obj().Name()
+	}
+	fix := &SuggestedFix{Title: fmt.Sprintf("Fill %s", name)}
+	indent := lineIndent(content, tok.Offset(lit.Lbrace))
+	var buf bytes.Buffer
+	if tok.Line(lit.Lbrace) == tok.Line(lit.Rbrace) {
+		// Move the existing elements of a literal written on a single line
+		// to lines of their own too.
+		buf.WriteString("\n")
+		for _, elt := range lit.Elts {
+			fmt.Fprintf(&buf, "%s\t%s,\n", indent, content[tok.Offset(elt.Pos()):tok.Offset(elt.End())])
+		}
+		for _, field := range fields {
+			fmt.Fprintf(&buf, "%s\t%s\n", indent, field)
+		}
+		buf.WriteString(indent)
+		fix.Edits = []TextEdit{{
+			Range:   Range{Start: lit.Lbrace + 1, End: lit.Rbrace},
+			NewText: buf.String(),
+		}}
+		return fix, nil
+	}
+	if len(lit.Elts) == 0 || tok.Line(lit.Elts[len(lit.Elts)-1].End()) < tok.Line(lit.Rbrace) {
+		// Insert the fields at the start of the line of the closing brace.
+		eltIndent := indent + "\t"
+		if len(lit.Elts) > 0 {
+			eltIndent = lineIndent(content, tok.Offset(lit.Elts[len(lit.Elts)-1].Pos()))
+		}
+		for _, field := range fields {
+			fmt.Fprintf(&buf, "%s%s\n", eltIndent, field)
+		}
+		lineStart := tok.Offset(lit.Rbrace)
+		for lineStart > 0 && content[lineStart-1] != '\n' {
+			lineStart--
+		}
+		fix.Edits = []TextEdit{{
+			Range:   Range{Start: tok.Pos(lineStart), End: tok.Pos(lineStart)},
+			NewText: buf.String(),
+		}}
+		return fix, nil
+	}
+	// The closing brace follows the last element, so move it to a line of
+	// its own.
+	last := lit.Elts[len(lit.Elts)-1]
+	start := tok.Offset(last.End())
+	if content[start] == ',' {
+		start++
+	}
+	buf.WriteString(",\n")
+	for _, field := range fields {
+		fmt.Fprintf(&buf, "%s\t%s\n", indent, field)
+	}
+	buf.WriteString(indent)
+	fix.Edits = []TextEdit{{
+		Range:   Range{Start: tok.Pos(start), End: lit.Rbrace},
+		NewText: buf.String(),
+	}}
+	return fix, nil
+}
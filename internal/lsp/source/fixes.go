@@ -0,0 +1,470 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// SuggestedFix is a machine-applicable change that resolves a diagnostic.
+type SuggestedFix struct {
+	Title string
+	Edits []TextEdit
+}
+
+// suggestedFixes returns the fixes for a type error reported at pos in the
+// given file. The messages of the type checker change between releases, so
+// the error is recognized by the syntax and the objects at its position
+// instead: an unused variable or import, the closing brace of a function
+// that lacks a return statement, or a value of a type that lacks methods of
+// the interface it is used as.
+func (v *View) suggestedFixes(ctx context.Context, filename string, pos token.Pos) []SuggestedFix {
+	f := v.GetFile(ToURI(filename))
+	fAST, err := f.GetAST(ctx)
+	if err != nil || fAST == nil {
+		return nil
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil || pkg == nil || pkg.TypesInfo == nil {
+		return nil
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil
+	}
+	content, err := f.Read()
+	if err != nil || len(content) != tok.Size() {
+		return nil
+	}
+	path, _ := astutil.PathEnclosingInterval(fAST, pos, pos)
+	if len(path) == 0 {
+		return nil
+	}
+	info := pkg.TypesInfo
+	var fix *SuggestedFix
+	if id, ok := path[0].(*ast.Ident); ok && isUnusedVar(info, id) {
+		fix = removeUnusedVar(tok, content, info, path)
+	} else if spec := enclosingImportSpec(path); spec != nil && isUnusedImport(info, spec) {
+		fix = removeUnusedImport(tok, content, path, spec.Path.Value)
+	} else if isMissingReturn(info, path, pos) {
+		fix = addMissingReturn(tok, content, pkg, path)
+	} else if typ, iface := unimplementedInterface(info, path, pos); typ != nil {
+		fix = v.addMissingMethods(pkg, typ, iface)
+	}
+	if fix == nil {
+		return nil
+	}
+	return []SuggestedFix{*fix}
+}
+
+// isUnusedVar reports whether id declares a local variable that is never
+// used. A variable that is only assigned to is not recognized.
+func isUnusedVar(info *types.Info, id *ast.Ident) bool {
+	obj, ok := info.Defs[id].(*types.Var)
+	if !ok || obj.IsField() || obj.Pkg() == nil || obj.Parent() == obj.Pkg().Scope() {
+		return false
+	}
+	for _, use := range info.Uses {
+		if use == obj {
+			return false
+		}
+	}
+	return true
+}
+
+// enclosingImportSpec returns the import spec of path, if any.
+func enclosingImportSpec(path []ast.Node) *ast.ImportSpec {
+	for _, n := range path {
+		if spec, ok := n.(*ast.ImportSpec); ok {
+			return spec
+		}
+	}
+	return nil
+}
+
+// isUnusedImport reports whether the package that spec imports is never
+// used. Blank and dot imports are never unused.
+func isUnusedImport(info *types.Info, spec *ast.ImportSpec) bool {
+	var obj types.Object
+	if spec.Name != nil {
+		if spec.Name.Name == "_" || spec.Name.Name == "." {
+			return false
+		}
+		obj = info.Defs[spec.Name]
+	} else {
+		obj = info.Implicits[spec]
+	}
+	pkgName, ok := obj.(*types.PkgName)
+	if !ok {
+		return false
+	}
+	for _, use := range info.Uses {
+		if use == pkgName {
+			return false
+		}
+	}
+	return true
+}
+
+// removeUnusedVar removes the declaration of the unused variable at path[0].
+// If the declaration also has a value with side effects, the variable is
+// replaced by the blank identifier instead.
+func removeUnusedVar(tok *token.File, content []byte, info *types.Info, path []ast.Node) *SuggestedFix {
+	id, ok := path[0].(*ast.Ident)
+	if !ok || len(path) < 2 {
+		return nil
+	}
+	fix := &SuggestedFix{Title: fmt.Sprintf("Remove unused variable %s", id.Name)}
+	blank := TextEdit{Range: Range{Start: id.Pos(), End: id.End()}, NewText: "_"}
+	switch n := path[1].(type) {
+	case *ast.AssignStmt:
+		if n.Tok != token.DEFINE {
+			return nil
+		}
+		if len(n.Lhs) == 1 {
+			if hasSideEffects(n.Rhs...) {
+				fix.Edits = []TextEdit{{
+					Range:   Range{Start: id.Pos(), End: n.TokPos + token.Pos(len(n.Tok.String()))},
+					NewText: "_ =",
+				}}
+			} else {
+				fix.Edits = []TextEdit{{Range: lineRange(tok, content, n.Pos(), n.End())}}
+			}
+			return fix
+		}
+		fix.Edits = []TextEdit{blank}
+		// Without any other new variable, the statement must become an
+		// assignment.
+		for _, lhs := range n.Lhs {
+			if lhs, ok := lhs.(*ast.Ident); ok && lhs != id && info.Defs[lhs] != nil {
+				return fix
+			}
+		}
+		fix.Edits = append(fix.Edits, TextEdit{
+			Range:   Range{Start: n.TokPos, End: n.TokPos + token.Pos(len(n.Tok.String()))},
+			NewText: "=",
+		})
+		return fix
+	case *ast.ValueSpec:
+		if len(path) < 4 {
+			return nil
+		}
+		decl, ok := path[2].(*ast.GenDecl)
+		if !ok {
+			return nil
+		}
+		stmt, ok := path[3].(*ast.DeclStmt)
+		if ok && len(n.Names) == 1 && len(decl.Specs) == 1 && !hasSideEffects(n.Values...) {
+			fix.Edits = []TextEdit{{Range: lineRange(tok, content, stmt.Pos(), stmt.End())}}
+			return fix
+		}
+		fix.Edits = []TextEdit{blank}
+		return fix
+	case *ast.RangeStmt:
+		other := n.Value
+		if n.Value == id {
+			other = n.Key
+		}
+		switch {
+		case other == nil || isBlank(other):
+			// for k, v := range x -> for range x
+			fix.Edits = []TextEdit{{Range: Range{Start: n.Key.Pos(), End: n.X.Pos()}}}
+		case n.Value == id:
+			// for k, v := range x -> for k := range x
+			fix.Edits = []TextEdit{{Range: Range{Start: n.Key.End(), End: n.Value.End()}}}
+		default:
+			fix.Edits = []TextEdit{blank}
+		}
+		return fix
+	}
+	return nil
+}
+
+// removeUnusedImport removes the unused import at path[0].
+func removeUnusedImport(tok *token.File, content []byte, path []ast.Node, importPath string) *SuggestedFix {
+	var spec *ast.ImportSpec
+	var decl *ast.GenDecl
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.ImportSpec:
+			spec = n
+		case *ast.GenDecl:
+			decl = n
+		}
+	}
+	if spec == nil || decl == nil {
+		return nil
+	}
+	var rng Range
+	if len(decl.Specs) == 1 {
+		rng = lineRange(tok, content, decl.Pos(), decl.End())
+	} else {
+		rng = lineRange(tok, content, spec.Pos(), spec.End())
+	}
+	return &SuggestedFix{
+		Title: fmt.Sprintf("Remove unused import %s", importPath),
+		Edits: []TextEdit{{Range: rng}},
+	}
+}
+
+// isMissingReturn reports whether pos is the closing brace of the body of
+// the innermost function of path, which has results, where the type checker
+// reports a missing return statement.
+func isMissingReturn(info *types.Info, path []ast.Node, pos token.Pos) bool {
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			sig, ok := info.TypeOf(n.Name).(*types.Signature)
+			return ok && n.Body != nil && n.Body.Rbrace == pos && sig.Results().Len() > 0
+		case *ast.FuncLit:
+			sig, ok := info.TypeOf(n).(*types.Signature)
+			return ok && n.Body.Rbrace == pos && sig.Results().Len() > 0
+		}
+	}
+	return false
+}
+
+// addMissingReturn adds a return statement, returning the zero values of
+// the result types, at the end of the function whose body ends at path[0].
+func addMissingReturn(tok *token.File, content []byte, pkg *packages.Package, path []ast.Node) *SuggestedFix {
+	var sig *types.Signature
+	var body *ast.BlockStmt
+	for _, n := range path {
+		if decl, ok := n.(*ast.FuncDecl); ok {
+			if fn, ok := pkg.TypesInfo.Defs[decl.Name].(*types.Func); ok {
+				sig = fn.Type().(*types.Signature)
+			}
+			body = decl.Body
+			break
+		}
+		if lit, ok := n.(*ast.FuncLit); ok {
+			if tv, ok := pkg.TypesInfo.Types[lit]; ok {
+				sig, _ = tv.Type.(*types.Signature)
+			}
+			body = lit.Body
+			break
+		}
+	}
+	if sig == nil || body == nil {
+		return nil
+	}
+	ret := "return"
+	if results := sig.Results(); results.Len() > 0 && results.At(0).Name() == "" {
+		qf := packageQualifier(pkg.Types)
+		zeros := make([]string, results.Len())
+		for i := range zeros {
+			zeros[i] = zeroValue(results.At(i).Type(), qf)
+		}
+		ret += " " + strings.Join(zeros, ", ")
+	}
+	// If the closing brace is on its own line, add the statement on a line
+	// before it, with the indentation of the body.
+	edit := TextEdit{
+		Range:   Range{Start: body.Rbrace, End: body.Rbrace},
+		NewText: "; " + ret + " ",
+	}
+	rbrace := tok.Offset(body.Rbrace)
+	lineOffset := rbrace
+	for lineOffset > 0 && (content[lineOffset-1] == ' ' || content[lineOffset-1] == '\t') {
+		lineOffset--
+	}
+	if lineOffset == 0 || content[lineOffset-1] == '\n' {
+		indent := string(content[lineOffset:rbrace])
+		start := tok.Pos(lineOffset)
+		edit = TextEdit{
+			Range:   Range{Start: start, End: start},
+			NewText: indent + "\t" + ret + "\n",
+		}
+	}
+	return &SuggestedFix{
+		Title: "Add missing return",
+		Edits: []TextEdit{edit},
+	}
+}
+
+// unimplementedInterface returns the type of the expression at pos in path,
+// and the interface that it is used as, if the type lacks methods of the
+// interface: the operand of an assignment, a call, a conversion, or a return
+// statement, where the type checker reports the error.
+func unimplementedInterface(info *types.Info, path []ast.Node, pos token.Pos) (typ, iface types.Type) {
+	// The operand is the outermost expression that starts at pos.
+	i := -1
+	for j, n := range path {
+		if _, ok := n.(ast.Expr); !ok || n.Pos() != pos {
+			break
+		}
+		i = j
+	}
+	if i < 0 || i+1 >= len(path) {
+		return nil, nil
+	}
+	expr := path[i].(ast.Expr)
+	var want types.Type
+	switch parent := path[i+1].(type) {
+	case *ast.AssignStmt:
+		if len(parent.Lhs) == len(parent.Rhs) {
+			if j := exprIndex(parent.Rhs, expr); j >= 0 {
+				want = info.TypeOf(parent.Lhs[j])
+			}
+		}
+	case *ast.ValueSpec:
+		if parent.Type != nil && exprIndex(parent.Values, expr) >= 0 {
+			want = info.TypeOf(parent.Type)
+		}
+	case *ast.CallExpr:
+		j := exprIndex(parent.Args, expr)
+		if j < 0 {
+			break
+		}
+		if tv, ok := info.Types[parent.Fun]; ok && tv.IsType() {
+			want = tv.Type
+		} else if sig, ok := info.TypeOf(parent.Fun).(*types.Signature); ok {
+			want = paramType(sig, j)
+		}
+	case *ast.ReturnStmt:
+		j := exprIndex(parent.Results, expr)
+		if j < 0 {
+			break
+		}
+		for _, n := range path[i+1:] {
+			var sig *types.Signature
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				sig, _ = info.TypeOf(n.Name).(*types.Signature)
+			case *ast.FuncLit:
+				sig, _ = info.TypeOf(n).(*types.Signature)
+			default:
+				continue
+			}
+			if sig != nil && j < sig.Results().Len() {
+				want = sig.Results().At(j).Type()
+			}
+			break
+		}
+	}
+	if want == nil {
+		return nil, nil
+	}
+	if _, ok := want.Underlying().(*types.Interface); !ok {
+		return nil, nil
+	}
+	typ = info.TypeOf(expr)
+	if typ == nil || types.AssignableTo(typ, want) {
+		return nil, nil
+	}
+	return typ, want
+}
+
+// exprIndex returns the index of expr in exprs, or -1.
+func exprIndex(exprs []ast.Expr, expr ast.Expr) int {
+	for i, e := range exprs {
+		if e == expr {
+			return i
+		}
+	}
+	return -1
+}
+
+// paramType returns the type of the parameter of sig that the argument at
+// index i is passed as, or nil if there is none.
+func paramType(sig *types.Signature, i int) types.Type {
+	params := sig.Params()
+	if sig.Variadic() && i >= params.Len()-1 {
+		if s, ok := params.At(params.Len() - 1).Type().(*types.Slice); ok {
+			return s.Elem()
+		}
+		return nil
+	}
+	if i >= params.Len() {
+		return nil
+	}
+	return params.At(i).Type()
+}
+
+// zeroValue returns the Go expression for the zero value of typ.
+func zeroValue(typ types.Type, qf types.Qualifier) string {
+	switch u := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsNumeric != 0:
+			return "0"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		}
+		return "nil"
+	case *types.Struct, *types.Array:
+		return types.TypeString(typ, qf) + "{}"
+	}
+	return "nil"
+}
+
+// packageQualifier qualifies the types of packages other than pkg by the
+// names of the packages.
+func packageQualifier(pkg *types.Package) types.Qualifier {
+	return func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		return p.Name()
+	}
+}
+
+func isBlank(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+// hasSideEffects reports whether evaluating any of the expressions may have
+// side effects, so that they cannot be removed.
+func hasSideEffects(exprs ...ast.Expr) bool {
+	for _, expr := range exprs {
+		found := false
+		ast.Inspect(expr, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				found = true
+			case *ast.UnaryExpr:
+				if n.Op == token.ARROW {
+					found = true
+				}
+			}
+			return !found
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// lineRange returns the range from start to end, extended to whole lines if
+// nothing but whitespace surrounds it on its first and last lines, so that
+// deleting it does not leave an empty line behind.
+func lineRange(tok *token.File, content []byte, start, end token.Pos) Range {
+	s, e := tok.Offset(start), tok.Offset(end)
+	for s > 0 && (content[s-1] == ' ' || content[s-1] == '\t') {
+		s--
+	}
+	for e < len(content) && (content[e] == ' ' || content[e] == '\t') {
+		e++
+	}
+	if (s > 0 && content[s-1] != '\n') || (e < len(content) && content[e] != '\n') {
+		return Range{Start: start, End: end}
+	}
+	if e < len(content) {
+		e++ // the newline
+	}
+	return Range{Start: tok.Pos(s), End: tok.Pos(e)}
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"sort"
+)
+
+type FoldingRangeKind int
+
+const (
+	CodeFolding FoldingRangeKind = iota
+	CommentFolding
+	ImportsFolding
+)
+
+// FoldingRange is a range of a file that can be collapsed.
+type FoldingRange struct {
+	Range Range
+	Kind  FoldingRangeKind
+}
+
+// FoldingRanges returns the ranges of f that span several lines and can be
+// folded: the contents of blocks, case clauses, composite literals, struct
+// and interface types and parenthesized declarations, and comments.
+func FoldingRanges(ctx context.Context, f *File) ([]FoldingRange, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ranges []FoldingRange
+	add := func(start, end token.Pos, kind FoldingRangeKind) {
+		if !start.IsValid() || !end.IsValid() || tok.Line(start) >= tok.Line(end) {
+			return
+		}
+		ranges = append(ranges, FoldingRange{
+			Range: Range{Start: start, End: end},
+			Kind:  kind,
+		})
+	}
+	ast.Inspect(fAST, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			add(n.Lbrace+1, n.Rbrace, CodeFolding)
+		case *ast.CaseClause:
+			add(n.Colon+1, n.End(), CodeFolding)
+		case *ast.CommClause:
+			add(n.Colon+1, n.End(), CodeFolding)
+		case *ast.CompositeLit:
+			add(n.Lbrace+1, n.Rbrace, CodeFolding)
+		case *ast.FieldList:
+			// Struct and interface types, and parameter and result lists.
+			add(n.Opening+1, n.Closing, CodeFolding)
+		case *ast.CallExpr:
+			add(n.Lparen+1, n.Rparen, CodeFolding)
+		case *ast.GenDecl:
+			if !n.Lparen.IsValid() {
+				break
+			}
+			kind := CodeFolding
+			if n.Tok == token.IMPORT {
+				kind = ImportsFolding
+			}
+			add(n.Lparen+1, n.Rparen, kind)
+		}
+		return true
+	})
+	for _, c := range fAST.Comments {
+		add(c.Pos(), c.End(), CommentFolding)
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].Range.Start != ranges[j].Range.Start {
+			return ranges[i].Range.Start < ranges[j].Range.Start
+		}
+		return ranges[i].Range.End > ranges[j].Range.End
+	})
+	return ranges, nil
+}
@@ -10,16 +10,33 @@ import (
 	"fmt"
 	"go/ast"
 	"go/format"
+	"go/printer"
+	"go/token"
+	"strings"
 
 	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/diff"
 )
 
 // Format formats a document with a given range.
+// If the range is not the whole document, the statements or declarations
+// that overlap it are formatted.
+// A go.mod file is always formatted as a whole.
 func Format(ctx context.Context, f *File, rng Range) ([]TextEdit, error) {
-	fAST, err := f.GetAST()
+	if IsModFile(f.URI) {
+		return formatMod(ctx, f)
+	}
+	fAST, err := f.GetAST(ctx)
 	if err != nil {
 		return nil, err
 	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rng.Start != tok.Pos(0) || rng.End != tok.Pos(tok.Size()) {
+		return formatRange(f, fAST, tok, rng)
+	}
 	path, exact := astutil.PathEnclosingInterval(fAST, rng.Start, rng.End)
 	if !exact || len(path) == 0 {
 		return nil, fmt.Errorf("no exact AST node matching the specified range")
@@ -49,11 +66,246 @@ func Format(ctx context.Context, f *File, rng Range) ([]TextEdit, error) {
 	if err := format.Node(buf, f.view.Config.Fset, node); err != nil {
 		return nil, err
 	}
-	// TODO(rstambler): Compute text edits instead of replacing whole file.
+	content, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) != tok.Size() {
+		return nil, fmt.Errorf("%s has changed since it was parsed", f.URI)
+	}
+	return computeTextEdits(tok, string(content), buf.String()), nil
+}
+
+// computeTextEdits returns the edits that transform before, the content of
+// tok, into after. Each edit replaces whole lines, and only the lines that
+// differ are replaced.
+func computeTextEdits(tok *token.File, before, after string) []TextEdit {
+	a, b := diff.SplitLines(before), diff.SplitLines(after)
+	// lineOffsets[i] is the offset of the start of line i of before.
+	lineOffsets := make([]int, len(a)+1)
+	for i, line := range a {
+		lineOffsets[i+1] = lineOffsets[i] + len(line)
+	}
+	var edits []TextEdit
+	ops := diff.Operations(a, b)
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
+		var start, end int
+		var text string
+		switch op.Kind {
+		case diff.Equal:
+			continue
+		case diff.Delete:
+			start, end = lineOffsets[op.I1], lineOffsets[op.I2]
+			// A deletion followed by an insertion is a replacement.
+			if i+1 < len(ops) && ops[i+1].Kind == diff.Insert {
+				i++
+				text = strings.Join(ops[i].Content, "")
+			}
+		case diff.Insert:
+			start, end = lineOffsets[op.I1], lineOffsets[op.I1]
+			text = strings.Join(op.Content, "")
+		}
+		// The end of a file that ends with a newline is reported as a
+		// position past the end of its last line, rather than as the start
+		// of a new line, so avoid it by moving the edit back over the newline
+		// that precedes it.
+		if end == len(before) && start > 0 && before[start-1] == '\n' &&
+			strings.HasSuffix(before, "\n") && (text == "" || strings.HasSuffix(text, "\n")) {
+			start, end = start-1, end-1
+			if text != "" {
+				text = "\n" + text[:len(text)-1]
+			}
+		}
+		edits = append(edits, TextEdit{
+			Range:   Range{Start: tok.Pos(start), End: tok.Pos(end)},
+			NewText: text,
+		})
+	}
+	return edits
+}
+
+// formatRange formats the statements or declarations that overlap rng,
+// returning an edit for each of them that is not formatted already.
+func formatRange(f *File, fAST *ast.File, tok *token.File, rng Range) ([]TextEdit, error) {
+	content, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) != tok.Size() {
+		return nil, fmt.Errorf("%s has changed since it was parsed", f.URI)
+	}
+	path, _ := astutil.PathEnclosingInterval(fAST, rng.Start, rng.End)
+	var nodes []ast.Node
+	for _, n := range path {
+		nodes = overlappingNodes(n, rng)
+		if nodes != nil {
+			break
+		}
+		// Format the smallest statement or declaration enclosing the range.
+		if _, ok := n.(*ast.BlockStmt); ok {
+			continue
+		}
+		_, isStmt := n.(ast.Stmt)
+		_, isDecl := n.(ast.Decl)
+		if isStmt || isDecl {
+			nodes = []ast.Node{n}
+			break
+		}
+	}
+	var edits []TextEdit
+	for _, n := range nodes {
+		formatted, ok := formatNode(f.view.Config.Fset, fAST, content, n)
+		if !ok {
+			return nil, fmt.Errorf("unable to format the selection: it has syntax errors or multi-line raw strings")
+		}
+		if formatted == string(content[tok.Offset(n.Pos()):tok.Offset(n.End())]) {
+			continue
+		}
+		edits = append(edits, TextEdit{
+			Range:   Range{Start: n.Pos(), End: n.End()},
+			NewText: formatted,
+		})
+	}
+	return edits, nil
+}
+
+// overlappingNodes returns the statements or declarations in the list of n
+// that overlap rng, if n is a block, clause or file.
+func overlappingNodes(n ast.Node, rng Range) []ast.Node {
+	var list []ast.Node
+	switch n := n.(type) {
+	case *ast.BlockStmt:
+		for _, stmt := range n.List {
+			list = append(list, stmt)
+		}
+	case *ast.CaseClause:
+		for _, stmt := range n.Body {
+			list = append(list, stmt)
+		}
+	case *ast.CommClause:
+		for _, stmt := range n.Body {
+			list = append(list, stmt)
+		}
+	case *ast.File:
+		for _, decl := range n.Decls {
+			list = append(list, decl)
+		}
+	}
+	var nodes []ast.Node
+	for _, node := range list {
+		if node.Pos() < rng.End && rng.Start < node.End() {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// FormatOnType formats the statement or declaration that was just completed
+// by typing ch, which ends at pos: a "}" closing a block or composite literal,
+// or a newline ending a line.
+// It returns no edits if the code is already formatted or cannot be parsed.
+func FormatOnType(ctx context.Context, f *File, pos token.Pos, ch string) ([]TextEdit, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) != tok.Size() {
+		return nil, nil // the AST is out of date
+	}
+	// Find the last character of the code that was just completed.
+	offset := tok.Offset(pos) - 1
+	if ch == "\n" {
+		for offset > 0 && isSpace(content[offset]) {
+			offset--
+		}
+	}
+	if offset < 0 {
+		return nil, nil
+	}
+	target := tok.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(fAST, target, target)
+	var node ast.Node
+	for _, n := range path {
+		if _, ok := n.(*ast.BlockStmt); ok {
+			continue
+		}
+		_, isStmt := n.(ast.Stmt)
+		_, isDecl := n.(ast.Decl)
+		if isStmt || isDecl {
+			node = n
+			break
+		}
+	}
+	if node == nil {
+		return nil, nil
+	}
+	formatted, ok := formatNode(f.view.Config.Fset, fAST, content, node)
+	if !ok {
+		return nil, nil
+	}
+	start, end := tok.Offset(node.Pos()), tok.Offset(node.End())
+	if formatted == string(content[start:end]) {
+		return nil, nil
+	}
 	return []TextEdit{
 		{
-			Range:   rng,
-			NewText: buf.String(),
+			Range:   Range{Start: node.Pos(), End: node.End()},
+			NewText: formatted,
 		},
 	}, nil
 }
+
+// formatNode formats node, including the comments within it, indented like
+// the line it starts on in content. It reports false if the node cannot be
+// formatted on its own: if it contains syntax errors or a multi-line raw
+// string, whose contents must not be re-indented.
+func formatNode(fset *token.FileSet, fAST *ast.File, content []byte, node ast.Node) (string, bool) {
+	ok := true
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BadDecl, *ast.BadExpr, *ast.BadStmt:
+			ok = false
+		case *ast.BasicLit:
+			if n.Kind == token.STRING && strings.Contains(n.Value, "\n") {
+				ok = false
+			}
+		}
+		return ok
+	})
+	if !ok {
+		return "", false
+	}
+	var comments []*ast.CommentGroup
+	for _, c := range fAST.Comments {
+		if node.Pos() <= c.Pos() && c.End() <= node.End() {
+			comments = append(comments, c)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, fset, &printer.CommentedNode{Node: node, Comments: comments}); err != nil {
+		return "", false
+	}
+	// The node is formatted as if it started in the first column, so indent
+	// all but its first line like the line it starts on.
+	indent := lineIndent(content, fset.File(node.Pos()).Offset(node.Pos()))
+	lines := strings.Split(buf.String(), "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
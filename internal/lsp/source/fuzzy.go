@@ -0,0 +1,90 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// fuzzyMatch reports whether pattern matches candidate, ignoring case, as a
+// subsequence of its characters.
+// The returned score is in the range (0, 1] for a match; higher is better.
+// Matches at the start of the candidate or of a word within it (as in
+// camelCase or snake_case), consecutive matches, and matches with the same
+// case as the pattern score higher.
+// An empty pattern matches everything with a score of 1.
+func fuzzyMatch(pattern, candidate string) (float64, bool) {
+	if pattern == "" {
+		return 1, true
+	}
+	var score, best float64
+	prev := ' '      // the previous rune of the candidate
+	matched := false // whether prev was matched
+	pi := 0
+	pr, psize := utf8.DecodeRuneInString(pattern)
+	for _, cr := range candidate {
+		if pi >= len(pattern) {
+			break
+		}
+		// Score each pattern rune as if it were an ideal match, to normalize.
+		if unicode.ToLower(cr) != unicode.ToLower(pr) {
+			prev, matched = cr, false
+			continue
+		}
+		s := 1.0
+		if isWordStart(prev, cr) {
+			s += 2
+		}
+		if matched {
+			s += 1
+		}
+		if cr == pr {
+			s += 0.5
+		}
+		score += s
+		best += 4.5
+		pi += psize
+		pr, psize = utf8.DecodeRuneInString(pattern[pi:])
+		prev, matched = cr, true
+	}
+	if pi < len(pattern) {
+		return 0, false
+	}
+	// Prefer shorter candidates when the match is otherwise equal.
+	score -= float64(utf8.RuneCountInString(candidate)) * 0.01
+	if score <= 0 {
+		score = 0.01
+	}
+	return score / best, true
+}
+
+// isSubsequence reports whether the runes of pattern occur in candidate in
+// the same order and case.
+func isSubsequence(pattern, candidate string) bool {
+	for _, cr := range candidate {
+		if pattern == "" {
+			break
+		}
+		if pr, size := utf8.DecodeRuneInString(pattern); pr == cr {
+			pattern = pattern[size:]
+		}
+	}
+	return pattern == ""
+}
+
+// isWordStart reports whether cur starts a new word in an identifier, given
+// the rune preceding it.
+func isWordStart(prev, cur rune) bool {
+	switch {
+	case prev == ' ', prev == '_', prev == '.', prev == '/':
+		return true
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return true
+	case unicode.IsDigit(cur) && !unicode.IsDigit(prev):
+		return true
+	}
+	return false
+}
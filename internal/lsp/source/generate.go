@@ -0,0 +1,27 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"strings"
+)
+
+// GenerateDirectives returns the ranges of the //go:generate directives of f.
+func GenerateDirectives(ctx context.Context, f *File) ([]Range, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var directives []Range
+	for _, group := range fAST.Comments {
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, "//go:generate ") {
+				directives = append(directives, Range{Start: c.Pos(), End: c.End()})
+			}
+		}
+	}
+	return directives, nil
+}
@@ -0,0 +1,238 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+type HighlightKind int
+
+const (
+	TextHighlight HighlightKind = iota
+	ReadHighlight
+	WriteHighlight
+)
+
+// Highlight is an occurrence of an identifier in a file.
+type Highlight struct {
+	Range Range
+	Kind  HighlightKind
+}
+
+// Highlights returns the occurrences in f of the object denoted by the
+// identifier at pos, or, if pos is on the func keyword of a function or the
+// return keyword of one of its return statements, its exit points.
+// Occurrences of variables are reported as reads or writes; the declaration
+// of a variable and the left-hand side of an assignment are writes.
+func Highlights(ctx context.Context, f *File, pos token.Pos) ([]Highlight, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if typ, body := exitPointsFunc(fAST, pos); body != nil {
+		return exitPoints(typ, body), nil
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i, err := findIdentifier(fAST, pos)
+	if err != nil {
+		return nil, err
+	}
+	if i.ident == nil {
+		return nil, fmt.Errorf("highlight was not a valid identifier")
+	}
+	info := pkg.TypesInfo
+	obj := info.ObjectOf(i.ident)
+	if obj == nil {
+		return nil, fmt.Errorf("no object for %s", i.ident.Name)
+	}
+	_, isVar := obj.(*types.Var)
+
+	// Find the identifiers that are assigned to.
+	writes := make(map[*ast.Ident]bool)
+	ast.Inspect(fAST, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if id := assignedIdent(lhs); id != nil {
+					writes[id] = true
+				}
+			}
+		case *ast.IncDecStmt:
+			if id := assignedIdent(n.X); id != nil {
+				writes[id] = true
+			}
+		case *ast.RangeStmt:
+			if id := assignedIdent(n.Key); id != nil {
+				writes[id] = true
+			}
+			if id := assignedIdent(n.Value); id != nil {
+				writes[id] = true
+			}
+		}
+		return true
+	})
+
+	var result []Highlight
+	ast.Inspect(fAST, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || info.ObjectOf(id) != obj {
+			return true
+		}
+		kind := TextHighlight
+		if isVar {
+			kind = ReadHighlight
+			if info.Defs[id] != nil || writes[id] {
+				kind = WriteHighlight
+			}
+		}
+		result = append(result, Highlight{
+			Range: Range{Start: id.Pos(), End: id.End()},
+			Kind:  kind,
+		})
+		return true
+	})
+	return result, nil
+}
+
+// exitPointsFunc returns the type and body of the function whose func
+// keyword, or the return keyword of one of whose return statements, is at
+// pos.
+func exitPointsFunc(fAST *ast.File, pos token.Pos) (*ast.FuncType, *ast.BlockStmt) {
+	path, _ := astutil.PathEnclosingInterval(fAST, pos, pos)
+	onKeyword := func(kw token.Pos, tok token.Token) bool {
+		return kw <= pos && pos <= kw+token.Pos(len(tok.String()))
+	}
+	onReturn := false
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.ReturnStmt:
+			onReturn = onReturn || onKeyword(n.Return, token.RETURN)
+		case *ast.FuncLit:
+			if onReturn || onKeyword(n.Type.Func, token.FUNC) {
+				return n.Type, n.Body
+			}
+			return nil, nil
+		case *ast.FuncDecl:
+			if n.Body != nil && (onReturn || onKeyword(n.Type.Func, token.FUNC)) {
+				return n.Type, n.Body
+			}
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// exitPoints returns the highlights of the signature typ of a function and
+// of the points of its body where it returns: its return statements, and
+// the end of the body if it has no results and may reach it.
+func exitPoints(typ *ast.FuncType, body *ast.BlockStmt) []Highlight {
+	result := []Highlight{{Range: Range{Start: typ.Pos(), End: typ.End()}}}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			result = append(result, Highlight{Range: Range{Start: n.Pos(), End: n.End()}})
+		}
+		return true
+	})
+	if typ.Results == nil || typ.Results.NumFields() == 0 {
+		if n := len(body.List); n == 0 || !isTerminating(body.List[n-1]) {
+			result = append(result, Highlight{Range: Range{Start: body.Rbrace, End: body.Rbrace + 1}})
+		}
+	}
+	return result
+}
+
+// isTerminating reports whether the statement s obviously ends the function,
+// as a return statement or a call of panic does.
+func isTerminating(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.ExprStmt:
+		call, ok := s.X.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		id, ok := call.Fun.(*ast.Ident)
+		return ok && id.Name == "panic"
+	}
+	return false
+}
+
+// LinkedEditingRanges returns the ranges of the occurrences in f of the
+// object denoted by the identifier at pos, which an editor changes together
+// as one of them is edited, if it is local to a function. The occurrences
+// of the other objects may be in other files, which only Rename changes.
+func LinkedEditingRanges(ctx context.Context, f *File, pos token.Pos) ([]Range, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i, err := findIdentifier(fAST, pos)
+	if err != nil {
+		return nil, err
+	}
+	if i.ident == nil || i.wasEmbeddedField {
+		return nil, nil
+	}
+	info := pkg.TypesInfo
+	obj := info.ObjectOf(i.ident)
+	if obj == nil || !isLocal(obj) {
+		return nil, nil
+	}
+	var result []Range
+	ast.Inspect(fAST, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && info.ObjectOf(id) == obj {
+			result = append(result, Range{Start: id.Pos(), End: id.End()})
+		}
+		return true
+	})
+	return result, nil
+}
+
+// isLocal reports whether obj is declared in a function, and only referred
+// to in it.
+func isLocal(obj types.Object) bool {
+	if _, ok := obj.(*types.Label); ok {
+		return true
+	}
+	scope := obj.Parent()
+	if scope == nil || obj.Pkg() == nil {
+		return false
+	}
+	// The scopes of files, which hold the imported packages, are children of
+	// that of the package.
+	return scope != obj.Pkg().Scope() && scope.Parent() != obj.Pkg().Scope()
+}
+
+// assignedIdent returns the identifier of the variable or field that is
+// assigned to by an assignment to e, if any.
+func assignedIdent(e ast.Expr) *ast.Ident {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e
+	case *ast.ParenExpr:
+		return assignedIdent(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// HoverInformation is the information shown when hovering over an identifier.
+type HoverInformation struct {
+	// Signature is the declaration of the object, formatted as Go source.
+	Signature string
+	// Doc is the doc comment associated with the object's declaration, if any.
+	Doc string
+	// Details describes the value of a constant or the memory layout of a
+	// type or a field, if the hover is verbose.
+	Details string
+	// Range is the range of the identifier that was hovered over.
+	Range Range
+}
+
+// Hover returns the type and documentation for the identifier at pos, and
+// its details if opts are verbose.
+// In a go.mod file, it returns the version of the module required at pos.
+func Hover(ctx context.Context, f *File, pos token.Pos, opts HoverOptions) (*HoverInformation, error) {
+	if IsModFile(f.URI) {
+		return modHover(ctx, f, pos)
+	}
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i, err := findIdentifier(fAST, pos)
+	if err != nil {
+		return nil, err
+	}
+	if i.ident == nil {
+		return nil, fmt.Errorf("hover was not a valid identifier")
+	}
+	obj := pkg.TypesInfo.ObjectOf(i.ident)
+	if obj == nil {
+		return nil, fmt.Errorf("no object for %s", i.ident.Name)
+	}
+	qf := qualifier(fAST, pkg.Types, pkg.TypesInfo)
+	info := &HoverInformation{
+		Signature: objectString(obj, qf),
+		Doc:       objectDoc(pkg, obj),
+		Range: Range{
+			Start: i.ident.Pos(),
+			End:   i.ident.End(),
+		},
+	}
+	if opts.Verbose {
+		filename, err := f.URI.Filename()
+		if err != nil {
+			return nil, err
+		}
+		path, _ := astutil.PathEnclosingInterval(fAST, i.ident.Pos(), i.ident.End())
+		info.Details = objectDetails(obj, path, pkg.TypesInfo, f.view.sizes(filename))
+	}
+	return info, nil
+}
+
+// sizes returns the sizes of the types of the packages of the file named
+// filename, for the architecture it is built for.
+func (v *View) sizes(filename string) types.Sizes {
+	v.mu.Lock()
+	env := v.configFor(filename).Env
+	v.mu.Unlock()
+	if sizes := types.SizesFor("gc", goarch(env)); sizes != nil {
+		return sizes
+	}
+	return types.SizesFor("gc", "amd64")
+}
+
+// objectDetails returns the value of the constant obj in other notations,
+// the size and alignment of the type obj, or the offset, size and alignment
+// of the field obj, or "" for the other objects. path is the path to the
+// identifier that refers to obj.
+func objectDetails(obj types.Object, path []ast.Node, info *types.Info, sizes types.Sizes) string {
+	switch obj := obj.(type) {
+	case *types.Const:
+		val := obj.Val()
+		switch val.Kind() {
+		case constant.Int:
+			if i, ok := constant.Int64Val(val); ok {
+				return fmt.Sprintf("value: %d (%#x)", i, i)
+			}
+		case constant.Float:
+			if s := val.String(); s != val.ExactString() {
+				return "value: " + s
+			}
+		}
+	case *types.TypeName:
+		if _, ok := obj.Type().Underlying().(*types.Interface); ok && obj.Pkg() == nil {
+			return "" // the error type
+		}
+		return layout(sizes, obj.Type())
+	case *types.Var:
+		if !obj.IsField() {
+			break
+		}
+		st := enclosingStruct(obj, path, info)
+		if st == nil {
+			break
+		}
+		fields := make([]*types.Var, st.NumFields())
+		for i := range fields {
+			fields[i] = st.Field(i)
+		}
+		offsets := sizes.Offsetsof(fields)
+		for i, field := range fields {
+			if field == obj {
+				return fmt.Sprintf("offset: %d, %s", offsets[i], layout(sizes, obj.Type()))
+			}
+		}
+	}
+	return ""
+}
+
+// layout describes the size and the alignment of t.
+func layout(sizes types.Sizes, t types.Type) string {
+	return fmt.Sprintf("size: %d, align: %d", sizes.Sizeof(t), sizes.Alignof(t))
+}
+
+// enclosingStruct returns the struct type that declares field, as the
+// identifier at the end of path refers to it: the struct of the selected
+// field of a selector, the struct of a composite literal whose key it is,
+// or the struct type whose declaration it is.
+func enclosingStruct(field *types.Var, path []ast.Node, info *types.Info) *types.Struct {
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			sel, ok := info.Selections[n]
+			if !ok {
+				return nil
+			}
+			// Follow the embedded fields through which the field is
+			// promoted.
+			t := sel.Recv()
+			index := sel.Index()
+			for _, i := range index[:len(index)-1] {
+				st, ok := deref(t).Underlying().(*types.Struct)
+				if !ok {
+					return nil
+				}
+				t = st.Field(i).Type()
+			}
+			st, _ := deref(t).Underlying().(*types.Struct)
+			return st
+		case *ast.CompositeLit:
+			if t := info.TypeOf(n); t != nil {
+				st, _ := deref(t).Underlying().(*types.Struct)
+				return st
+			}
+			return nil
+		case *ast.StructType:
+			if t := info.TypeOf(n); t != nil {
+				st, _ := t.(*types.Struct)
+				return st
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// Markdown formats the hover information as markdown, with the signature in a
+// fenced Go code block followed by the details and the doc comment.
+func (h *HoverInformation) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "```go\n%s\n```", h.Signature)
+	if h.Details != "" {
+		fmt.Fprintf(&b, "\n\n%s", h.Details)
+	}
+	if h.Doc != "" {
+		fmt.Fprintf(&b, "\n\n%s", strings.TrimSpace(h.Doc))
+	}
+	return b.String()
+}
+
+// PlainText formats the hover information as plain text, for the clients
+// that do not support markdown, with the signature followed by the details
+// and the doc comment.
+func (h *HoverInformation) PlainText() string {
+	var b strings.Builder
+	b.WriteString(h.Signature)
+	if h.Details != "" {
+		fmt.Fprintf(&b, "\n\n%s", h.Details)
+	}
+	if h.Doc != "" {
+		fmt.Fprintf(&b, "\n\n%s", strings.TrimSpace(h.Doc))
+	}
+	return b.String()
+}
+
+// objectString is like types.ObjectString, but it also shows the underlying
+// type of named types, as that is usually what the user is interested in.
+func objectString(obj types.Object, qf types.Qualifier) string {
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		if obj.IsAlias() {
+			return types.ObjectString(obj, qf)
+		}
+		return fmt.Sprintf("type %s %s", obj.Name(), types.TypeString(obj.Type().Underlying(), qf))
+	case *types.Const:
+		if obj.Parent() != types.Universe {
+			return fmt.Sprintf("%s = %s", types.ObjectString(obj, qf), obj.Val().ExactString())
+		}
+	}
+	return types.ObjectString(obj, qf)
+}
+
+// objectDoc returns the doc comment for the declaration of obj, if the
+// syntax for the declaring package is available.
+func objectDoc(pkg *packages.Package, obj types.Object) string {
+	if obj.Pkg() == nil {
+		return "" // builtins and universe objects have no syntax
+	}
+	declPkg := findPackage(pkg, obj.Pkg().Path())
+	if declPkg == nil {
+		return ""
+	}
+	tok := pkg.Fset.File(obj.Pos())
+	for _, file := range declPkg.Syntax {
+		if pkg.Fset.File(file.Pos()) != tok {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(file, obj.Pos(), obj.Pos())
+		for _, n := range path {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				return n.Doc.Text()
+			case *ast.Field:
+				if n.Doc != nil {
+					return n.Doc.Text()
+				}
+				return n.Comment.Text()
+			case *ast.ValueSpec:
+				if n.Doc != nil {
+					return n.Doc.Text()
+				}
+				if n.Comment != nil {
+					return n.Comment.Text()
+				}
+			case *ast.TypeSpec:
+				if n.Doc != nil {
+					return n.Doc.Text()
+				}
+				if n.Comment != nil {
+					return n.Comment.Text()
+				}
+			case *ast.GenDecl:
+				// Only use the declaration comment if it is not a grouped
+				// declaration, otherwise it doesn't belong to this object.
+				if !n.Lparen.IsValid() {
+					return n.Doc.Text()
+				}
+				return ""
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// findPackage returns the package with the given path among pkg and its
+// dependencies, or nil.
+func findPackage(pkg *packages.Package, path string) *packages.Package {
+	seen := make(map[*packages.Package]bool)
+	var find func(pkg *packages.Package) *packages.Package
+	find = func(pkg *packages.Package) *packages.Package {
+		if pkg.PkgPath == path {
+			return pkg
+		}
+		seen[pkg] = true
+		for _, imp := range pkg.Imports {
+			if seen[imp] {
+				continue
+			}
+			if found := find(imp); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return find(pkg)
+}
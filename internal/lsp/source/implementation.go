@@ -0,0 +1,127 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// Implementation returns the declarations of the concrete types that
+// implement the interface at pos, or, if pos is on an interface method, the
+// declarations of the corresponding concrete methods.
+// Only the types declared in the packages loaded by the view are considered.
+func Implementation(ctx context.Context, v *View, f *File, pos token.Pos) ([]Range, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i, err := findIdentifier(fAST, pos)
+	if err != nil {
+		return nil, err
+	}
+	if i.ident == nil {
+		return nil, fmt.Errorf("implementation was not a valid identifier")
+	}
+	var iface *types.Interface
+	var method *types.Func
+	switch obj := pkg.TypesInfo.ObjectOf(i.ident).(type) {
+	case *types.TypeName:
+		iface, _ = obj.Type().Underlying().(*types.Interface)
+	case *types.Func:
+		iface, method = interfaceMethod(obj)
+	}
+	if iface == nil {
+		return nil, fmt.Errorf("%s is not an interface or interface method", i.ident.Name)
+	}
+	impls, err := v.implementations(ctx, iface, method)
+	if err != nil {
+		return nil, err
+	}
+	var result []Range
+	for _, impl := range impls {
+		result = append(result, Range{
+			Start: impl.Pos(),
+			End:   impl.Pos() + token.Pos(len(impl.Name())),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		pi, pj := v.Config.Fset.Position(result[i].Start), v.Config.Fset.Position(result[j].Start)
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	return result, nil
+}
+
+// implementations returns the named concrete types, declared in the
+// packages loaded by the view, that implement iface, or, if method is not
+// nil, their methods that correspond to it, a method of iface.
+func (v *View) implementations(ctx context.Context, iface *types.Interface, method *types.Func) ([]types.Object, error) {
+	seen := make(map[objKey]bool)
+	var result []types.Object
+	for _, p := range v.packages() {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		idx := v.index(p)
+		for _, obj := range idx.symbols {
+			tn, ok := obj.(*types.TypeName)
+			if !ok || types.IsInterface(tn.Type()) {
+				continue
+			}
+			T := tn.Type()
+			if !types.Implements(T, iface) {
+				T = types.NewPointer(T)
+				if !types.Implements(T, iface) {
+					continue
+				}
+			}
+			target := types.Object(tn)
+			if method != nil {
+				sel := idx.msets.MethodSet(T).Lookup(method.Pkg(), method.Name())
+				if sel == nil {
+					continue
+				}
+				target = sel.Obj()
+			}
+			key := v.keyOf(target)
+			if seen[key] || !target.Pos().IsValid() {
+				continue
+			}
+			seen[key] = true
+			result = append(result, target)
+		}
+	}
+	return result, nil
+}
+
+// interfaceMethod returns the interface and obj, if obj is a method of an
+// interface.
+func interfaceMethod(obj types.Object) (*types.Interface, *types.Func) {
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil, nil
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil, nil
+	}
+	iface, ok := recv.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil, nil
+	}
+	return iface, fn
+}
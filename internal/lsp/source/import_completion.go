@@ -0,0 +1,254 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/tools/imports"
+	"golang.org/x/tools/internal/gopathwalk"
+)
+
+// importPathCompletion returns the completions of the import path of the
+// import spec of file at pos, if pos is in one: the paths of the packages
+// of GOROOT, GOPATH and the module cache, and of the packages that the view
+// has loaded, that the file does not import yet.
+// The internal packages are offered once their path is being typed, since
+// most of them can't be imported.
+func importPathCompletion(v *View, file *ast.File, filename string, pos token.Pos) ([]CompletionItem, bool) {
+	var lit *ast.BasicLit
+	for _, imp := range file.Imports {
+		if imp.Path.Pos() < pos && pos < imp.Path.End() {
+			lit = imp.Path
+			break
+		}
+	}
+	if lit == nil {
+		return nil, false
+	}
+	prefix := lit.Value[1 : pos-lit.Pos()]
+	paths, imported := v.knownPackagePaths(file, filename)
+	var items []CompletionItem
+	for _, p := range paths {
+		if imported[p] || !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		if strings.Contains(p+"/", "/internal/") && !strings.Contains(prefix, "internal") {
+			continue
+		}
+		items = append(items, CompletionItem{
+			Label:      p,
+			Kind:       PackageCompletionItem,
+			Score:      stdScore,
+			InsertText: p,
+			FilterText: p,
+			Replace:    Range{Start: lit.Pos() + 1, End: pos},
+		})
+	}
+	return items, true
+}
+
+// knownPackagePaths returns the sorted import paths of the packages of
+// GOROOT, GOPATH and the module cache, and of the packages that the view has
+// loaded, and the set of those that file, named filename, imports already or
+// is a part of.
+func (v *View) knownPackagePaths(file *ast.File, filename string) ([]string, map[string]bool) {
+	imported := make(map[string]bool)
+	for _, imp := range file.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err == nil {
+			imported[p] = true
+		}
+	}
+	paths := imports.ImportPaths()
+	paths = append(paths, v.moduleCachePaths(filename)...)
+	for _, pkg := range v.packages() {
+		paths = append(paths, pkg.PkgPath)
+		// The package of the file can't import itself.
+		for _, f := range pkg.CompiledGoFiles {
+			if f == filename {
+				imported[pkg.PkgPath] = true
+			}
+		}
+	}
+	sort.Strings(paths)
+	unique := paths[:0]
+	for i, p := range paths {
+		if i == 0 || p != paths[i-1] {
+			unique = append(unique, p)
+		}
+	}
+	return unique, imported
+}
+
+var (
+	moduleCacheMu sync.Mutex
+	// moduleCachePkgs caches the import paths of the packages of the module
+	// caches, by their directories.
+	moduleCachePkgs = make(map[string][]string)
+)
+
+// moduleCachePaths returns the import paths of the packages of the module
+// cache that the file named filename is built with, without their versions.
+// They are cached for the life of the process, as the module cache is only
+// added to.
+func (v *View) moduleCachePaths(filename string) []string {
+	v.mu.Lock()
+	env := v.configFor(filename).Env
+	v.mu.Unlock()
+	dir := getenv(env, "GOMODCACHE")
+	if dir == "" {
+		gopath := getenv(env, "GOPATH")
+		if gopath == "" {
+			gopath = build.Default.GOPATH
+		}
+		dir = filepath.Join(filepath.SplitList(gopath)[0], "pkg", "mod")
+	}
+	moduleCacheMu.Lock()
+	defer moduleCacheMu.Unlock()
+	if paths, ok := moduleCachePkgs[dir]; ok {
+		return paths
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	var mu sync.Mutex
+	add := func(root gopathwalk.Root, pkgDir string) {
+		rel, err := filepath.Rel(root.Path, pkgDir)
+		if err != nil {
+			return
+		}
+		// Remove the versions of the modules from the path, and decode the
+		// upper case letters, which are escaped as "!" and the lower case
+		// letter.
+		elems := strings.Split(filepath.ToSlash(rel), "/")
+		for i, elem := range elems {
+			if at := strings.Index(elem, "@"); at >= 0 {
+				elem = elem[:at]
+			}
+			elems[i] = unescapeModulePath(elem)
+		}
+		p := strings.Join(elems, "/")
+		mu.Lock()
+		defer mu.Unlock()
+		if !seen[p] && !strings.HasPrefix(p, "cache/") {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	gopathwalk.Walk([]gopathwalk.Root{{Path: dir, Type: gopathwalk.RootModuleCache}}, add, gopathwalk.Options{ModulesEnabled: true})
+	moduleCachePkgs[dir] = paths
+	return paths
+}
+
+// unescapeModulePath decodes the upper case letters of an element of a
+// path of the module cache.
+func unescapeModulePath(s string) string {
+	if !strings.Contains(s, "!") {
+		return s
+	}
+	var b strings.Builder
+	upper := false
+	for _, r := range s {
+		switch {
+		case r == '!':
+			upper = true
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// packageClauseCompletion returns the completions of the name of the
+// package clause of file at pos, if pos is in it: the name of the package of
+// the other files of the directory of filename, or one derived from the name
+// of the directory, and that name with _test in the files of tests.
+func packageClauseCompletion(tok *token.File, file *ast.File, filename string, pos token.Pos) ([]CompletionItem, bool) {
+	var prefix string
+	switch {
+	case file.Name.Name != "":
+		if pos < file.Name.Pos() || pos > file.Name.End() {
+			return nil, false
+		}
+		prefix = file.Name.Name[:pos-file.Name.Pos()]
+	case file.Name.NamePos.IsValid():
+		// The name is missing, so the file has only the package clause.
+		if pos <= file.Name.NamePos || tok.Line(pos) != tok.Line(file.Package) {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	name := dirPackageName(filename)
+	names := []string{name}
+	if strings.HasSuffix(filename, "_test.go") {
+		names = append(names, name+"_test")
+	}
+	if name != "main" {
+		names = append(names, "main")
+	}
+	var items []CompletionItem
+	for i, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		items = append(items, CompletionItem{
+			Label: name,
+			Kind:  PackageCompletionItem,
+			Score: stdScore / float64(i+1),
+		})
+	}
+	return items, true
+}
+
+// dirPackageName returns the name of the package of the other files of the
+// directory of filename, or one derived from the name of the directory if
+// there are none.
+func dirPackageName(filename string) string {
+	dir := filepath.Dir(filename)
+	infos, _ := ioutil.ReadDir(dir)
+	fset := token.NewFileSet()
+	for _, info := range infos {
+		other := filepath.Join(dir, info.Name())
+		if other == filename || !strings.HasSuffix(other, ".go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, other, nil, parser.PackageClauseOnly)
+		if err != nil || f.Name == nil || f.Name.Name == "_" {
+			continue
+		}
+		name := f.Name.Name
+		if strings.HasSuffix(name, "_test") && strings.HasSuffix(other, "_test.go") {
+			name = strings.TrimSuffix(name, "_test")
+		}
+		return name
+	}
+	// Make an identifier of the last element of the directory, such as foo
+	// for go-foo.
+	base := filepath.Base(dir)
+	base = strings.TrimPrefix(base, "go-")
+	var b strings.Builder
+	for _, r := range strings.ToLower(base) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) && b.Len() > 0 || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "main"
+	}
+	return b.String()
+}
@@ -0,0 +1,43 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"golang.org/x/tools/imports"
+)
+
+// Imports adds and removes the imports of f as needed, like goimports does,
+// and returns the edits to apply to f. The given range must cover the whole
+// file.
+// It returns no edits if the imports are already correct.
+func Imports(ctx context.Context, f *File, rng Range) ([]TextEdit, error) {
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) != tok.Size() {
+		return nil, fmt.Errorf("%s has changed since it was parsed", f.URI)
+	}
+	filename, err := f.URI.Filename()
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := imports.Process(filename, content, nil)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(formatted, content) {
+		return nil, nil
+	}
+	return computeTextEdits(tok, string(content), string(formatted)), nil
+}
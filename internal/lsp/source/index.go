@@ -0,0 +1,122 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// packageIndex holds the information derived from a type-checked package
+// that is needed to answer workspace-wide queries.
+// It is computed lazily and discarded when the package is invalidated.
+type packageIndex struct {
+	// refs maps each object to the identifiers that define or use it.
+	refs map[objKey][]*ast.Ident
+
+	// symbols holds the package-level declarations and methods of the package.
+	symbols []types.Object
+
+	// msets caches the method sets of the types declared in the package.
+	msets typeutil.MethodSetCache
+}
+
+// objKey identifies an object independently of the type-checking pass that
+// created it. Files are parsed once per package variant, so the same
+// declaration can have several types.Object values and token.Pos values.
+type objKey struct {
+	name     string
+	filename string
+	offset   int
+}
+
+func (v *View) keyOf(obj types.Object) objKey {
+	posn := v.Config.Fset.Position(obj.Pos())
+	return objKey{
+		name:     obj.Name(),
+		filename: posn.Filename,
+		offset:   posn.Offset,
+	}
+}
+
+// index returns the index for pkg, building and caching it if needed.
+func (v *View) index(pkg *packages.Package) *packageIndex {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if idx, ok := v.indexes[pkg]; ok {
+		return idx
+	}
+	idx := &packageIndex{
+		refs: make(map[objKey][]*ast.Ident),
+	}
+	if pkg.TypesInfo != nil {
+		add := func(id *ast.Ident, obj types.Object) {
+			if obj == nil || obj.Pkg() == nil {
+				return
+			}
+			key := v.keyOf(obj)
+			idx.refs[key] = append(idx.refs[key], id)
+		}
+		for id, obj := range pkg.TypesInfo.Defs {
+			add(id, obj)
+		}
+		for id, obj := range pkg.TypesInfo.Uses {
+			add(id, obj)
+		}
+	}
+	if pkg.Types != nil {
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			idx.symbols = append(idx.symbols, obj)
+			if tn, ok := obj.(*types.TypeName); ok {
+				if named, ok := tn.Type().(*types.Named); ok {
+					for i := 0; i < named.NumMethods(); i++ {
+						idx.symbols = append(idx.symbols, named.Method(i))
+					}
+				}
+			}
+		}
+	}
+	v.indexes[pkg] = idx
+	return idx
+}
+
+// invalidate discards the cached index for pkg.
+// It must be called with v.mu held.
+func (v *View) invalidate(pkg *packages.Package) {
+	if pkg != nil {
+		delete(v.indexes, pkg)
+	}
+}
+
+// packages returns all the type-checked packages known to the view, including
+// the dependencies of the packages of the files in the view.
+func (v *View) packages() []*packages.Package {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var result []*packages.Package
+	seen := make(map[*packages.Package]bool)
+	var visit func(*packages.Package)
+	visit = func(pkg *packages.Package) {
+		if pkg == nil || seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		if pkg.TypesInfo != nil {
+			result = append(result, pkg)
+		}
+		for _, imp := range pkg.Imports {
+			visit(imp)
+		}
+	}
+	for _, f := range v.files {
+		visit(f.pkg)
+	}
+	return result
+}
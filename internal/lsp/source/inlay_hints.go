@@ -0,0 +1,138 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+type InlayHintKind int
+
+const (
+	// ParameterNameHint is the name of the parameter an argument of a call
+	// is passed to.
+	ParameterNameHint InlayHintKind = iota
+	// VariableTypeHint is the type of a variable declared by a short
+	// variable declaration.
+	VariableTypeHint
+)
+
+// InlayHint is a label displayed inline at a position of a file.
+type InlayHint struct {
+	Pos   token.Pos
+	Label string
+	Kind  InlayHintKind
+}
+
+// InlayHints returns the hints of the enabled kinds for the part of f within
+// rng.
+func InlayHints(ctx context.Context, f *File, rng Range, enabled map[InlayHintKind]bool) ([]InlayHint, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info := pkg.TypesInfo
+	qf := qualifier(fAST, pkg.Types, info)
+	if !rng.End.IsValid() {
+		rng.End = fAST.End() // the range extends past the end of the file
+	}
+
+	var hints []InlayHint
+	ast.Inspect(fAST, func(n ast.Node) bool {
+		if n == nil || n.End() < rng.Start || n.Pos() > rng.End {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if enabled[ParameterNameHint] {
+				hints = append(hints, parameterNameHints(info, n)...)
+			}
+		case *ast.AssignStmt:
+			if enabled[VariableTypeHint] && n.Tok == token.DEFINE {
+				hints = append(hints, variableTypeHints(info, qf, n.Lhs)...)
+			}
+		case *ast.RangeStmt:
+			if enabled[VariableTypeHint] && n.Tok == token.DEFINE {
+				hints = append(hints, variableTypeHints(info, qf, []ast.Expr{n.Key, n.Value})...)
+			}
+		}
+		return true
+	})
+	return hints, nil
+}
+
+// parameterNameHints returns the names of the parameters of the arguments of
+// the call. Arguments that are identifiers with the same name as their
+// parameter are not labeled.
+func parameterNameHints(info *types.Info, call *ast.CallExpr) []InlayHint {
+	tv, ok := info.Types[call.Fun]
+	if !ok || tv.IsType() {
+		return nil // a conversion
+	}
+	sig, ok := tv.Type.Underlying().(*types.Signature)
+	if !ok {
+		return nil
+	}
+	params := sig.Params()
+	var hints []InlayHint
+	for i, arg := range call.Args {
+		index := i
+		variadic := sig.Variadic() && i >= params.Len()-1
+		if variadic {
+			if i > params.Len()-1 {
+				break // only label the first variadic argument
+			}
+			index = params.Len() - 1
+		}
+		if index >= params.Len() {
+			break
+		}
+		name := params.At(index).Name()
+		if name == "" || name == "_" {
+			continue
+		}
+		if id, ok := arg.(*ast.Ident); ok && id.Name == name {
+			continue
+		}
+		if variadic && !call.Ellipsis.IsValid() {
+			name += "..."
+		}
+		hints = append(hints, InlayHint{
+			Pos:   arg.Pos(),
+			Label: name + ":",
+			Kind:  ParameterNameHint,
+		})
+	}
+	return hints
+}
+
+// variableTypeHints returns the types of the variables declared by the
+// identifiers in lhs.
+func variableTypeHints(info *types.Info, qf types.Qualifier, lhs []ast.Expr) []InlayHint {
+	var hints []InlayHint
+	for _, e := range lhs {
+		id, ok := e.(*ast.Ident)
+		if !ok || id.Name == "_" {
+			continue
+		}
+		obj := info.Defs[id]
+		if obj == nil {
+			continue // not a new variable
+		}
+		hints = append(hints, InlayHint{
+			Pos:   id.End(),
+			Label: types.TypeString(obj.Type(), qf),
+			Kind:  VariableTypeHint,
+		})
+	}
+	return hints
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// KnownPackages returns the sorted import paths of the packages that f may
+// import and does not import yet: those of GOROOT, GOPATH and the module
+// cache, and those that the view has loaded, except for the internal
+// packages that f may not import.
+func KnownPackages(ctx context.Context, v *View, f *File) ([]string, error) {
+	file, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filename, err := f.URI.Filename()
+	if err != nil {
+		return nil, err
+	}
+	paths, imported := v.knownPackagePaths(file, filename)
+	importer := v.packagePath(filename)
+	var known []string
+	for _, p := range paths {
+		if imported[p] || !canImportInternal(importer, p) {
+			continue
+		}
+		known = append(known, p)
+	}
+	return known, nil
+}
+
+// AddImport returns the edit that adds the import of the package with the
+// given path to f, or no edit if f imports it already.
+func AddImport(ctx context.Context, f *File, importPath string) ([]TextEdit, error) {
+	if importPath == "" || strings.ContainsAny(importPath, "\" \t\n\\") {
+		return nil, fmt.Errorf("invalid import path %q", importPath)
+	}
+	file, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, imp := range file.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err == nil && p == importPath {
+			return nil, nil
+		}
+	}
+	return []TextEdit{importEdit(tok, file, path.Base(importPath), importPath)}, nil
+}
+
+// packagePath returns the import path of the package of the file named
+// filename, or "" if the view has not loaded it.
+func (v *View) packagePath(filename string) string {
+	for _, pkg := range v.packages() {
+		for _, f := range pkg.CompiledGoFiles {
+			if f == filename {
+				return pkg.PkgPath
+			}
+		}
+	}
+	return ""
+}
+
+// canImportInternal reports whether the package with the import path
+// importer may import the package with the path imported: the internal
+// packages may only be imported by the packages of the tree rooted at the
+// parent of their internal directory. An unknown importer may only import
+// the packages that are not internal.
+func canImportInternal(importer, imported string) bool {
+	i := strings.LastIndex("/"+imported+"/", "/internal/")
+	if i < 0 {
+		return true
+	}
+	if importer == "" {
+		return false
+	}
+	if i == 0 {
+		// The internal packages of GOROOT may be imported by the standard
+		// library, whose paths have no dot in their first element.
+		return !strings.Contains(strings.SplitN(importer, "/", 2)[0], ".")
+	}
+	parent := imported[:i-1]
+	return importer == parent || strings.HasPrefix(importer, parent+"/")
+}
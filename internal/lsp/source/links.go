@@ -0,0 +1,67 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Link is a range of a file that links to a URL.
+type Link struct {
+	Range  Range
+	Target string
+}
+
+// urlPattern matches the URLs in string literals.
+var urlPattern = regexp.MustCompile(`https?://[^\s"'` + "`" + `<>]+`)
+
+// Links returns the links of f: those of its import paths to the
+// documentation of their packages at docURL, unless it is empty, and those
+// of the URLs in its string literals.
+func Links(ctx context.Context, f *File, docURL string) ([]Link, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var links []Link
+	imports := make(map[*ast.BasicLit]bool)
+	for _, imp := range fAST.Imports {
+		imports[imp.Path] = true
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || docURL == "" {
+			continue
+		}
+		links = append(links, Link{
+			Range:  Range{Start: imp.Path.Pos() + 1, End: imp.Path.End() - 1},
+			Target: strings.TrimSuffix(docURL, "/") + "/" + path,
+		})
+	}
+	ast.Inspect(fAST, func(n ast.Node) bool {
+		lit, ok := n.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING || imports[lit] {
+			return true
+		}
+		for _, loc := range urlPattern.FindAllStringIndex(lit.Value, -1) {
+			// Don't link the punctuation that ends a sentence.
+			target := strings.TrimRight(lit.Value[loc[0]:loc[1]], ".,;:!?)")
+			if u, err := url.Parse(target); err != nil || u.Host == "" {
+				continue
+			}
+			start := lit.Pos() + token.Pos(loc[0])
+			links = append(links, Link{
+				Range:  Range{Start: start, End: start + token.Pos(len(target))},
+				Target: target,
+			})
+		}
+		return true
+	})
+	return links, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// literal returns the completion of a literal of typ, the type expected at
+// pos, if it has literals: a function literal of a function type, such as
+// func(x int) bool {}, or a composite literal of a slice, array, map or
+// struct type or of a pointer to a struct type, such as []string{}.
+// The snippets of the literals place the cursor inside their braces. prefix
+// is the text of the identifier being completed, which the literal replaces.
+func literal(typ types.Type, qualifier types.Qualifier, prefix string, pos token.Pos) (items []CompletionItem) {
+	if typ == nil {
+		return nil
+	}
+	var label, snippet string
+	kind := VariableCompletionItem
+	switch t := typ.Underlying().(type) {
+	case *types.Signature:
+		if _, ok := typ.(*types.Named); ok {
+			// Don't offer a literal of a named function type, such as
+			// http.HandlerFunc, whose values are mostly declared functions.
+			return nil
+		}
+		params := formatLiteralParams(t.Params(), t.Variadic(), qualifier)
+		results := types.TypeString(t.Results(), qualifier)
+		switch {
+		case t.Results().Len() == 0:
+			results = ""
+		case t.Results().Len() == 1 && t.Results().At(0).Name() == "":
+			results = strings.Trim(results, "()") + " "
+		default:
+			results += " "
+		}
+		label = "func" + params + " " + results + "{}"
+		snippet = "func" + snippetEscaper.Replace(params) + " " + snippetEscaper.Replace(results) + "{\n\t$0\n}"
+		kind = FunctionCompletionItem
+	case *types.Slice, *types.Array, *types.Map, *types.Struct:
+		name := types.TypeString(typ, qualifier)
+		label = name + "{}"
+		snippet = snippetEscaper.Replace(name) + "{$0}"
+		if _, ok := t.(*types.Struct); ok {
+			kind = StructCompletionItem
+		}
+	case *types.Pointer:
+		if _, ok := t.Elem().Underlying().(*types.Struct); !ok {
+			return nil
+		}
+		name := types.TypeString(t.Elem(), qualifier)
+		label = "&" + name + "{}"
+		snippet = "&" + snippetEscaper.Replace(name) + "{$0}"
+		kind = StructCompletionItem
+	default:
+		return nil
+	}
+	match, ok := fuzzyMatch(prefix, label)
+	if !ok {
+		return nil
+	}
+	return []CompletionItem{{
+		Label:      label,
+		Detail:     types.TypeString(typ, qualifier),
+		Kind:       kind,
+		Score:      stdScore * match,
+		InsertText: label,
+		Snippet:    snippet,
+		FilterText: label,
+		Replace:    Range{Start: pos - token.Pos(len(prefix)), End: pos},
+	}}
+}
+
+// formatLiteralParams formats the parameters of a function literal, naming
+// them if the function type does not, so that the body can use them.
+func formatLiteralParams(t *types.Tuple, variadic bool, qualifier types.Qualifier) string {
+	var b bytes.Buffer
+	b.WriteByte('(')
+	for i := 0; i < t.Len(); i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		el := t.At(i)
+		typ := types.TypeString(el.Type(), qualifier)
+		if variadic && i == t.Len()-1 {
+			typ = strings.Replace(typ, "[]", "...", 1)
+		}
+		name := el.Name()
+		if name == "" {
+			name = fmt.Sprintf("p%d", i)
+		}
+		fmt.Fprintf(&b, "%v %v", name, typ)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
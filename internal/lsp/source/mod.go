@@ -0,0 +1,478 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/semver"
+)
+
+// IsModFile reports whether uri names a go.mod file.
+func IsModFile(uri URI) bool {
+	filename, err := uri.Filename()
+	return err == nil && filepath.Base(filename) == "go.mod"
+}
+
+// A modFile is a parsed go.mod file.
+// The syntax of go.mod files is line based: each line holds a directive,
+// such as "require example.com/m v1.0.0", or is part of a block of
+// directives with the same verb, such as "require ( ... )".
+type modFile struct {
+	lines []*modLine
+	// errors holds the syntax errors of the file, and the directives that
+	// are not valid.
+	errors []Diagnostic
+}
+
+// A modLine is a line of a go.mod file.
+type modLine struct {
+	// verb is the verb of the directive of the line, such as require, or ""
+	// for lines without one.
+	verb string
+	// tokens holds the words of the line, including the verb unless the
+	// line is in a block.
+	tokens []modToken
+	// comment is the comment at the end of the line, including the
+	// leading //, if any.
+	comment string
+	// inBlock is set for the directives in a block, and for the
+	// parentheses that enclose the block.
+	inBlock bool
+	// start and end are the offsets of the line, without its newline.
+	start, end int
+}
+
+// A modToken is a word of a line of a go.mod file, which may be quoted.
+type modToken struct {
+	// text is the unquoted text of the token.
+	text string
+	// raw is the token as written in the file.
+	raw string
+	// start and end are the offsets of the token in the file.
+	start, end int
+}
+
+// args returns the arguments of the directive of the line.
+func (l *modLine) args() []modToken {
+	if l.inBlock || len(l.tokens) == 0 {
+		return l.tokens
+	}
+	return l.tokens[1:]
+}
+
+// modVerbs holds the verbs of the known directives, and whether they may be
+// used in blocks.
+var modVerbs = map[string]bool{
+	"module":    false,
+	"go":        false,
+	"toolchain": false,
+	"godebug":   true,
+	"require":   true,
+	"exclude":   true,
+	"replace":   true,
+	"retract":   true,
+	"tool":      true,
+	"ignore":    true,
+}
+
+// goVersionRe matches the versions of the go directive.
+var goVersionRe = regexp.MustCompile(`^([1-9][0-9]*)\.(0|[1-9][0-9]*)(\.(0|[1-9][0-9]*))?([a-z]+[0-9]+)?$`)
+
+// parseMod parses the go.mod file f, which must be locked.
+func (v *View) parseMod(f *File) error {
+	content, err := f.read()
+	if err != nil {
+		return err
+	}
+	filename, err := f.URI.Filename()
+	if err != nil {
+		return err
+	}
+	tok := v.Config.Fset.AddFile(filename, -1, len(content))
+	tok.SetLinesForContent(content)
+	f.token = tok
+	f.mod = parseModContent(tok, string(content))
+	return nil
+}
+
+// parseModContent parses the content of the go.mod file tok.
+func parseModContent(tok *token.File, content string) *modFile {
+	mod := &modFile{}
+	errorf := func(start, end int, format string, args ...interface{}) {
+		mod.errors = append(mod.errors, Diagnostic{
+			Range:    Range{Start: tok.Pos(start), End: tok.Pos(end)},
+			Severity: SeverityError,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+	block, blockStart := "", 0
+	modules := 0
+	for start := 0; start < len(content); {
+		end := strings.IndexByte(content[start:], '\n')
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += start
+		}
+		line := &modLine{start: start, end: end}
+		next := end + 1
+		start = next
+		mod.lines = append(mod.lines, line)
+		if err := lexModLine(content, line); err != nil {
+			errorf(err.offset, end, "%s", err.msg)
+			continue
+		}
+		if block != "" {
+			line.inBlock = true
+			line.verb = block
+		}
+		if len(line.tokens) == 0 {
+			continue
+		}
+		first := line.tokens[0]
+		if block != "" {
+			if first.raw == ")" {
+				if len(line.tokens) > 1 {
+					errorf(line.tokens[1].start, line.end, "unexpected %s after )", line.tokens[1].raw)
+				}
+				line.verb = ""
+				block = ""
+				continue
+			}
+		} else {
+			line.verb = first.text
+			canBlock, ok := modVerbs[line.verb]
+			if !ok {
+				errorf(first.start, first.end, "unknown directive: %s", first.raw)
+				continue
+			}
+			if args := line.args(); len(args) == 1 && args[0].raw == "(" {
+				if !canBlock {
+					errorf(first.start, args[0].end, "%s directive cannot be a block", line.verb)
+				}
+				line.inBlock = true
+				block, blockStart = line.verb, line.start
+				continue
+			}
+			if line.verb == "module" {
+				modules++
+				if modules > 1 {
+					errorf(line.start, line.end, "repeated module statement")
+				}
+			}
+		}
+		if msg := checkModDirective(line.verb, line.args()); msg != "" {
+			errorf(line.tokens[0].start, line.tokens[len(line.tokens)-1].end, "%s", msg)
+		}
+	}
+	if block != "" {
+		errorf(blockStart, blockStart, "unterminated %s block", block)
+	}
+	return mod
+}
+
+// A modSyntaxError is an error in the syntax of a line of a go.mod file.
+type modSyntaxError struct {
+	offset int
+	msg    string
+}
+
+// lexModLine splits the content of line into its tokens and comment.
+func lexModLine(content string, line *modLine) *modSyntaxError {
+	for i := line.start; i < line.end; {
+		switch c := content[i]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(content[i:line.end], "//"):
+			line.comment = strings.TrimRight(content[i:line.end], " \t\r")
+			return nil
+		case c == '(' || c == ')':
+			line.tokens = append(line.tokens, modToken{text: string(c), raw: string(c), start: i, end: i + 1})
+			i++
+		case c == '"' || c == '`':
+			j := i + 1
+			for j < line.end && content[j] != c {
+				if c == '"' && content[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= line.end {
+				return &modSyntaxError{i, "unterminated quoted string"}
+			}
+			raw := content[i : j+1]
+			text, err := strconv.Unquote(raw)
+			if err != nil {
+				return &modSyntaxError{i, fmt.Sprintf("invalid quoted string: %v", err)}
+			}
+			line.tokens = append(line.tokens, modToken{text: text, raw: raw, start: i, end: j + 1})
+			i = j + 1
+		default:
+			j := i
+			for j < line.end && !strings.ContainsRune(" \t\r()\"`", rune(content[j])) && !strings.HasPrefix(content[j:line.end], "//") {
+				j++
+			}
+			raw := content[i:j]
+			line.tokens = append(line.tokens, modToken{text: raw, raw: raw, start: i, end: j})
+			i = j
+		}
+	}
+	return nil
+}
+
+// checkModDirective returns the reason why the directive with the given verb
+// and arguments is not valid, or "" if it is.
+func checkModDirective(verb string, args []modToken) string {
+	switch verb {
+	case "module":
+		if len(args) != 1 {
+			return "usage: module module/path"
+		}
+	case "go":
+		if len(args) != 1 {
+			return "usage: go 1.23"
+		}
+		if !goVersionRe.MatchString(args[0].text) {
+			return fmt.Sprintf("invalid go version '%s': must match format 1.23", args[0].text)
+		}
+	case "require", "exclude":
+		if len(args) != 2 {
+			return fmt.Sprintf("usage: %s module/path v1.2.3", verb)
+		}
+		if !semver.IsValid(args[1].text) {
+			return fmt.Sprintf("invalid version %q of %s", args[1].text, args[0].text)
+		}
+	case "replace":
+		arrow := -1
+		for i, arg := range args {
+			if arg.raw == "=>" {
+				arrow = i
+				break
+			}
+		}
+		from, to := args, []modToken(nil)
+		if arrow >= 0 {
+			from, to = args[:arrow], args[arrow+1:]
+		}
+		if arrow < 0 || len(from) < 1 || len(from) > 2 || len(to) < 1 || len(to) > 2 {
+			return "usage: replace module/path [v1.2.3] => other/module v1.4\n\t or replace module/path [v1.2.3] => ../local/directory"
+		}
+		if len(from) == 2 && !semver.IsValid(from[1].text) {
+			return fmt.Sprintf("invalid version %q of %s", from[1].text, from[0].text)
+		}
+		if len(to) == 2 && !semver.IsValid(to[1].text) {
+			return fmt.Sprintf("invalid version %q of %s", to[1].text, to[0].text)
+		}
+		if len(to) == 1 && !isLocalModPath(to[0].text) {
+			return "replacement module without version must be directory path (rooted or starting with ./ or ../)"
+		}
+	}
+	return ""
+}
+
+// isLocalModPath reports whether path is the path of a directory, rather
+// than of a module, in a replace directive.
+func isLocalModPath(path string) bool {
+	return filepath.IsAbs(path) || path == "." || path == ".." ||
+		strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") ||
+		strings.HasPrefix(path, `.\`) || strings.HasPrefix(path, `..\`)
+}
+
+// getMod returns the parsed content of the go.mod file f.
+func (f *File) getMod() (*modFile, error) {
+	f.view.mu.Lock()
+	defer f.view.mu.Unlock()
+	if f.mod == nil {
+		if err := f.view.parseMod(f); err != nil {
+			return nil, err
+		}
+	}
+	return f.mod, nil
+}
+
+// modDiagnostics returns the diagnostics of the go.mod file f.
+func modDiagnostics(ctx context.Context, f *File) (map[string][]Diagnostic, error) {
+	mod, err := f.getMod()
+	if err != nil {
+		return nil, err
+	}
+	filename, err := f.URI.Filename()
+	if err != nil {
+		return nil, err
+	}
+	diagnostics := append([]Diagnostic{}, mod.errors...)
+	return map[string][]Diagnostic{filename: diagnostics}, nil
+}
+
+// formatMod returns the edits that format the go.mod file f: the
+// directives in blocks are indented by a tab, the words of each line are
+// separated by single spaces, and there are no consecutive blank lines.
+// Files with syntax errors are not formatted.
+func formatMod(ctx context.Context, f *File) ([]TextEdit, error) {
+	mod, err := f.getMod()
+	if err != nil {
+		return nil, err
+	}
+	if len(mod.errors) > 0 {
+		return nil, fmt.Errorf("%s has errors", f.URI)
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) != tok.Size() {
+		return nil, fmt.Errorf("%s has changed since it was parsed", f.URI)
+	}
+	var b strings.Builder
+	blank := false
+	for _, line := range mod.lines {
+		if len(line.tokens) == 0 && line.comment == "" {
+			blank = b.Len() > 0
+			continue
+		}
+		if blank {
+			b.WriteString("\n")
+			blank = false
+		}
+		if line.inBlock && line.verb != "" && (len(line.tokens) == 0 || line.tokens[len(line.tokens)-1].raw != "(") {
+			b.WriteString("\t")
+		}
+		var words []string
+		for _, t := range line.tokens {
+			words = append(words, t.raw)
+		}
+		if line.comment != "" {
+			words = append(words, line.comment)
+		}
+		b.WriteString(strings.Join(words, " "))
+		b.WriteString("\n")
+	}
+	return computeTextEdits(tok, string(content), b.String()), nil
+}
+
+// modHover returns the information about the module required by the
+// require directive at pos in the go.mod file f: its version, and its
+// replacement if it has one.
+func modHover(ctx context.Context, f *File, pos token.Pos) (*HoverInformation, error) {
+	mod, err := f.getMod()
+	if err != nil {
+		return nil, err
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	offset := tok.Offset(pos)
+	for _, line := range mod.lines {
+		args := line.args()
+		if line.verb != "require" || len(args) != 2 || offset < args[0].start || offset > args[1].end {
+			continue
+		}
+		path, version := args[0].text, args[1].text
+		doc := fmt.Sprintf("%s is required at version %s", path, version)
+		if strings.Contains(line.comment, "indirect") {
+			doc += ", as an indirect dependency"
+		}
+		doc += "."
+		if r := mod.replacement(path, version); r != "" {
+			doc += fmt.Sprintf(" It is replaced by %s.", r)
+		}
+		return &HoverInformation{
+			Signature: path + " " + version,
+			Doc:       doc,
+			Range:     Range{Start: tok.Pos(args[0].start), End: tok.Pos(args[1].end)},
+		}, nil
+	}
+	return nil, fmt.Errorf("no require directive at the position")
+}
+
+// replacement returns the replacement of the given version of the module
+// with the given path, or "" if it is not replaced.
+func (mod *modFile) replacement(path, version string) string {
+	for _, line := range mod.lines {
+		args := line.args()
+		if line.verb != "replace" || len(args) < 3 || args[0].text != path {
+			continue
+		}
+		to := args[2:]
+		if args[1].raw != "=>" {
+			if args[1].text != version {
+				continue
+			}
+			to = args[3:]
+		}
+		var words []string
+		for _, t := range to {
+			words = append(words, t.text)
+		}
+		return strings.Join(words, " ")
+	}
+	return ""
+}
+
+// A ModRequirement is a module required by a go.mod file.
+type ModRequirement struct {
+	Path     string
+	Version  string
+	Indirect bool
+	// Range is the range of the path and version of the requirement.
+	Range Range
+}
+
+// ModModule returns the path of the module of the go.mod file f, and the
+// range of its module directive.
+func ModModule(ctx context.Context, f *File) (string, Range, error) {
+	mod, err := f.getMod()
+	if err != nil {
+		return "", Range{}, err
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return "", Range{}, err
+	}
+	for _, line := range mod.lines {
+		if args := line.args(); line.verb == "module" && len(args) == 1 {
+			return args[0].text, Range{Start: tok.Pos(line.tokens[0].start), End: tok.Pos(args[0].end)}, nil
+		}
+	}
+	return "", Range{}, fmt.Errorf("no module directive in %s", f.URI)
+}
+
+// ModRequirements returns the modules required by the go.mod file f.
+func ModRequirements(ctx context.Context, f *File) ([]ModRequirement, error) {
+	mod, err := f.getMod()
+	if err != nil {
+		return nil, err
+	}
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var reqs []ModRequirement
+	for _, line := range mod.lines {
+		args := line.args()
+		if line.verb != "require" || len(args) != 2 {
+			continue
+		}
+		reqs = append(reqs, ModRequirement{
+			Path:     args[0].text,
+			Version:  args[1].text,
+			Indirect: strings.Contains(line.comment, "indirect"),
+			Range:    Range{Start: tok.Pos(args[0].start), End: tok.Pos(args[1].end)},
+		})
+	}
+	return reqs, nil
+}
@@ -0,0 +1,318 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// parseModString parses content as the content of a go.mod file.
+func parseModString(content string) (*token.File, *modFile) {
+	tok := token.NewFileSet().AddFile("go.mod", -1, len(content))
+	tok.SetLinesForContent([]byte(content))
+	return tok, parseModContent(tok, content)
+}
+
+// describeModLine returns a summary of line, such as
+// `block require: "example.com/m" "v1.0.0" // indirect`.
+func describeModLine(line *modLine) string {
+	var b strings.Builder
+	if line.inBlock {
+		b.WriteString("block ")
+	}
+	fmt.Fprintf(&b, "%s:", line.verb)
+	for _, t := range line.tokens {
+		fmt.Fprintf(&b, " %q", t.text)
+	}
+	if line.comment != "" {
+		fmt.Fprintf(&b, " %s", line.comment)
+	}
+	return b.String()
+}
+
+func TestParseMod(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		lines   []string
+	}{
+		{
+			name:    "module",
+			content: "module example.com/m\n",
+			lines:   []string{`module: "module" "example.com/m"`},
+		},
+		{
+			name:    "quoted",
+			content: "module \"example.com/m\" // the module\n",
+			lines:   []string{`module: "module" "example.com/m" // the module`},
+		},
+		{
+			name:    "comments and blanks",
+			content: "// header\n\ngo 1.12\n",
+			lines:   []string{`: // header`, `:`, `go: "go" "1.12"`},
+		},
+		{
+			name:    "block",
+			content: "require (\n\texample.com/a v1.0.0\n\texample.com/b v1.2.0 // indirect\n)\n",
+			lines: []string{
+				`block require: "require" "("`,
+				`block require: "example.com/a" "v1.0.0"`,
+				`block require: "example.com/b" "v1.2.0" // indirect`,
+				`block : ")"`,
+			},
+		},
+		{
+			name:    "replace",
+			content: "replace example.com/a v1.0.0 => ../a\n",
+			lines:   []string{`replace: "replace" "example.com/a" "v1.0.0" "=>" "../a"`},
+		},
+		{
+			name:    "no final newline",
+			content: "module m\ngo 1.12",
+			lines:   []string{`module: "module" "m"`, `go: "go" "1.12"`},
+		},
+	} {
+		_, mod := parseModString(test.content)
+		if len(mod.errors) > 0 {
+			t.Errorf("%s: unexpected errors: %v", test.name, mod.errors)
+		}
+		var lines []string
+		for _, line := range mod.lines {
+			lines = append(lines, describeModLine(line))
+		}
+		if !reflect.DeepEqual(lines, test.lines) {
+			t.Errorf("%s: got lines\n%s\nwant\n%s", test.name, strings.Join(lines, "\n"), strings.Join(test.lines, "\n"))
+		}
+	}
+}
+
+func TestParseModErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		// line is the 1-based line of the error, and msg a substring of its
+		// message.
+		line int
+		msg  string
+	}{
+		{"unknown directive", "module m\nrequires example.com/a v1.0.0\n", 2, "unknown directive: requires"},
+		{"bad require version", "module m\nrequire example.com/a 1.0\n", 2, `invalid version "1.0" of example.com/a`},
+		{"bad require version in block", "require (\n\texample.com/a v1\n\texample.com/b latest\n)\n", 3, `invalid version "latest" of example.com/b`},
+		{"require usage", "require example.com/a\n", 1, "usage: require module/path v1.2.3"},
+		{"bad go version", "module m\ngo 1.12.x\n", 2, "invalid go version '1.12.x'"},
+		{"unterminated block", "module m\n\nrequire (\n\texample.com/a v1.0.0\n", 3, "unterminated require block"},
+		{"replace without arrow", "replace example.com/a ../a\n", 1, "usage: replace module/path"},
+		{"replace with bad version", "replace example.com/a v1.0.0 => example.com/b 2.0\n", 1, `invalid version "2.0" of example.com/b`},
+		{"replace with module path", "replace example.com/a => example.com/b\n", 1, "replacement module without version must be directory path"},
+		{"block of module", "module (\n\tm\n)\n", 1, "module directive cannot be a block"},
+		{"repeated module", "module m\nmodule n\n", 2, "repeated module statement"},
+		{"unterminated string", "module \"m\n", 1, "unterminated quoted string"},
+	} {
+		tok, mod := parseModString(test.content)
+		if len(mod.errors) != 1 {
+			t.Errorf("%s: got %d errors, want 1: %v", test.name, len(mod.errors), mod.errors)
+			continue
+		}
+		err := mod.errors[0]
+		if got := tok.Line(err.Range.Start); got != test.line {
+			t.Errorf("%s: got error on line %d, want %d", test.name, got, test.line)
+		}
+		if !strings.Contains(err.Message, test.msg) {
+			t.Errorf("%s: got error %q, want %q", test.name, err.Message, test.msg)
+		}
+		if err.Severity != SeverityError {
+			t.Errorf("%s: got severity %v, want %v", test.name, err.Severity, SeverityError)
+		}
+	}
+}
+
+func TestParseModValid(t *testing.T) {
+	for _, content := range []string{
+		"module example.com/m\n\ngo 1.12\n",
+		"go 1.21rc1\n",
+		"require example.com/a v1.0.0-20190101000000-abcdefabcdef\n",
+		"exclude example.com/a v1.0.0\n",
+		"replace example.com/a => ./a\n",
+		"replace example.com/a => /home/gopher/a\n",
+		"replace example.com/a v1.0.0 => example.com/b v1.1.0\n",
+		"replace (\n\texample.com/a => ../a\n)\n",
+	} {
+		if _, mod := parseModString(content); len(mod.errors) > 0 {
+			t.Errorf("%q: unexpected errors: %v", content, mod.errors)
+		}
+	}
+}
+
+// modTestFile returns the go.mod file of a new view, with the given content.
+func modTestFile(content string) *File {
+	f := NewView().GetFile(ToURI("/gopher/m/go.mod"))
+	f.SetContent([]byte(content))
+	return f
+}
+
+// applyEdits returns content with the edits of the file tok applied.
+func applyEdits(tok *token.File, content string, edits []TextEdit) string {
+	edits = append([]TextEdit(nil), edits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].Range.Start < edits[j].Range.Start
+	})
+	var b strings.Builder
+	last := 0
+	for _, edit := range edits {
+		start, end := tok.Offset(edit.Range.Start), tok.Offset(edit.Range.End)
+		b.WriteString(content[last:start])
+		b.WriteString(edit.NewText)
+		last = end
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+func TestFormatMod(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name, content, want string
+	}{
+		{
+			name:    "formatted",
+			content: "module example.com/m\n\ngo 1.12\n\nrequire (\n\texample.com/a v1.0.0\n\texample.com/b v1.2.0 // indirect\n)\n",
+			want:    "module example.com/m\n\ngo 1.12\n\nrequire (\n\texample.com/a v1.0.0\n\texample.com/b v1.2.0 // indirect\n)\n",
+		},
+		{
+			name:    "spaces",
+			content: "module   example.com/m\ngo\t1.12   \n",
+			want:    "module example.com/m\ngo 1.12\n",
+		},
+		{
+			name:    "indentation",
+			content: "require (\nexample.com/a v1.0.0\n    example.com/b v1.2.0\n  )\n",
+			want:    "require (\n\texample.com/a v1.0.0\n\texample.com/b v1.2.0\n)\n",
+		},
+		{
+			name:    "blank lines",
+			content: "\n\nmodule m\n\n\n\ngo 1.12\n\n",
+			want:    "module m\n\ngo 1.12\n",
+		},
+		{
+			name:    "comments",
+			content: "// header\nmodule m   //   the module\n",
+			want:    "// header\nmodule m //   the module\n",
+		},
+		{
+			name:    "quoted",
+			content: "module \"example.com/m\"\nreplace example.com/a   =>\t../a\n",
+			want:    "module \"example.com/m\"\nreplace example.com/a => ../a\n",
+		},
+		{
+			name:    "no final newline",
+			content: "module m\ngo 1.12",
+			want:    "module m\ngo 1.12\n",
+		},
+	} {
+		f := modTestFile(test.content)
+		edits, err := formatMod(ctx, f)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		tok, err := f.GetToken(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := applyEdits(tok, test.content, edits)
+		if got != test.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", test.name, got, test.want)
+			continue
+		}
+		// Formatting is idempotent.
+		f = modTestFile(got)
+		if edits, err := formatMod(ctx, f); err != nil || len(edits) > 0 {
+			t.Errorf("%s: formatting the formatted file got edits %v, %v", test.name, edits, err)
+		}
+	}
+}
+
+func TestFormatModErrors(t *testing.T) {
+	f := modTestFile("module m\nrequire (\n")
+	if edits, err := formatMod(context.Background(), f); err == nil {
+		t.Errorf("formatting a file with errors got edits %v, want an error", edits)
+	}
+}
+
+func TestModDiagnostics(t *testing.T) {
+	f := modTestFile("module m\nfoo bar\n")
+	reports, err := modDiagnostics(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename, err := f.URI.Filename()
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagnostics := reports[filename]
+	if len(reports) != 1 || len(diagnostics) != 1 || diagnostics[0].Message != "unknown directive: foo" {
+		t.Errorf("got diagnostics %v, want one for the unknown directive of %s", reports, filename)
+	}
+}
+
+func TestModHover(t *testing.T) {
+	ctx := context.Background()
+	const content = `module example.com/m
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.2.0 // indirect
+)
+
+require example.com/c v0.1.0
+
+replace example.com/a => ../a
+
+replace example.com/c v0.1.0 => example.com/d v0.2.0
+`
+	for _, test := range []struct {
+		// at is the text of the content that the position is at the start
+		// of, which is unique.
+		at        string
+		signature string
+		doc       string
+	}{
+		{"example.com/a v1", "example.com/a v1.0.0", "example.com/a is required at version v1.0.0. It is replaced by ../a."},
+		{"v1.2.0", "example.com/b v1.2.0", "example.com/b is required at version v1.2.0, as an indirect dependency."},
+		{"example.com/c v0", "example.com/c v0.1.0", "example.com/c is required at version v0.1.0. It is replaced by example.com/d v0.2.0."},
+		{"module", "", ""},
+		{"example.com/a =>", "", ""},
+	} {
+		f := modTestFile(content)
+		tok, err := f.GetToken(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset := strings.Index(content, test.at)
+		hover, err := modHover(ctx, f, tok.Pos(offset))
+		if test.signature == "" {
+			if err == nil {
+				t.Errorf("hover at %q: got %v, want an error", test.at, hover)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("hover at %q: %v", test.at, err)
+			continue
+		}
+		if hover.Signature != test.signature || hover.Doc != test.doc {
+			t.Errorf("hover at %q: got %q, %q, want %q, %q", test.at, hover.Signature, hover.Doc, test.signature, test.doc)
+		}
+		if got := tok.Offset(hover.Range.Start); got != strings.Index(content, test.signature[:len("example.com/a")]) {
+			t.Errorf("hover at %q: range starts at offset %d", test.at, got)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// configFor returns the configuration with which to load the package of the
+// file named filename: the configuration of the view, with the build flags
+// and environment of its options.
+// Files in a module, that is in a directory that has a go.mod file or
+// whose parent directories do, are loaded in module mode from the root
+// of the module, with a configuration of their own for each module.
+func (v *View) configFor(filename string) *packages.Config {
+	env := v.Config.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	env = append(env[:len(env):len(env)], v.options.Env...)
+	modFile := ""
+	if getenv(env, "GO111MODULE") != "off" {
+		modFile = v.modFile(filepath.Dir(filename))
+	}
+	if cfg, ok := v.moduleConfigs[modFile]; ok && modFile != "" {
+		return cfg
+	}
+	cfg := *v.Config
+	cfg.BuildFlags = append(cfg.BuildFlags[:len(cfg.BuildFlags):len(cfg.BuildFlags)], v.options.BuildFlags...)
+	cfg.Env = env
+	if modFile == "" {
+		return &cfg
+	}
+	cfg.Dir = filepath.Dir(modFile)
+	cfg.Env = append(env, "GO111MODULE=on")
+	v.moduleConfigs[modFile] = &cfg
+	return &cfg
+}
+
+// Env returns the environment in which to run the go command in dir, which
+// is that of the configuration of the module of dir, if any.
+func (v *View) Env(dir string) []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.configFor(filepath.Join(dir, "go.mod")).Env
+}
+
+// modFile returns the go.mod file of the module that contains the
+// directory dir, or "" if it is not in a module.
+// The result is cached for dir and its parent directories.
+func (v *View) modFile(dir string) string {
+	if modFile, ok := v.modFiles[dir]; ok {
+		return modFile
+	}
+	modFile := filepath.Join(dir, "go.mod")
+	if fi, err := os.Stat(modFile); err != nil || fi.IsDir() {
+		modFile = ""
+		if parent := filepath.Dir(dir); parent != dir {
+			modFile = v.modFile(parent)
+		}
+	}
+	v.modFiles[dir] = modFile
+	return modFile
+}
+
+// sameModule reports whether the files named a and b are in the same
+// module, or both outside of modules.
+func (v *View) sameModule(a, b string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.modFile(filepath.Dir(a)) == v.modFile(filepath.Dir(b))
+}
+
+// getenv returns the value of the variable key in env, which holds
+// "key=value" strings as os.Environ does, or in the environment of the
+// process if env is nil. The last value wins, as it does for the go
+// command.
+func getenv(env []string, key string) string {
+	if env == nil {
+		return os.Getenv(key)
+	}
+	for i := len(env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(env[i], key+"=") {
+			return env[i][len(key)+1:]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// NewFileContent returns the content of f, a new Go file that is blank,
+// which declares the package of the other Go files of its directory: their
+// package, or for a test file, the package of the other test files if
+// they have one, such as an external test package. In a directory without
+// Go files, it is named after the directory.
+// The content of a test file is the skeleton of a test function too, named
+// after the file.
+func NewFileContent(f *File) (string, error) {
+	filename, err := f.URI.Filename()
+	if err != nil {
+		return "", err
+	}
+	if filepath.Ext(filename) != ".go" {
+		return "", fmt.Errorf("%s is not a Go file", f.URI)
+	}
+	content, err := f.Read()
+	if err != nil {
+		return "", err
+	}
+	if len(bytes.TrimSpace(content)) > 0 {
+		return "", fmt.Errorf("%s is not empty", f.URI)
+	}
+	isTest := strings.HasSuffix(filename, "_test.go")
+	name := siblingPackageName(filename, isTest)
+	if name == "" && isTest {
+		name = siblingPackageName(filename, false)
+	}
+	if name == "" {
+		name = packageNameOf(filepath.Dir(filename))
+	}
+	if !isTest {
+		return fmt.Sprintf("package %s\n", name), nil
+	}
+	// The words of the name of the file, such as "string" and "util" in
+	// string_util_test.go, make the name of the test, TestStringUtil.
+	words := strings.FieldsFunc(strings.TrimSuffix(filepath.Base(filename), "_test.go"), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	test := "Test"
+	for _, word := range words {
+		test += strings.Title(word)
+	}
+	return fmt.Sprintf(`package %s
+
+import "testing"
+
+func %s(t *testing.T) {
+}
+`, name, test), nil
+}
+
+// siblingPackageName returns the package that the most Go files of the
+// directory of filename declare, other than filename itself, among the test
+// files or among the others, or "" if there is none.
+func siblingPackageName(filename string, tests bool) string {
+	dir := filepath.Dir(filename)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	fset := token.NewFileSet()
+	counts := make(map[string]int)
+	best := ""
+	for _, info := range infos {
+		name := info.Name()
+		path := filepath.Join(dir, name)
+		if info.IsDir() || filepath.Ext(name) != ".go" || path == filename || strings.HasSuffix(name, "_test.go") != tests {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.PackageClauseOnly)
+		if err != nil || file.Name == nil {
+			continue
+		}
+		pkg := file.Name.Name
+		// The documentation of commands is in files of a package of its own.
+		if pkg == "documentation" {
+			continue
+		}
+		counts[pkg]++
+		if counts[pkg] > counts[best] || counts[pkg] == counts[best] && pkg < best {
+			best = pkg
+		}
+	}
+	return best
+}
+
+// packageNameOf returns a valid package name made of the letters and digits
+// of the base name of path, in lower case, such as "gotools" for
+// "go-tools".
+func packageNameOf(path string) string {
+	var b strings.Builder
+	for _, r := range filepath.Base(path) {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsDigit(r) && b.Len() > 0:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "main"
+	}
+	return b.String()
+}
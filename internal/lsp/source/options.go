@@ -0,0 +1,150 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"reflect"
+	"time"
+)
+
+// Options are the settings of a view, which the user may set for each
+// workspace folder.
+type Options struct {
+	Completion CompletionOptions
+
+	Hover HoverOptions
+
+	References ReferencesOptions
+
+	Diagnostics DiagnosticsOptions
+
+	// Templates enables the support of the files of text/template and
+	// html/template templates, whose extension is .tmpl or .gotmpl.
+	Templates bool
+
+	// FormatOnSave formats the Go files before they are saved.
+	FormatOnSave bool
+
+	// OrganizeImportsOnSave adds and removes the imports of the Go files
+	// before they are saved, and formats them, as goimports does.
+	OrganizeImportsOnSave bool
+
+	// BuildFlags are the flags of the go command with which the packages
+	// are loaded, such as -tags.
+	BuildFlags []string
+
+	// Env holds "key=value" strings that are added to the environment of
+	// the go command.
+	Env []string
+
+	// DocumentationURL is the base URL of the documentation of packages,
+	// to which the import paths link. Empty, they link nowhere.
+	DocumentationURL string
+
+	// Hints enables or disables each kind of inlay hint.
+	Hints map[InlayHintKind]bool
+
+	// StructTagCase is the naming convention of added struct tags.
+	StructTagCase TagCase
+
+	// TypeCheckParallelism is the maximum number of packages that are
+	// type-checked at the same time. Zero or less selects the number of
+	// CPUs.
+	TypeCheckParallelism int
+
+	// MemoryBudget is the estimated memory, in bytes, that the cached
+	// packages may use before the least recently used ones are evicted.
+	// Zero or less means no limit.
+	MemoryBudget int64
+
+	// DiagnosticsDelay is the delay after which the diagnostics of a
+	// changed document are published, if it does not change again in the
+	// meantime.
+	DiagnosticsDelay time.Duration
+}
+
+// CompletionOptions are the settings of completion.
+type CompletionOptions struct {
+	// MatchCase requires the candidates to contain the characters of the
+	// prefix of the identifier at the position of the completion in order
+	// and in the same case.
+	MatchCase bool
+
+	// UsePlaceholders makes the completions of functions snippets, with a
+	// placeholder for each parameter, for the clients that support them.
+	UsePlaceholders bool
+
+	// MaxResults is the maximum number of candidates that are returned,
+	// the best ones first. Zero or less means no limit.
+	MaxResults int
+}
+
+// HoverOptions are the settings of hover.
+type HoverOptions struct {
+	// Verbose adds the details of the hovered object: the value of a
+	// constant in other notations, the size and alignment of a type, and the
+	// offset of a struct field.
+	Verbose bool
+}
+
+// ReferencesOptions are the settings of references.
+type ReferencesOptions struct {
+	// IncludeImplementations adds the references to the implementations of
+	// an interface method, and their declarations, to its references.
+	IncludeImplementations bool
+}
+
+// DiagnosticsOptions are the settings of diagnostics.
+type DiagnosticsOptions struct {
+	// Analyses enables or disables the analyses that report diagnostics, by
+	// name. The analyses that it does not name have their default state.
+	Analyses map[string]bool
+
+	// AnalysisSeverities overrides the severity of the diagnostics of the
+	// analyses, by name. The analyses that it does not name have their
+	// default severity.
+	AnalysisSeverities map[string]DiagnosticSeverity
+
+	// UnusedExported reports the exported functions and types that no
+	// package of the workspace uses, as hints.
+	UnusedExported bool
+}
+
+// DefaultOptions returns the options of a view that the user has not
+// configured.
+func DefaultOptions() Options {
+	return Options{
+		Completion: CompletionOptions{
+			MatchCase: true,
+		},
+		Hints: map[InlayHintKind]bool{
+			ParameterNameHint: true,
+			VariableTypeHint:  true,
+		},
+		DocumentationURL: "https://pkg.go.dev",
+		DiagnosticsDelay: 250 * time.Millisecond,
+	}
+}
+
+// Options returns the options of the view.
+func (v *View) Options() Options {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.options
+}
+
+// SetOptions sets the options of the view. A change of the build flags or
+// of the environment reloads the packages of the view.
+func (v *View) SetOptions(options Options) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	reload := !reflect.DeepEqual(options.BuildFlags, v.options.BuildFlags) ||
+		!reflect.DeepEqual(options.Env, v.options.Env)
+	v.options = options
+	if reload {
+		v.reload()
+	}
+	v.evict("")
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"strconv"
+)
+
+// A postfixTemplate is a completion of a selector of an expression
+// statement that replaces the statement with one that uses the
+// expression, such as x.print, which becomes fmt.Println(x).
+type postfixTemplate struct {
+	name, detail string
+
+	// importPath is the path of the package that the statement uses, if
+	// any. The package is imported if the file does not import it yet.
+	importPath string
+
+	// applies reports whether the template applies to the expressions of
+	// type t.
+	applies func(t types.Type) bool
+
+	// expand returns the statement for the expression x of type t, calling
+	// tabStop for its tab stops, which returns their text, and with qual as
+	// the name of the imported package.
+	expand func(x string, t types.Type, qual string, tabStop func(n int, text string) string) string
+}
+
+var postfixTemplates = []postfixTemplate{
+	{
+		name:   "if",
+		detail: "if expr {}",
+		applies: func(t types.Type) bool {
+			b, ok := t.Underlying().(*types.Basic)
+			return ok && b.Info()&types.IsBoolean != 0
+		},
+		expand: func(x string, _ types.Type, _ string, tabStop func(int, string) string) string {
+			return "if " + x + " {\n\t" + tabStop(0, "") + "\n}"
+		},
+	},
+	{
+		name:    "ifnotnil",
+		detail:  "if expr != nil {}",
+		applies: isNillable,
+		expand: func(x string, _ types.Type, _ string, tabStop func(int, string) string) string {
+			return "if " + x + " != nil {\n\t" + tabStop(0, "") + "\n}"
+		},
+	},
+	{
+		name:   "range",
+		detail: "for ... := range expr {}",
+		applies: func(t types.Type) bool {
+			switch t := t.Underlying().(type) {
+			case *types.Slice, *types.Array, *types.Map, *types.Chan:
+				return true
+			case *types.Pointer:
+				_, ok := t.Elem().Underlying().(*types.Array)
+				return ok
+			case *types.Basic:
+				return t.Info()&types.IsString != 0
+			}
+			return false
+		},
+		expand: func(x string, t types.Type, _ string, tabStop func(int, string) string) string {
+			var vars string
+			switch t.Underlying().(type) {
+			case *types.Map:
+				vars = tabStop(1, "k") + ", " + tabStop(2, "v")
+			case *types.Chan:
+				vars = tabStop(1, "v")
+			default:
+				vars = tabStop(1, "i") + ", " + tabStop(2, "v")
+			}
+			return "for " + vars + " := range " + x + " {\n\t" + tabStop(0, "") + "\n}"
+		},
+	},
+	{
+		name:       "print",
+		detail:     "fmt.Println(expr)",
+		importPath: "fmt",
+		applies:    func(types.Type) bool { return true },
+		expand: func(x string, _ types.Type, qual string, tabStop func(int, string) string) string {
+			return qual + ".Println(" + x + ")" + tabStop(0, "")
+		},
+	},
+}
+
+// isNillable reports whether the values of type t may be nil.
+func isNillable(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Interface, *types.Map, *types.Slice, *types.Chan, *types.Signature:
+		return true
+	}
+	return false
+}
+
+// postfix returns the postfix completions of sel, the selector of an
+// expression statement whose selected identifier is being completed, such
+// as x.ra, which completes to a loop over x. path is the path to sel, and
+// pos the position of the completion.
+// There are none until a prefix of the selected identifier is typed, so
+// that they don't crowd the members of x.
+func postfix(file *ast.File, path []ast.Node, sel *ast.SelectorExpr, fset *token.FileSet, info *types.Info, prefix string, pos token.Pos) (items []CompletionItem) {
+	if prefix == "" || len(path) < 2 {
+		return nil
+	}
+	if _, ok := path[1].(*ast.ExprStmt); !ok {
+		return nil
+	}
+	tv, ok := info.Types[sel.X]
+	if !ok || !tv.IsValue() || tv.Type == nil || tv.Type == types.Typ[types.Invalid] {
+		return nil
+	}
+	if fset.Position(sel.X.Pos()).Line != fset.Position(pos).Line {
+		return nil // the edits of completions may not span lines
+	}
+	x := types.ExprString(sel.X)
+	for _, tmpl := range postfixTemplates {
+		if !tmpl.applies(tv.Type) {
+			continue
+		}
+		score, ok := fuzzyMatch(prefix, tmpl.name)
+		if !ok {
+			continue
+		}
+		qual, imported := "", true
+		if tmpl.importPath != "" {
+			qual, imported = importName(file, tmpl.importPath)
+		}
+		item := CompletionItem{
+			Label:  tmpl.name,
+			Detail: tmpl.detail,
+			Kind:   SnippetCompletionItem,
+			Score:  stdScore * 0.5 * score,
+			Snippet: tmpl.expand(snippetEscaper.Replace(x), tv.Type, qual, func(n int, text string) string {
+				if text == "" {
+					return fmt.Sprintf("$%d", n)
+				}
+				return fmt.Sprintf("${%d:%s}", n, snippetEscaper.Replace(text))
+			}),
+			InsertText: tmpl.expand(x, tv.Type, qual, func(_ int, text string) string {
+				return text
+			}),
+			FilterText: x + "." + tmpl.name,
+			Replace:    Range{Start: sel.X.Pos(), End: pos},
+		}
+		if !imported {
+			item.importPath, item.importName = tmpl.importPath, qual
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// importName returns the name under which the file imports the package
+// with the given path, and whether it imports it. If it does not, the name
+// is the last element of the path.
+func importName(file *ast.File, importPath string) (string, bool) {
+	for _, imp := range file.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err != nil || p != importPath {
+			continue
+		}
+		if imp.Name == nil {
+			return path.Base(importPath), true
+		}
+		if imp.Name.Name != "_" && imp.Name.Name != "." {
+			return imp.Name.Name, true
+		}
+	}
+	return path.Base(importPath), false
+}
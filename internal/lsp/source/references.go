@@ -0,0 +1,90 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// References returns the ranges of all the identifiers that refer to the same
+// object as the identifier at pos, across all the packages loaded in the view.
+// If includeDeclaration is set, the declaring identifier is part of the result.
+// If opts.IncludeImplementations is set and the object is an interface
+// method, the references to its implementations, and their declarations,
+// are too: with those to the interface method, which include the calls that
+// dispatch through the interface, they are all the calls that may run the
+// implementations.
+func References(ctx context.Context, v *View, f *File, pos token.Pos, includeDeclaration bool, opts ReferencesOptions) ([]Range, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i, err := findIdentifier(fAST, pos)
+	if err != nil {
+		return nil, err
+	}
+	if i.ident == nil {
+		return nil, fmt.Errorf("references was not a valid identifier")
+	}
+	obj := pkg.TypesInfo.ObjectOf(i.ident)
+	if obj == nil {
+		return nil, fmt.Errorf("no object for %s", i.ident.Name)
+	}
+	if obj.Pkg() == nil {
+		return nil, fmt.Errorf("no references for builtin %s", obj.Name())
+	}
+	objs := []types.Object{obj}
+	if opts.IncludeImplementations {
+		if iface, method := interfaceMethod(obj); iface != nil {
+			impls, err := v.implementations(ctx, iface, method)
+			if err != nil {
+				return nil, err
+			}
+			objs = append(objs, impls...)
+		}
+	}
+	seen := make(map[token.Position]bool)
+	var refs []Range
+	for _, p := range v.packages() {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		idx := v.index(p)
+		for _, obj := range objs {
+			key := v.keyOf(obj)
+			for _, id := range idx.refs[key] {
+				// The same file may be type-checked as part of several packages
+				// (for example, its test variant), so deduplicate by position.
+				posn := v.Config.Fset.Position(id.Pos())
+				if seen[posn] {
+					continue
+				}
+				seen[posn] = true
+				if !includeDeclaration && posn.Filename == key.filename && posn.Offset == key.offset {
+					continue
+				}
+				refs = append(refs, Range{Start: id.Pos(), End: id.End()})
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		pi, pj := v.Config.Fset.Position(refs[i].Start), v.Config.Fset.Position(refs[j].Start)
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	return refs, nil
+}
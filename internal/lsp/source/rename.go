@@ -0,0 +1,123 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"unicode"
+)
+
+// Rename returns the edits, grouped by file, that are required to rename the
+// object referred to by the identifier at pos to newName.
+// The edits cover every reference to the object in the packages loaded by
+// the view, not only those in f, and those to the objects renamed with it:
+// the methods that the types implementing interfaces need to keep doing so,
+// and the fields that embed a type. Renames that change the meaning of the
+// program, such as by making a declaration shadow another, fail.
+func Rename(ctx context.Context, v *View, f *File, pos token.Pos, newName string) (map[URI][]TextEdit, error) {
+	if !isValidIdentifier(newName) {
+		return nil, fmt.Errorf("invalid identifier to rename: %q", newName)
+	}
+	_, obj, err := renameTarget(ctx, v, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Name() == newName {
+		return nil, fmt.Errorf("old and new names are the same: %s", newName)
+	}
+	filename, err := f.URI.Filename()
+	if err != nil {
+		return nil, err
+	}
+	r := newRenamer(v, obj, newName)
+	for _, other := range r.sortedObjects() {
+		declFilename := v.Config.Fset.Position(other.Pos()).Filename
+		if declFilename == "" || !v.sameModule(filename, declFilename) {
+			return nil, fmt.Errorf("cannot rename %s, which requires renaming the one declared in another module at %s", obj.Name(), v.Config.Fset.Position(other.Pos()))
+		}
+	}
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+	return r.edits(), nil
+}
+
+// PrepareRename returns the range of the identifier at pos, which Rename
+// renames, or an error that explains why it cannot be renamed.
+func PrepareRename(ctx context.Context, v *View, f *File, pos token.Pos) (Range, error) {
+	id, _, err := renameTarget(ctx, v, f, pos)
+	if err != nil {
+		return Range{}, err
+	}
+	return Range{Start: id.Pos(), End: id.End()}, nil
+}
+
+// renameTarget returns the identifier at pos and the object that it refers
+// to, if the object may be renamed: it must not be a package, a builtin, or
+// declared in another module than f, whose files the view does not edit.
+func renameTarget(ctx context.Context, v *View, f *File, pos token.Pos) (*ast.Ident, types.Object, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, spec := range fAST.Imports {
+		if spec.Path.Pos() <= pos && pos <= spec.Path.End() {
+			return nil, nil, fmt.Errorf("cannot rename the package of an import path")
+		}
+	}
+	if fAST.Name != nil && fAST.Name.Pos() <= pos && pos <= fAST.Name.End() {
+		return nil, nil, fmt.Errorf("cannot rename package %s", fAST.Name.Name)
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	i, err := findIdentifier(fAST, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+	if i.ident == nil {
+		return nil, nil, fmt.Errorf("rename was not a valid identifier")
+	}
+	obj := pkg.TypesInfo.ObjectOf(i.ident)
+	if obj == nil {
+		return nil, nil, fmt.Errorf("no object for %s", i.ident.Name)
+	}
+	// An embedded field is named after its type, which is renamed instead.
+	if field, ok := obj.(*types.Var); ok && field.Anonymous() {
+		if tn, ok := pkg.TypesInfo.Uses[i.ident].(*types.TypeName); ok {
+			obj = tn
+		}
+	}
+	if obj.Pkg() == nil || obj.Pkg() == types.Unsafe {
+		return nil, nil, fmt.Errorf("cannot rename builtin %q", obj.Name())
+	}
+	filename, err := f.URI.Filename()
+	if err != nil {
+		return nil, nil, err
+	}
+	declFilename := v.Config.Fset.Position(obj.Pos()).Filename
+	if declFilename == "" || !v.sameModule(filename, declFilename) {
+		return nil, nil, fmt.Errorf("cannot rename %s, which is declared in another module", obj.Name())
+	}
+	return i.ident, obj, nil
+}
+
+// isValidIdentifier reports whether id is a valid, non-blank Go identifier.
+func isValidIdentifier(id string) bool {
+	if id == "" || id == "_" {
+		return false
+	}
+	for i, r := range id {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return token.Lookup(id) == token.IDENT
+}
@@ -0,0 +1,458 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// A renamer renames a set of objects that must be renamed together, such as
+// a method and the methods of the interfaces that its type implements, after
+// checking that the new name does not conflict with others, as
+// refactor/rename does.
+type renamer struct {
+	v        *View
+	pkgs     []*packages.Package
+	target   types.Object
+	from, to string
+
+	// objs are the objects to rename, by key.
+	objs map[objKey]types.Object
+
+	conflicts []string
+}
+
+func newRenamer(v *View, target types.Object, to string) *renamer {
+	r := &renamer{
+		v:      v,
+		pkgs:   v.packages(),
+		target: target,
+		from:   target.Name(),
+		to:     to,
+		objs:   make(map[objKey]types.Object),
+	}
+	r.objs[v.keyOf(target)] = target
+	switch obj := target.(type) {
+	case *types.Func:
+		if obj.Type().(*types.Signature).Recv() != nil {
+			r.addCoupledMethods()
+		}
+	case *types.TypeName:
+		r.addEmbeddedFields()
+	}
+	return r
+}
+
+// addCoupledMethods adds the methods that must be renamed along with the
+// target, a method, for the types that implement interfaces to still do:
+// the methods of the interfaces implemented by the types whose method sets
+// have the target, the methods of the other types that implement them,
+// and so on.
+func (r *renamer) addCoupledMethods() {
+	// Connect the method of each interface that has one of the name to the
+	// methods that select it in the types that implement the interface.
+	var ifaces, concretes []types.Type
+	for _, p := range r.pkgs {
+		for _, obj := range r.v.index(p).symbols {
+			if tn, ok := obj.(*types.TypeName); ok && !tn.IsAlias() {
+				if types.IsInterface(tn.Type()) {
+					ifaces = append(ifaces, tn.Type())
+				} else {
+					concretes = append(concretes, tn.Type())
+				}
+			}
+		}
+	}
+	pkg := r.target.Pkg()
+	edges := make(map[objKey][]types.Object)
+	connect := func(a, b types.Object) {
+		ka, kb := r.v.keyOf(a), r.v.keyOf(b)
+		edges[ka] = append(edges[ka], b)
+		edges[kb] = append(edges[kb], a)
+	}
+	for _, iface := range ifaces {
+		sel := types.NewMethodSet(iface).Lookup(pkg, r.from)
+		if sel == nil {
+			continue
+		}
+		for _, T := range concretes {
+			if !types.Implements(T, iface.Underlying().(*types.Interface)) {
+				T = types.NewPointer(T)
+				if !types.Implements(T, iface.Underlying().(*types.Interface)) {
+					continue
+				}
+			}
+			if m := types.NewMethodSet(T).Lookup(pkg, r.from); m != nil {
+				connect(sel.Obj(), m.Obj())
+			}
+		}
+	}
+	queue := []types.Object{r.target}
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:]
+		for _, coupled := range edges[r.v.keyOf(obj)] {
+			if key := r.v.keyOf(coupled); r.objs[key] == nil {
+				r.objs[key] = coupled
+				queue = append(queue, coupled)
+			}
+		}
+	}
+}
+
+// addEmbeddedFields adds the fields that embed the target, a type, which
+// are named after it.
+func (r *renamer) addEmbeddedFields() {
+	key := r.v.keyOf(r.target)
+	for _, p := range r.pkgs {
+		for _, obj := range p.TypesInfo.Defs {
+			field, ok := obj.(*types.Var)
+			if !ok || !field.Anonymous() {
+				continue
+			}
+			T := field.Type()
+			if ptr, ok := T.(*types.Pointer); ok {
+				T = ptr.Elem()
+			}
+			if named, ok := T.(*types.Named); ok && r.v.keyOf(named.Obj()) == key {
+				r.objs[r.v.keyOf(field)] = field
+			}
+		}
+	}
+}
+
+// sortedObjects returns the objects to rename in the order of their
+// declarations.
+func (r *renamer) sortedObjects() []types.Object {
+	var objs []types.Object
+	for _, obj := range r.objs {
+		objs = append(objs, obj)
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		ki, kj := r.v.keyOf(objs[i]), r.v.keyOf(objs[j])
+		if ki.filename != kj.filename {
+			return ki.filename < kj.filename
+		}
+		return ki.offset < kj.offset
+	})
+	return objs
+}
+
+// check returns an error that lists the conflicts of the new name, if
+// there are any.
+func (r *renamer) check() error {
+	for _, obj := range r.sortedObjects() {
+		r.checkExport(obj)
+		for _, p := range r.pkgs {
+			if p.Types.Path() != obj.Pkg().Path() {
+				continue
+			}
+			// The package may be type-checked in several variants, such as
+			// with its tests, each with objects of its own.
+			obj := r.lookup(p, r.v.keyOf(obj))
+			if obj == nil {
+				continue
+			}
+			switch obj := obj.(type) {
+			case *types.Var:
+				if obj.IsField() {
+					r.checkField(p, obj)
+					continue
+				}
+			case *types.Func:
+				if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+					r.checkMethod(obj, recv.Type())
+					continue
+				}
+			case *types.Label:
+				continue
+			}
+			if obj.Parent() == p.Types.Scope() {
+				r.checkPackageLevel(p, obj)
+			} else if obj.Parent() != nil {
+				r.checkLocal(p, obj)
+			}
+		}
+	}
+	for _, p := range r.pkgs {
+		r.checkSelections(p)
+	}
+	if len(r.conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(r.conflicts)
+	var msgs []string
+	for i, msg := range r.conflicts {
+		if i == 0 || msg != r.conflicts[i-1] {
+			msgs = append(msgs, msg)
+		}
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "\n"))
+}
+
+// conflict records a conflict of the new name at pos.
+func (r *renamer) conflict(pos token.Pos, format string, args ...interface{}) {
+	msg := fmt.Sprintf("renaming %s to %s ", r.from, r.to) + fmt.Sprintf(format, args...)
+	r.conflicts = append(r.conflicts, fmt.Sprintf("%s: %s", r.v.Config.Fset.Position(pos), msg))
+}
+
+// lookup returns the object of p with key, if p declares it.
+func (r *renamer) lookup(p *packages.Package, key objKey) types.Object {
+	for _, id := range r.v.index(p).refs[key] {
+		if obj := p.TypesInfo.Defs[id]; obj != nil {
+			return obj
+		}
+	}
+	for _, obj := range p.TypesInfo.Implicits {
+		if obj, ok := obj.(*types.PkgName); ok && r.v.keyOf(obj) == key {
+			return obj
+		}
+	}
+	return nil
+}
+
+// checkExport checks that no other package refers to obj if the new name
+// unexports it.
+func (r *renamer) checkExport(obj types.Object) {
+	if !ast.IsExported(r.from) || ast.IsExported(r.to) {
+		return
+	}
+	key := r.v.keyOf(obj)
+	for _, p := range r.pkgs {
+		if p.Types.Path() == obj.Pkg().Path() {
+			continue
+		}
+		if refs := r.v.index(p).refs[key]; len(refs) > 0 {
+			r.conflict(refs[0].Pos(), "would unexport it, but package %s refers to it", p.Types.Path())
+		}
+	}
+}
+
+// checkPackageLevel checks that the new name of obj, a package-level object
+// of p, is not declared in the package or its files already, and that the
+// references to obj and to the objects of the new name keep referring to
+// them.
+func (r *renamer) checkPackageLevel(p *packages.Package, obj types.Object) {
+	if r.from == "init" || r.from == "main" && p.Types.Name() == "main" {
+		if _, ok := obj.(*types.Func); ok {
+			r.conflict(obj.Pos(), "would change the meaning of the program")
+			return
+		}
+	}
+	if r.to == "init" || r.to == "main" && p.Types.Name() == "main" {
+		r.conflict(obj.Pos(), "would declare a special function")
+	}
+	if other := p.Types.Scope().Lookup(r.to); other != nil {
+		r.conflict(obj.Pos(), "would conflict with the declaration at %s", r.v.Config.Fset.Position(other.Pos()))
+	}
+	for i := 0; i < p.Types.Scope().NumChildren(); i++ {
+		if other := p.Types.Scope().Child(i).Lookup(r.to); other != nil {
+			r.conflict(other.Pos(), "would conflict with this import")
+		}
+	}
+	r.checkReferences(p, obj)
+	for id, used := range p.TypesInfo.Uses {
+		if id.Name == r.to && used.Parent() == types.Universe {
+			r.conflict(id.Pos(), "would shadow this reference to the builtin %s", r.to)
+		}
+	}
+}
+
+// checkLocal checks that the new name of obj, a local object of p or an
+// imported package, is not declared in its scope already, and that the
+// references to obj and to the objects of the new name keep referring to
+// them.
+func (r *renamer) checkLocal(p *packages.Package, obj types.Object) {
+	scope := obj.Parent()
+	if other := scope.Lookup(r.to); other != nil {
+		r.conflict(obj.Pos(), "would conflict with the declaration at %s", r.v.Config.Fset.Position(other.Pos()))
+	}
+	if _, ok := obj.(*types.PkgName); ok {
+		if other := p.Types.Scope().Lookup(r.to); other != nil {
+			r.conflict(obj.Pos(), "would conflict with the declaration at %s", r.v.Config.Fset.Position(other.Pos()))
+		}
+	}
+	r.checkReferences(p, obj)
+	// The references to other objects of the new name must not be in the
+	// scope of obj.
+	for id, used := range p.TypesInfo.Uses {
+		if id.Name != r.to || used.Parent() == nil || within(used.Parent(), scope) {
+			continue
+		}
+		s := p.Types.Scope().Innermost(id.Pos())
+		if s == nil {
+			continue
+		}
+		if _, visible := s.LookupParent(r.from, id.Pos()); visible == obj {
+			r.conflict(id.Pos(), "would shadow this reference to the declaration at %s", r.v.Config.Fset.Position(used.Pos()))
+		}
+	}
+}
+
+// checkReferences checks that no other declaration of the new name shadows
+// the references to obj in p.
+func (r *renamer) checkReferences(p *packages.Package, obj types.Object) {
+	for _, id := range r.v.index(p).refs[r.v.keyOf(obj)] {
+		if p.TypesInfo.Uses[id] == nil {
+			continue
+		}
+		s := p.Types.Scope().Innermost(id.Pos())
+		if s == nil {
+			continue
+		}
+		_, other := s.LookupParent(r.to, id.Pos())
+		if other == nil || other.Parent() == types.Universe || !within(other.Parent(), obj.Parent()) || other.Parent() == obj.Parent() {
+			continue
+		}
+		r.conflict(id.Pos(), "would make this reference refer to the declaration at %s", r.v.Config.Fset.Position(other.Pos()))
+	}
+}
+
+// checkField checks that the struct of field, a field of p, has no other
+// field or method of the new name.
+func (r *renamer) checkField(p *packages.Package, field *types.Var) {
+	for _, f := range p.Syntax {
+		if f.Pos() > field.Pos() || field.Pos() > f.End() {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(f, field.Pos(), field.Pos())
+		for i, n := range path {
+			st, ok := n.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			if s, ok := p.TypesInfo.TypeOf(st).(*types.Struct); ok {
+				for j := 0; j < s.NumFields(); j++ {
+					if other := s.Field(j); other.Name() == r.to {
+						r.conflict(field.Pos(), "would conflict with the field at %s", r.v.Config.Fset.Position(other.Pos()))
+					}
+				}
+			}
+			if i+1 < len(path) {
+				if spec, ok := path[i+1].(*ast.TypeSpec); ok {
+					if tn, ok := p.TypesInfo.Defs[spec.Name].(*types.TypeName); ok {
+						r.checkMethods(field, tn.Type())
+					}
+				}
+			}
+			return
+		}
+	}
+}
+
+// checkMethod checks that the receiver type recv of method has no other
+// field or method of the new name.
+func (r *renamer) checkMethod(method *types.Func, recv types.Type) {
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	if iface, ok := recv.Underlying().(*types.Interface); ok {
+		for i := 0; i < iface.NumMethods(); i++ {
+			if other := iface.Method(i); other.Name() == r.to {
+				r.conflict(method.Pos(), "would conflict with the method at %s", r.v.Config.Fset.Position(other.Pos()))
+			}
+		}
+		return
+	}
+	r.checkMethods(method, recv)
+	if s, ok := recv.Underlying().(*types.Struct); ok {
+		for i := 0; i < s.NumFields(); i++ {
+			if other := s.Field(i); other.Name() == r.to {
+				r.conflict(method.Pos(), "would conflict with the field at %s", r.v.Config.Fset.Position(other.Pos()))
+			}
+		}
+	}
+}
+
+// checkMethods checks that the named type T, which declares obj, has no
+// method of the new name.
+func (r *renamer) checkMethods(obj types.Object, T types.Type) {
+	named, ok := T.(*types.Named)
+	if !ok {
+		return
+	}
+	for i := 0; i < named.NumMethods(); i++ {
+		if other := named.Method(i); other.Name() == r.to {
+			r.conflict(obj.Pos(), "would conflict with the method at %s", r.v.Config.Fset.Position(other.Pos()))
+		}
+	}
+}
+
+// checkSelections checks that the selections of p of the renamed fields and
+// methods still select them with the new name, and that those of the
+// fields and methods of the new name are not hidden by them.
+func (r *renamer) checkSelections(p *packages.Package) {
+	pkg := r.target.Pkg()
+	for expr, sel := range p.TypesInfo.Selections {
+		renamed := r.objs[r.v.keyOf(sel.Obj())] != nil
+		switch {
+		case renamed:
+			obj, index, _ := types.LookupFieldOrMethod(sel.Recv(), true, pkg, r.to)
+			if index != nil && len(index) <= len(sel.Index()) && (obj == nil || r.objs[r.v.keyOf(obj)] == nil) {
+				r.conflict(expr.Sel.Pos(), "would make this selection ambiguous or select another field or method")
+			}
+		case sel.Obj().Name() == r.to:
+			obj, index, _ := types.LookupFieldOrMethod(sel.Recv(), true, pkg, r.from)
+			if obj != nil && r.objs[r.v.keyOf(obj)] != nil && len(index) <= len(sel.Index()) {
+				r.conflict(expr.Sel.Pos(), "would make this selection ambiguous or select the renamed field or method")
+			}
+		}
+	}
+}
+
+// edits returns the edits that rename the objects, grouped by file.
+func (r *renamer) edits() map[URI][]TextEdit {
+	seen := make(map[token.Position]bool)
+	result := make(map[URI][]TextEdit)
+	add := func(rng Range, newText string) {
+		posn := r.v.Config.Fset.Position(rng.Start)
+		if seen[posn] {
+			return
+		}
+		seen[posn] = true
+		uri := ToURI(posn.Filename)
+		result[uri] = append(result[uri], TextEdit{Range: rng, NewText: newText})
+	}
+	for _, p := range r.pkgs {
+		idx := r.v.index(p)
+		for key := range r.objs {
+			for _, id := range idx.refs[key] {
+				add(Range{Start: id.Pos(), End: id.End()}, r.to)
+			}
+		}
+		// The imports that do not name their package are given the new
+		// name.
+		for node, obj := range p.TypesInfo.Implicits {
+			spec, ok := node.(*ast.ImportSpec)
+			if ok && r.objs[r.v.keyOf(obj)] != nil {
+				add(Range{Start: spec.Path.Pos(), End: spec.Path.Pos()}, r.to+" ")
+			}
+		}
+	}
+	for _, edits := range result {
+		sort.Slice(edits, func(i, j int) bool {
+			return edits[i].Range.Start < edits[j].Range.Start
+		})
+	}
+	return result
+}
+
+// within reports whether the scope s is ancestor or nested in it.
+func within(s, ancestor *types.Scope) bool {
+	for ; s != nil; s = s.Parent() {
+		if s == ancestor {
+			return true
+		}
+	}
+	return false
+}
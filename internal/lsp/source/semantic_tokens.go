@@ -0,0 +1,142 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+)
+
+type SemanticTokenType int
+
+const (
+	NamespaceToken SemanticTokenType = iota
+	TypeToken
+	FunctionToken
+	VariableToken
+	ParameterToken
+	ConstantToken
+	PropertyToken
+)
+
+// SemanticTokenModifiers is a bit set of the modifiers of a token.
+type SemanticTokenModifiers int
+
+const (
+	DeclarationModifier SemanticTokenModifiers = 1 << iota
+	ReadonlyModifier
+	DefaultLibraryModifier
+)
+
+// SemanticToken is an identifier classified by the kind of object it
+// denotes.
+type SemanticToken struct {
+	Range     Range
+	Type      SemanticTokenType
+	Modifiers SemanticTokenModifiers
+}
+
+// SemanticTokens returns the classified identifiers of f that are within
+// rng, in the order in which they appear in the file.
+// An invalid range stands for the whole file.
+func SemanticTokens(ctx context.Context, f *File, rng Range) ([]SemanticToken, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info := pkg.TypesInfo
+	if !rng.Start.IsValid() {
+		rng = Range{Start: fAST.Pos(), End: fAST.End()}
+	}
+	if !rng.End.IsValid() {
+		rng.End = fAST.End() // the range extends past the end of the file
+	}
+
+	// Parameters and results are variables like any other for go/types.
+	params := make(map[types.Object]bool)
+	ast.Inspect(fAST, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			addParams(params, info, n.Recv)
+		case *ast.FuncType:
+			addParams(params, info, n.Params)
+			addParams(params, info, n.Results)
+		}
+		return true
+	})
+
+	var tokens []SemanticToken
+	ast.Inspect(fAST, func(n ast.Node) bool {
+		if n == nil || n.End() < rng.Start || n.Pos() > rng.End {
+			return false
+		}
+		id, ok := n.(*ast.Ident)
+		if !ok || id.Name == "_" {
+			return true
+		}
+		tok := SemanticToken{Range: Range{Start: id.Pos(), End: id.End()}}
+		obj := info.ObjectOf(id)
+		if obj == nil {
+			if id != fAST.Name {
+				return true
+			}
+			tok.Type = NamespaceToken // the package clause
+			tokens = append(tokens, tok)
+			return true
+		}
+		if info.Defs[id] != nil {
+			tok.Modifiers |= DeclarationModifier
+		}
+		if obj.Parent() == types.Universe {
+			tok.Modifiers |= DefaultLibraryModifier
+		}
+		switch obj := obj.(type) {
+		case *types.PkgName:
+			tok.Type = NamespaceToken
+		case *types.TypeName:
+			tok.Type = TypeToken
+		case *types.Func, *types.Builtin:
+			tok.Type = FunctionToken
+		case *types.Const:
+			tok.Type = ConstantToken
+			tok.Modifiers |= ReadonlyModifier
+		case *types.Nil:
+			tok.Type = ConstantToken
+			tok.Modifiers |= ReadonlyModifier
+		case *types.Var:
+			switch {
+			case obj.IsField():
+				tok.Type = PropertyToken
+			case params[obj]:
+				tok.Type = ParameterToken
+			default:
+				tok.Type = VariableToken
+			}
+		default:
+			return true // labels
+		}
+		tokens = append(tokens, tok)
+		return true
+	})
+	return tokens, nil
+}
+
+func addParams(params map[types.Object]bool, info *types.Info, list *ast.FieldList) {
+	if list == nil {
+		return
+	}
+	for _, field := range list.List {
+		for _, name := range field.Names {
+			if obj := info.Defs[name]; obj != nil {
+				params[obj] = true
+			}
+		}
+	}
+}
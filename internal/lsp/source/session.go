@@ -0,0 +1,115 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"sync"
+)
+
+// A Session holds the state of the connection of an editor to the server:
+// the views of its workspace, and the contents of the documents that are
+// open in the editor, which are not saved to disk yet.
+// The contents of the open documents overlay the files on disk in all the
+// views of the session.
+type Session struct {
+	// cache is the state that the session shares with the other sessions
+	// of the server.
+	cache *Cache
+
+	mu sync.Mutex // protects all mutable state of the session
+
+	views []*View
+
+	// overlays holds the content of each open document, by its canonical
+	// URI.
+	overlays map[URI][]byte
+}
+
+// NewSession returns a session without views or open documents, with a
+// cache of its own.
+func NewSession() *Session {
+	return NewCache().NewSession()
+}
+
+// NewView adds a view to the session.
+func (s *Session) NewView() *View {
+	v := newView(s)
+	s.mu.Lock()
+	s.views = append(s.views, v)
+	s.mu.Unlock()
+	return v
+}
+
+// RemoveView removes a view from the session.
+func (s *Session) RemoveView(v *View) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, view := range s.views {
+		if view == v {
+			s.views = append(s.views[:i], s.views[i+1:]...)
+			return
+		}
+	}
+}
+
+// Views returns the views of the session.
+func (s *Session) Views() []*View {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*View(nil), s.views...)
+}
+
+// SetOverlay sets the content of the open document with the given URI. A
+// nil content closes the document, so that the content on disk is used
+// again. The state derived from the previous content is discarded in all
+// the views.
+func (s *Session) SetOverlay(uri URI, content []byte) {
+	uri = uri.canonical()
+	s.mu.Lock()
+	if content == nil {
+		delete(s.overlays, uri)
+	} else {
+		s.overlays[uri] = content
+	}
+	views := append([]*View(nil), s.views...)
+	s.mu.Unlock()
+	for _, v := range views {
+		v.invalidateFile(uri)
+	}
+}
+
+// OpenFiles returns the URIs of the documents that are open in the editor.
+func (s *Session) OpenFiles() []URI {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var uris []URI
+	for uri := range s.overlays {
+		uris = append(uris, uri)
+	}
+	return uris
+}
+
+// overlay returns the content of the open document with the given URI, or
+// false if it is not open.
+func (s *Session) overlay(uri URI) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.overlays[uri.canonical()]
+	return content, ok
+}
+
+// overlayMap returns the contents of the open documents by filename, as
+// packages.Config.Overlay expects them.
+func (s *Session) overlayMap() map[string][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	overlays := make(map[string][]byte, len(s.overlays))
+	for uri, content := range s.overlays {
+		if filename, err := uri.Filename(); err == nil {
+			overlays[filename] = content
+		}
+	}
+	return overlays
+}
@@ -16,6 +16,7 @@ import (
 
 type SignatureInformation struct {
 	Label           string
+	Documentation   string
 	Parameters      []ParameterInformation
 	ActiveParameter int
 }
@@ -25,11 +26,11 @@ type ParameterInformation struct {
 }
 
 func SignatureHelp(ctx context.Context, f *File, pos token.Pos) (*SignatureInformation, error) {
-	fAST, err := f.GetAST()
+	fAST, err := f.GetAST(ctx)
 	if err != nil {
 		return nil, err
 	}
-	pkg, err := f.GetPackage()
+	pkg, err := f.GetPackage(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -41,7 +42,9 @@ func SignatureHelp(ctx context.Context, f *File, pos token.Pos) (*SignatureInfor
 		return nil, fmt.Errorf("cannot find node enclosing position")
 	}
 	for _, node := range path {
-		if c, ok := node.(*ast.CallExpr); ok {
+		// Only consider calls whose parentheses enclose the position, so that
+		// a call in the function expression of another call is not chosen.
+		if c, ok := node.(*ast.CallExpr); ok && c.Lparen < pos && pos <= c.Rparen {
 			callExpr = c
 			break
 		}
@@ -105,14 +108,35 @@ func SignatureHelp(ctx context.Context, f *File, pos token.Pos) (*SignatureInfor
 		activeParam++
 		start = expr.Pos() + 1 // to account for commas
 	}
-	// Label for function, qualified by package name.
+	// All extra arguments of a variadic call correspond to the final parameter.
+	if n := len(paramInfo); sig.Variadic() && activeParam >= n && n > 0 {
+		activeParam = n - 1
+	}
+	// Label for function, qualified by package name, unless it is a method.
 	label := obj.Name()
-	if pkg := pkgStringer(obj.Pkg()); pkg != "" {
+	if pkg := pkgStringer(obj.Pkg()); pkg != "" && sig.Recv() == nil {
 		label = pkg + "." + label
 	}
+	label += formatParams(sig.Params(), sig.Variadic(), pkgStringer)
+	if results := formatResults(sig.Results(), pkgStringer); results != "" {
+		label += " " + results
+	}
 	return &SignatureInformation{
-		Label:           label + formatParams(sig.Params(), sig.Variadic(), pkgStringer),
+		Label:           label,
+		Documentation:   objectDoc(pkg, obj),
 		Parameters:      paramInfo,
 		ActiveParameter: activeParam,
 	}, nil
 }
+
+// formatResults formats the results of a function signature, adding
+// parentheses only when they are required.
+func formatResults(results *types.Tuple, qualifier types.Qualifier) string {
+	switch {
+	case results.Len() == 0:
+		return ""
+	case results.Len() == 1 && results.At(0).Name() == "":
+		return types.TypeString(results.At(0).Type(), qualifier)
+	}
+	return types.TypeString(results, qualifier)
+}
@@ -0,0 +1,165 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// TagCase is the naming convention of the names in added struct tags.
+type TagCase int
+
+const (
+	// SnakeCase names are lower case words separated by underscores, such
+	// as user_id.
+	SnakeCase TagCase = iota
+	// CamelCase names are words joined with initial capitals, starting with
+	// a lower case word, such as userID.
+	CamelCase
+)
+
+// AddStructTags returns the fix that adds a tag with the given key, such as
+// json, to the exported fields of the struct type enclosing rng that do not
+// have one already. The tags name the fields in the given case.
+func AddStructTags(ctx context.Context, f *File, rng Range, key string, tagCase TagCase) (*SuggestedFix, error) {
+	fix, err := rewriteStructTags(ctx, f, rng, func(field *ast.Field) {
+		if len(field.Names) != 1 || !field.Names[0].IsExported() {
+			return // a tag applies to all the names of the field
+		}
+		var tag string
+		if field.Tag != nil {
+			tag, _ = strconv.Unquote(field.Tag.Value)
+			if _, ok := reflect.StructTag(tag).Lookup(key); ok {
+				return
+			}
+		}
+		value := fmt.Sprintf("%s:%q", key, tagName(field.Names[0].Name, tagCase))
+		if tag != "" {
+			value = tag + " " + value
+		}
+		field.Tag = &ast.BasicLit{
+			ValuePos: field.Type.End(),
+			Kind:     token.STRING,
+			Value:    "`" + value + "`",
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	fix.Title = fmt.Sprintf("Add %s tags", key)
+	return fix, nil
+}
+
+// RemoveStructTags returns the fix that removes the tags of all the fields of
+// the struct type enclosing rng.
+func RemoveStructTags(ctx context.Context, f *File, rng Range) (*SuggestedFix, error) {
+	fix, err := rewriteStructTags(ctx, f, rng, func(field *ast.Field) {
+		field.Tag = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	fix.Title = "Remove struct tags"
+	return fix, nil
+}
+
+// rewriteStructTags applies rewrite to each field of the struct type that
+// encloses rng, and returns the fix that replaces the struct type with the
+// rewritten one.
+// The rewrite is applied to a copy of the file, parsed anew, so that the
+// cached syntax tree is not modified.
+func rewriteStructTags(ctx context.Context, f *File, rng Range, rewrite func(*ast.Field)) (*SuggestedFix, error) {
+	tok, err := f.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) != tok.Size() {
+		return nil, fmt.Errorf("%s has changed since it was parsed", f.URI)
+	}
+	fset := token.NewFileSet()
+	fAST, err := parser.ParseFile(fset, tok.Name(), content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	file := fset.File(fAST.Pos())
+	start, end := file.Pos(tok.Offset(rng.Start)), file.Pos(tok.Offset(rng.End))
+	path, _ := astutil.PathEnclosingInterval(fAST, start, end)
+	var st *ast.StructType
+	for _, n := range path {
+		if n, ok := n.(*ast.StructType); ok {
+			st = n
+			break
+		}
+	}
+	if st == nil || st.Fields == nil || len(st.Fields.List) == 0 {
+		return nil, fmt.Errorf("no struct type at the selection")
+	}
+	s, e := file.Offset(st.Pos()), file.Offset(st.End())
+	for _, field := range st.Fields.List {
+		rewrite(field)
+	}
+	text, ok := formatNode(fset, fAST, content, st)
+	if !ok {
+		return nil, fmt.Errorf("unable to format the struct type")
+	}
+	if text == string(content[s:e]) {
+		return nil, fmt.Errorf("the struct tags are unchanged")
+	}
+	return &SuggestedFix{
+		Edits: []TextEdit{{
+			Range:   Range{Start: tok.Pos(s), End: tok.Pos(e)},
+			NewText: text,
+		}},
+	}, nil
+}
+
+// tagName returns the name of the field in a struct tag, in the given case.
+func tagName(name string, tagCase TagCase) string {
+	words := splitWords(name)
+	switch tagCase {
+	case CamelCase:
+		words[0] = strings.ToLower(words[0])
+		return strings.Join(words, "")
+	default:
+		for i, w := range words {
+			words[i] = strings.ToLower(w)
+		}
+		return strings.Join(words, "_")
+	}
+}
+
+// splitWords splits a mixed caps identifier into its words, keeping
+// initialisms together: HTTPServerID is split into HTTP, Server and ID.
+func splitWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		if !unicode.IsUpper(runes[i]) {
+			continue
+		}
+		prev := runes[i-1]
+		nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if !unicode.IsUpper(prev) || nextLower {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
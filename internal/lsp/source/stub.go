@@ -0,0 +1,97 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// addMissingMethods adds stubs for the methods of the interface iface that
+// typ, a type declared in pkg or a pointer to one, does not have. The stubs
+// have pointer receivers if typ is a pointer.
+// They are added after the declaration of the type, which may be in another
+// file of the package than the error.
+func (v *View) addMissingMethods(pkg *packages.Package, typ, ifaceType types.Type) *SuggestedFix {
+	recvType := typ
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok || named.Obj().Pkg() != pkg.Types {
+		return nil
+	}
+	tn := named.Obj()
+	iface, ok := ifaceType.Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+	var missing []*types.Func
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		if !m.Exported() && m.Pkg() != pkg.Types {
+			return nil // the method cannot be implemented outside of its package
+		}
+		if obj, _, _ := types.LookupFieldOrMethod(recvType, false, m.Pkg(), m.Name()); obj == nil {
+			missing = append(missing, m)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Find the declaration of the type.
+	var decl *ast.GenDecl
+	for _, file := range pkg.Syntax {
+		if file.Pos() > tn.Pos() || tn.Pos() >= file.End() {
+			continue
+		}
+		for _, d := range file.Decls {
+			if d, ok := d.(*ast.GenDecl); ok && d.Pos() <= tn.Pos() && tn.Pos() < d.End() {
+				decl = d
+			}
+		}
+	}
+	if decl == nil {
+		return nil
+	}
+
+	// Name the receiver like the receivers of the existing methods.
+	recv := stubReceiverName(named)
+	qf := packageQualifier(pkg.Types)
+	var buf bytes.Buffer
+	for _, m := range missing {
+		sig := m.Type().(*types.Signature)
+		fmt.Fprintf(&buf, "\n\nfunc (%s %s) %s", recv, types.TypeString(recvType, qf), m.Name())
+		types.WriteSignature(&buf, sig, qf)
+		buf.WriteString(" {\n\tpanic(\"unimplemented\")\n}")
+	}
+	return &SuggestedFix{
+		Title: fmt.Sprintf("Implement %s for %s", types.TypeString(ifaceType, qf), types.TypeString(recvType, qf)),
+		Edits: []TextEdit{{
+			Range:   Range{Start: decl.End(), End: decl.End()},
+			NewText: buf.String(),
+		}},
+	}
+}
+
+// stubReceiverName returns the name of the receivers of the methods of
+// named, or if it has none, the lower-cased initial of its name.
+func stubReceiverName(named *types.Named) string {
+	for i := 0; i < named.NumMethods(); i++ {
+		recv := named.Method(i).Type().(*types.Signature).Recv()
+		if recv.Name() != "" && recv.Name() != "_" {
+			return recv.Name()
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(named.Obj().Name())
+	return string(unicode.ToLower(r))
+}
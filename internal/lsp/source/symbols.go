@@ -0,0 +1,314 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+type SymbolKind int
+
+const (
+	PackageSymbol SymbolKind = iota
+	StructSymbol
+	TypeSymbol
+	InterfaceSymbol
+	VariableSymbol
+	ConstantSymbol
+	FunctionSymbol
+	MethodSymbol
+	FieldSymbol
+	NumberSymbol
+	StringSymbol
+	BooleanSymbol
+)
+
+// Symbol is a named declaration in a file.
+// Methods are children of their receiver type, and fields and interface
+// methods are children of the type that declares them.
+type Symbol struct {
+	Name          string
+	Detail        string
+	Kind          SymbolKind
+	Span          Range // the whole declaration, including its body
+	SelectionSpan Range // the name of the declared object
+	Children      []Symbol
+}
+
+// DocumentSymbols returns the hierarchy of top-level declarations in f.
+func DocumentSymbols(ctx context.Context, f *File) ([]Symbol, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info := pkg.TypesInfo
+	qf := qualifier(fAST, pkg.Types, info)
+
+	var symbols []Symbol
+	typeIndex := make(map[string]int) // index in symbols of each type declaration
+	var methods []Symbol
+	var receivers []string
+	for _, decl := range fAST.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			s := funcSymbol(decl, info, qf)
+			if recv := receiverName(decl); recv != "" {
+				methods = append(methods, s)
+				receivers = append(receivers, recv)
+				continue
+			}
+			symbols = append(symbols, s)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					typeIndex[spec.Name.Name] = len(symbols)
+					symbols = append(symbols, typeSymbol(spec, info, qf))
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if name.Name == "_" {
+							continue
+						}
+						symbols = append(symbols, valueSymbol(decl, spec, name, info, qf))
+					}
+				}
+			}
+		}
+	}
+	// Nest methods under their receiver type, if it is declared in this file.
+	for i, m := range methods {
+		if j, ok := typeIndex[receivers[i]]; ok {
+			symbols[j].Children = append(symbols[j].Children, m)
+			continue
+		}
+		symbols = append(symbols, m)
+	}
+	return symbols, nil
+}
+
+func funcSymbol(decl *ast.FuncDecl, info *types.Info, qf types.Qualifier) Symbol {
+	s := Symbol{
+		Name:          decl.Name.Name,
+		Kind:          FunctionSymbol,
+		Span:          Range{Start: decl.Pos(), End: decl.End()},
+		SelectionSpan: Range{Start: decl.Name.Pos(), End: decl.Name.End()},
+	}
+	if decl.Recv != nil {
+		s.Kind = MethodSymbol
+	}
+	if obj, ok := info.Defs[decl.Name].(*types.Func); ok {
+		sig := obj.Type().(*types.Signature)
+		s.Detail = types.TypeString(sig, qf)[len("func"):]
+	}
+	return s
+}
+
+func typeSymbol(spec *ast.TypeSpec, info *types.Info, qf types.Qualifier) Symbol {
+	s := Symbol{
+		Name:          spec.Name.Name,
+		Kind:          TypeSymbol,
+		Span:          Range{Start: spec.Pos(), End: spec.End()},
+		SelectionSpan: Range{Start: spec.Name.Pos(), End: spec.Name.End()},
+	}
+	obj, ok := info.Defs[spec.Name].(*types.TypeName)
+	if !ok {
+		return s
+	}
+	s.Kind = typeToKind(obj.Type())
+	s.Detail, _ = formatType(obj.Type(), qf)
+
+	switch t := obj.Type().Underlying().(type) {
+	case *types.Struct:
+		st, _ := spec.Type.(*ast.StructType)
+		for i := 0; i < t.NumFields(); i++ {
+			field := t.Field(i)
+			child := Symbol{
+				Name:   field.Name(),
+				Kind:   FieldSymbol,
+				Detail: types.TypeString(field.Type(), qf),
+			}
+			if st != nil {
+				if f := fieldAt(st.Fields, field); f != nil {
+					child.Span = Range{Start: f.Pos(), End: f.End()}
+				}
+			}
+			child.SelectionSpan = Range{Start: field.Pos(), End: field.Pos() + token.Pos(len(field.Name()))}
+			if !child.Span.Start.IsValid() {
+				child.Span = child.SelectionSpan
+			}
+			s.Children = append(s.Children, child)
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			m := t.ExplicitMethod(i)
+			r := Range{Start: m.Pos(), End: m.Pos() + token.Pos(len(m.Name()))}
+			s.Children = append(s.Children, Symbol{
+				Name:          m.Name(),
+				Kind:          MethodSymbol,
+				Detail:        types.TypeString(m.Type(), qf)[len("func"):],
+				Span:          r,
+				SelectionSpan: r,
+			})
+		}
+	}
+	return s
+}
+
+func valueSymbol(decl *ast.GenDecl, spec *ast.ValueSpec, name *ast.Ident, info *types.Info, qf types.Qualifier) Symbol {
+	s := Symbol{
+		Name:          name.Name,
+		Kind:          VariableSymbol,
+		Span:          Range{Start: spec.Pos(), End: spec.End()},
+		SelectionSpan: Range{Start: name.Pos(), End: name.End()},
+	}
+	switch obj := info.Defs[name].(type) {
+	case *types.Const:
+		s.Kind = ConstantSymbol
+		s.Detail = fmt.Sprintf("%s = %s", types.TypeString(obj.Type(), qf), obj.Val())
+	case *types.Var:
+		s.Detail = types.TypeString(obj.Type(), qf)
+	default:
+		if decl.Tok == token.CONST {
+			s.Kind = ConstantSymbol
+		}
+	}
+	return s
+}
+
+// typeToKind returns the most specific symbol kind for a named type.
+func typeToKind(typ types.Type) SymbolKind {
+	switch t := typ.Underlying().(type) {
+	case *types.Interface:
+		return InterfaceSymbol
+	case *types.Struct:
+		return StructSymbol
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsNumeric != 0:
+			return NumberSymbol
+		case t.Info()&types.IsString != 0:
+			return StringSymbol
+		case t.Info()&types.IsBoolean != 0:
+			return BooleanSymbol
+		}
+	}
+	return TypeSymbol
+}
+
+// receiverName returns the name of the receiver's base type, or "" if decl is
+// not a method.
+func receiverName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return ""
+	}
+	typ := decl.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if id, ok := typ.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// fieldAt returns the field in list that declares the given struct field.
+func fieldAt(list *ast.FieldList, field *types.Var) *ast.Field {
+	for _, f := range list.List {
+		if f.Pos() <= field.Pos() && field.Pos() < f.End() {
+			return f
+		}
+	}
+	return nil
+}
+
+// maxWorkspaceSymbols is the maximum number of results of a workspace symbol
+// query, as clients don't display more than that anyway.
+const maxWorkspaceSymbols = 100
+
+// WorkspaceSymbol is a package-level declaration or method that matched a
+// workspace symbol query.
+type WorkspaceSymbol struct {
+	Name      string
+	Container string // the package path, qualified by the receiver type for methods
+	Kind      SymbolKind
+	Range     Range
+	Score     float64
+}
+
+// WorkspaceSymbols returns the symbols declared in the packages loaded by the
+// view that fuzzily match query, best matches first.
+func WorkspaceSymbols(ctx context.Context, v *View, query string) ([]WorkspaceSymbol, error) {
+	seen := make(map[objKey]bool)
+	var result []WorkspaceSymbol
+	for _, pkg := range v.packages() {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		for _, obj := range v.index(pkg).symbols {
+			score, ok := fuzzyMatch(query, obj.Name())
+			if !ok {
+				continue
+			}
+			key := v.keyOf(obj)
+			if seen[key] {
+				continue // the same declaration in another package variant
+			}
+			seen[key] = true
+			s := WorkspaceSymbol{
+				Name:      obj.Name(),
+				Container: obj.Pkg().Path(),
+				Kind:      objToSymbolKind(obj),
+				Range:     Range{Start: obj.Pos(), End: obj.Pos() + token.Pos(len(obj.Name()))},
+				Score:     score,
+			}
+			if fn, ok := obj.(*types.Func); ok {
+				if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+					if named, ok := deref(recv.Type()).(*types.Named); ok {
+						s.Container += "." + named.Obj().Name()
+					}
+				}
+			}
+			result = append(result, s)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].Name < result[j].Name
+	})
+	if len(result) > maxWorkspaceSymbols {
+		result = result[:maxWorkspaceSymbols]
+	}
+	return result, nil
+}
+
+// objToSymbolKind returns the symbol kind for a package-level object.
+func objToSymbolKind(obj types.Object) SymbolKind {
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		return typeToKind(obj.Type())
+	case *types.Func:
+		if obj.Type().(*types.Signature).Recv() != nil {
+			return MethodSymbol
+		}
+		return FunctionSymbol
+	case *types.Const:
+		return ConstantSymbol
+	default:
+		return VariableSymbol
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A testFunctionTemplate is a completion of an identifier at the top level
+// of a test file that declares a function that go test runs, such as
+// TestXxx(t *testing.T).
+type testFunctionTemplate struct {
+	prefix, detail string
+
+	// expand returns the declaration of the function named name, with qual
+	// as the name of the imported testing package, calling tabStop for its
+	// tab stops, which returns their text.
+	expand func(name, qual string, tabStop func(n int, text string) string) string
+}
+
+var testFunctionTemplates = []testFunctionTemplate{
+	{
+		prefix: "Test",
+		detail: "test function",
+		expand: func(name, qual string, tabStop func(int, string) string) string {
+			return "func " + name + "(t *" + qual + ".T) {\n\t" + tabStop(0, "") + "\n}"
+		},
+	},
+	{
+		prefix: "Test",
+		detail: "table-driven test function",
+		expand: func(name, qual string, tabStop func(int, string) string) string {
+			return "func " + name + "(t *" + qual + ".T) {\n" +
+				"\ttests := []struct {\n" +
+				"\t\tname string\n" +
+				"\t\t" + tabStop(2, "") + "\n" +
+				"\t}{\n" +
+				"\t\t" + tabStop(3, "") + "\n" +
+				"\t}\n" +
+				"\tfor _, tt := range tests {\n" +
+				"\t\tt.Run(tt.name, func(t *" + qual + ".T) {\n" +
+				"\t\t\t" + tabStop(0, "") + "\n" +
+				"\t\t})\n" +
+				"\t}\n" +
+				"}"
+		},
+	},
+	{
+		prefix: "Benchmark",
+		detail: "benchmark function",
+		expand: func(name, qual string, tabStop func(int, string) string) string {
+			return "func " + name + "(b *" + qual + ".B) {\n" +
+				"\tfor i := 0; i < b.N; i++ {\n" +
+				"\t\t" + tabStop(0, "") + "\n" +
+				"\t}\n" +
+				"}"
+		},
+	},
+	{
+		prefix: "Fuzz",
+		detail: "fuzz test function",
+		expand: func(name, qual string, tabStop func(int, string) string) string {
+			return "func " + name + "(f *" + qual + ".F) {\n" +
+				"\tf.Add(" + tabStop(2, "seed") + ")\n" +
+				"\tf.Fuzz(func(t *" + qual + ".T, " + tabStop(3, "in []byte") + ") {\n" +
+				"\t\t" + tabStop(0, "") + "\n" +
+				"\t})\n" +
+				"}"
+		},
+	},
+}
+
+// testFunctionCompletion returns the completions of the identifier before
+// pos, if it is at the top level of src, the content of a test file: the
+// declarations of the test, benchmark and fuzz test functions whose name it
+// starts, or that its name starts, such as TestFoo for TestFoo.
+func testFunctionCompletion(tok *token.File, file *ast.File, src []byte, pos token.Pos) ([]CompletionItem, bool) {
+	if file.Name == nil || pos <= file.Name.End() {
+		return nil, false
+	}
+	end := tok.Offset(pos)
+	if end > len(src) {
+		return nil, false
+	}
+	start := end
+	for start > 0 {
+		r, size := utf8.DecodeLastRune(src[:start])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		start -= size
+	}
+	// The identifier is at the top level if it starts a line outside of the
+	// other declarations. It is not a declaration, so the parser made a
+	// bad one of it.
+	if start == end || start > 0 && src[start-1] != '\n' {
+		return nil, false
+	}
+	for _, decl := range file.Decls {
+		if _, ok := decl.(*ast.BadDecl); !ok && decl.Pos() <= pos && pos <= decl.End() {
+			return nil, false
+		}
+	}
+	word := string(src[start:end])
+	qual, imported := importName(file, "testing")
+	var items []CompletionItem
+	for _, tmpl := range testFunctionTemplates {
+		var name, snippetName string
+		switch {
+		case strings.HasPrefix(word, tmpl.prefix):
+			name, snippetName = word, word
+		case strings.HasPrefix(tmpl.prefix, word):
+			name, snippetName = tmpl.prefix+"Xxx", tmpl.prefix+"${1:Xxx}"
+		default:
+			continue
+		}
+		item := CompletionItem{
+			Label:  name,
+			Detail: tmpl.detail,
+			Kind:   SnippetCompletionItem,
+			Score:  stdScore,
+			Snippet: tmpl.expand(snippetName, qual, func(n int, text string) string {
+				if text == "" {
+					return fmt.Sprintf("$%d", n)
+				}
+				return fmt.Sprintf("${%d:%s}", n, snippetEscaper.Replace(text))
+			}),
+			InsertText: tmpl.expand(name, qual, func(_ int, text string) string {
+				return text
+			}),
+			FilterText: name,
+			Replace:    Range{Start: pos - token.Pos(end-start), End: pos},
+		}
+		if !imported {
+			item.importPath, item.importName = "testing", qual
+		}
+		items = append(items, item)
+	}
+	return items, len(items) > 0
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TestFunc is a test or benchmark function that go test runs.
+type TestFunc struct {
+	Name      string
+	Range     Range // the declaration of the function
+	Benchmark bool
+}
+
+// TestFuncs returns the test and benchmark functions declared in f, which
+// are only found in _test.go files.
+func TestFuncs(ctx context.Context, f *File) ([]TestFunc, error) {
+	filename, err := f.URI.Filename()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(filename, "_test.go") {
+		return nil, nil
+	}
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var funcs []TestFunc
+	for _, decl := range fAST.Decls {
+		decl, ok := decl.(*ast.FuncDecl)
+		if !ok || decl.Recv != nil {
+			continue
+		}
+		fn, ok := pkg.TypesInfo.Defs[decl.Name].(*types.Func)
+		if !ok {
+			continue
+		}
+		params := fn.Type().(*types.Signature).Params()
+		if params.Len() != 1 {
+			continue
+		}
+		param := types.TypeString(params.At(0).Type(), nil)
+		name := decl.Name.Name
+		rng := Range{Start: decl.Pos(), End: decl.End()}
+		switch {
+		case isTestName(name, "Test") && param == "*testing.T":
+			funcs = append(funcs, TestFunc{Name: name, Range: rng})
+		case isTestName(name, "Benchmark") && param == "*testing.B":
+			funcs = append(funcs, TestFunc{Name: name, Range: rng, Benchmark: true})
+		}
+	}
+	return funcs, nil
+}
+
+// isTestName reports whether name is the name of a test function with the
+// given prefix, as go test determines it: the prefix must not be followed by
+// a lower case letter, so that Testable is not a test.
+func isTestName(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
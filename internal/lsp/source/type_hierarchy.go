@@ -0,0 +1,177 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// TypeHierarchyItem is a named type in a type hierarchy.
+type TypeHierarchyItem struct {
+	Name           string
+	Detail         string // the package path
+	Kind           SymbolKind
+	Range          Range // the whole type specification
+	SelectionRange Range // the name of the type
+}
+
+// PrepareTypeHierarchy returns the named type declared or referred to by
+// the identifier at pos.
+func PrepareTypeHierarchy(ctx context.Context, v *View, f *File, pos token.Pos) (*TypeHierarchyItem, error) {
+	tn, err := typeNameAt(ctx, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	item := v.typeHierarchyItem(tn)
+	return &item, nil
+}
+
+// Supertypes returns the interfaces that the type at pos, or a pointer to
+// it, implements, across all the packages loaded in the view.
+// Go has no explicit hierarchy of types, so all the interfaces are
+// reported, not only the nearest ones, except those that are empty, which
+// every type implements.
+func Supertypes(ctx context.Context, v *View, f *File, pos token.Pos) ([]TypeHierarchyItem, error) {
+	tn, err := typeNameAt(ctx, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	T := tn.Type()
+	return v.relatedTypes(ctx, tn, func(other *types.TypeName) bool {
+		iface, ok := other.Type().Underlying().(*types.Interface)
+		if !ok || iface.Empty() {
+			return false
+		}
+		return types.Implements(T, iface) || !types.IsInterface(T) && types.Implements(types.NewPointer(T), iface)
+	})
+}
+
+// Subtypes returns the types that implement the interface at pos, or whose
+// pointers do, across all the packages loaded in the view. The other types
+// have no subtypes, nor have the empty interfaces, which all types
+// implement.
+func Subtypes(ctx context.Context, v *View, f *File, pos token.Pos) ([]TypeHierarchyItem, error) {
+	tn, err := typeNameAt(ctx, f, pos)
+	if err != nil {
+		return nil, err
+	}
+	iface, ok := tn.Type().Underlying().(*types.Interface)
+	if !ok || iface.Empty() {
+		return nil, nil
+	}
+	return v.relatedTypes(ctx, tn, func(other *types.TypeName) bool {
+		T := other.Type()
+		return types.Implements(T, iface) || !types.IsInterface(T) && types.Implements(types.NewPointer(T), iface)
+	})
+}
+
+// relatedTypes returns the items of the named types, other than tn, that
+// the packages loaded in the view declare and for which related reports
+// true, in the order of their declarations.
+func (v *View) relatedTypes(ctx context.Context, tn *types.TypeName, related func(*types.TypeName) bool) ([]TypeHierarchyItem, error) {
+	seen := map[objKey]bool{v.keyOf(tn): true}
+	var result []TypeHierarchyItem
+	for _, p := range v.packages() {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		for _, obj := range v.index(p).symbols {
+			other, ok := obj.(*types.TypeName)
+			if !ok || other.IsAlias() || !other.Pos().IsValid() {
+				continue
+			}
+			key := v.keyOf(other)
+			if seen[key] || !related(other) {
+				continue
+			}
+			seen[key] = true
+			result = append(result, v.typeHierarchyItem(other))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		pi := v.Config.Fset.Position(result[i].SelectionRange.Start)
+		pj := v.Config.Fset.Position(result[j].SelectionRange.Start)
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	return result, nil
+}
+
+// typeNameAt returns the named type denoted by the identifier at pos.
+func typeNameAt(ctx context.Context, f *File, pos token.Pos) (*types.TypeName, error) {
+	fAST, err := f.GetAST(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := f.GetPackage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i, err := findIdentifier(fAST, pos)
+	if err != nil {
+		return nil, err
+	}
+	if i.ident == nil {
+		return nil, fmt.Errorf("type hierarchy was not a valid identifier")
+	}
+	obj := pkg.TypesInfo.ObjectOf(i.ident)
+	if _, ok := obj.(*types.TypeName); obj == nil || !ok && !i.wasEmbeddedField {
+		return nil, fmt.Errorf("%s is not a named type", i.ident.Name)
+	}
+	// An alias or an embedded field denotes the type that it names.
+	named, ok := types.Unalias(deref(types.Unalias(obj.Type()))).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return nil, fmt.Errorf("%s is not a named type", i.ident.Name)
+	}
+	return named.Obj(), nil
+}
+
+// typeHierarchyItem returns the item for tn. If the declaration of tn is not
+// available, its range is the range of its name.
+func (v *View) typeHierarchyItem(tn *types.TypeName) TypeHierarchyItem {
+	name := Range{Start: tn.Pos(), End: tn.Pos() + token.Pos(len(tn.Name()))}
+	item := TypeHierarchyItem{
+		Name:           tn.Name(),
+		Detail:         tn.Pkg().Path(),
+		Kind:           typeToKind(tn.Type()),
+		Range:          name,
+		SelectionRange: name,
+	}
+	if spec := v.typeSpec(tn); spec != nil {
+		item.Range = Range{Start: spec.Pos(), End: spec.End()}
+	}
+	return item
+}
+
+// typeSpec returns the specification of tn, if its syntax is available.
+func (v *View) typeSpec(tn *types.TypeName) *ast.TypeSpec {
+	for _, pkg := range v.packages() {
+		if pkg.Types == nil || pkg.Types.Path() != tn.Pkg().Path() {
+			continue
+		}
+		file := syntaxFile(pkg, tn.Pos())
+		if file == nil {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(file, tn.Pos(), tn.Pos())
+		for _, n := range path {
+			if spec, ok := n.(*ast.TypeSpec); ok && spec.Name.Pos() == tn.Pos() {
+				return spec
+			}
+		}
+	}
+	return nil
+}
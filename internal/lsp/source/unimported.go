@@ -0,0 +1,116 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/imports"
+)
+
+// maxUnimportedCandidates is the number of the candidate packages for a name
+// that are loaded to find the one the name refers to.
+const maxUnimportedCandidates = 5
+
+// unimportedPackage returns the package named name that the file named
+// filename would import to refer to it, as goimports would find it, or nil.
+// The packages that the view has loaded already are preferred; the others
+// are loaded, and cached until the view is reloaded.
+func (v *View) unimportedPackage(ctx context.Context, filename, name string) *types.Package {
+	paths, err := imports.CandidateImports(name, filename)
+	if err != nil || len(paths) == 0 {
+		return nil
+	}
+	if len(paths) > maxUnimportedCandidates {
+		paths = paths[:maxUnimportedCandidates]
+	}
+	known := make(map[string]*types.Package)
+	for _, pkg := range v.packages() {
+		known[pkg.PkgPath] = pkg.Types
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var missing []string
+	for _, path := range paths {
+		if _, ok := known[path]; ok {
+			continue
+		}
+		if pkg, ok := v.unimported[path]; ok {
+			known[path] = pkg
+			continue
+		}
+		missing = append(missing, path)
+	}
+	if len(missing) > 0 {
+		cfg := *v.configFor(filename)
+		cfg.Mode = packages.LoadTypes
+		cfg.Overlay = v.session.overlayMap()
+		cfg.ParseFile = v.parsed.parseFile
+		pkgs, err := v.load(ctx, &cfg, nil, missing...)
+		if err != nil {
+			return nil
+		}
+		for _, pkg := range pkgs {
+			if pkg.Types != nil {
+				known[pkg.PkgPath] = pkg.Types
+				v.unimported[pkg.PkgPath] = pkg.Types
+			}
+		}
+		// Don't load the packages that could not be loaded again.
+		for _, path := range missing {
+			if _, ok := known[path]; !ok {
+				v.unimported[path] = nil
+			}
+		}
+	}
+	for _, path := range paths {
+		if pkg := known[path]; pkg != nil && pkg.Name() == name {
+			return pkg
+		}
+	}
+	return nil
+}
+
+// importEdit returns the edit that adds the import of the package with the
+// given path to the file, under the given name if it is not the last
+// element of the path.
+func importEdit(tok *token.File, file *ast.File, name, importPath string) TextEdit {
+	spec := strconv.Quote(importPath)
+	if name != path.Base(importPath) {
+		spec = name + " " + spec
+	}
+	var last *ast.GenDecl
+	for _, decl := range file.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			last = d
+		}
+	}
+	switch {
+	case last == nil:
+		// Add an import declaration after the package clause.
+		pos := file.Name.End()
+		return TextEdit{Range: Range{Start: pos, End: pos}, NewText: "\n\nimport " + spec}
+	case last.Rparen.IsValid():
+		// Add the import at the end of the last import declaration.
+		pos := tok.LineStart(tok.Line(last.Rparen))
+		if len(last.Specs) == 0 || tok.Line(last.Specs[len(last.Specs)-1].End()) == tok.Line(last.Rparen) {
+			pos = last.Rparen
+			spec = "\n\t" + spec + "\n"
+		} else {
+			spec = "\t" + spec + "\n"
+		}
+		return TextEdit{Range: Range{Start: pos, End: pos}, NewText: spec}
+	default:
+		// Add an import declaration after the last one.
+		pos := last.End()
+		return TextEdit{Range: Range{Start: pos, End: pos}, NewText: "\nimport " + spec}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// unusedExportedDiagnostics adds the diagnostics of the exported functions
+// and types of the files of pkg that no package loaded in the view refers
+// to, other than by their declarations, to reports.
+// The functions that cgo exports to C are used, and the test files are not
+// checked, as the functions that go test runs are entry points too.
+func (v *View) unusedExportedDiagnostics(pkg *packages.Package, reports map[string][]Diagnostic) {
+	pkgs := v.packages()
+	unused := func(id *ast.Ident) bool {
+		obj := pkg.TypesInfo.Defs[id]
+		if obj == nil {
+			return false
+		}
+		key := v.keyOf(obj)
+		for _, p := range pkgs {
+			for _, ref := range v.index(p).refs[key] {
+				if posn := v.Config.Fset.Position(ref.Pos()); posn.Filename != key.filename || posn.Offset != key.offset {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	for _, file := range pkg.Syntax {
+		filename := v.Config.Fset.Position(file.Pos()).Filename
+		if _, ok := reports[filename]; !ok || strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		report := func(id *ast.Ident, kind string) {
+			reports[filename] = append(reports[filename], Diagnostic{
+				Range:    Range{Start: id.Pos(), End: id.End()},
+				Severity: SeverityHint,
+				Message:  fmt.Sprintf("exported %s %s is unused in the workspace", kind, id.Name),
+				Tags:     []DiagnosticTag{UnnecessaryTag},
+			})
+		}
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv != nil || !decl.Name.IsExported() || cgoExported(decl) {
+					continue
+				}
+				if unused(decl.Name) {
+					report(decl.Name, "function")
+				}
+			case *ast.GenDecl:
+				if decl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					spec := spec.(*ast.TypeSpec)
+					if spec.Name.IsExported() && unused(spec.Name) {
+						report(spec.Name, "type")
+					}
+				}
+			}
+		}
+	}
+}
+
+// cgoExported reports whether decl has an //export comment, which makes cgo
+// export it to C.
+func cgoExported(decl *ast.FuncDecl) bool {
+	if decl.Doc == nil {
+		return false
+	}
+	for _, c := range decl.Doc.List {
+		if strings.HasPrefix(c.Text, "//export ") {
+			return true
+		}
+	}
+	return false
+}
@@ -7,13 +7,10 @@ package source
 import (
 	"fmt"
 	"net/url"
-	"path/filepath"
 	"runtime"
 	"strings"
 )
 
-const fileSchemePrefix = "file://"
-
 // URI represents the full uri for a file.
 type URI string
 
@@ -21,27 +18,96 @@ type URI string
 // It will return an error if the uri is not valid, or if the URI was not
 // a file URI
 func (uri URI) Filename() (string, error) {
-	s := string(uri)
-	if !strings.HasPrefix(s, fileSchemePrefix) {
-		return "", fmt.Errorf("only file URI's are supported, got %v", uri)
-	}
-	s = s[len(fileSchemePrefix):]
-	s, err := url.PathUnescape(s)
-	if err != nil {
-		return s, err
-	}
-	s = filepath.FromSlash(s)
-	return s, nil
+	return filename(uri, runtime.GOOS == "windows")
 }
 
 // ToURI returns a protocol URI for the supplied path.
 // It will always have the file scheme.
 func ToURI(path string) URI {
 	const prefix = "$GOROOT"
-	if strings.EqualFold(prefix, path[:len(prefix)]) {
+	if len(path) >= len(prefix) && strings.EqualFold(prefix, path[:len(prefix)]) {
 		suffix := path[len(prefix):]
 		//TODO: we need a better way to get the GOROOT that uses the packages api
 		path = runtime.GOROOT() + suffix
 	}
-	return URI(fileSchemePrefix + filepath.ToSlash(path))
+	return toURI(path, runtime.GOOS == "windows")
+}
+
+// canonical returns the URI that ToURI returns for the file of uri, so that
+// the URIs that clients escape differently, or whose drive letters differ in
+// case, name the same file, or uri itself if it is not a file URI.
+func (uri URI) canonical() URI {
+	filename, err := uri.Filename()
+	if err != nil {
+		return uri
+	}
+	return ToURI(filename)
+}
+
+// filename returns the path of the file URI uri, whose separators are
+// backslashes if windows is set. On Windows, the drive letter of the path is
+// upper case, and a URI with a host is the UNC path \\host\share\...
+func filename(uri URI, windows bool) (string, error) {
+	u, err := url.Parse(string(uri))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("only file URI's are supported, got %v", uri)
+	}
+	path := u.Path
+	host := u.Host
+	if host == "localhost" {
+		host = ""
+	}
+	if !windows {
+		if host != "" {
+			return "", fmt.Errorf("file URI %v names a remote host", uri)
+		}
+		return path, nil
+	}
+	switch {
+	case isDrive(host):
+		// The file:// prefix was added to the path C:/... as it is.
+		path = host + path
+	case host != "":
+		path = "//" + host + path
+	case len(path) > 0 && path[0] == '/' && isDrive(path[1:]):
+		path = path[1:]
+	}
+	if isDrive(path) {
+		path = strings.ToUpper(path[:1]) + path[1:]
+	}
+	return strings.Replace(path, "/", `\`, -1), nil
+}
+
+// toURI returns the file URI of path, whose separators may be backslashes
+// if windows is set.
+func toURI(path string, windows bool) URI {
+	u := &url.URL{Scheme: "file", Path: path}
+	if windows {
+		path = strings.Replace(path, `\`, "/", -1)
+		switch {
+		case strings.HasPrefix(path, "//"):
+			// The UNC path \\host\share\...
+			u.Host, u.Path = path[2:], "/"
+			if i := strings.IndexByte(u.Host, '/'); i >= 0 {
+				u.Host, u.Path = u.Host[:i], u.Host[i:]
+			}
+		case isDrive(path):
+			u.Path = "/" + strings.ToUpper(path[:1]) + path[1:]
+		default:
+			u.Path = path
+		}
+	}
+	return URI(u.String())
+}
+
+// isDrive reports whether path starts with a drive letter, as C: or C:/...
+func isDrive(path string) bool {
+	if len(path) < 2 || path[1] != ':' || len(path) > 2 && path[2] != '/' {
+		return false
+	}
+	c := path[0] | 0x20 // lower case
+	return 'a' <= c && c <= 'z'
 }
@@ -0,0 +1,63 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import "testing"
+
+func TestURI(t *testing.T) {
+	for _, test := range []struct {
+		windows bool
+		path    string
+		uri     URI
+	}{
+		{false, "/home/gopher/a.go", "file:///home/gopher/a.go"},
+		{false, "/home/gopher/a b#c.go", "file:///home/gopher/a%20b%23c.go"},
+		{true, `C:\Users\gopher\a.go`, "file:///C:/Users/gopher/a.go"},
+		{true, `C:\a b\é.go`, "file:///C:/a%20b/%C3%A9.go"},
+		{true, `\\server\share\a.go`, "file://server/share/a.go"},
+	} {
+		if got := toURI(test.path, test.windows); got != test.uri {
+			t.Errorf("toURI(%q, %v) = %q, want %q", test.path, test.windows, got, test.uri)
+		}
+		if got, err := filename(test.uri, test.windows); err != nil || got != test.path {
+			t.Errorf("filename(%q, %v) = %q, %v, want %q", test.uri, test.windows, got, err, test.path)
+		}
+	}
+}
+
+func TestFilename(t *testing.T) {
+	for _, test := range []struct {
+		windows bool
+		uri     URI
+		path    string
+	}{
+		{false, "file://localhost/home/gopher/a.go", "/home/gopher/a.go"},
+		{true, "file:///c%3A/Users/gopher/a.go", `C:\Users\gopher\a.go`},
+		{true, "file:///c:/Users/gopher/a.go", `C:\Users\gopher\a.go`},
+		{true, "file://C:/Users/gopher/a.go", `C:\Users\gopher\a.go`},
+		{true, "file://localhost/C:/a.go", `C:\a.go`},
+	} {
+		if got, err := filename(test.uri, test.windows); err != nil || got != test.path {
+			t.Errorf("filename(%q, %v) = %q, %v, want %q", test.uri, test.windows, got, err, test.path)
+		}
+	}
+	for _, uri := range []URI{"http://golang.org/a.go", "file:///a%zz.go", "file://server/a.go"} {
+		if got, err := filename(uri, false); err == nil {
+			t.Errorf("filename(%q) = %q, want an error", uri, got)
+		}
+	}
+}
+
+func TestCanonicalURI(t *testing.T) {
+	uri := ToURI("/home/gopher/a b.go")
+	for _, other := range []URI{"file:///home/gopher/a b.go", "file:///home/gopher/a%20b.go", "file://localhost/home/gopher/a%20b.go"} {
+		if got := other.canonical(); got != uri {
+			t.Errorf("%q.canonical() = %q, want %q", other, got, uri)
+		}
+	}
+	if got := ToURI("$GOROOT"); got.canonical() != got {
+		t.Errorf("ToURI($GOROOT) = %q is not canonical", got)
+	}
+}
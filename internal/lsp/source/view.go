@@ -5,30 +5,89 @@
 package source
 
 import (
+	"context"
 	"fmt"
-	"go/token"
+	"go/types"
+	"path/filepath"
 	"sync"
 
 	"golang.org/x/tools/go/packages"
 )
 
+// A View is a directory of the workspace, such as a workspace folder, whose
+// packages are loaded and type-checked with the same configuration and
+// options, and cached until their files change.
 type View struct {
-	mu sync.Mutex // protects all mutable state of the view
+	// mu protects all mutable state of the view. The state that is cached
+	// already may be read concurrently.
+	mu sync.RWMutex
 
+	// session is the session of the view, which holds the contents of
+	// the open documents.
+	session *Session
+
+	// options are the settings of the view.
+	options Options
+
+	// Config is the configuration with which the packages of the view are
+	// loaded. Its Overlay is replaced with the open documents of the
+	// session.
 	Config *packages.Config
 
+	// files holds the files of the view by their canonical URIs, as ToURI
+	// returns them.
 	files map[URI]*File
+
+	// modFiles caches the go.mod file of each directory, or "" for the
+	// directories that are not in a module.
+	modFiles map[string]string
+
+	// moduleConfigs holds the configuration with which to load the packages
+	// of each module, by the name of its go.mod file.
+	moduleConfigs map[string]*packages.Config
+
+	// parsed caches the syntax trees of the files of the view, and of the
+	// views of the other sessions of its cache.
+	parsed *parseCache
+
+	// pkgCache caches the type-checked packages of the view, by the
+	// filenames of their files.
+	pkgCache map[string]*cachedPackage
+
+	// uses counts the uses of the cached packages, to find the least
+	// recently used ones.
+	uses uint64
+
+	// indexes caches the workspace query information for each package.
+	indexes map[*packages.Package]*packageIndex
+
+	// unimported caches the packages that the files of the view do not
+	// import, by their paths, as completion loads them, or nil for those
+	// that could not be loaded.
+	unimported map[string]*types.Package
 }
 
+// NewView returns a view in a session of its own.
 func NewView() *View {
+	return NewSession().NewView()
+}
+
+func newView(session *Session) *View {
 	return &View{
+		session: session,
+		options: DefaultOptions(),
 		Config: &packages.Config{
-			Mode:    packages.LoadSyntax,
-			Fset:    token.NewFileSet(),
-			Tests:   true,
-			Overlay: make(map[string][]byte),
+			Mode:  packages.LoadSyntax,
+			Fset:  session.cache.fset,
+			Tests: true,
 		},
-		files: make(map[URI]*File),
+		parsed:        &session.cache.parsed,
+		files:         make(map[URI]*File),
+		modFiles:      make(map[string]string),
+		moduleConfigs: make(map[string]*packages.Config),
+		pkgCache:      make(map[string]*cachedPackage),
+		indexes:       make(map[*packages.Package]*packageIndex),
+		unimported:    make(map[string]*types.Package),
 	}
 }
 
@@ -43,6 +102,7 @@ func (v *View) GetFile(uri URI) *File {
 
 // getFile is the unlocked internal implementation of GetFile.
 func (v *View) getFile(uri URI) *File {
+	uri = uri.canonical()
 	f, found := v.files[uri]
 	if !found {
 		f = &File{
@@ -54,12 +114,20 @@ func (v *View) getFile(uri URI) *File {
 	return f
 }
 
-func (v *View) parse(uri URI) error {
+func (v *View) parse(ctx context.Context, uri URI) error {
 	path, err := uri.Filename()
 	if err != nil {
 		return err
 	}
-	pkgs, err := packages.Load(v.Config, fmt.Sprintf("file=%s", path))
+	cfg := *v.configFor(path)
+	config := configKey(&cfg)
+	if pkg, ok := v.cachedPackage(config, path); ok {
+		v.addPackage(pkg)
+		return nil
+	}
+	cfg.Overlay = v.session.overlayMap()
+	cfg.ParseFile = v.parsed.parseFile
+	pkgs, err := v.load(ctx, &cfg, nil, fmt.Sprintf("file=%s", path))
 	if len(pkgs) == 0 {
 		if err == nil {
 			err = fmt.Errorf("no packages found for %s", path)
@@ -67,16 +135,145 @@ func (v *View) parse(uri URI) error {
 		return err
 	}
 	for _, pkg := range pkgs {
-		// add everything we find to the files cache
-		for _, fAST := range pkg.Syntax {
-			// if a file was in multiple packages, which token/ast/pkg do we store
-			fToken := v.Config.Fset.File(fAST.Pos())
-			fURI := ToURI(fToken.Name())
-			f := v.getFile(fURI)
-			f.token = fToken
-			f.ast = fAST
-			f.pkg = pkg
-		}
+		v.cachePackage(config, pkg)
+		v.addPackage(pkg)
 	}
+	v.evict(path)
 	return nil
 }
+
+// LoadWorkspace loads and type-checks the packages in the directory of the
+// view and its subdirectories, so that the queries of the workspace, such as
+// the workspace symbols, find them before their files are opened.
+// If report is not nil, it is called as the packages are type-checked, with
+// the number of packages type-checked so far and their total number.
+func (v *View) LoadWorkspace(ctx context.Context, report func(checked, total int)) error {
+	_, err := v.loadPatterns(ctx, report, "./...")
+	return err
+}
+
+// loadPatterns loads and type-checks the packages that match the patterns,
+// resolved in the directory of the view, as LoadWorkspace does, and returns
+// them.
+func (v *View) loadPatterns(ctx context.Context, report func(checked, total int), patterns ...string) ([]*packages.Package, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	base := v.configFor(filepath.Join(v.Config.Dir, "go.mod"))
+	// The packages are the same as those that parse loads, whatever the
+	// directory in which the pattern is resolved.
+	config := configKey(base)
+	cfg := *base
+	cfg.Dir = v.Config.Dir
+	cfg.Overlay = v.session.overlayMap()
+	cfg.ParseFile = v.parsed.parseFile
+	pkgs, err := v.load(ctx, &cfg, report, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		v.cachePackage(config, pkg)
+		v.addPackage(pkg)
+	}
+	v.evict("")
+	return pkgs, nil
+}
+
+// addPackage sets the package of its files, and their syntax trees.
+func (v *View) addPackage(pkg *packages.Package) {
+	// add everything we find to the files cache
+	for _, fAST := range pkg.Syntax {
+		// if a file was in multiple packages, which token/ast/pkg do we store
+		fToken := v.Config.Fset.File(fAST.Pos())
+		fURI := ToURI(fToken.Name())
+		f := v.getFile(fURI)
+		v.invalidate(f.pkg)
+		f.token = fToken
+		f.ast = fAST
+		f.pkg = pkg
+	}
+	// The files that cgo processes have the syntax trees that check parsed
+	// for them, to which it added the type information of the files that
+	// cgo generates from them.
+	for _, filename := range cgoFiles(pkg) {
+		content, ok := v.fileContent(filename)
+		if !ok {
+			continue
+		}
+		fAST, _ := v.parsed.parseFile(v.Config.Fset, filename, content)
+		if fAST == nil {
+			continue
+		}
+		f := v.getFile(ToURI(filename))
+		v.invalidate(f.pkg)
+		f.token = v.Config.Fset.File(fAST.Pos())
+		f.ast = fAST
+		f.pkg = pkg
+	}
+}
+
+// invalidateFile discards the state derived from the content of the file
+// with the given URI, if the view has it, and the packages of the files of
+// the view that depend on it, so that they are type-checked again.
+func (v *View) invalidateFile(uri URI) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	uri = uri.canonical()
+	if filename, err := uri.Filename(); err == nil {
+		for _, f := range v.files {
+			fname, err := f.URI.Filename()
+			if f.pkg == nil || err != nil {
+				continue
+			}
+			c, ok := v.pkgCache[fname]
+			if !ok || c.pkg != f.pkg {
+				continue
+			}
+			if _, ok := c.hashes[filename]; !ok {
+				continue
+			}
+			v.invalidate(f.pkg)
+			f.ast = nil
+			f.token = nil
+			f.pkg = nil
+		}
+	}
+	f, ok := v.files[uri]
+	if !ok {
+		return
+	}
+	f.content = nil
+	f.ast = nil
+	f.token = nil
+	f.mod = nil
+	v.invalidate(f.pkg)
+	f.pkg = nil
+}
+
+// Reload discards the parsed and type-checked state of all the files of the
+// view, so that they are loaded again on their next use, with their current
+// contents, and finds their modules again. It is used after the files or the
+// build configuration have been changed by a tool such as go generate.
+func (v *View) Reload() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.reload()
+}
+
+// reload is the internal part of Reload that presumes the lock is already
+// held.
+func (v *View) reload() {
+	v.modFiles = make(map[string]string)
+	v.moduleConfigs = make(map[string]*packages.Config)
+	v.pkgCache = make(map[string]*cachedPackage)
+	v.unimported = make(map[string]*types.Package)
+	// The packages that the other sessions cached may be out of date too.
+	v.session.cache.forgetPackages()
+	for _, f := range v.files {
+		v.invalidate(f.pkg)
+		f.ast = nil
+		f.token = nil
+		f.pkg = nil
+		f.mod = nil
+		f.content = nil
+	}
+}
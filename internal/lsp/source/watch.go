@@ -0,0 +1,104 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"path/filepath"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// FileChanged discards the state of the view that depends on the file on
+// disk with the given URI, which was created, modified or deleted: the
+// packages that contain the file, or that a new file joins by being in the
+// same directory, and the packages that import them, directly or not.
+// It returns the URIs of the files whose packages were discarded.
+// A change of a go.mod file reloads all the files of the view, as the
+// build configuration may have changed.
+func (v *View) FileChanged(uri URI, deleted bool) []URI {
+	if IsModFile(uri) {
+		v.Reload()
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		var uris []URI
+		for uri := range v.files {
+			uris = append(uris, uri)
+		}
+		return uris
+	}
+	uri = uri.canonical()
+	filename, err := uri.Filename()
+	if err != nil {
+		return nil
+	}
+	dir := filepath.Dir(filename)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Find the packages that the change affects directly, by path, as each
+	// load of the view has packages of its own.
+	changed := make(map[string]bool)
+	for _, f := range v.files {
+		if f.pkg == nil {
+			continue
+		}
+		if f.URI == uri {
+			changed[f.pkg.PkgPath] = true
+			continue
+		}
+		if fname, err := f.URI.Filename(); err == nil && filepath.Dir(fname) == dir {
+			changed[f.pkg.PkgPath] = true
+		}
+	}
+	// A new file does not invalidate the cached packages of its directory
+	// by itself, as it is not one of their files, in the view or in the
+	// cache of its session.
+	for name, c := range v.pkgCache {
+		if _, ok := c.hashes[filename]; changed[c.pkg.PkgPath] && !ok {
+			delete(v.pkgCache, name)
+		}
+	}
+	v.session.cache.forgetNewFile(filename)
+	if f, ok := v.files[uri]; ok {
+		f.content = nil
+		f.ast = nil
+		f.token = nil
+		f.mod = nil
+		if _, open := v.session.overlay(uri); deleted && !open {
+			delete(v.files, uri)
+		}
+	}
+
+	// Discard the packages that depend on them, which includes themselves.
+	depends := make(map[*packages.Package]bool)
+	var dependsOnChange func(pkg *packages.Package) bool
+	dependsOnChange = func(pkg *packages.Package) bool {
+		if d, ok := depends[pkg]; ok {
+			return d
+		}
+		depends[pkg] = false // break cycles
+		d := changed[pkg.PkgPath]
+		for _, imp := range pkg.Imports {
+			if d {
+				break
+			}
+			d = dependsOnChange(imp)
+		}
+		depends[pkg] = d
+		return d
+	}
+	var uris []URI
+	for _, f := range v.files {
+		if f.pkg == nil || !dependsOnChange(f.pkg) {
+			continue
+		}
+		v.invalidate(f.pkg)
+		f.ast = nil
+		f.token = nil
+		f.pkg = nil
+		uris = append(uris, f.URI)
+	}
+	return uris
+}
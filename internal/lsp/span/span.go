@@ -0,0 +1,101 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package span converts between the byte offsets of the content of a file
+// and the positions of the language server protocol, whose lines and
+// columns count from zero, and whose columns count UTF-16 code units rather
+// than bytes.
+package span
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Line returns the text of the line of content with the given 0-based
+// number, without its newline, and the offset of its start, or false if
+// content has fewer lines. The line after the last newline is empty.
+func Line(content []byte, line int) ([]byte, int, bool) {
+	start := 0
+	for ; line > 0; line-- {
+		i := bytes.IndexByte(content[start:], '\n')
+		if i < 0 {
+			return nil, 0, false
+		}
+		start += i + 1
+	}
+	end := bytes.IndexByte(content[start:], '\n')
+	if end < 0 {
+		end = len(content) - start
+	}
+	return content[start : start+end], start, true
+}
+
+// UTF16Column returns the column of the byte column col of line, the text
+// of a line, in UTF-16 code units. The bytes that are not valid UTF-8 count
+// as a code unit each, as they do once they are decoded as U+FFFD, and so
+// do those past the end of line, such as its newline, which go/token counts
+// in the column of the end of a file that ends with one.
+func UTF16Column(line []byte, col int) int {
+	n := 0
+	if col > len(line) {
+		n, col = col-len(line), len(line)
+	}
+	for i := 0; i < col; {
+		r, size := utf8.DecodeRune(line[i:])
+		n += runeLen16(r)
+		i += size
+	}
+	return n
+}
+
+// ByteColumn returns the byte column of the column col of line, the text of
+// a line, in UTF-16 code units. A column past the end of line is that of its
+// end, and a column in the middle of a character that takes two code units
+// is that of the character.
+func ByteColumn(line []byte, col int) int {
+	i := 0
+	for n := 0; i < len(line); {
+		r, size := utf8.DecodeRune(line[i:])
+		if n += runeLen16(r); n > col {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// runeLen16 returns the number of UTF-16 code units that encode r.
+func runeLen16(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// Offset returns the byte offset in content of the position at the
+// 0-based line and UTF-16 column col. A column past the end of the line
+// refers to the end of the line.
+func Offset(content []byte, line, col int) (int, error) {
+	if line < 0 || col < 0 {
+		return 0, fmt.Errorf("invalid position %d:%d", line, col)
+	}
+	text, start, ok := Line(content, line)
+	if !ok {
+		return 0, fmt.Errorf("line %d is beyond the end of the content", line)
+	}
+	return start + ByteColumn(text, col), nil
+}
+
+// Position returns the 0-based line and UTF-16 column of the byte offset
+// offset in content.
+func Position(content []byte, offset int) (line, col int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	line = bytes.Count(content[:offset], []byte("\n"))
+	start := bytes.LastIndexByte(content[:offset], '\n') + 1
+	return line, UTF16Column(content[start:], offset-start)
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package span
+
+import "testing"
+
+// content has characters of one, two, three and four bytes in UTF-8, the
+// last of which take two code units in UTF-16.
+const content = "a := \"é\"\nb := \"世界\" // 𝄞x\n\nc\xffd\n"
+
+var tests = []struct {
+	offset    int // in content
+	line, col int // in UTF-16
+}{
+	{0, 0, 0},
+	{5, 0, 5},   // "é"
+	{6, 0, 6},   // é
+	{8, 0, 7},   // the closing quote
+	{10, 1, 0},  // b
+	{15, 1, 5},  // "世界"
+	{16, 1, 6},  // 世
+	{19, 1, 7},  // 界
+	{22, 1, 8},  // the closing quote
+	{27, 1, 13}, // 𝄞
+	{31, 1, 15}, // x
+	{32, 1, 16}, // the end of the line
+	{33, 2, 0},  // the empty line
+	{35, 3, 1},  // the invalid byte
+	{36, 3, 2},  // d
+	{38, 4, 0},  // the end of the content
+}
+
+func TestPosition(t *testing.T) {
+	for _, test := range tests {
+		line, col := Position([]byte(content), test.offset)
+		if line != test.line || col != test.col {
+			t.Errorf("Position(%d) = %d:%d, want %d:%d", test.offset, line, col, test.line, test.col)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	for _, test := range tests {
+		offset, err := Offset([]byte(content), test.line, test.col)
+		if err != nil || offset != test.offset {
+			t.Errorf("Offset(%d:%d) = %d, %v, want %d", test.line, test.col, offset, err, test.offset)
+		}
+	}
+	for _, test := range []struct {
+		line, col, offset int
+	}{
+		{0, 100, 9},  // past the end of the line
+		{1, 14, 27},  // in the middle of 𝄞
+		{1, 16, 32},  // the end of the line
+		{2, 1, 33},   // past the end of the empty line
+		{4, 100, 38}, // past the end of the content
+	} {
+		offset, err := Offset([]byte(content), test.line, test.col)
+		if err != nil || offset != test.offset {
+			t.Errorf("Offset(%d:%d) = %d, %v, want %d", test.line, test.col, offset, err, test.offset)
+		}
+	}
+	if _, err := Offset([]byte(content), 5, 0); err == nil {
+		t.Errorf("Offset(5:0) succeeded beyond the end of the content")
+	}
+}
+
+func TestUTF16Column(t *testing.T) {
+	line := []byte("\"世界\" // 𝄞x")
+	for _, test := range []struct {
+		col, want int
+	}{
+		{0, 0},
+		{1, 1},   // 世
+		{7, 3},   // the closing quote
+		{12, 8},  // 𝄞
+		{16, 10}, // x
+		{17, 11}, // the end of the line
+		{18, 12}, // past the end of the line
+	} {
+		if got := UTF16Column(line, test.col); got != test.want {
+			t.Errorf("UTF16Column(%d) = %d, want %d", test.col, got, test.want)
+		}
+		if test.col <= len(line) {
+			if got := ByteColumn(line, test.want); got != test.col {
+				t.Errorf("ByteColumn(%d) = %d, want %d", test.want, got, test.col)
+			}
+		}
+	}
+}
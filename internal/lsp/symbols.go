@@ -0,0 +1,87 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func toProtocolDocumentSymbols(m *columnMapper, symbols []source.Symbol) []protocol.DocumentSymbol {
+	result := make([]protocol.DocumentSymbol, 0, len(symbols))
+	for _, s := range symbols {
+		ps := protocol.DocumentSymbol{
+			Name:           s.Name,
+			Detail:         s.Detail,
+			Kind:           toProtocolSymbolKind(s.Kind),
+			Range:          toProtocolRange(m, s.Span),
+			SelectionRange: toProtocolRange(m, s.SelectionSpan),
+		}
+		if len(s.Children) > 0 {
+			ps.Children = toProtocolDocumentSymbols(m, s.Children)
+		}
+		result = append(result, ps)
+	}
+	return result
+}
+
+// toProtocolFlatSymbols returns the symbols of the document uri, and their
+// children, as a flat list, for the clients that do not support the tree of
+// the symbols. The symbols are in container, the name of their parent.
+func toProtocolFlatSymbols(uri protocol.DocumentURI, m *columnMapper, symbols []source.Symbol, container string) []protocol.SymbolInformation {
+	var result []protocol.SymbolInformation
+	for _, s := range symbols {
+		result = append(result, protocol.SymbolInformation{
+			Name:          s.Name,
+			Kind:          float64(toProtocolSymbolKind(s.Kind)),
+			Location:      protocol.Location{URI: uri, Range: toProtocolRange(m, s.Span)},
+			ContainerName: container,
+		})
+		result = append(result, toProtocolFlatSymbols(uri, m, s.Children, s.Name)...)
+	}
+	return result
+}
+
+func toProtocolSymbolInformation(v *source.View, symbols []source.WorkspaceSymbol) []protocol.SymbolInformation {
+	result := make([]protocol.SymbolInformation, 0, len(symbols))
+	for _, s := range symbols {
+		result = append(result, protocol.SymbolInformation{
+			Name:          s.Name,
+			Kind:          float64(toProtocolSymbolKind(s.Kind)),
+			Location:      toProtocolLocation(v, s.Range),
+			ContainerName: s.Container,
+		})
+	}
+	return result
+}
+
+func toProtocolSymbolKind(kind source.SymbolKind) protocol.SymbolKind {
+	switch kind {
+	case source.StructSymbol:
+		return protocol.StructSymbol
+	case source.PackageSymbol:
+		return protocol.PackageSymbol
+	case source.VariableSymbol:
+		return protocol.VariableSymbol
+	case source.ConstantSymbol:
+		return protocol.ConstantSymbol
+	case source.FunctionSymbol:
+		return protocol.FunctionSymbol
+	case source.MethodSymbol:
+		return protocol.MethodSymbol
+	case source.InterfaceSymbol:
+		return protocol.InterfaceSymbol
+	case source.NumberSymbol:
+		return protocol.NumberSymbol
+	case source.StringSymbol:
+		return protocol.StringSymbol
+	case source.BooleanSymbol:
+		return protocol.BooleanSymbol
+	case source.FieldSymbol:
+		return protocol.FieldSymbol
+	default:
+		return protocol.ClassSymbol // a named type without a more specific kind
+	}
+}
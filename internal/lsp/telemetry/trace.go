@@ -0,0 +1,164 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package telemetry records trace spans of the operations of the language
+// server, in the manner of OpenCensus, so that slow operations can be
+// diagnosed. The spans are passed to the registered exporters as they end.
+package telemetry
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// A Span is a timed operation. The spans that start while a span is in the
+// context of an operation are its children, and belong to the same trace.
+type Span struct {
+	TraceID    string                 `json:"traceId"`
+	SpanID     string                 `json:"spanId"`
+	ParentID   string                 `json:"parentSpanId,omitempty"`
+	Name       string                 `json:"name"`
+	Start      time.Time              `json:"startTime"`
+	End        time.Time              `json:"endTime"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+
+	mu    sync.Mutex
+	ended bool
+}
+
+// An Exporter receives the spans that end.
+// Its ExportSpan method may be called from several goroutines at a time.
+type Exporter interface {
+	ExportSpan(*Span)
+}
+
+var (
+	exportersMu sync.Mutex
+	exporters   []Exporter
+
+	idsMu sync.Mutex
+	ids   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+type spanKey struct{}
+
+// RegisterExporter adds an exporter of the spans that end.
+func RegisterExporter(e Exporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	exporters = append(exporters, e)
+}
+
+// UnregisterExporter removes an exporter added by RegisterExporter.
+func UnregisterExporter(e Exporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	for i, x := range exporters {
+		if x == e {
+			exporters = append(exporters[:i:i], exporters[i+1:]...)
+			return
+		}
+	}
+}
+
+// StartSpan starts a span with the given name, whose parent is the span of
+// ctx, if any, and returns a context that holds the new span. The span must
+// be ended.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	span := &Span{
+		SpanID: newID(8),
+		Name:   name,
+		Start:  time.Now(),
+	}
+	if parent := FromContext(ctx); parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	} else {
+		span.TraceID = newID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext returns the span of ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SetAttribute sets an attribute of the span, such as the number of results
+// of the operation.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{})
+	}
+	s.Attributes[key] = value
+}
+
+// SetError records that the operation failed, if err is not nil.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Error = err.Error()
+}
+
+// Finish ends the span and exports it. Only its first call has an effect.
+func (s *Span) Finish() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mu.Unlock()
+	exportersMu.Lock()
+	es := exporters
+	exportersMu.Unlock()
+	for _, e := range es {
+		e.ExportSpan(s)
+	}
+}
+
+// Duration returns the duration of the span, which must be ended.
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	idsMu.Lock()
+	ids.Read(b)
+	idsMu.Unlock()
+	return hex.EncodeToString(b)
+}
+
+// NewJSONExporter returns an exporter that writes each span to w as a line
+// of JSON.
+func NewJSONExporter(w io.Writer) Exporter {
+	return &jsonExporter{enc: json.NewEncoder(w)}
+}
+
+type jsonExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (e *jsonExporter) ExportSpan(s *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.enc.Encode(s)
+}
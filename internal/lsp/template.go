@@ -0,0 +1,121 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/template"
+)
+
+// templateFile returns the file of uri and its content, if it is a template
+// file and the view of uri supports them.
+func (s *server) templateFile(uri protocol.DocumentURI) (*source.View, string, []byte, bool) {
+	filename, err := source.URI(uri).Filename()
+	if err != nil || !template.IsTemplate(filename) {
+		return nil, "", nil, false
+	}
+	v := s.viewFor(uri)
+	if !v.Options().Templates {
+		return nil, "", nil, false
+	}
+	content, err := v.GetFile(source.URI(uri)).Read()
+	if err != nil {
+		return nil, "", nil, false
+	}
+	return v, filename, content, true
+}
+
+// templateRead returns the function that reads the template files of v.
+func templateRead(v *source.View) template.ReadFunc {
+	return func(filename string) ([]byte, error) {
+		return v.GetFile(source.ToURI(filename)).Read()
+	}
+}
+
+// templateDiagnostics publishes the syntax errors of the template file of
+// uri, whose content is content.
+func (s *server) templateDiagnostics(ctx context.Context, uri protocol.DocumentURI, filename string, content []byte) {
+	diagnostics := []protocol.Diagnostic{}
+	for _, diag := range template.Diagnostics(filename, content) {
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    toProtocolSpan(content, diag.Span),
+			Severity: protocol.SeverityError,
+			Source:   "template",
+			Message:  diag.Message,
+		})
+	}
+	s.client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+// templateDefinition returns the locations of the declarations of the
+// template whose name is at pos in the template file named filename.
+func templateDefinition(v *source.View, filename string, content []byte, pos protocol.Position) ([]protocol.Location, error) {
+	offset, err := contentOffset(content, pos)
+	if err != nil {
+		return nil, err
+	}
+	read := templateRead(v)
+	spans, err := template.Definition(filename, content, offset, read)
+	if err != nil {
+		return nil, err
+	}
+	locations := make([]protocol.Location, 0, len(spans))
+	for _, span := range spans {
+		src := content
+		if span.Filename != filename {
+			if src, err = read(span.Filename); err != nil {
+				return nil, err
+			}
+		}
+		locations = append(locations, protocol.Location{
+			URI:   protocol.DocumentURI(source.ToURI(span.Filename)),
+			Range: toProtocolSpan(src, span),
+		})
+	}
+	return locations, nil
+}
+
+// templateCompletion returns the completion of the name of the template at
+// pos in the template file named filename.
+func templateCompletion(v *source.View, filename string, content []byte, pos protocol.Position) (*protocol.CompletionList, error) {
+	offset, err := contentOffset(content, pos)
+	if err != nil {
+		return nil, err
+	}
+	names, span, ok := template.Completion(filename, content, offset, templateRead(v))
+	if !ok {
+		return nil, fmt.Errorf("no template name at the position")
+	}
+	items := []protocol.CompletionItem{}
+	for _, name := range names {
+		quoted := strconv.Quote(name)
+		items = append(items, protocol.CompletionItem{
+			Label:      name,
+			Kind:       float64(protocol.ReferenceCompletion),
+			FilterText: quoted,
+			TextEdit: &protocol.TextEdit{
+				Range:   toProtocolSpan(content, span),
+				NewText: quoted,
+			},
+		})
+	}
+	return &protocol.CompletionList{Items: items}, nil
+}
+
+// toProtocolSpan converts a span of content to a protocol range.
+func toProtocolSpan(content []byte, span template.Span) protocol.Range {
+	return protocol.Range{
+		Start: offsetPosition(content, span.Start),
+		End:   offsetPosition(content, span.End),
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package template implements the features of the language server for the
+// files of text/template and html/template templates: the diagnostics of
+// their syntax, the definitions of the templates that they execute, and the
+// completion of the names of the templates that they may execute.
+// The templates of a file may execute those of the other template files of
+// its directory, with which they are usually parsed.
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+// IsTemplate reports whether filename names a template file, whose
+// extension is .tmpl or .gotmpl.
+func IsTemplate(filename string) bool {
+	switch filepath.Ext(filename) {
+	case ".tmpl", ".gotmpl":
+		return true
+	}
+	return false
+}
+
+// A Span is a range of the content of a file, in bytes.
+type Span struct {
+	Filename   string
+	Start, End int
+}
+
+// A Diagnostic is a syntax error of a template file, on the line of its
+// span.
+type Diagnostic struct {
+	Span    Span
+	Message string
+}
+
+// Diagnostics returns the syntax errors of content, the content of the
+// template file named filename. The parsing stops at the first one.
+// The functions that the templates call are not checked, as the program
+// that parses them defines them.
+func Diagnostics(filename string, content []byte) []Diagnostic {
+	t := parse.New(filename)
+	t.Mode = parse.SkipFuncCheck
+	_, err := t.Parse(string(content), "", "", make(map[string]*parse.Tree))
+	if err == nil {
+		return nil
+	}
+	// The error is "template: filename:line: message".
+	msg := strings.TrimPrefix(err.Error(), "template: "+filename+":")
+	line := 1
+	if i := strings.Index(msg, ": "); i > 0 {
+		if n, err := strconv.Atoi(msg[:i]); err == nil {
+			line, msg = n, msg[i+2:]
+		}
+	}
+	start, end := lineSpan(content, line)
+	return []Diagnostic{{
+		Span:    Span{Filename: filename, Start: start, End: end},
+		Message: msg,
+	}}
+}
+
+// lineSpan returns the offsets of the start and the end of the line of
+// content with the given number, or those of the last line if there are
+// fewer lines.
+func lineSpan(content []byte, line int) (int, int) {
+	start := 0
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(content[start:], '\n')
+		if i < 0 {
+			break
+		}
+		start += i + 1
+	}
+	end := bytes.IndexByte(content[start:], '\n')
+	if end < 0 {
+		return start, len(content)
+	}
+	return start, start + end
+}
+
+// actionPattern matches the actions that declare a template, {{define}} and
+// {{block}}, and those that execute one, {{template}}, with the keyword of
+// the action in group 1 and the name of the template, quoted, in group 2.
+// The quote of the name may be unterminated, as it is while it is typed.
+var actionPattern = regexp.MustCompile(`\{\{-?\s*(define|block|template)\s+("(?:[^"\\\n]|\\.)*"?|` + "`[^`]*`?)")
+
+// An action is an action that declares or executes a template, with the
+// span of the quoted name of the template.
+type action struct {
+	keyword, name string
+	start, end    int
+	terminated    bool // the quote of the name is terminated
+}
+
+// declares reports whether the action declares its template.
+func (a action) declares() bool {
+	return a.keyword != "template"
+}
+
+// actions returns the actions of content that declare or execute a
+// template.
+func actions(content []byte) []action {
+	var actions []action
+	for _, m := range actionPattern.FindAllSubmatchIndex(content, -1) {
+		quoted := string(content[m[4]:m[5]])
+		name, err := strconv.Unquote(quoted)
+		terminated := err == nil
+		if !terminated {
+			name = quoted[1:]
+		}
+		actions = append(actions, action{
+			keyword:    string(content[m[2]:m[3]]),
+			name:       name,
+			start:      m[4],
+			end:        m[5],
+			terminated: terminated,
+		})
+	}
+	return actions
+}
+
+// actionAt returns the action of content whose name contains offset.
+func actionAt(content []byte, offset int) (action, bool) {
+	for _, a := range actions(content) {
+		if a.start < offset && offset <= a.end {
+			return a, true
+		}
+	}
+	return action{}, false
+}
+
+// A ReadFunc returns the content of the file named filename, as it is open
+// in the editor or on disk.
+type ReadFunc func(filename string) ([]byte, error)
+
+// templateFiles returns the names of the template files of the directory of
+// filename, including filename.
+func templateFiles(filename string) []string {
+	filenames := []string{filename}
+	infos, err := ioutil.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		return filenames
+	}
+	for _, info := range infos {
+		path := filepath.Join(filepath.Dir(filename), info.Name())
+		if !info.IsDir() && IsTemplate(path) && path != filename {
+			filenames = append(filenames, path)
+		}
+	}
+	return filenames
+}
+
+// declarations returns the spans of the names of the actions that declare
+// the templates of the template files of the directory of filename, by the
+// names of the templates. The content of filename is content.
+func declarations(filename string, content []byte, read ReadFunc) map[string][]Span {
+	decls := make(map[string][]Span)
+	for _, name := range templateFiles(filename) {
+		src := content
+		if name != filename {
+			var err error
+			if src, err = read(name); err != nil {
+				continue
+			}
+		}
+		for _, a := range actions(src) {
+			if a.declares() {
+				decls[a.name] = append(decls[a.name], Span{Filename: name, Start: a.start, End: a.end})
+			}
+		}
+	}
+	return decls
+}
+
+// Definition returns the spans of the names of the {{define}} and {{block}}
+// actions of the template files of the directory of filename that declare
+// the template whose name is at offset in content, the content of filename,
+// in an action that declares or executes it.
+func Definition(filename string, content []byte, offset int, read ReadFunc) ([]Span, error) {
+	a, ok := actionAt(content, offset)
+	if !ok {
+		return nil, fmt.Errorf("no template name at the position")
+	}
+	spans := declarations(filename, content, read)[a.name]
+	if len(spans) == 0 {
+		return nil, fmt.Errorf("no declaration of template %q found", a.name)
+	}
+	return spans, nil
+}
+
+// Completion returns the names of the templates that the template files of
+// the directory of filename declare, that complete the name of the
+// {{template}} action at offset in content, the content of filename, and
+// the span of the name that they replace, quoted. It reports whether offset
+// is in the name of such an action.
+func Completion(filename string, content []byte, offset int, read ReadFunc) ([]string, Span, bool) {
+	a, ok := actionAt(content, offset)
+	if !ok || a.declares() || a.terminated && offset == a.end {
+		return nil, Span{}, false
+	}
+	prefix := string(content[a.start+1 : offset])
+	var names []string
+	for name := range declarations(filename, content, read) {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, Span{Filename: filename, Start: a.start, End: a.end}, true
+}
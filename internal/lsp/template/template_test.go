@@ -0,0 +1,84 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	for _, test := range []struct {
+		content, line, msg string // the line of the error, if any
+	}{
+		{`{{define "a"}}{{.X}}{{end}}`, "", ""},
+		{`{{len .X | printf "%d"}}`, "", ""},
+		{"a\n{{if .X}}\nb", "b", "unexpected EOF"},
+		{"{{.X}\n", "{{.X}", "bad character"},
+		{"{{end}}", "{{end}}", "unexpected {{end}}"},
+	} {
+		diags := Diagnostics("a.tmpl", []byte(test.content))
+		if test.msg == "" {
+			if len(diags) > 0 {
+				t.Errorf("Diagnostics(%q) = %v, want none", test.content, diags)
+			}
+			continue
+		}
+		if len(diags) != 1 {
+			t.Errorf("Diagnostics(%q) = %v, want one", test.content, diags)
+			continue
+		}
+		d := diags[0]
+		if line := test.content[d.Span.Start:d.Span.End]; line != test.line || !strings.Contains(d.Message, test.msg) {
+			t.Errorf("Diagnostics(%q) = %q at %q, want %q at %q", test.content, d.Message, line, test.msg, test.line)
+		}
+	}
+}
+
+func TestDefinitionAndCompletion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	layout := filepath.Join(dir, "layout.tmpl")
+	page := filepath.Join(dir, "page.gotmpl")
+	files := map[string]string{
+		layout: `{{define "header"}}<h1>{{.}}</h1>{{end}}{{block "footer" .}}{{end}}`,
+		page:   `{{template "header" .Title}}{{template "fo`,
+	}
+	for filename, content := range files {
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(filename string) ([]byte, error) { return []byte(files[filename]), nil }
+	content := []byte(files[page])
+
+	spans, err := Definition(page, content, strings.Index(files[page], "header")+2, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := strings.Index(files[layout], `"header"`)
+	if want := []Span{{layout, start, start + len(`"header"`)}}; !reflect.DeepEqual(spans, want) {
+		t.Errorf("Definition = %v, want %v", spans, want)
+	}
+
+	names, span, ok := Completion(page, content, len(content), read)
+	if want := []string{"footer"}; !ok || !reflect.DeepEqual(names, want) {
+		t.Errorf("Completion = %v, %v, want %v", names, ok, want)
+	}
+	if got := files[page][span.Start:span.End]; got != `"fo` {
+		t.Errorf("Completion replaces %q, want %q", got, `"fo`)
+	}
+	names, _, _ = Completion(page, content, strings.Index(files[page], `"header"`)+1, read)
+	if want := []string{"footer", "header"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Completion of the empty prefix = %v, want %v", names, want)
+	}
+}
@@ -5,7 +5,7 @@ package bad
 func stuff() { //@item(stuff, "stuff()", "", "func")
 	x := "heeeeyyyy"
 	random2(x) //@diag("x", "cannot use x (variable of type string) as int value in argument to random2")
-	random2(1) //@complete("dom", random, random2, random3)
+	random2(1) //@complete("dom", random2, random, random3)
 	y := 3     //@diag("y", "y declared but not used")
 }
 
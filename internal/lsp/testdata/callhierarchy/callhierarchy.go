@@ -0,0 +1,25 @@
+package callhierarchy
+
+type T struct{}
+
+func (T) M() {} //@mark(declM, "M")
+
+func A() { //@mark(declA, "A"),incoming("A", declB, declC),outgoing("A", declM, declD)
+	var t T
+	t.M()
+	D()
+	func() {
+		D()
+	}()
+}
+
+func B() { //@mark(declB, "B")
+	A()
+}
+
+func C(err error) string { //@mark(declC, "C"),outgoing("C", declA)
+	A()
+	return err.Error()
+}
+
+func D() {} //@mark(declD, "D"),incoming("D", declA)
@@ -0,0 +1,5 @@
+package extract
+
+func _(x int) int {
+	return x * (60 * 60) //@refactor("60 * 60", "60 * 60", "Extract to constant")
+}
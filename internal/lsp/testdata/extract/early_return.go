@@ -0,0 +1,12 @@
+package extract
+
+import "errors"
+
+func _(ok bool) (int, error) {
+	n := 0
+	if !ok { //@mark(exReturnStart, "if")
+		return 0, errors.New("not ok")
+	}
+	n++ //@mark(exReturnEnd, "n++"),refactor(exReturnStart, exReturnEnd, "Extract to function")
+	return n, nil
+}
@@ -0,0 +1,21 @@
+package extract
+
+func _(x int) int {
+	y := x + 1 //@norefactor("x + 1", "x + 1", "Extract to function")
+	for {
+		if y > 10 { //@mark(exBreakStart, "if")
+			break
+		} //@mark(exBreakEnd, "}"),norefactor(exBreakStart, exBreakEnd, "Extract to function")
+		y++
+	}
+	p := &y //@norefactor("p := &y", "p := &y", "Extract to function")
+	return *p
+}
+
+func _() (n int) {
+	n = 1
+	if n > 0 { //@mark(exNamedStart, "if")
+		return
+	} //@mark(exNamedEnd, "}"),norefactor(exNamedStart, exNamedEnd, "Extract to function")
+	return 2
+}
@@ -0,0 +1,9 @@
+package extract
+
+import "fmt"
+
+func _() {
+	a, b := 1, 2
+	sum := a + b     //@mark(exParamsStart, "sum")
+	fmt.Println(sum) //@mark(exParamsEnd, "fmt.Println(sum)"),refactor(exParamsStart, exParamsEnd, "Extract to function")
+}
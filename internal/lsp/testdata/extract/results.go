@@ -0,0 +1,8 @@
+package extract
+
+func _() int {
+	x := 1
+	y := x * 2 //@mark(exResultsStart, "y")
+	x++        //@mark(exResultsEnd, "x++"),refactor(exResultsStart, exResultsEnd, "Extract to function")
+	return x + y
+}
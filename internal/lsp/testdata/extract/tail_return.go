@@ -0,0 +1,6 @@
+package extract
+
+func _(x int) int {
+	y := x + 1   //@mark(exTailStart, "y")
+	return y * 2 //@mark(exTailEnd, "return y * 2"),refactor(exTailStart, exTailEnd, "Extract to function")
+}
@@ -0,0 +1,8 @@
+package extract
+
+func _(a, b int) int {
+	if a > 0 {
+		return a*b + 1 //@refactor("a*b", "a*b", "Extract to variable")
+	}
+	return 0
+}
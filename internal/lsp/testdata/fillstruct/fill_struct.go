@@ -0,0 +1,11 @@
+package fillstruct
+
+type config struct {
+	Name    string
+	Retries int
+	Verbose bool
+	Tags    []string
+	Limits  struct{ Max int }
+}
+
+var _ = config{Name: "a"} //@refactor("Name", "Name", "Fill config")
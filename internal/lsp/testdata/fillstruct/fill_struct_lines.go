@@ -0,0 +1,5 @@
+package fillstruct
+
+var _ = &config{
+	Retries: 1, //@refactor("Retries", "Retries", "Fill config")
+}
@@ -19,5 +19,9 @@ func _() {
 	}
 }
 
-//@complete("", Foo, IntFoo, StructFoo)
+//@complete("", StructFoo, Foo, IntFoo)
 type IntFoo int //@item(IntFoo, "IntFoo", "int", "type")
+
+func _() {
+	_ = SFo //@complete("o", StructFoo)
+}
@@ -20,4 +20,8 @@ func Bar() {
 	_ = x.F1    //@godef("F1", S1F1)
 	_ = x.F2    //@godef("F2", S2F2)
 	_ = x.S2.F1 //@godef("F1", S2F1)
+
+	y := &x        //@typdef("y", S1)
+	var z a.A      //@typdef("z", A)
+	_, _ = y.S2, z //@typdef("S2", S2)
 }
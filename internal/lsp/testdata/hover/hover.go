@@ -0,0 +1,20 @@
+package hover
+
+// Answer is the answer.
+const Answer = 42 //@hover("Answer", "const Answer untyped int = 42")
+
+// Pair is a pair of ints.
+type Pair struct {
+	X, Y int //@hover("Y", "field Y int")
+}
+
+// Sum returns the sum of the ints of p.
+func (p Pair) Sum() int { //@hover("Sum", "func (Pair).Sum() int")
+	return p.X + p.Y //@hover("p", "var p Pair")
+}
+
+func _() {
+	var p Pair       //@hover("Pair", "type Pair struct{X int; Y int}")
+	_ = p.Sum()      //@hover("Sum", "func (Pair).Sum() int")
+	_ = Answer + p.X //@hover("X", "field X int")
+}
@@ -0,0 +1,10 @@
+package imports //@imports("package")
+
+import (
+	"os"
+	"fmt"
+)
+
+func _() {
+	fmt.Println(strings.ToUpper("imports"))
+}
@@ -0,0 +1,13 @@
+package maplit //@item(maplit, "maplit", "", "package"), complete("t ", maplit)
+
+type Weekday int
+
+const (
+	Monday    Weekday = iota /* Monday = 0 */ //@item(Monday, "Monday = 0", "Weekday", "const")
+	Tuesday                  /* Tuesday = 1 */ //@item(Tuesday, "Tuesday = 1", "Weekday", "const")
+	Wednesday                /* Wednesday = 2 */ //@item(Wednesday, "Wednesday = 2", "Weekday", "const")
+)
+
+func _() {
+	_ = map[Weekday]bool{Tuesday: true, } //@complete("}", Monday, Wednesday)
+}
@@ -0,0 +1,3 @@
+package references
+
+var _ = T(x) //@mark(convT, "T"),mark(otherX, "x"),refs("T", typeT, varT, resultT, convT)
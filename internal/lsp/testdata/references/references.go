@@ -0,0 +1,10 @@
+package references
+
+type T int //@mark(typeT, "T"),refs("T", typeT, varT, resultT, convT)
+
+var x T //@mark(varT, "T"),mark(declX, "x"),refs("x", declX, useX, returnX, otherX)
+
+func F() T { //@mark(resultT, "T")
+	x++      //@mark(useX, "x")
+	return x //@mark(returnX, "x"),refs("x", declX, useX, returnX, otherX)
+}
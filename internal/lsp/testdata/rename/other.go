@@ -0,0 +1,5 @@
+package rename
+
+func (t T) Y() int { //@mark(recvPair, "T")
+	return 0
+}
@@ -0,0 +1,10 @@
+package rename
+
+type T struct { //@mark(declPair, "T"),rename("T", "Pair", declPair, litPair, recvPair)
+	X int //@mark(declFirst, "X"),rename("X", "First", declFirst, useFirst)
+}
+
+func F() int {
+	t := T{X: 1} //@mark(litPair, "T"),mark(useFirst, "X")
+	return t.Y()
+}
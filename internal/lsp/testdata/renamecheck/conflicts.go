@@ -0,0 +1,17 @@
+package renamecheck
+
+var global = 1 //@renameerr("global", "other", "would conflict with the declaration")
+
+var other = 2
+
+var Exported = global + other
+
+func shadowed() int {
+	n := 1
+	return global + n //@renameerr("global", "n", "would make this reference refer to the declaration")
+}
+
+func shadowing() int {
+	local := 1 //@renameerr("local", "global", "would shadow this reference to the declaration")
+	return local + global
+}
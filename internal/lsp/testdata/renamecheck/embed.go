@@ -0,0 +1,11 @@
+package renamecheck
+
+type Inner struct{} //@mark(declInner, "Inner")
+
+type Outer struct {
+	Inner //@mark(fieldInner, "Inner"),rename("Inner", "Core", declInner, fieldInner, selInner)
+}
+
+func inner(o Outer) {
+	_ = o.Inner //@mark(selInner, "Inner")
+}
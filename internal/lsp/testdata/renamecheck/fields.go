@@ -0,0 +1,22 @@
+package renamecheck
+
+type A struct{ X int }
+
+type B struct{ Y int } //@renameerr("Y", "X", "would make this selection ambiguous")
+
+type AB struct {
+	A
+	B
+}
+
+func selectX(ab AB) int {
+	return ab.X
+}
+
+type Point struct {
+	X int //@renameerr("X", "Len", "would conflict with the method")
+}
+
+func (p Point) Len() int {
+	return p.X
+}
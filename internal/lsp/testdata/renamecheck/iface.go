@@ -0,0 +1,21 @@
+package renamecheck
+
+type Shape interface {
+	Area() int //@mark(ifaceArea, "Area"),rename("Area", "Size", ifaceArea, squareArea, circleArea, callArea)
+}
+
+type square struct{}
+
+func (square) Area() int { return 1 } //@mark(squareArea, "Area"),rename("Area", "Size", ifaceArea, squareArea, circleArea, callArea)
+
+type circle struct{}
+
+func (*circle) Area() int { return 3 } //@mark(circleArea, "Area")
+
+func total(shapes ...Shape) int {
+	t := 0
+	for _, s := range shapes {
+		t += s.Area() //@mark(callArea, "Area")
+	}
+	return t
+}
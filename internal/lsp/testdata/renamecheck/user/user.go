@@ -0,0 +1,5 @@
+package user
+
+import "golang.org/x/tools/internal/lsp/renamecheck"
+
+var _ = renamecheck.Exported //@renameerr("Exported", "exported", "would unexport it")
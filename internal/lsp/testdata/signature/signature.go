@@ -0,0 +1,32 @@
+package signature
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Foo reports whether a is longer than b.
+func Foo(a string, b int) (c bool) {
+	return len(a) > b
+}
+
+func Bar(float64, ...byte) {
+}
+
+func Qux() {
+	Foo("foo", 123) //@signature("\"", "Foo(a string, b int) (c bool)", 0)
+	Foo("foo", 123) //@signature("123", "Foo(a string, b int) (c bool)", 1)
+	Foo("foo", 123) //@signature(")", "Foo(a string, b int) (c bool)", 1)
+
+	Bar(13.37, 0x1337)     //@signature("13.37", "Bar(float64, ...byte)", 0)
+	Bar(13.37, 0x13, 0x37) //@signature("0x37", "Bar(float64, ...byte)", 1)
+
+	fn := func(hi, there string) func(i int) rune {
+		return func(int) rune { return 0 }
+	}
+	fn("hi", "there") //@signature("there", "fn(hi string, there string) func(i int) rune", 1)
+
+	var buf bytes.Buffer
+	buf.Next(2)                  //@signature("2", "Next(n int) []byte", 0)
+	fmt.Println(Foo("foo", 123)) //@signature("123", "Foo(a string, b int) (c bool)", 1)
+}
@@ -0,0 +1,8 @@
+package structtags
+
+type user struct {
+	UserID    int
+	HTTPProxy string `yaml:"proxy"`
+	Name      string `json:"full_name"`
+	password  string
+} //@refactor("}", "}", "Add json tags")
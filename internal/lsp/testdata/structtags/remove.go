@@ -0,0 +1,6 @@
+package structtags
+
+type account struct {
+	ID      int    `json:"id" yaml:"id"`
+	Balance string `json:"balance"`
+} //@refactor("}", "}", "Remove struct tags")
@@ -0,0 +1,14 @@
+package stub
+
+type shape interface {
+	Area() float64
+	Scale(factor float64)
+}
+
+type square struct{ side float64 }
+
+func (sq *square) Area() float64 { return sq.side * sq.side }
+
+func newShape() shape {
+	return &square{} //@suggestedfix("&square{}")
+}
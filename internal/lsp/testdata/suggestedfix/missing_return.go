@@ -0,0 +1,7 @@
+package suggestedfix
+
+func f(ok bool) (int, error) {
+	if ok {
+		return 1, nil
+	}
+} //@suggestedfix("}")
@@ -0,0 +1,7 @@
+package suggestedfix
+
+import "io"
+
+type reader struct{}
+
+var _ io.Reader = reader{} //@suggestedfix("reader{}")
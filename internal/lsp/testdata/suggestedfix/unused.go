@@ -0,0 +1,5 @@
+package suggestedfix
+
+func _() {
+	x := 1 //@suggestedfix("x")
+}
@@ -0,0 +1,8 @@
+package suggestedfix
+
+import (
+	"fmt" //@suggestedfix("\"fmt\"")
+	"strings"
+)
+
+var _ = strings.ToUpper
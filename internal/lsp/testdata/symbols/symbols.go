@@ -0,0 +1,17 @@
+package symbols
+
+const C = 1 //@symbol("C", "C", "constant")
+
+var V = 2 //@symbol("V", "V", "variable")
+
+type S struct { //@symbol("S", "S", "struct")
+	F int //@symbol("F", "F", "field")
+}
+
+func (s S) M() {} //@symbol("M", "M", "method")
+
+type I interface { //@symbol("I", "I", "interface")
+	Do() //@symbol("Do", "Do", "method")
+}
+
+func Fn() {} //@symbol("Fn", "Fn", "function")
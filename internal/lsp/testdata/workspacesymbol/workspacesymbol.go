@@ -0,0 +1,11 @@
+package workspacesymbol
+
+type Quokka struct{} //@mark(typeQuokka, "Quokka"),workspacesymbol("Quokka", typeQuokka, varCount, funcNew, methodHop)
+
+var quokkaCount int //@mark(varCount, "quokkaCount")
+
+func NewQuokka() Quokka { return Quokka{} } //@mark(funcNew, "NewQuokka")
+
+func (Quokka) QuokkaHop() {} //@mark(methodHop, "QuokkaHop")
+
+func (Quokka) Hop() {}
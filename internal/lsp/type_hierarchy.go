@@ -0,0 +1,44 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"go/token"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// typeHierarchyItemPos returns the file and position of the name of the
+// type of an item that was sent back by the client.
+func typeHierarchyItemPos(ctx context.Context, v *source.View, item protocol.TypeHierarchyItem) (*source.File, token.Pos, error) {
+	f := v.GetFile(source.URI(item.URI))
+	m, err := newColumnMapper(ctx, f)
+	if err != nil {
+		return nil, token.NoPos, err
+	}
+	return f, fromProtocolPosition(m, item.SelectionRange.Start), nil
+}
+
+func toProtocolTypeHierarchyItem(v *source.View, item source.TypeHierarchyItem) protocol.TypeHierarchyItem {
+	m := fileColumnMapper(v, item.SelectionRange.Start)
+	return protocol.TypeHierarchyItem{
+		Name:           item.Name,
+		Kind:           toProtocolSymbolKind(item.Kind),
+		Detail:         item.Detail,
+		URI:            protocol.DocumentURI(source.ToURI(m.filename)),
+		Range:          toProtocolRange(m, item.Range),
+		SelectionRange: toProtocolRange(m, item.SelectionRange),
+	}
+}
+
+func toProtocolTypeHierarchyItems(v *source.View, items []source.TypeHierarchyItem) []protocol.TypeHierarchyItem {
+	result := make([]protocol.TypeHierarchyItem, 0, len(items))
+	for _, item := range items {
+		result = append(result, toProtocolTypeHierarchyItem(v, item))
+	}
+	return result
+}
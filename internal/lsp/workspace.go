@@ -0,0 +1,159 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// addFolder adds a view, with a configuration of its own, for the files of
+// the workspace folder with the given URI.
+func (s *server) addFolder(uri string) error {
+	dir, err := source.URI(uri).Filename()
+	if err != nil {
+		return err
+	}
+	v := s.session.NewView()
+	v.Config.Dir = dir
+	// The folder has the options of the server until its own are fetched.
+	v.SetOptions(s.view.Options())
+	s.viewsMu.Lock()
+	defer s.viewsMu.Unlock()
+	if s.folders == nil {
+		s.folders = make(map[string]*source.View)
+	}
+	s.folders[filepath.Clean(dir)] = v
+	return nil
+}
+
+// removeFolder removes the view of the workspace folder with the given URI.
+func (s *server) removeFolder(uri string) error {
+	dir, err := source.URI(uri).Filename()
+	if err != nil {
+		return err
+	}
+	s.viewsMu.Lock()
+	defer s.viewsMu.Unlock()
+	if v, ok := s.folders[filepath.Clean(dir)]; ok {
+		s.session.RemoveView(v)
+		delete(s.folders, filepath.Clean(dir))
+	}
+	return nil
+}
+
+// viewFor returns the view of the innermost workspace folder that contains
+// the document with the given URI, or the default view of the server if no
+// folder contains it.
+func (s *server) viewFor(uri protocol.DocumentURI) *source.View {
+	filename, err := source.URI(uri).Filename()
+	if err != nil {
+		return s.view
+	}
+	s.viewsMu.Lock()
+	defer s.viewsMu.Unlock()
+	v, longest := s.view, ""
+	for dir, folder := range s.folders {
+		if len(dir) > len(longest) && inDir(dir, filename) {
+			v, longest = folder, dir
+		}
+	}
+	return v
+}
+
+// views returns all the views of the server: those of the workspace folders
+// and the default one.
+func (s *server) views() []*source.View {
+	s.viewsMu.Lock()
+	defer s.viewsMu.Unlock()
+	views := []*source.View{s.view}
+	for _, v := range s.folders {
+		views = append(views, v)
+	}
+	return views
+}
+
+// loadFolders loads the packages of the workspace folders of the given
+// views, reporting the progress to the client. The loading of a folder may
+// be cancelled by the user.
+func (s *server) loadFolders(ctx context.Context, views []*source.View) error {
+	for _, v := range views {
+		if v == s.view {
+			continue
+		}
+		ctx, p := s.startProgress(ctx, "Loading "+filepath.Base(v.Config.Dir), true)
+		percentage := 0
+		err := v.LoadWorkspace(ctx, func(checked, total int) {
+			// Report whole percentages only, not each package.
+			if pc := checked * 100 / total; pc > percentage {
+				percentage = pc
+				p.report(fmt.Sprintf("%d/%d packages", checked, total), float64(pc))
+			}
+		})
+		switch {
+		case ctx.Err() != nil:
+			p.end("cancelled") // by the user
+		case err != nil:
+			p.end(err.Error())
+			return err
+		default:
+			p.end("done")
+		}
+	}
+	return nil
+}
+
+// inDir reports whether filename is in the directory dir or one of its
+// subdirectories.
+func inDir(dir, filename string) bool {
+	rel, err := filepath.Rel(dir, filename)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// watchFiles asks the client to notify the server of the changes of the Go
+// and go.mod files of the workspace, if it supports it.
+func (s *server) watchFiles(ctx context.Context) error {
+	if !s.clientOptions.watchedFiles {
+		return nil
+	}
+	return s.client.RegisterCapability(ctx, &protocol.RegistrationParams{
+		Registrations: []protocol.Registration{{
+			ID:     "workspace/didChangeWatchedFiles",
+			Method: "workspace/didChangeWatchedFiles",
+			RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+				Watchers: []protocol.FileSystemWatcher{
+					{GlobPattern: "**/*.go"},
+					{GlobPattern: "**/go.mod"},
+				},
+			},
+		}},
+	})
+}
+
+// filesChanged discards the state of the views that depends on the files on
+// disk that changed, and publishes the diagnostics of the open documents
+// whose packages were discarded again.
+func (s *server) filesChanged(ctx context.Context, changes []protocol.FileEvent) error {
+	affected := make(map[source.URI]bool)
+	for _, change := range changes {
+		deleted := protocol.FileChangeType(change.Type) == protocol.Deleted
+		for _, v := range s.views() {
+			for _, uri := range v.FileChanged(source.URI(change.URI), deleted) {
+				affected[uri] = true
+			}
+		}
+	}
+	for _, uri := range s.session.OpenFiles() {
+		if affected[uri] {
+			s.scheduleDiagnostics(ctx, protocol.DocumentURI(uri), 0)
+		}
+	}
+	return nil
+}